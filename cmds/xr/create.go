@@ -31,7 +31,7 @@ Notes:
 `
 
 	createCmd.Flags().StringP("output", "o", "none",
-		"Output format (none, json) when xReg metadata")
+		"Output format (none, json, yaml) when xReg metadata")
 	createCmd.Flags().BoolP("details", "m", false, "Data is resource metadata")
 	createCmd.Flags().StringP("data", "d", "",
 		"Data, @FILE, @URL, @-(stdin)")
@@ -67,7 +67,7 @@ Notes:
 `
 
 	upsertCmd.Flags().StringP("output", "o",
-		"none", "Output format (none, json) when xReg metadata")
+		"none", "Output format (none, json, yaml) when xReg metadata")
 	upsertCmd.Flags().BoolP("details", "m", false, "Data is resource metadata")
 	upsertCmd.Flags().StringP("data", "d", "",
 		"Data, @FILE, @URL, @-(stdin)")
@@ -99,7 +99,7 @@ Notes:
 `
 
 	updateCmd.Flags().StringP("output", "o", "none",
-		"Output format (none, json) when xReg metadata")
+		"Output format (none, json, yaml) when xReg metadata")
 	updateCmd.Flags().BoolP("details", "m", false, "Data is resource metadata")
 	updateCmd.Flags().StringP("data", "d", "",
 		"Data, @FILE, @URL, @-(stdin)")
@@ -177,7 +177,9 @@ func createFunc(cmd *cobra.Command, args []string) {
 		}
 
 		if len(data) > 0 {
-			Error(json.Unmarshal([]byte(data), &dataMap))
+			buf, err := xrlib.DataToJSON([]byte(data))
+			Error(err)
+			Error(json.Unmarshal(buf, &dataMap))
 		}
 
 		setsIndex := len(dels)
@@ -285,6 +287,13 @@ func createFunc(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	// xReg metadata may be in YAML, but the server wants JSON
+	if !isDomainDoc {
+		buf, err := xrlib.DataToJSON([]byte(data))
+		Error(err)
+		data = string(buf)
+	}
+
 	if len(data) == 0 {
 		if xid.Type == ENTITY_REGISTRY || xid.Type == ENTITY_GROUP {
 			data = `{}`
@@ -378,6 +387,13 @@ func createFunc(cmd *cobra.Command, args []string) {
 			return
 		}
 
+		if output == "yaml" {
+			buf, err := xrlib.FormatOutput(res.Body, output)
+			Error(err)
+			fmt.Printf("%s", string(buf))
+			return
+		}
+
 		/*
 			if output == "table" {
 				fmt.Printf("%s\n", xrlib.Tablize(xid.String(), objects))
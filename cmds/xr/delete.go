@@ -62,7 +62,9 @@ func deleteFunc(cmd *cobra.Command, args []string) {
 			data = string(buf)
 		}
 
-		Error(json.Unmarshal([]byte(data), &objects))
+		buf, err := xrlib.DataToJSON([]byte(data))
+		Error(err)
+		Error(json.Unmarshal(buf, &objects))
 	} else {
 		for _, arg := range args {
 			objects[arg] = nil
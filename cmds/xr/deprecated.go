@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	// log "github.com/duglin/dlog"
+	"github.com/spf13/cobra"
+	"github.com/xregistry/server/cmds/xr/xrlib"
+	. "github.com/xregistry/server/common"
+)
+
+func addDeprecatedCmd(parent *cobra.Command) {
+	deprecatedCmd := &cobra.Command{
+		Use:     "deprecated",
+		Short:   "Show the Resources that are past their deprecation removal date",
+		Run:     deprecatedFunc,
+		GroupID: "Entities",
+	}
+	deprecatedCmd.Flags().StringP("output", "o", "text",
+		"Output format: text, json, yaml")
+
+	parent.AddCommand(deprecatedCmd)
+}
+
+func deprecatedFunc(cmd *cobra.Command, args []string) {
+	if Server == "" {
+		Error("No Server address provided. Try either -s or XR_SERVER env var")
+	}
+
+	reg, err := xrlib.GetRegistry(Server)
+	Error(err)
+
+	output, _ := cmd.Flags().GetString("output")
+	if !ArrayContains([]string{"text", "json", "yaml"}, output) {
+		Error("--output must be one of: text, json, yaml")
+	}
+
+	if len(args) != 0 {
+		Error("No arguments are allowed")
+	}
+
+	model, err := reg.GetModel()
+	Error(err)
+
+	// xid -> meta.deprecated
+	results := map[string]any{}
+
+	for _, gPlural := range SortedKeys(model.Groups) {
+		gm := model.Groups[gPlural]
+		for _, rPlural := range SortedKeys(gm.Resources) {
+			path := gPlural + "." + rPlural + ".meta"
+			query := "?filter=" +
+				url.QueryEscape(path+".deprecated.removal<now") +
+				"&inline=" + url.QueryEscape(path)
+
+			res, err := reg.HttpDo("GET", "/"+query, nil)
+			Error(err)
+
+			root := map[string]any{}
+			if err = json.Unmarshal(res.Body, &root); err != nil {
+				Error("Error parsing result json: %s\nResponse:\n%s", err,
+					string(res.Body))
+			}
+
+			groups, _ := root[gPlural].(map[string]any)
+			for gID, gAny := range groups {
+				group, _ := gAny.(map[string]any)
+				resources, _ := group[rPlural].(map[string]any)
+				for rID, rAny := range resources {
+					resource, _ := rAny.(map[string]any)
+					meta, _ := resource["meta"].(map[string]any)
+					if meta == nil || meta["deprecated"] == nil {
+						continue
+					}
+					xid := "/" + gPlural + "/" + gID + "/" + rPlural + "/" + rID
+					results[xid] = meta["deprecated"]
+				}
+			}
+		}
+	}
+
+	if output == "json" || output == "yaml" {
+		buf, err := xrlib.FormatOutput([]byte(ToJSON(results)), output)
+		Error(err)
+		fmt.Printf("%s", string(buf))
+		return
+	}
+
+	for _, xid := range SortedKeys(results) {
+		deprecated, _ := results[xid].(map[string]any)
+		fmt.Printf("%s: removal %v", xid, deprecated["removal"])
+		if alt, ok := deprecated["alternative"]; ok {
+			fmt.Printf(", alternative %v", alt)
+		}
+		fmt.Printf("\n")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	// log "github.com/duglin/dlog"
+	"github.com/spf13/cobra"
+	"github.com/xregistry/server/cmds/xr/xrlib"
+	. "github.com/xregistry/server/common"
+)
+
+func addDiffCmd(parent *cobra.Command) {
+	diffCmd := &cobra.Command{
+		Use:     "diff XID1 XID2",
+		Short:   "Show the differences between two Resources or Versions",
+		Run:     diffFunc,
+		GroupID: "Entities",
+	}
+	diffCmd.Flags().StringP("output", "o", "text",
+		"Output format: text, json, yaml")
+	diffCmd.Flags().BoolP("details", "m", false,
+		"Only compare the metadata, not the documents")
+
+	parent.AddCommand(diffCmd)
+}
+
+func diffFunc(cmd *cobra.Command, args []string) {
+	if Server == "" {
+		Error("No Server address provided. Try either -s or XR_SERVER env var")
+	}
+
+	reg, err := xrlib.GetRegistry(Server)
+	Error(err)
+
+	output, _ := cmd.Flags().GetString("output")
+	if !ArrayContains([]string{"text", "json", "yaml"}, output) {
+		Error("--output must be one of: text, json, yaml")
+	}
+
+	if len(args) != 2 {
+		Error("Two XIDs must be specified")
+	}
+
+	xids := []*Xid{}
+	for _, arg := range args {
+		xid, err := ParseXid(arg)
+		Error(err)
+		if xid.ResourceID == "" || !xid.IsEntity || xid.Version == "meta" {
+			Error("%q must reference a Resource or Version", arg)
+		}
+		xids = append(xids, xid)
+	}
+
+	suffix := ""
+	if hasDetails, _ := cmd.Flags().GetBool("details"); hasDetails {
+		suffix = "$details"
+	}
+
+	res, err := reg.HttpDo("GET", xids[1].String()+suffix+"?diff="+
+		url.QueryEscape(xids[0].String()), nil)
+	Error(err)
+
+	if output == "json" || output == "yaml" {
+		buf, err := xrlib.FormatOutput(res.Body, output)
+		if err != nil {
+			Error("Error parsing result json: %s\nResponse:\n%s", err,
+				string(res.Body))
+		}
+		fmt.Printf("%s", string(buf))
+		return
+	}
+
+	diff := struct {
+		Metadata     []map[string]any `json:"metadata"`
+		Document     []map[string]any `json:"document"`
+		DocumentDiff string           `json:"documentdiff"`
+	}{}
+	if err = json.Unmarshal(res.Body, &diff); err != nil {
+		Error("Error parsing result json: %s\nResponse:\n%s", err,
+			string(res.Body))
+	}
+
+	showOps := func(title string, ops []map[string]any) {
+		if len(ops) == 0 {
+			return
+		}
+		fmt.Printf("%s:\n", title)
+		for _, op := range ops {
+			fmt.Printf("  %s %s", op["op"], op["path"])
+			if val, ok := op["value"]; ok {
+				buf, _ := json.Marshal(val)
+				fmt.Printf(": %s", string(buf))
+			}
+			fmt.Printf("\n")
+		}
+	}
+
+	showOps("Metadata", diff.Metadata)
+	showOps("Document", diff.Document)
+	if diff.DocumentDiff != "" {
+		fmt.Printf("Document:\n%s", diff.DocumentDiff)
+	}
+}
@@ -18,7 +18,7 @@ func addGetCmd(parent *cobra.Command) {
 		Run:     getFunc,
 		GroupID: "Entities",
 	}
-	getCmd.Flags().StringP("output", "o", "json", "Output format: json, table")
+	getCmd.Flags().StringP("output", "o", "json", "Output format: json, yaml, table")
 	getCmd.Flags().BoolP("details", "m", false, "Show resource metadata")
 
 	parent.AddCommand(getCmd)
@@ -33,8 +33,8 @@ func getFunc(cmd *cobra.Command, args []string) {
 	Error(err)
 
 	output, _ := cmd.Flags().GetString("output")
-	if !ArrayContains([]string{"table", "json"}, output) {
-		Error("--output must be one of: json, table")
+	if !ArrayContains([]string{"table", "json", "yaml"}, output) {
+		Error("--output must be one of: json, yaml, table")
 	}
 
 	if len(args) == 0 {
@@ -81,14 +81,14 @@ func getFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	if output == "json" {
-		buf, err := PrettyPrintJSON(res.Body, "", "  ")
+	if output == "json" || output == "yaml" {
+		buf, err := xrlib.FormatOutput(res.Body, output)
 		if err != nil {
 			Error("Error parsing result json: %s\nResponse:\n%s", err,
 				string(res.Body))
 		}
 
-		fmt.Printf("%s\n", string(buf))
+		fmt.Printf("%s", string(buf))
 		return
 	}
 
@@ -16,7 +16,7 @@ func addImportCmd(parent *cobra.Command) {
 		GroupID: "Entities",
 	}
 	importCmd.Flags().StringP("data", "d", "",
-		"Data(json), @FILE, @URL, @-(stdin)")
+		"Data(json|yaml), @FILE, @URL, @-(stdin)")
 
 	parent.AddCommand(importCmd)
 }
@@ -69,6 +69,10 @@ func importFunc(cmd *cobra.Command, args []string) {
 		Error("Missing data")
 	}
 
+	buf, err := xrlib.DataToJSON([]byte(data))
+	Error(err)
+	data = string(buf)
+
 	obj := map[string]json.RawMessage{}
 	Error(json.Unmarshal([]byte(data), &obj))
 
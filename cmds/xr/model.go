@@ -50,7 +50,7 @@ func addModelCmd(parent *cobra.Command) {
 		Run:   modelUpdateFunc,
 	}
 	updateCmd.Flags().StringP("data", "d", "",
-		"Data(json), @FILE, @URL, @-(stdin)")
+		"Data(json|yaml), @FILE, @URL, @-(stdin)")
 	modelCmd.AddCommand(updateCmd)
 
 	getCmd := &cobra.Command{
@@ -59,7 +59,8 @@ func addModelCmd(parent *cobra.Command) {
 		Run:   modelGetFunc,
 	}
 	getCmd.Flags().BoolP("all", "a", false, "Include default attributes")
-	getCmd.Flags().StringP("output", "o", "table", "Output format: table, json")
+	getCmd.Flags().StringP("output", "o", "table",
+		"Output format: table, json, yaml")
 	modelCmd.AddCommand(getCmd)
 
 	// "model group" commands
@@ -307,6 +308,9 @@ func modelUpdateFunc(cmd *cobra.Command, args []string) {
 		Error(err)
 	}
 
+	buf, err = xrlib.DataToJSON(buf)
+	Error(err)
+
 	buf, err = ProcessIncludes(fileName, buf, true)
 	Error(err)
 
@@ -330,8 +334,8 @@ func modelGetFunc(cmd *cobra.Command, args []string) {
 	output, _ := cmd.Flags().GetString("output")
 	all, _ := cmd.Flags().GetBool("all")
 
-	if !ArrayContains([]string{"table", "json"}, output) {
-		Error("--output must be one of 'json', 'table'")
+	if !ArrayContains([]string{"table", "json", "yaml"}, output) {
+		Error("--output must be one of 'json', 'yaml', 'table'")
 	}
 
 	model, err := reg.GetModel()
@@ -342,6 +346,13 @@ func modelGetFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if output == "yaml" {
+		buf, err := xrlib.FormatOutput([]byte(ToJSON(model)), output)
+		Error(err)
+		fmt.Printf("%s", string(buf))
+		return
+	}
+
 	fmt.Println("xRegistry Model:")
 	PrintLabels(model.Labels, "  ", os.Stdout)
 	PrintAttributes(ENTITY_REGISTRY, "", model.Attributes, "registry", "",
@@ -461,8 +472,12 @@ func PrintResourceModel(rm *xrlib.ResourceModel, format string, indent string, a
 	PrintNotEmpty(indent+"  Set version id    ", rm.SetVersionId, os.Stdout)
 	PrintNotEmpty(indent+"  Set version sticky", rm.SetDefaultSticky, os.Stdout)
 	PrintNotEmpty(indent+"  Has document      ", rm.HasDocument, os.Stdout)
+	PrintNotEmpty(indent+"  Version mode      ", rm.VersionMode, os.Stdout)
+	PrintNotEmpty(indent+"  Deprecation policy", strings.Join(
+		rm.DeprecationPolicy, ","), os.Stdout)
 	PrintNotEmpty(indent+"  Model version     ", rm.ModelVersion, os.Stdout)
 	PrintNotEmpty(indent+"  Compatible with   ", rm.CompatibleWith, os.Stdout)
+	PrintNotEmpty(indent+"  Schema attribute  ", rm.SchemaAttribute, os.Stdout)
 
 	PrintLabels(rm.Labels, indent+"  ", os.Stdout)
 	PrintAttributes(ENTITY_VERSION, "", rm.VersionAttributes,
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	// log "github.com/duglin/dlog"
+	"github.com/spf13/cobra"
+	"github.com/xregistry/server/cmds/xr/xrlib"
+	. "github.com/xregistry/server/common"
+)
+
+func addRefsCmd(parent *cobra.Command) {
+	refsCmd := &cobra.Command{
+		Use:     "refs XID",
+		Short:   "Show the entities that reference an entity",
+		Run:     refsFunc,
+		GroupID: "Entities",
+	}
+	refsCmd.Flags().StringP("output", "o", "text",
+		"Output format: text, json, yaml")
+	refsCmd.Flags().StringP("type", "t", "",
+		"Only show references from this type of entity (an xidtype)")
+
+	parent.AddCommand(refsCmd)
+}
+
+func refsFunc(cmd *cobra.Command, args []string) {
+	if Server == "" {
+		Error("No Server address provided. Try either -s or XR_SERVER env var")
+	}
+
+	reg, err := xrlib.GetRegistry(Server)
+	Error(err)
+
+	output, _ := cmd.Flags().GetString("output")
+	if !ArrayContains([]string{"text", "json", "yaml"}, output) {
+		Error("--output must be one of: text, json, yaml")
+	}
+
+	if len(args) != 1 {
+		Error("One XID must be specified")
+	}
+
+	xid, err := ParseXid(args[0])
+	Error(err)
+	if !xid.IsEntity {
+		Error("%q must reference an entity, not a collection", args[0])
+	}
+
+	query := "?referencedby"
+	if fromType, _ := cmd.Flags().GetString("type"); fromType != "" {
+		_, err := ParseXidType(fromType)
+		Error(err)
+		query += "=" + url.QueryEscape(fromType)
+	}
+
+	res, err := reg.HttpDo("GET", xid.String()+query, nil)
+	Error(err)
+
+	if output == "json" || output == "yaml" {
+		buf, err := xrlib.FormatOutput(res.Body, output)
+		if err != nil {
+			Error("Error parsing result json: %s\nResponse:\n%s", err,
+				string(res.Body))
+		}
+		fmt.Printf("%s", string(buf))
+		return
+	}
+
+	refs := map[string]struct {
+		Attributes []string `json:"attributes"`
+	}{}
+	if err = json.Unmarshal(res.Body, &refs); err != nil {
+		Error("Error parsing result json: %s\nResponse:\n%s", err,
+			string(res.Body))
+	}
+
+	for _, refXID := range SortedKeys(refs) {
+		fmt.Printf("%s: %s\n", refXID,
+			strings.Join(refs[refXID].Attributes, ", "))
+	}
+}
@@ -227,9 +227,12 @@ func main() {
 
 	addCreateCmd(xrCmd)
 	addDeleteCmd(xrCmd)
+	addDeprecatedCmd(xrCmd)
+	addDiffCmd(xrCmd)
 	addGetCmd(xrCmd)
 	addImportCmd(xrCmd)
 	addModelCmd(xrCmd)
+	addRefsCmd(xrCmd)
 	addUpdateCmd(xrCmd)
 	addUpsertCmd(xrCmd)
 
@@ -167,6 +167,28 @@ func ReadFile(fileName string) ([]byte, error) {
 	return buf, nil
 }
 
+// DataToJSON is used on user provided xRegistry data (e.g. --data). If
+// 'buf' isn't JSON then it's assumed to be YAML and is converted to JSON.
+func DataToJSON(buf []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(buf)) == 0 || json.Valid(buf) {
+		return buf, nil
+	}
+	return YAMLToJSON(buf)
+}
+
+// FormatOutput converts the JSON 'buf' into the requested --output format.
+// Only "json" (pretty-printed) and "yaml" are supported.
+func FormatOutput(buf []byte, output string) ([]byte, error) {
+	if output == "yaml" {
+		return JSONToYAML(buf)
+	}
+	buf, err := PrettyPrintJSON(buf, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
 func IsValidJSON(buf []byte) error {
 	tmp := map[string]any{}
 	if err := Unmarshal(buf, &tmp); err != nil {
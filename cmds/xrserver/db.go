@@ -5,6 +5,7 @@ import (
 	"os"
 	"sort"
 	"text/tabwriter"
+	"time"
 
 	// log "github.com/duglin/dlog"
 	"github.com/spf13/cobra"
@@ -15,7 +16,7 @@ import (
 func addDBCmd(parent *cobra.Command) *cobra.Command {
 	dbCmd := &cobra.Command{
 		Use:   "db",
-		Short: "Manage mysql databases",
+		Short: "Manage databases",
 	}
 
 	parent.AddCommand(dbCmd)
@@ -30,7 +31,7 @@ func addDBCmd(parent *cobra.Command) *cobra.Command {
 			}
 
 			dbs, err := registry.ListDBs()
-			ErrStop(err, "Error talking to mysql: %s", err)
+			ErrStop(err, "Error talking to the DB: %s", err)
 
 			sort.Strings(dbs)
 
@@ -58,7 +59,7 @@ func addDBCmd(parent *cobra.Command) *cobra.Command {
 
 	createCmd := &cobra.Command{
 		Use:   "create NAME",
-		Short: "Create a new mysql DB",
+		Short: "Create a new DB",
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				Stop("Missing DB NAME argument")
@@ -88,7 +89,7 @@ func addDBCmd(parent *cobra.Command) *cobra.Command {
 
 	deleteCmd := &cobra.Command{
 		Use:   "delete NAME",
-		Short: "Delete a mysql DB",
+		Short: "Delete a DB",
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				Stop("Missing DB NAME argument")
@@ -115,7 +116,7 @@ func addDBCmd(parent *cobra.Command) *cobra.Command {
 
 	getCmd := &cobra.Command{
 		Use:   "get NAME",
-		Short: "Get details about a mysql DB",
+		Short: "Get details about a DB",
 		Run: func(cmd *cobra.Command, args []string) {
 			if len(args) == 0 {
 				Stop("Missing DB NAME argument")
@@ -134,10 +135,42 @@ func addDBCmd(parent *cobra.Command) *cobra.Command {
 	}
 	dbCmd.AddCommand(getCmd)
 
+	migrateBlobsCmd := &cobra.Command{
+		Use:   "migrate-blobs NAME",
+		Short: "Move a DB's documents into the --blobstore",
+		Run: func(cmd *cobra.Command, args []string) {
+			DBName = dbBlobsArgs(args)
+
+			count, err := registry.MigrateBlobs(registry.Blobs)
+			ErrStop(err, "Error moving documents to %s: %s", registry.Blobs,
+				err)
+			fmt.Printf("Moved %d document(s) to %s\n", count, registry.Blobs)
+		},
+	}
+	dbCmd.AddCommand(migrateBlobsCmd)
+
+	gcBlobsCmd := &cobra.Command{
+		Use:   "gc-blobs NAME",
+		Short: "Delete the documents in the --blobstore no longer used by a DB",
+		Run: func(cmd *cobra.Command, args []string) {
+			DBName = dbBlobsArgs(args)
+			minAge, _ := cmd.Flags().GetDuration("min-age")
+
+			count, err := registry.GCBlobs(registry.Blobs, minAge)
+			ErrStop(err, "Error deleting documents from %s: %s",
+				registry.Blobs, err)
+			fmt.Printf("Deleted %d document(s) from %s\n", count,
+				registry.Blobs)
+		},
+	}
+	gcBlobsCmd.Flags().DurationP("min-age", "", time.Hour,
+		"Skip documents newer than this")
+	dbCmd.AddCommand(gcBlobsCmd)
+
 	/*
 		listCmd := &cobra.Command{
 			Use:   "list",
-			Short: "List the mysql DBs",
+			Short: "List the DBs",
 			Run: func(cmd *cobra.Command, args []string) {
 				Stop("TBD... list 'em")
 			},
@@ -147,3 +180,25 @@ func addDBCmd(parent *cobra.Command) *cobra.Command {
 
 	return dbCmd
 }
+
+// Check the args of the *-blobs cmds and open the DB
+func dbBlobsArgs(args []string) string {
+	if len(args) == 0 {
+		Stop("Missing DB NAME argument")
+	}
+	if len(args) > 1 {
+		Stop("Too many argument on the command line")
+	}
+	if registry.Blobs == nil {
+		Stop("Missing --blobstore")
+	}
+
+	name := args[0]
+	if !registry.DBExists(name) {
+		Stop("DB %q doesn't exist", name)
+	}
+	err := registry.OpenDB(name)
+	ErrStop(err, "Can't connect to db(%s): %s", name, err)
+
+	return name
+}
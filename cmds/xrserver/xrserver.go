@@ -3,8 +3,11 @@ package main
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	log "github.com/duglin/dlog"
 	"github.com/spf13/cobra"
@@ -12,17 +15,20 @@ import (
 	"github.com/xregistry/server/registry"
 )
 
+var defDBDriver = "mysql"
 var defDBHost = "127.0.0.1"
 var defDBPort = 3306
 var defDBName = "registry"
 var defDBUser = "root"
 var defDBPassword = "password"
 
+var DBDriver = EnvString("DBDRIVER", defDBDriver)
 var DBHost = EnvString("DBHOST", defDBHost)
 var DBPort = EnvInt("DBPORT", defDBPort)
 var DBName = EnvString("DBNAME", defDBName)
 var DBUser = EnvString("DBUSER", defDBUser)
 var DBPassword = EnvString("DBPASSWORD", defDBPassword)
+var BlobStore = EnvString("XR_BLOBSTORE", "")
 
 var RegistryName = "xRegistry"
 var APIPort = 8080
@@ -30,6 +36,18 @@ var VerboseCount = 0 // to change, do it as definition of -v flag
 var DontCreate = false
 var RecreateDB = false
 var RecreateReg = false
+var AuthFile = ""
+var Webhooks = []string{}
+var WaitForDB = time.Duration(0)
+var TLSCert = ""
+var TLSKey = ""
+var TLSClientCA = ""
+var ShutdownTimeout = 30 * time.Second
+var ProxyTimeout = registry.Proxy.Timeout
+var ProxyMaxSize = int(registry.Proxy.MaxSize / (1024 * 1024))
+var ProxyCacheSize = int(registry.Proxy.CacheSize / (1024 * 1024))
+var ProxyAllow = []string{}
+var ProxyDeny = []string{}
 
 func ErrStop(err error, args ...any) {
 	ErrStopTx(err, nil, args...)
@@ -114,9 +132,35 @@ func setupCmds() *cobra.Command {
 		"Don't create DB/reg if missing")
 	serverCmd.Flags().StringVarP(&RegistryName, "registry", "r", RegistryName,
 		"Default Registry name")
+	serverCmd.Flags().StringVarP(&AuthFile, "auth", "", AuthFile,
+		"Auth config file")
+	serverCmd.Flags().StringArrayVarP(&Webhooks, "webhook", "", Webhooks,
+		"URL to send change events to (repeatable)")
+	serverCmd.Flags().StringVarP(&TLSCert, "tls-cert", "", TLSCert,
+		"TLS cert file, enables https (reloaded when changed)")
+	serverCmd.Flags().StringVarP(&TLSKey, "tls-key", "", TLSKey,
+		"TLS key file (reloaded when changed)")
+	serverCmd.Flags().StringVarP(&TLSClientCA, "tls-client-ca", "", TLSClientCA,
+		"CA file used to verify required client certs")
+	serverCmd.Flags().DurationVarP(&ShutdownTimeout, "shutdown-timeout", "",
+		ShutdownTimeout, "How long to wait for requests to finish on exit")
+	serverCmd.Flags().DurationVarP(&ProxyTimeout, "proxy-timeout", "",
+		ProxyTimeout, "Timeout for fetching proxyurl documents (0=none)")
+	serverCmd.Flags().IntVarP(&ProxyMaxSize, "proxy-max-size", "", ProxyMaxSize,
+		"Max size (MB) of a proxyurl document (0=no limit)")
+	serverCmd.Flags().IntVarP(&ProxyCacheSize, "proxy-cache-size", "",
+		ProxyCacheSize, "Size (MB) of the proxyurl cache (0=no cache)")
+	serverCmd.Flags().StringArrayVarP(&ProxyAllow, "proxy-allow", "", ProxyAllow,
+		"Only allowed proxyurl host/IP/CIDR/*.domain/private (repeatable)")
+	serverCmd.Flags().StringArrayVarP(&ProxyDeny, "proxy-deny", "", ProxyDeny,
+		"Disallowed proxyurl host/IP/CIDR/*.domain, private is always "+
+			"included unless it's a --proxy-allow (repeatable)")
 
 	serverCmd.CompletionOptions.HiddenDefaultCmd = true
 	serverCmd.PersistentFlags().StringVarP(&DBName, "db", "", DBName, "DB name")
+	serverCmd.PersistentFlags().StringVarP(&DBDriver, "db-driver", "",
+		DBDriver, "DB driver ("+
+			strings.Join(registry.DialectNames(), ", ")+")")
 	serverCmd.PersistentFlags().StringVarP(&DBHost, "dbhost", "", defDBHost,
 		"DB host address")
 	serverCmd.PersistentFlags().IntVarP(&DBPort, "dbport", "", defDBPort,
@@ -125,6 +169,10 @@ func setupCmds() *cobra.Command {
 		"DB user")
 	serverCmd.PersistentFlags().StringVarP(&DBPassword, "dbpassword", "",
 		defDBPassword, "DB password")
+	serverCmd.PersistentFlags().DurationVarP(&WaitForDB, "waitfordb", "",
+		WaitForDB, "How long to wait for the DB to be available (e.g. 30s)")
+	serverCmd.PersistentFlags().StringVarP(&BlobStore, "blobstore", "",
+		BlobStore, "Where to store documents: DIR or s3://BUCKET[/PREFIX]")
 	serverCmd.PersistentFlags().CountVarP(&VerboseCount, "verbose", "v",
 		"Be chatty - can specify multiple (-v=0 to turn off)``")
 
@@ -146,6 +194,29 @@ func setupCmds() *cobra.Command {
 		"Don't create DB/reg if missing")
 	runCmd.Flags().StringVarP(&RegistryName, "registry", "r", RegistryName,
 		"Default Registry name")
+	runCmd.Flags().StringVarP(&AuthFile, "auth", "", AuthFile,
+		"Auth config file")
+	runCmd.Flags().StringArrayVarP(&Webhooks, "webhook", "", Webhooks,
+		"URL to send change events to (repeatable)")
+	runCmd.Flags().StringVarP(&TLSCert, "tls-cert", "", TLSCert,
+		"TLS cert file, enables https (reloaded when changed)")
+	runCmd.Flags().StringVarP(&TLSKey, "tls-key", "", TLSKey,
+		"TLS key file (reloaded when changed)")
+	runCmd.Flags().StringVarP(&TLSClientCA, "tls-client-ca", "", TLSClientCA,
+		"CA file used to verify required client certs")
+	runCmd.Flags().DurationVarP(&ShutdownTimeout, "shutdown-timeout", "",
+		ShutdownTimeout, "How long to wait for requests to finish on exit")
+	runCmd.Flags().DurationVarP(&ProxyTimeout, "proxy-timeout", "",
+		ProxyTimeout, "Timeout for fetching proxyurl documents (0=none)")
+	runCmd.Flags().IntVarP(&ProxyMaxSize, "proxy-max-size", "", ProxyMaxSize,
+		"Max size (MB) of a proxyurl document (0=no limit)")
+	runCmd.Flags().IntVarP(&ProxyCacheSize, "proxy-cache-size", "",
+		ProxyCacheSize, "Size (MB) of the proxyurl cache (0=no cache)")
+	runCmd.Flags().StringArrayVarP(&ProxyAllow, "proxy-allow", "", ProxyAllow,
+		"Only allowed proxyurl host/IP/CIDR/*.domain/private (repeatable)")
+	runCmd.Flags().StringArrayVarP(&ProxyDeny, "proxy-deny", "", ProxyDeny,
+		"Disallowed proxyurl host/IP/CIDR/*.domain, private is always "+
+			"included unless it's a --proxy-allow (repeatable)")
 
 	serverCmd.AddCommand(runCmd)
 
@@ -155,6 +226,19 @@ func setupCmds() *cobra.Command {
 	serverCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
 		log.SetVerbose(VerboseCount)
 		registry.DB_Name = DBName
+		registry.DBDRIVER = DBDriver
+		_, err := registry.GetDialect()
+		ErrStop(err)
+
+		if BlobStore != "" {
+			registry.Blobs, err = registry.NewBlobStore(BlobStore)
+			ErrStop(err)
+		}
+
+		if WaitForDB > 0 {
+			Verbose("Waiting up to %s for the DB", WaitForDB)
+			ErrStop(registry.WaitForDB(WaitForDB))
+		}
 	}
 
 	serverCmd.PersistentFlags().BoolP("help", "?", false, "Help for commands")
@@ -176,7 +260,20 @@ func runFunc(cmd *cobra.Command, args []string) {
 
 	PanicIf(GitCommit == "" || GitCommit == "<n/a>", "GitCommit isn't set")
 	Verbose("GitCommit: %.10s", GitCommit)
-	Verbose("DB server: %s:%s", registry.DBHOST, registry.DBPORT)
+	if DBDriver == "mysql" {
+		Verbose("DB server: %s:%s", registry.DBHOST, registry.DBPORT)
+	} else {
+		Verbose("DB driver: %s (%s)", DBDriver, registry.DBDIR)
+	}
+	if registry.Blobs != nil {
+		Verbose("Blob store: %s", registry.Blobs)
+	}
+
+	registry.Proxy.Timeout = ProxyTimeout
+	registry.Proxy.MaxSize = int64(ProxyMaxSize) * 1024 * 1024
+	registry.Proxy.CacheSize = int64(ProxyCacheSize) * 1024 * 1024
+	registry.Proxy.Allow = ProxyAllow
+	registry.Proxy.Deny = append(registry.Proxy.Deny, ProxyDeny...)
 
 	if tmp := os.Getenv("XR_PORT"); tmp != "" {
 		tmpInt, _ := strconv.Atoi(tmp)
@@ -193,6 +290,15 @@ func runFunc(cmd *cobra.Command, args []string) {
 		Stop("Default Registry name missing, try: -r NAME")
 	}
 
+	// Load it before we touch the DB so we don't do anything if it's bad
+	var auth *registry.Auth
+	if AuthFile != "" {
+		var err error
+		auth, err = registry.LoadAuth(AuthFile)
+		ErrStop(err, "Error loading auth config(%s): %s", AuthFile, err)
+		Verbose("Auth config: %s", AuthFile)
+	}
+
 	if RecreateDB {
 		if registry.DBExists(DBName) {
 			Verbose("Deleting DB: %s", DBName)
@@ -213,6 +319,9 @@ func runFunc(cmd *cobra.Command, args []string) {
 	err := registry.OpenDB(DBName)
 	ErrStop(err, "Can't connect to db(%s): %s", DBName, err)
 
+	err = registry.UpgradeDB()
+	ErrStop(err, "Can't upgrade db(%s): %s", DBName, err)
+
 	// Load samples before we look for the default reg because if the default
 	// one points to sample, but it's not there, it might try to create it
 	if val, _ := cmd.Flags().GetBool("samples"); val {
@@ -265,8 +374,47 @@ func runFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	for _, url := range Webhooks {
+		Verbose("Webhook: %s", url)
+		registry.NewWebhook(url).Start()
+	}
+
 	registry.DefaultRegDbSID = reg.DbSID
-	registry.NewServer(APIPort).Serve()
+	server := registry.NewServer(APIPort)
+	server.Auth = auth
+
+	if TLSCert != "" || TLSKey != "" {
+		if TLSCert == "" || TLSKey == "" {
+			Stop("Both --tls-cert and --tls-key must be specified")
+		}
+		err = server.EnableTLS(TLSCert, TLSKey, TLSClientCA)
+		ErrStop(err)
+		Verbose("TLS cert: %s", TLSCert)
+		if TLSClientCA != "" {
+			Verbose("TLS client CA: %s", TLSClientCA)
+		}
+	} else if TLSClientCA != "" {
+		Stop("--tls-client-ca requires --tls-cert and --tls-key")
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve() }()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+
+	select {
+	case err = <-serveErr:
+		ErrStop(err, "Error starting server: %s", err)
+	case sig := <-sigs:
+		Verbose("Received %s, shutting down", sig)
+		err = server.Shutdown(ShutdownTimeout)
+		if dbErr := registry.CloseDB(); err == nil {
+			err = dbErr
+		}
+		ErrStop(err)
+		Verbose("Done")
+	}
 }
 
 func BufPrintf(buf *strings.Builder, fmtStr string, args ...any) {
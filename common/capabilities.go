@@ -48,7 +48,7 @@ var AllowableAPIs = ArrayToLower([]string{
 	"/capabilities", "/export", "/model", "/modelsource"})
 
 var AllowableFlags = ArrayToLower([]string{
-	"collections", "doc", "epoch", "filter", "inline",
+	"collections", "doc", "epoch", "filter", "inline", "migrate",
 	"nodefaultversionid", "nodefaultversionsticky",
 	"noepoch", "noreadonly", "offered",
 	"schema", "setdefaultversionid", "sort", "specversion"})
@@ -61,7 +61,7 @@ var AllowableSchemas = ArrayToLower([]string{XREGSCHEMA + "/" + SPECVERSION})
 var AllowableSpecVersions = ArrayToLower([]string{"1.0-rc2", SPECVERSION})
 
 var SupportedFlags = ArrayToLower([]string{
-	"collections", "doc", "epoch", "filter", "inline",
+	"collections", "doc", "epoch", "filter", "inline", "migrate",
 	"nodefaultversionid", "nodefaultversionsticky",
 	"noepoch", "noreadonly", "offered",
 	"schema", "setdefaultversionid", "sort", "specversion"})
@@ -70,7 +70,7 @@ var DefaultCapabilities = &Capabilities{
 	APIs:         AllowableAPIs,
 	Flags:        SupportedFlags,
 	Mutable:      AllowableMutable,
-	Pagination:   false,
+	Pagination:   true,
 	Schemas:      AllowableSchemas,
 	ShortSelf:    false,
 	SpecVersions: AllowableSpecVersions,
@@ -119,7 +119,7 @@ func GetOffered() *Offered {
 		},
 		Pagination: OfferedCapability{
 			Type: "boolean",
-			Enum: []any{false},
+			Enum: []any{false, true},
 		},
 		Schemas: OfferedCapability{
 			Type: "string",
@@ -127,7 +127,7 @@ func GetOffered() *Offered {
 		},
 		ShortSelf: OfferedCapability{
 			Type: "boolean",
-			Enum: []any{false},
+			Enum: []any{false, true},
 		},
 		SpecVersions: OfferedCapability{
 			Type: "string",
@@ -224,10 +224,6 @@ func (c *Capabilities) Validate() error {
 		return err
 	}
 
-	if c.Pagination != false {
-		return fmt.Errorf(`"pagination" must be "false"`)
-	}
-
 	c.Schemas, err = CleanArray(c.Schemas, AllowableSchemas, "schemas")
 	if err != nil {
 		return err
@@ -243,10 +239,6 @@ func (c *Capabilities) Validate() error {
 		return fmt.Errorf(`"schemas" must contain %q`, XREGSCHEMA+"/"+SPECVERSION)
 	}
 
-	if c.ShortSelf != false {
-		return fmt.Errorf(`"shortself" must be "false"`)
-	}
-
 	if !ArrayContainsAnyCase(c.SpecVersions, SPECVERSION) {
 		return fmt.Errorf(`"specversions" must contain %q`, SPECVERSION)
 	}
@@ -13,6 +13,32 @@ const SETDEFAULTSTICKY = true
 const HASDOCUMENT = true
 const SINGLEVERSIONROOT = false
 const READONLY = false
+const VERSIONMODE = VERSIONMODE_MANUAL
+const INTEGRITY = INTEGRITY_NONE
+
+// Resource model "versionmode" values
+const VERSIONMODE_MANUAL = "manual"
+const VERSIONMODE_CREATEDAT = "createdat"
+const VERSIONMODE_SEMVER = "semver"
+
+var VERSIONMODES = []string{VERSIONMODE_MANUAL, VERSIONMODE_CREATEDAT,
+	VERSIONMODE_SEMVER}
+
+// xid attribute "integrity" values
+const INTEGRITY_NONE = "none"
+const INTEGRITY_RESTRICT = "restrict"
+const INTEGRITY_CASCADE_NULL = "cascade-null"
+
+var INTEGRITIES = []string{INTEGRITY_NONE, INTEGRITY_RESTRICT,
+	INTEGRITY_CASCADE_NULL}
+
+// Resource model "deprecationpolicy" values - what's rejected once a
+// Resource's "deprecated.effective" time has passed
+const DEPRECATIONPOLICY_NOVERSIONS = "noversions"
+const DEPRECATIONPOLICY_NOXREFS = "noxrefs"
+
+var DEPRECATIONPOLICIES = []string{DEPRECATIONPOLICY_NOVERSIONS,
+	DEPRECATIONPOLICY_NOXREFS}
 
 // Attribute types
 const ANY = "any"
@@ -0,0 +1,245 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PatchOp is one operation of an RFC 6902 JSON Patch
+type PatchOp struct {
+	Op    string
+	Path  string
+	Value any
+}
+
+// "value" can be a JSON null so it's only omitted for "remove" ops
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	om := &OrderedMap{Values: map[string]any{}}
+	omSet(om, "op", op.Op)
+	omSet(om, "path", op.Path)
+	if op.Op != "remove" {
+		omSet(om, "value", op.Value)
+	}
+	return json.Marshal(om)
+}
+
+// CreateJSONPatch returns the RFC 6902 JSON Patch that turns the JSON
+// document 'from' into 'to'. Maps are compared key by key and arrays
+// index by index, so it's not always the smallest possible patch, but it
+// is deterministic - keys are processed in the order they appear in the
+// documents.
+func CreateJSONPatch(from []byte, to []byte) ([]PatchOp, error) {
+	fromObj, err := ParseJSONToObject(from)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing document: %s", err)
+	}
+	toObj, err := ParseJSONToObject(to)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing document: %s", err)
+	}
+
+	ops := []PatchOp{}
+	diffValues(NewPP(), fromObj, toObj, &ops)
+	return ops, nil
+}
+
+func diffValues(pp *PropPath, from any, to any, ops *[]PatchOp) {
+	if patchEqual(from, to) {
+		return
+	}
+
+	switch fromVal := from.(type) {
+	case *OrderedMap:
+		toVal, ok := to.(*OrderedMap)
+		if !ok {
+			break
+		}
+		for _, key := range fromVal.Keys {
+			if val, ok := toVal.Values[key]; ok {
+				diffValues(pp.P(key), fromVal.Values[key], val, ops)
+			} else {
+				*ops = append(*ops, PatchOp{Op: "remove", Path: pp.P(key).Pointer()})
+			}
+		}
+		for _, key := range toVal.Keys {
+			if _, ok := fromVal.Values[key]; !ok {
+				*ops = append(*ops, PatchOp{Op: "add", Path: pp.P(key).Pointer(),
+					Value: toVal.Values[key]})
+			}
+		}
+		return
+
+	case []any:
+		toVal, ok := to.([]any)
+		if !ok {
+			break
+		}
+		i := 0
+		for ; i < len(fromVal) && i < len(toVal); i++ {
+			diffValues(pp.I(i), fromVal[i], toVal[i], ops)
+		}
+		// Remove from the end so the indexes don't shift on us
+		for j := len(fromVal) - 1; j >= i; j-- {
+			*ops = append(*ops, PatchOp{Op: "remove", Path: pp.I(j).Pointer()})
+		}
+		for ; i < len(toVal); i++ {
+			*ops = append(*ops, PatchOp{Op: "add", Path: pp.I(i).Pointer(),
+				Value: toVal[i]})
+		}
+		return
+	}
+
+	*ops = append(*ops, PatchOp{Op: "replace", Path: pp.Pointer(), Value: to})
+}
+
+// Max number of (fromLines * toLines), after removing the common prefix and
+// suffix, that UnifiedDiff will try to match up. Anything bigger is shown
+// as all of 'from' being replaced by all of 'to'.
+const MAX_DIFF_SIZE = 4 * 1024 * 1024
+
+// UnifiedDiff returns a "diff -u" style diff of the text 'from' and 'to',
+// with 3 lines of context. 'fromName' and 'toName' are used for the
+// "---" and "+++" header lines. Returns "" if they're the same.
+func UnifiedDiff(fromName string, toName string, from string, to string) string {
+	if from == to {
+		return ""
+	}
+
+	aLines, bLines := splitLines(from), splitLines(to)
+	edits := diffLines(aLines, bLines)
+
+	res := &strings.Builder{}
+	fmt.Fprintf(res, "--- %s\n+++ %s\n", fromName, toName)
+
+	const context = 3
+	for i := 0; i < len(edits); {
+		if edits[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Back up to include the leading context, then keep going until
+		// we've seen more than 2*context unchanged lines in a row
+		start := max(0, i-context)
+		end, same := i, 0
+		for ; end < len(edits) && same <= 2*context; end++ {
+			if edits[end].kind == ' ' {
+				same++
+			} else {
+				same = 0
+			}
+		}
+		end -= max(0, same-context)
+
+		aStart, aCount, bStart, bCount := edits[start].a, 0, edits[start].b, 0
+		for _, e := range edits[start:end] {
+			if e.kind != '+' {
+				aCount++
+			}
+			if e.kind != '-' {
+				bCount++
+			}
+		}
+		if aCount > 0 {
+			aStart++
+		}
+		if bCount > 0 {
+			bStart++
+		}
+		fmt.Fprintf(res, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart,
+			bCount)
+
+		for _, e := range edits[start:end] {
+			res.WriteByte(e.kind)
+			res.WriteString(e.text)
+			if !strings.HasSuffix(e.text, "\n") {
+				res.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+
+	return res.String()
+}
+
+// One line of a diff. 'a' and 'b' are the (0-based) line numbers in 'from'
+// and 'to' that this line is at, or would be inserted at.
+type lineEdit struct {
+	kind byte // ' ', '-' or '+'
+	a    int
+	b    int
+	text string
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Uses the longest common subsequence of lines to figure out which lines
+// were removed from 'a' and added to 'b'
+func diffLines(a []string, b []string) []lineEdit {
+	edits := []lineEdit{}
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		edits = append(edits, lineEdit{' ', prefix, prefix, a[prefix]})
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+
+	// lcs[i][j] is the length of the LCS of midA[i:] and midB[j:]
+	lcs := [][]int32{}
+	if n*m <= MAX_DIFF_SIZE {
+		lcs = make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case len(lcs) > 0 && i < n && j < m && midA[i] == midB[j]:
+			edits = append(edits, lineEdit{' ', prefix + i, prefix + j, midA[i]})
+			i++
+			j++
+		case i < n && (j == m || len(lcs) == 0 || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, lineEdit{'-', prefix + i, prefix + j, midA[i]})
+			i++
+		default:
+			edits = append(edits, lineEdit{'+', prefix + i, prefix + j, midB[j]})
+			j++
+		}
+	}
+
+	for k := suffix; k > 0; k-- {
+		edits = append(edits, lineEdit{' ', len(a) - k, len(b) - k, a[len(a)-k]})
+	}
+
+	return edits
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCreateJSONPatch(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		exp  string
+	}{
+		{`{"a":1}`, `{"a":1.0}`, `[]`},
+		{`{"a":1,"b":{"c":2,"d":3}}`, `{"b":{"d":3,"c":2},"a":1}`, `[]`},
+		{`{"a":1}`, `{"a":2}`, `[{"op":"replace","path":"/a","value":2}]`},
+		{`{"a":1,"b":2}`, `{"b":2,"c":null}`,
+			`[{"op":"remove","path":"/a"},{"op":"add","path":"/c","value":null}]`},
+		{`{"a":{"x/y":1,"m~n":2}}`, `{"a":{"x/y":3}}`,
+			`[{"op":"replace","path":"/a/x~1y","value":3},` +
+				`{"op":"remove","path":"/a/m~0n"}]`},
+		{`{"a":[1,2,3]}`, `{"a":[1,5]}`,
+			`[{"op":"replace","path":"/a/1","value":5},` +
+				`{"op":"remove","path":"/a/2"}]`},
+		{`{"a":[1,2,3,4]}`, `{"a":[1]}`,
+			`[{"op":"remove","path":"/a/3"},{"op":"remove","path":"/a/2"},` +
+				`{"op":"remove","path":"/a/1"}]`},
+		{`{"a":[1]}`, `{"a":[1,{"b":2},3]}`,
+			`[{"op":"add","path":"/a/1","value":{"b":2}},` +
+				`{"op":"add","path":"/a/2","value":3}]`},
+		{`{"a":[1]}`, `{"a":{"0":1}}`,
+			`[{"op":"replace","path":"/a","value":{"0":1}}]`},
+		{`{"a":1}`, `[1]`, `[{"op":"replace","path":"","value":[1]}]`},
+		{`{"a":`, `{}`, `Error parsing document: EOF`},
+	}
+
+	for _, test := range tests {
+		got := ""
+		ops, err := CreateJSONPatch([]byte(test.from), []byte(test.to))
+		if err != nil {
+			got = err.Error()
+		} else {
+			buf, _ := json.Marshal(ops)
+			got = string(buf)
+
+			// Applying the patch should always get us 'to'
+			res, err := ApplyJSONPatch([]byte(test.from), buf)
+			if err != nil {
+				t.Errorf("From: %s\nTo: %s\nPatch: %s\nApply failed: %s",
+					test.from, test.to, got, err)
+			} else if ops, _ := CreateJSONPatch(res, []byte(test.to)); len(ops) != 0 {
+				t.Errorf("From: %s\nTo: %s\nPatch: %s\nApply result: %s",
+					test.from, test.to, got, string(res))
+			}
+		}
+		if got != test.exp {
+			t.Errorf("From: %s\nTo: %s\nExp: %s\nGot: %s", test.from,
+				test.to, test.exp, got)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(from, to int) string {
+		res := ""
+		for i := from; i <= to; i++ {
+			res += string(rune('a'+i-1)) + "\n"
+		}
+		return res
+	}
+
+	tests := []struct {
+		from string
+		to   string
+		exp  string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"", "a\n", "@@ -0,0 +1,1 @@\n+a\n"},
+		{"a\n", "", "@@ -1,1 +0,0 @@\n-a\n"},
+		{"a\nb\nc\n", "a\nx\nc\n", "@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{"a\nb", "a\nb\n",
+			"@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		// Context is limited to 3 lines
+		{lines(1, 10), lines(1, 4) + "X\n" + lines(6, 10),
+			"@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+X\n f\n g\n h\n"},
+		// Changes 7+ lines apart are in separate hunks
+		{lines(1, 12), "X\n" + lines(2, 11) + "Y\n",
+			"@@ -1,4 +1,4 @@\n-a\n+X\n b\n c\n d\n" +
+				"@@ -9,4 +9,4 @@\n i\n j\n k\n-l\n+Y\n"},
+		// ... but 6 apart are merged
+		{lines(1, 8), "X\n" + lines(2, 7) + "Y\n",
+			"@@ -1,8 +1,8 @@\n-a\n+X\n b\n c\n d\n e\n f\n g\n-h\n+Y\n"},
+		{"a\nb\nc\nd\n", "a\nc\nb\nd\n",
+			"@@ -1,4 +1,4 @@\n a\n-b\n c\n+b\n d\n"},
+	}
+
+	for _, test := range tests {
+		got := UnifiedDiff("from", "to", test.from, test.to)
+		exp := test.exp
+		if exp != "" {
+			exp = "--- from\n+++ to\n" + exp
+		}
+		if got != exp {
+			t.Errorf("From: %q\nTo: %q\nExp:\n%s\nGot:\n%s", test.from,
+				test.to, exp, got)
+		}
+	}
+
+	// Make sure really big diffs still work
+	big1, big2 := strings.Repeat("x\n", 3000), strings.Repeat("y\n", 3000)
+	got := UnifiedDiff("from", "to", big1, big2)
+	if !strings.HasPrefix(got, "--- from\n+++ to\n@@ -1,3000 +1,3000 @@\n") {
+		t.Errorf("Big diff is wrong:\n%.100s", got)
+	}
+}
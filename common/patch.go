@@ -0,0 +1,437 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const JSON_PATCH_MEDIATYPE = "application/json-patch+json"
+const MERGE_PATCH_MEDIATYPE = "application/merge-patch+json"
+
+// PatchMediaType returns the patch media type (JSON_PATCH_MEDIATYPE or
+// MERGE_PATCH_MEDIATYPE) of the HTTP Content-Type value, or "" if it isn't
+// one of them.
+func PatchMediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	if mt == JSON_PATCH_MEDIATYPE || mt == MERGE_PATCH_MEDIATYPE {
+		return mt
+	}
+	return ""
+}
+
+// ApplyPatch applies 'patch', which is of type 'mediaType', to the JSON
+// 'doc' and returns the resulting JSON. The order of any map keys in 'doc'
+// is preserved.
+func ApplyPatch(mediaType string, doc []byte, patch []byte) ([]byte, error) {
+	switch mediaType {
+	case JSON_PATCH_MEDIATYPE:
+		return ApplyJSONPatch(doc, patch)
+	case MERGE_PATCH_MEDIATYPE:
+		return ApplyMergePatch(doc, patch)
+	}
+	return nil, fmt.Errorf("Unsupported patch media type: %s", mediaType)
+}
+
+// ApplyMergePatch applies an RFC 7396 JSON Merge Patch to 'doc'.
+func ApplyMergePatch(doc []byte, patch []byte) ([]byte, error) {
+	docObj, err := ParseJSONToObject(doc)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing document: %s", err)
+	}
+
+	patchObj, err := ParseJSONToObject(patch)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing merge patch: %s", err)
+	}
+
+	return json.Marshal(mergePatch(docObj, patchObj))
+}
+
+func mergePatch(target any, patch any) any {
+	patchMap, ok := patch.(*OrderedMap)
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(*OrderedMap)
+	if !ok {
+		targetMap = &OrderedMap{Values: map[string]any{}}
+	}
+
+	for _, key := range patchMap.Keys {
+		val := patchMap.Values[key]
+		if val == nil {
+			omDelete(targetMap, key)
+			continue
+		}
+		omSet(targetMap, key, mergePatch(targetMap.Values[key], val))
+	}
+	return targetMap
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch to 'doc'. All operations
+// are applied, or none are - in which case the error will say which one
+// failed.
+func ApplyJSONPatch(doc []byte, patch []byte) ([]byte, error) {
+	docObj, err := ParseJSONToObject(doc)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing document: %s", err)
+	}
+
+	ops := []map[string]json.RawMessage{}
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		if !json.Valid(patch) {
+			return nil, fmt.Errorf("Error parsing JSON patch: %s", err)
+		}
+		return nil, fmt.Errorf("A JSON patch must be an array of operations")
+	}
+
+	for i, op := range ops {
+		docObj, err = applyPatchOp(docObj, op)
+		if err != nil {
+			return nil, fmt.Errorf("JSON patch operation #%d: %s", i, err)
+		}
+	}
+
+	return json.Marshal(docObj)
+}
+
+func applyPatchOp(doc any, op map[string]json.RawMessage) (any, error) {
+	getString := func(name string) (string, error) {
+		raw, ok := op[name]
+		if !ok {
+			return "", fmt.Errorf("missing %q", name)
+		}
+		str := ""
+		if err := json.Unmarshal(raw, &str); err != nil {
+			return "", fmt.Errorf("%q must be a string", name)
+		}
+		return str, nil
+	}
+
+	opName, err := getString("op")
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := getString("path")
+	if err != nil {
+		return nil, err
+	}
+	pp, err := PropPathFromPointer(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	if opName == "add" || opName == "replace" || opName == "test" {
+		raw, ok := op["value"]
+		if !ok {
+			return nil, fmt.Errorf("missing \"value\"")
+		}
+		if value, err = ParseJSONToObject(raw); err != nil {
+			return nil, fmt.Errorf("error parsing \"value\": %s", err)
+		}
+	}
+
+	from := (*PropPath)(nil)
+	if opName == "move" || opName == "copy" {
+		fromStr, err := getString("from")
+		if err != nil {
+			return nil, err
+		}
+		if from, err = PropPathFromPointer(fromStr); err != nil {
+			return nil, err
+		}
+	}
+
+	switch opName {
+	case "add":
+		return patchSet(doc, pp, value, true, NewPP())
+
+	case "remove":
+		doc, _, err = patchRemove(doc, pp, NewPP())
+		return doc, err
+
+	case "replace":
+		return patchSet(doc, pp, value, false, NewPP())
+
+	case "move":
+		if pp.HasPrefix(from) && !pp.Equals(from) {
+			return nil, fmt.Errorf("can't move %q into one of its children",
+				from.Pointer())
+		}
+		doc, value, err = patchRemove(doc, from, NewPP())
+		if err != nil {
+			return nil, err
+		}
+		return patchSet(doc, pp, value, true, NewPP())
+
+	case "copy":
+		if value, err = patchGet(doc, from, NewPP()); err != nil {
+			return nil, err
+		}
+		// Make a deep copy so the two don't share anything
+		buf, _ := json.Marshal(value)
+		if value, err = ParseJSONToObject(buf); err != nil {
+			return nil, err
+		}
+		return patchSet(doc, pp, value, true, NewPP())
+
+	case "test":
+		current, err := patchGet(doc, pp, NewPP())
+		if err != nil {
+			return nil, err
+		}
+		if !patchEqual(current, value) {
+			return nil, fmt.Errorf("test failed, value at %q doesn't match",
+				pp.Pointer())
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown \"op\" value %q", opName)
+}
+
+// PropPathFromPointer converts an RFC 6901 JSON Pointer (e.g. "/labels/a")
+// into a PropPath. Since a Pointer doesn't say whether a part is a map key
+// or an array index, all parts are map keys and it's up to the caller to
+// treat them as indexes when the data is an array.
+func PropPathFromPointer(ptr string) (*PropPath, error) {
+	pp := NewPP()
+	if ptr == "" {
+		return pp, nil
+	}
+	if ptr[0] != '/' {
+		return nil, fmt.Errorf("path %q must start with \"/\"", ptr)
+	}
+	for _, part := range strings.Split(ptr[1:], "/") {
+		part = strings.ReplaceAll(part, "~1", "/")
+		part = strings.ReplaceAll(part, "~0", "~")
+		pp = pp.P(part)
+	}
+	return pp, nil
+}
+
+// Pointer returns the PropPath as an RFC 6901 JSON Pointer
+func (pp *PropPath) Pointer() string {
+	res := strings.Builder{}
+	for _, part := range pp.Parts {
+		text := part.Text
+		if part.Index >= 0 {
+			text = strconv.Itoa(part.Index)
+		}
+		text = strings.ReplaceAll(text, "~", "~0")
+		text = strings.ReplaceAll(text, "/", "~1")
+		res.WriteString("/" + text)
+	}
+	return res.String()
+}
+
+// Convert 'part' into an index of an array of length 'l'. 'allowEnd'
+// means the index can be 'l' (or "-"), which is used for appending.
+func patchIndex(part string, l int, allowEnd bool, prev *PropPath) (int, error) {
+	if part == "-" && allowEnd {
+		return l, nil
+	}
+	i, err := strconv.Atoi(part)
+	if err != nil || strings.Trim(part, "0123456789") != "" ||
+		(part != "0" && part[0] == '0') {
+		return 0, fmt.Errorf("%q isn't a valid array index",
+			prev.P(part).Pointer())
+	}
+	if i > l || (i == l && !allowEnd) {
+		return 0, fmt.Errorf("array index %q is out of bounds",
+			prev.P(part).Pointer())
+	}
+	return i, nil
+}
+
+func patchGet(doc any, pp *PropPath, prev *PropPath) (any, error) {
+	if pp.Len() == 0 {
+		return doc, nil
+	}
+
+	top := pp.Top()
+	switch node := doc.(type) {
+	case *OrderedMap:
+		child, ok := node.Values[top]
+		if !ok {
+			return nil, fmt.Errorf("%q doesn't exist", prev.P(top).Pointer())
+		}
+		return patchGet(child, pp.Next(), prev.P(top))
+	case []any:
+		i, err := patchIndex(top, len(node), false, prev)
+		if err != nil {
+			return nil, err
+		}
+		return patchGet(node[i], pp.Next(), prev.P(top))
+	}
+	return nil, fmt.Errorf("%q doesn't exist", prev.P(top).Pointer())
+}
+
+// Sets the value at 'pp' and returns the (possibly new) 'doc'. If 'insert'
+// is true then this is an "add" (inserting into arrays, and creating map
+// keys as needed), otherwise it's a "replace" and 'pp' must already exist.
+func patchSet(doc any, pp *PropPath, val any, insert bool, prev *PropPath) (any, error) {
+	if pp.Len() == 0 {
+		return val, nil
+	}
+
+	top := pp.Top()
+	last := pp.Len() == 1
+
+	switch node := doc.(type) {
+	case *OrderedMap:
+		child, ok := node.Values[top]
+		if !ok && (!last || !insert) {
+			return nil, fmt.Errorf("%q doesn't exist", prev.P(top).Pointer())
+		}
+		child, err := patchSet(child, pp.Next(), val, insert, prev.P(top))
+		if err != nil {
+			return nil, err
+		}
+		omSet(node, top, child)
+		return node, nil
+
+	case []any:
+		i, err := patchIndex(top, len(node), last && insert, prev)
+		if err != nil {
+			return nil, err
+		}
+		if last && insert {
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = val
+			return node, nil
+		}
+		node[i], err = patchSet(node[i], pp.Next(), val, insert, prev.P(top))
+		if err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+	return nil, fmt.Errorf("%q doesn't exist", prev.P(top).Pointer())
+}
+
+// Removes the value at 'pp' and returns the (possibly new) 'doc' as well
+// as the value that was removed.
+func patchRemove(doc any, pp *PropPath, prev *PropPath) (any, any, error) {
+	if pp.Len() == 0 {
+		return nil, nil, fmt.Errorf("the entire document can't be removed")
+	}
+
+	top := pp.Top()
+	last := pp.Len() == 1
+
+	switch node := doc.(type) {
+	case *OrderedMap:
+		child, ok := node.Values[top]
+		if !ok {
+			return nil, nil, fmt.Errorf("%q doesn't exist",
+				prev.P(top).Pointer())
+		}
+		if last {
+			omDelete(node, top)
+			return node, child, nil
+		}
+		child, removed, err := patchRemove(child, pp.Next(), prev.P(top))
+		if err != nil {
+			return nil, nil, err
+		}
+		node.Values[top] = child
+		return node, removed, nil
+
+	case []any:
+		i, err := patchIndex(top, len(node), false, prev)
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+		child, removed, err := patchRemove(node[i], pp.Next(), prev.P(top))
+		if err != nil {
+			return nil, nil, err
+		}
+		node[i] = child
+		return node, removed, nil
+	}
+	return nil, nil, fmt.Errorf("%q doesn't exist", prev.P(top).Pointer())
+}
+
+// Per RFC 6902 the order of map keys doesn't matter, and numbers are
+// compared by value
+func patchEqual(a, b any) bool {
+	switch aVal := a.(type) {
+	case *OrderedMap:
+		bVal, ok := b.(*OrderedMap)
+		if !ok || len(aVal.Values) != len(bVal.Values) {
+			return false
+		}
+		for k, v := range aVal.Values {
+			if bv, ok := bVal.Values[k]; !ok || !patchEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bVal, ok := b.([]any)
+		if !ok || len(aVal) != len(bVal) {
+			return false
+		}
+		for i := range aVal {
+			if !patchEqual(aVal[i], bVal[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bVal, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		aF, aErr := aVal.Float64()
+		bF, bErr := bVal.Float64()
+		if aErr != nil || bErr != nil {
+			return aVal == bVal
+		}
+		return aF == bF
+	}
+	return a == b
+}
+
+func omSet(om *OrderedMap, key string, val any) {
+	if _, ok := om.Values[key]; !ok {
+		om.Keys = append(om.Keys, key)
+	}
+	om.Values[key] = val
+}
+
+func omDelete(om *OrderedMap, key string) {
+	if _, ok := om.Values[key]; !ok {
+		return
+	}
+	delete(om.Values, key)
+	for i, k := range om.Keys {
+		if k == key {
+			om.Keys = append(om.Keys[:i], om.Keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Make sure 'buf' is a JSON object, used to verify the results of a patch
+// before we try to use it
+func IsJSONObject(buf []byte) bool {
+	buf = bytes.TrimSpace(buf)
+	return len(buf) > 0 && buf[0] == '{' && json.Valid(buf)
+}
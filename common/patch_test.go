@@ -0,0 +1,150 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		exp   string
+	}{
+		// Mostly from RFC 6902's appendix
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`,
+			`{"foo":"bar","baz":"qux"}`},
+		{`{"foo":["bar","baz"]}`,
+			`[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			`{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc"]}]`,
+			`{"foo":["bar",["abc"]]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`,
+			`{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`,
+			`{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`,
+			`[{"op":"replace","path":"/baz","value":"boo"}]`,
+			`{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			`[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`,
+			`[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			`{"foo":["all","cows","eat","grass"]}`},
+		{`{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`,
+			`{"a":{"b":1},"c":{"b":1}}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[
+			{"op":"test","path":"/baz","value":"qux"},
+			{"op":"test","path":"/foo/1","value":2.0}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`,
+			`JSON patch operation #0: test failed, value at "/baz" ` +
+				`doesn't match`},
+		{`{"m":{"a":1,"b":2}}`,
+			`[{"op":"test","path":"/m","value":{"b":2,"a":1}}]`,
+			`{"m":{"a":1,"b":2}}`},
+		{`{"/":1,"~":2}`, `[{"op":"remove","path":"/~1"},` +
+			`{"op":"replace","path":"/~0","value":3}]`, `{"~":3}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"","value":{"a":null}}]`,
+			`{"a":null}`},
+
+		// Errors
+		{`{}`, `{"op":"add"}`, `A JSON patch must be an array of operations`},
+		{`{}`, `[{"path":"/a"}]`, `JSON patch operation #0: missing "op"`},
+		{`{}`, `[{"op":"foo","path":"/a"}]`,
+			`JSON patch operation #0: unknown "op" value "foo"`},
+		{`{}`, `[{"op":"add","path":"a","value":1}]`,
+			`JSON patch operation #0: path "a" must start with "/"`},
+		{`{}`, `[{"op":"add","path":"/a"}]`,
+			`JSON patch operation #0: missing "value"`},
+		{`{}`, `[{"op":"remove","path":"/a"}]`,
+			`JSON patch operation #0: "/a" doesn't exist`},
+		{`{}`, `[{"op":"replace","path":"/a","value":1}]`,
+			`JSON patch operation #0: "/a" doesn't exist`},
+		{`{}`, `[{"op":"add","path":"/a/b","value":1}]`,
+			`JSON patch operation #0: "/a" doesn't exist`},
+		{`{"a":[1]}`, `[{"op":"add","path":"/a/2","value":1}]`,
+			`JSON patch operation #0: array index "/a/2" is out of bounds`},
+		{`{"a":[1]}`, `[{"op":"remove","path":"/a/01"}]`,
+			`JSON patch operation #0: "/a/01" isn't a valid array index`},
+		{`{"a":{"b":1}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`,
+			`JSON patch operation #0: can't move "/a" into one of its ` +
+				`children`},
+		// Atomic - 1st op is tossed if the 2nd fails
+		{`{"a":1}`, `[{"op":"remove","path":"/a"},` +
+			`{"op":"test","path":"/a","value":1}]`,
+			`JSON patch operation #1: "/a" doesn't exist`},
+	}
+
+	for _, test := range tests {
+		res, err := ApplyJSONPatch([]byte(test.doc), []byte(test.patch))
+		got := string(res)
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.exp {
+			t.Errorf("Doc: %s\nPatch: %s\nExp: %s\nGot: %s", test.doc,
+				test.patch, test.exp, got)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		exp   string
+	}{
+		// From RFC 7396's appendix
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+
+		// Order of existing keys is kept
+		{`{"z":1,"m":{"y":1,"b":2},"a":3}`, `{"m":{"b":null,"c":4},"z":5}`,
+			`{"z":5,"m":{"y":1,"c":4},"a":3}`},
+
+		{`{}`, `{"a":`, `Error parsing merge patch: EOF`},
+	}
+
+	for _, test := range tests {
+		res, err := ApplyMergePatch([]byte(test.doc), []byte(test.patch))
+		got := string(res)
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.exp {
+			t.Errorf("Doc: %s\nPatch: %s\nExp: %s\nGot: %s", test.doc,
+				test.patch, test.exp, got)
+		}
+	}
+}
+
+func TestPatchMediaType(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"", ""},
+		{"application/json", ""},
+		{"application/json-patch+json", JSON_PATCH_MEDIATYPE},
+		{"application/merge-patch+json; charset=utf-8", MERGE_PATCH_MEDIATYPE},
+	}
+
+	for _, test := range tests {
+		if got := PatchMediaType(test.in); got != test.exp {
+			t.Errorf("PatchMediaType(%q) exp: %q got: %q", test.in, test.exp,
+				got)
+		}
+	}
+}
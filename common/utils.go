@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
@@ -880,6 +881,87 @@ func ArrayContainsAnyCase(strs []string, needle string) bool {
 	return false
 }
 
+// Compare two semantic version strings, returning -1, 0 or 1. A leading "v"
+// and missing minor/patch numbers (e.g. "v1.2") are allowed. Any "+build"
+// suffix is ignored. Strings that aren't semvers sort before those that
+// are, and are compared as plain strings.
+func CompareSemVer(a, b string) int {
+	aNums, aPre, aOK := parseSemVer(a)
+	bNums, bPre, bOK := parseSemVer(b)
+
+	if !aOK || !bOK {
+		if aOK != bOK {
+			if aOK {
+				return 1
+			}
+			return -1
+		}
+		return strings.Compare(a, b)
+	}
+
+	for i := range aNums {
+		if aNums[i] != bNums[i] {
+			return cmp.Compare(aNums[i], bNums[i])
+		}
+	}
+
+	// A pre-release is older than the release itself
+	if len(aPre) == 0 || len(bPre) == 0 {
+		return cmp.Compare(len(bPre), len(aPre))
+	}
+
+	for i := 0; i < len(aPre) && i < len(bPre); i++ {
+		aNum, aErr := strconv.ParseUint(aPre[i], 10, 64)
+		bNum, bErr := strconv.ParseUint(bPre[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				return cmp.Compare(aNum, bNum)
+			}
+		case aErr == nil: // Numeric ids are older than alphanumeric ones
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aPre[i], bPre[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	return cmp.Compare(len(aPre), len(bPre))
+}
+
+func parseSemVer(str string) ([3]uint64, []string, bool) {
+	nums := [3]uint64{}
+
+	str = strings.TrimPrefix(str, "v")
+	str, _, _ = strings.Cut(str, "+")
+	str, pre, hasPre := strings.Cut(str, "-")
+
+	parts := strings.Split(str, ".")
+	if len(parts) > 3 {
+		return nums, nil, false
+	}
+	for i, part := range parts {
+		num, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || (len(part) > 1 && part[0] == '0') {
+			return nums, nil, false
+		}
+		nums[i] = num
+	}
+
+	preIDs := []string(nil)
+	if hasPre {
+		preIDs = strings.Split(pre, ".")
+		for _, id := range preIDs {
+			if id == "" {
+				return nums, nil, false
+			}
+		}
+	}
+	return nums, preIDs, true
+}
+
 // Convert a string into a unique MD5 string - basically just for cases
 // where we want to create a tiny URL
 func MD5(str string) string {
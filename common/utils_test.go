@@ -401,6 +401,46 @@ func TestMatch(t *testing.T) {
 	}
 }
 
+func TestCompareSemVer(t *testing.T) {
+	type Test struct {
+		A      string
+		B      string
+		Result int
+	}
+
+	tests := []Test{
+		{"1.0.0", "1.0.0", 0},
+		{"1", "1.0.0", 0},
+		{"v1.2", "1.2.0", 0},
+		{"1.0.0+abc", "1.0.0+def", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"1.2.0", "1.10.0", -1},
+		{"1.0.10", "1.0.9", 1},
+		{"9", "10", -1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+
+		// Non-semvers are older than semvers
+		{"abc", "0.0.1", -1},
+		{"1.0.0.0", "0.0.1", -1},
+		{"01.0", "0.0.1", -1},
+		{"1.0.0-", "0.0.1", -1},
+		{"abc", "abd", -1},
+	}
+
+	for _, test := range tests {
+		if res := CompareSemVer(test.A, test.B); res != test.Result {
+			t.Fatalf("A: %q vs B: %q Got: %d", test.A, test.B, res)
+		}
+		if res := CompareSemVer(test.B, test.A); res != -test.Result {
+			t.Fatalf("B: %q vs A: %q Got: %d", test.B, test.A, res)
+		}
+	}
+}
+
 func TestMakeShort(t *testing.T) {
 	tests := []struct {
 		in  []byte
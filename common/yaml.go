@@ -0,0 +1,171 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsYAMLMediaType returns true if the HTTP Content-Type (or Accept) value
+// is one of the commonly used YAML media types. Any parameters
+// (e.g. charset) are ignored.
+func IsYAMLMediaType(mediaType string) bool {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		mt = strings.TrimSpace(strings.ToLower(mediaType))
+	}
+	switch mt {
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return true
+	}
+	return false
+}
+
+// AcceptsYAML returns true if the HTTP Accept header value lists a YAML
+// media type ahead of any JSON one. We don't bother with q-values, the
+// first of the two that's mentioned wins.
+func AcceptsYAML(accept string) bool {
+	for _, mt := range strings.Split(accept, ",") {
+		if IsYAMLMediaType(mt) {
+			return true
+		}
+		mt, _, _ = strings.Cut(mt, ";")
+		mt = strings.TrimSpace(strings.ToLower(mt))
+		if mt == "application/json" || strings.HasSuffix(mt, "+json") {
+			return false
+		}
+	}
+	return false
+}
+
+// JSONToYAML converts a JSON []byte into its YAML equivalent. Since JSON
+// is a subset of YAML we let the YAML parser do the work and then just
+// remove any of the JSON-isms (quoted strings, flow style) so that the
+// output looks like a normal block-style YAML doc. The order of the
+// map keys is preserved.
+func JSONToYAML(data []byte) ([]byte, error) {
+	node := yaml.Node{}
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	if node.Kind == 0 {
+		return nil, fmt.Errorf("No JSON to convert")
+	}
+
+	var clearStyle func(n *yaml.Node)
+	clearStyle = func(n *yaml.Node) {
+		n.Style = 0
+		for _, child := range n.Content {
+			clearStyle(child)
+		}
+	}
+	clearStyle(&node)
+
+	buf := bytes.Buffer{}
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// YAMLToJSON converts a YAML []byte into (compact) JSON. Only the first
+// document in the stream is used. The order of the map keys is preserved,
+// which is important for things like the model source.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	node := yaml.Node{}
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("Error parsing YAML: %s", err)
+	}
+	if node.Kind == 0 {
+		return nil, fmt.Errorf("Error parsing YAML: empty document")
+	}
+
+	obj, err := yamlNodeToObject(&node)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
+func yamlNodeToObject(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+		return yamlNodeToObject(node.Content[0])
+
+	case yaml.AliasNode:
+		return yamlNodeToObject(node.Alias)
+
+	case yaml.MappingNode:
+		ordered := &OrderedMap{Values: map[string]any{}}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode := node.Content[i]
+			if keyNode.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("Error parsing YAML: line %d: map "+
+					"keys must be strings", keyNode.Line)
+			}
+			key := keyNode.Value
+			if _, ok := ordered.Values[key]; !ok {
+				ordered.Keys = append(ordered.Keys, key)
+			}
+			val, err := yamlNodeToObject(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			ordered.Values[key] = val
+		}
+		return ordered, nil
+
+	case yaml.SequenceNode:
+		arr := []any{}
+		for _, child := range node.Content {
+			val, err := yamlNodeToObject(child)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, val)
+		}
+		return arr, nil
+
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return nil, nil
+		case "!!int", "!!float":
+			// Keep the number exactly as written when it's valid JSON
+			num := json.Number(node.Value)
+			if _, err := num.Float64(); err == nil && json.Valid([]byte(node.Value)) {
+				return num, nil
+			}
+			val := any(nil)
+			if err := node.Decode(&val); err != nil {
+				return nil, fmt.Errorf("Error parsing YAML: line %d: %s",
+					node.Line, err)
+			}
+			return val, nil
+		case "!!bool":
+			val := false
+			if err := node.Decode(&val); err != nil {
+				return nil, fmt.Errorf("Error parsing YAML: line %d: %s",
+					node.Line, err)
+			}
+			return val, nil
+		default:
+			return node.Value, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Error parsing YAML: line %d: unsupported node",
+		node.Line)
+}
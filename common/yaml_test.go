@@ -0,0 +1,89 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestJSONToYAML(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{in: `{}`, exp: "{}\n"},
+		{in: `{"zoo":"zop","aoo":"aop"}`, exp: "zoo: zop\naoo: aop\n"},
+		{in: `{"a":{"z":1,"b":[true,null,"x"]},"c":[]}`,
+			exp: "a:\n  z: 1\n  b:\n    - true\n    - null\n    - x\nc: []\n"},
+		// Strings that look like other types must stay strings
+		{in: `{"a":"5","b":"true","c":"null","d":""}`,
+			exp: "a: \"5\"\nb: \"true\"\nc: \"null\"\nd: \"\"\n"},
+		{in: `{"a":"line1\nline2"}`, exp: "a: |-\n  line1\n  line2\n"},
+		{in: `{"a":`, exp: "Err"},
+	}
+
+	for _, test := range tests {
+		out, err := JSONToYAML([]byte(test.in))
+		got := string(out)
+		if err != nil {
+			got = "Err"
+		}
+		if got != test.exp {
+			t.Errorf("In: %s\nExp:\n%s\nGot:\n%s", test.in, test.exp, got)
+		}
+	}
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{in: "{}", exp: `{}`},
+		{in: "zoo: zop\naoo: aop\n", exp: `{"zoo":"zop","aoo":"aop"}`},
+		{in: "a:\n  z: 1\n  b:\n  - true\n  - ~\n  - x\nc: []\n",
+			exp: `{"a":{"z":1,"b":[true,null,"x"]},"c":[]}`},
+		{in: "a: \"5\"\nb: 'true'\nc: 1.50\nd: 0x10\n",
+			exp: `{"a":"5","b":"true","c":1.50,"d":16}`},
+		{in: "base: &b\n  x: 1\nother: *b\n",
+			exp: `{"base":{"x":1},"other":{"x":1}}`},
+		{in: "a: |\n  line1\n  line2\n", exp: `{"a":"line1\nline2\n"}`},
+		// JSON is valid YAML too
+		{in: `{"b":2,"a":[1,2]}`, exp: `{"b":2,"a":[1,2]}`},
+		{in: "? [a]\n: b\n", exp: "Err"},
+		{in: "a: [", exp: "Err"},
+		{in: "", exp: "Err"},
+	}
+
+	for _, test := range tests {
+		out, err := YAMLToJSON([]byte(test.in))
+		got := string(out)
+		if err != nil {
+			got = "Err"
+		}
+		if got != test.exp {
+			t.Errorf("In: %s\nExp: %s\nGot: %s", test.in, test.exp, got)
+		}
+	}
+}
+
+func TestAcceptsYAML(t *testing.T) {
+	tests := []struct {
+		in  string
+		exp bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"application/json", false},
+		{"application/yaml", true},
+		{"application/x-yaml; charset=utf-8", true},
+		{"text/yaml", true},
+		{"application/json, application/yaml", false},
+		{"application/yaml, application/json", true},
+		{"text/html, application/yaml;q=0.9", true},
+	}
+
+	for _, test := range tests {
+		if got := AcceptsYAML(test.in); got != test.exp {
+			t.Errorf("AcceptsYAML(%q) exp: %v got: %v", test.in, test.exp, got)
+		}
+	}
+}
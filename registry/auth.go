@@ -0,0 +1,494 @@
+package registry
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Role int
+
+const (
+	ROLE_NONE Role = iota // No access, or auth isn't enabled
+	ROLE_READER
+	ROLE_WRITER
+	ROLE_ADMIN
+)
+
+var roleNames = []string{"none", "reader", "writer", "admin"}
+
+func (r Role) String() string {
+	if r < ROLE_NONE || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+func ParseRole(str string) (Role, error) {
+	for i, name := range roleNames {
+		if i != int(ROLE_NONE) && name == str {
+			return Role(i), nil
+		}
+	}
+	return ROLE_NONE, fmt.Errorf("Unknown role %q, must be one of: "+
+		"reader, writer, admin", str)
+}
+
+// A RoleBinding grants a Role, optionally scoped to a single Registry
+// and/or a single Group type. An empty scope means "all of them".
+// Syntax: ROLE[:REGISTRY[/GROUPTYPE]], "*" can be used as a wildcard.
+type RoleBinding struct {
+	Role      Role
+	Registry  string
+	GroupType string
+}
+
+func ParseRoleBinding(str string) (*RoleBinding, error) {
+	roleStr, scope, _ := strings.Cut(strings.TrimSpace(str), ":")
+	role, err := ParseRole(roleStr)
+	if err != nil {
+		return nil, err
+	}
+
+	regID, groupType, _ := strings.Cut(scope, "/")
+	if regID == "*" {
+		regID = ""
+	}
+	if groupType == "*" {
+		groupType = ""
+	}
+
+	return &RoleBinding{
+		Role:      role,
+		Registry:  regID,
+		GroupType: groupType,
+	}, nil
+}
+
+// Note that a binding scoped to a Group type only applies to requests
+// for that Group type, not to ones for the Registry itself
+func (rb *RoleBinding) Matches(regID string, groupType string) bool {
+	if rb.Registry != "" && rb.Registry != regID {
+		return false
+	}
+	if rb.GroupType != "" && rb.GroupType != groupType {
+		return false
+	}
+	return true
+}
+
+// An Authenticator determines who the client is.
+type Authenticator interface {
+	// Returns the name of the user, "" if the request doesn't have any
+	// credentials this Authenticator knows about, or an error if it does
+	// but they're not valid
+	Authenticate(r *http.Request) (string, error)
+
+	// Value for the WWW-Authenticate header, "" if there isn't one
+	Challenge() string
+}
+
+// Static bearer tokens: "Authorization: Bearer TOKEN"
+type TokenAuthenticator struct {
+	Tokens map[string]string // token -> user
+}
+
+func (ta *TokenAuthenticator) Authenticate(r *http.Request) (string, error) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", nil
+	}
+
+	token = strings.TrimSpace(token)
+	for t, user := range ta.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return user, nil
+		}
+	}
+	return "", fmt.Errorf("Invalid bearer token")
+}
+
+func (ta *TokenAuthenticator) Challenge() string {
+	return `Bearer realm="xRegistry"`
+}
+
+// HTTP basic auth, verified against the hashes from an htpasswd file.
+// Only bcrypt (htpasswd -B) and SHA1 (htpasswd -s) hashes are supported.
+type BasicAuthenticator struct {
+	Users map[string]string // user -> hash
+}
+
+func LoadHtpasswd(file string) (*BasicAuthenticator, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ba := &BasicAuthenticator{Users: map[string]string{}}
+
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("%s: line %d: missing \"USER:HASH\"",
+				file, lineNum)
+		}
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("%s: line %d: unsupported hash for user "+
+				"%q, must be bcrypt or SHA1", file, lineNum, user)
+		}
+		ba.Users[user] = hash
+	}
+
+	return ba, scanner.Err()
+}
+
+func (ba *BasicAuthenticator) Authenticate(r *http.Request) (string, error) {
+	user, pwd, ok := r.BasicAuth()
+	if !ok {
+		return "", nil
+	}
+
+	hash, ok := ba.Users[user]
+	if !ok {
+		return "", fmt.Errorf("Invalid user or password")
+	}
+
+	if strings.HasPrefix(hash, "{SHA}") {
+		sum := sha1.Sum([]byte(pwd))
+		calc := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		ok = subtle.ConstantTimeCompare([]byte(calc), []byte(hash)) == 1
+	} else {
+		ok = bcrypt.CompareHashAndPassword([]byte(hash), []byte(pwd)) == nil
+	}
+
+	if !ok {
+		return "", fmt.Errorf("Invalid user or password")
+	}
+	return user, nil
+}
+
+func (ba *BasicAuthenticator) Challenge() string {
+	return `Basic realm="xRegistry"`
+}
+
+// Trust a header (e.g. X-Forwarded-User) set by an authenticating proxy
+// in front of us. The header is ignored unless the request came directly
+// from one of the Trusted addresses.
+type ProxyAuthenticator struct {
+	Header  string
+	Trusted []*net.IPNet
+}
+
+func NewProxyAuthenticator(header string, trusted []string) (*ProxyAuthenticator, error) {
+	if header == "" {
+		return nil, fmt.Errorf("Missing the proxy's user header name")
+	}
+
+	pa := &ProxyAuthenticator{Header: header}
+
+	for _, addr := range trusted {
+		if !strings.Contains(addr, "/") {
+			if strings.Contains(addr, ":") {
+				addr += "/128"
+			} else {
+				addr += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid trusted proxy address %q: %s",
+				addr, err)
+		}
+		pa.Trusted = append(pa.Trusted, ipNet)
+	}
+
+	if len(pa.Trusted) == 0 {
+		return nil, fmt.Errorf("Missing the list of trusted proxy addresses")
+	}
+
+	return pa, nil
+}
+
+func (pa *ProxyAuthenticator) Authenticate(r *http.Request) (string, error) {
+	user := strings.TrimSpace(r.Header.Get(pa.Header))
+	if user == "" {
+		return "", nil
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+
+	for _, ipNet := range pa.Trusted {
+		if ip != nil && ipNet.Contains(ip) {
+			return user, nil
+		}
+	}
+
+	log.VPrintf(2, "Ignoring %q header from untrusted client: %s",
+		pa.Header, r.RemoteAddr)
+	return "", nil
+}
+
+func (pa *ProxyAuthenticator) Challenge() string {
+	return ""
+}
+
+// Auth holds the server's authentication and authorization config.
+// A nil *Auth means auth is disabled and everyone can do anything.
+type Auth struct {
+	Authenticators []Authenticator
+
+	Users     map[string][]*RoleBinding // "*" means any authenticated user
+	Anonymous []*RoleBinding            // For clients w/o credentials
+}
+
+// The on-disk (JSON) version of Auth. See docs/installation.md
+type AuthConfig struct {
+	Tokens   map[string]string `json:"tokens,omitempty"`
+	Htpasswd string            `json:"htpasswd,omitempty"`
+	Proxy    *AuthProxyConfig  `json:"proxy,omitempty"`
+
+	Roles     map[string][]string `json:"roles,omitempty"`
+	Anonymous []string            `json:"anonymous,omitempty"`
+}
+
+type AuthProxyConfig struct {
+	Header  string   `json:"header,omitempty"`
+	Trusted []string `json:"trusted,omitempty"` // IPs or CIDRs
+}
+
+func LoadAuth(file string) (*Auth, error) {
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config := AuthConfig{}
+	if err = Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("Error parsing %q: %s", file, err)
+	}
+
+	// Relative htpasswd files are relative to the config file
+	if config.Htpasswd != "" && !filepath.IsAbs(config.Htpasswd) {
+		config.Htpasswd = filepath.Join(filepath.Dir(file), config.Htpasswd)
+	}
+
+	auth, err := NewAuth(&config)
+	if err != nil {
+		return nil, fmt.Errorf("Error in %q: %s", file, err)
+	}
+	return auth, nil
+}
+
+func NewAuth(config *AuthConfig) (*Auth, error) {
+	auth := &Auth{
+		Users: map[string][]*RoleBinding{},
+	}
+
+	if len(config.Tokens) > 0 {
+		auth.Authenticators = append(auth.Authenticators,
+			&TokenAuthenticator{Tokens: config.Tokens})
+	}
+
+	if config.Htpasswd != "" {
+		ba, err := LoadHtpasswd(config.Htpasswd)
+		if err != nil {
+			return nil, err
+		}
+		auth.Authenticators = append(auth.Authenticators, ba)
+	}
+
+	if config.Proxy != nil {
+		pa, err := NewProxyAuthenticator(config.Proxy.Header,
+			config.Proxy.Trusted)
+		if err != nil {
+			return nil, err
+		}
+		auth.Authenticators = append(auth.Authenticators, pa)
+	}
+
+	for user, strs := range config.Roles {
+		for _, str := range strs {
+			rb, err := ParseRoleBinding(str)
+			if err != nil {
+				return nil, fmt.Errorf("Role for user %q: %s", user, err)
+			}
+			auth.Users[user] = append(auth.Users[user], rb)
+		}
+	}
+
+	for _, str := range config.Anonymous {
+		rb, err := ParseRoleBinding(str)
+		if err != nil {
+			return nil, fmt.Errorf("Anonymous role: %s", err)
+		}
+		auth.Anonymous = append(auth.Anonymous, rb)
+	}
+
+	if len(auth.Authenticators) == 0 && len(auth.Anonymous) == 0 {
+		return nil, fmt.Errorf("No authenticators or anonymous roles " +
+			"defined, nothing would be allowed")
+	}
+
+	return auth, nil
+}
+
+// Returns the authenticated user, "" for anonymous clients
+func (a *Auth) Authenticate(r *http.Request) (string, error) {
+	for _, authenticator := range a.Authenticators {
+		user, err := authenticator.Authenticate(r)
+		if err != nil || user != "" {
+			return user, err
+		}
+	}
+	return "", nil
+}
+
+func (a *Auth) bindings(user string) []*RoleBinding {
+	if user == "" {
+		return a.Anonymous
+	}
+	res := append([]*RoleBinding{}, a.Anonymous...)
+	res = append(res, a.Users["*"]...)
+	return append(res, a.Users[user]...)
+}
+
+// The highest Role 'user' has for the Registry/Group type
+func (a *Auth) GetRole(user string, regID string, groupType string) Role {
+	role := ROLE_NONE
+	for _, rb := range a.bindings(user) {
+		if rb.Role > role && rb.Matches(regID, groupType) {
+			role = rb.Role
+		}
+	}
+	return role
+}
+
+// True if 'user' can do anything at all, in any scope
+func (a *Auth) HasAnyRole(user string) bool {
+	return len(a.bindings(user)) > 0
+}
+
+func (a *Auth) AddChallenges(w http.ResponseWriter) {
+	for _, authenticator := range a.Authenticators {
+		if c := authenticator.Challenge(); c != "" {
+			w.Header().Add("WWW-Authenticate", c)
+		}
+	}
+}
+
+// GET /metrics covers all Registries, and can include SQL text (see
+// XR_TIMING), so only clients that can read everything can see it.
+// Returns false, after writing the error response, if they can't.
+func (a *Auth) AuthorizeMetrics(w http.ResponseWriter, r *http.Request) bool {
+	user, err := a.Authenticate(r)
+	if err == nil && a.GetRole(user, "", "") < ROLE_READER {
+		if user != "" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprintf(w, "User %q is not allowed to %s %q\n", user,
+				r.Method, r.URL.Path)
+			return false
+		}
+		err = fmt.Errorf("Authentication required")
+	}
+	if err != nil {
+		a.AddChallenges(w)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(err.Error() + "\n"))
+		return false
+	}
+	return true
+}
+
+// Writes to the model or capabilities require an admin, other writes
+// require a writer and everything else only needs a reader
+func RequiredRole(info *RequestInfo) Role {
+	switch info.OriginalRequest.Method {
+	case "PUT", "POST", "PATCH", "DELETE":
+		if ArrayContains([]string{"model", "modelsource", "capabilities"},
+			info.RootPath) {
+			return ROLE_ADMIN
+		}
+		return ROLE_WRITER
+	}
+	return ROLE_READER
+}
+
+// Make sure 'user' is allowed to do what 'info' is asking for, and if so
+// save who they are, and their Role, in the Tx for later checks
+func (a *Auth) Authorize(info *RequestInfo, user string) error {
+	need := RequiredRole(info)
+	role := a.GetRole(user, info.Registry.UID, info.GroupType)
+
+	if role < need {
+		if user == "" {
+			info.StatusCode = http.StatusUnauthorized
+			a.AddChallenges(info.OriginalResponse)
+			return fmt.Errorf("Authentication required")
+		}
+		info.StatusCode = http.StatusForbidden
+		return fmt.Errorf("User %q is not allowed to %s %q",
+			user, info.OriginalRequest.Method, "/"+info.OriginalPath)
+	}
+
+	info.tx.User = user
+	info.tx.Role = role
+	info.tx.Auth = a
+	return nil
+}
+
+// True if the client is an admin, which means they can modify read-only
+// Resources. Never true when auth isn't enabled.
+func (tx *Tx) IsAdmin() bool {
+	return tx.Role == ROLE_ADMIN
+}
+
+// True if the client can read entities of 'groupType'. Used when a request
+// for one Group type ends up exposing data from another one, since the
+// authorization check only looked at the Group type in the URL.
+func (tx *Tx) CanRead(groupType string) bool {
+	if tx.Auth == nil {
+		return true
+	}
+	return tx.Auth.GetRole(tx.User, tx.Registry.UID, groupType) >= ROLE_READER
+}
+
+// Returned when the client isn't allowed to do something. Since the
+// check can happen deep in the processing of the request, where the caller
+// will probably assume a 400, ServeHTTP will turn it into a 403.
+type ForbiddenError struct {
+	Message string
+}
+
+func (fe *ForbiddenError) Error() string {
+	return fe.Message
+}
+
+// Returns an error if auth is enabled and the client isn't an admin
+func (tx *Tx) CheckAdmin(what string) error {
+	if tx.Role == ROLE_NONE || tx.Role == ROLE_ADMIN {
+		return nil
+	}
+	return &ForbiddenError{Message: "Only admins can change " + what}
+}
@@ -0,0 +1,328 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Where to store Resource documents. If nil they're saved in the DB (in
+// ResourceContents.Content), otherwise just their key (ContentHash) is.
+// See NewBlobStore
+var Blobs BlobStore
+
+// Text documents larger than this only have their first MAX_SEARCH_TEXT
+// bytes searchable via ?searchdocs
+var MAX_SEARCH_TEXT = 1024 * 1024
+
+// A BlobStore saves Resource documents outside of the DB. Blobs are
+// content-addressed, their key is the hex SHA-256 of their data (see
+// BlobKey), so the same document used by many Versions is only stored once.
+// Because of that, blobs aren't deleted along with their Versions. See
+// GCBlobs.
+type BlobStore interface {
+	String() string
+
+	Put(key string, data []byte) error     // No-op'ish if already there
+	Get(key string) (io.ReadCloser, error) // Error if not found
+	Delete(key string) error               // Not an error if not found
+	List() ([]*BlobInfo, error)            // All blobs in the store
+}
+
+type BlobInfo struct {
+	Key     string
+	ModTime time.Time
+}
+
+func BlobKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func IsBlobKey(key string) bool {
+	if len(key) != 2*sha256.Size {
+		return false
+	}
+	for _, ch := range key {
+		if !strings.ContainsRune("0123456789abcdef", ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// Create a BlobStore from 'spec', which is one of:
+//   - file:DIR or just DIR
+//   - s3://BUCKET[/PREFIX][?endpoint=URL&region=REGION]
+//
+// For S3 the credentials are taken from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN env vars. The region defaults
+// to AWS_REGION, or "us-east-1".
+func NewBlobStore(spec string) (BlobStore, error) {
+	if spec == "" {
+		return nil, fmt.Errorf("Missing blob store location")
+	}
+
+	if dir, ok := strings.CutPrefix(spec, "file:"); ok {
+		return NewFileBlobStore(dir)
+	}
+
+	if !strings.HasPrefix(spec, "s3://") {
+		if strings.Contains(spec, "://") {
+			return nil, fmt.Errorf("Unsupported blob store %q, must be "+
+				"a directory or an s3:// URL", spec)
+		}
+		return NewFileBlobStore(spec)
+	}
+
+	u, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid blob store URL %q: %s", spec, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("Missing bucket name in %q", spec)
+	}
+
+	region := u.Query().Get("region")
+	if region == "" {
+		region = EnvString("AWS_REGION", "us-east-1")
+	}
+
+	return NewS3BlobStore(u.Query().Get("endpoint"), region, u.Host,
+		strings.Trim(u.Path, "/"), os.Getenv("AWS_ACCESS_KEY_ID"),
+		os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN"))
+}
+
+// Save the document of the Version 'vSID'. Text docs are saved in the DB
+// as text too so that ?search can look at them.
+func saveContent(tx *Tx, vSID string, val any) error {
+	data := []byte(nil)
+	switch content := val.(type) {
+	case []byte:
+		data = content
+	case string:
+		data = []byte(content)
+	default:
+		return fmt.Errorf("Unexpected document type: %T", val)
+	}
+
+	text := any(nil)
+	if utf8.Valid(data) {
+		text = truncateUTF8(string(data), MAX_SEARCH_TEXT)
+	}
+
+	if Blobs == nil {
+		return DoOneTwo(tx, `
+            REPLACE INTO ResourceContents(VersionSID,Content,ContentHash,SearchText)
+            VALUES(?,?,NULL,?)`, vSID, data, text)
+	}
+
+	key := BlobKey(data)
+	if err := Blobs.Put(key, data); err != nil {
+		return fmt.Errorf("Error saving document: %s", err)
+	}
+	return DoOneTwo(tx, `
+        REPLACE INTO ResourceContents(VersionSID,Content,ContentHash,SearchText)
+        VALUES(?,NULL,?,?)`, vSID, key, text)
+}
+
+// Returns the document saved under 'contentID' (a Version's #contentid),
+// either from the DB or from the BlobStore. Returns nil if there isn't one.
+func openContent(tx *Tx, contentID any) (io.ReadCloser, error) {
+	results, err := Query(tx, `
+        SELECT Content,ContentHash FROM ResourceContents WHERE VersionSID=?`,
+		contentID)
+	defer results.Close()
+
+	if err != nil {
+		return nil, fmt.Errorf("Error finding contents %q: %s", contentID, err)
+	}
+
+	row := results.NextRow()
+	if row == nil {
+		// No data so just return
+		return nil, nil
+	}
+
+	if results.NextRow() != nil {
+		panic("too many results")
+	}
+
+	if key := NotNilString(row[1]); key != "" {
+		if Blobs == nil {
+			return nil, fmt.Errorf("Document %q is in a blob store but "+
+				"none is configured", key)
+		}
+		return Blobs.Get(key)
+	}
+
+	// Some DBs return a string if that's what was saved, or
+	// nil for an empty document
+	buf := []byte{}
+	switch content := (*(row[0])).(type) {
+	case string:
+		buf = []byte(content)
+	case []byte:
+		if content != nil {
+			buf = content
+		}
+	}
+	return io.NopCloser(bytes.NewReader(buf)), nil
+}
+
+// Returns the first 'max' bytes of 'str' w/o splitting a character
+func truncateUTF8(str string, max int) string {
+	if len(str) <= max {
+		return str
+	}
+	for max > 0 && !utf8.RuneStart(str[max]) {
+		max--
+	}
+	return str[:max]
+}
+
+// Move all of the documents that are still in the DB into 'store'. Returns
+// how many were moved. Each batch of documents is moved in its own Tx so
+// this can be safely stopped, and restarted, at any time.
+func MigrateBlobs(store BlobStore) (int, error) {
+	log.VPrintf(3, ">Enter: MigrateBlobs(%s)", store)
+	defer log.VPrintf(3, "<Exit: MigrateBlobs")
+
+	count := 0
+	for {
+		tx, err := NewTx()
+		if err != nil {
+			return count, err
+		}
+
+		results, err := Query(tx, `
+            SELECT VersionSID,Content FROM ResourceContents
+            WHERE ContentHash IS NULL LIMIT 100`)
+		if err != nil {
+			tx.Rollback()
+			return count, err
+		}
+
+		contents := map[string][]byte{}
+		for row := results.NextRow(); row != nil; row = results.NextRow() {
+			buf := []byte{}
+			switch content := (*(row[1])).(type) {
+			case string:
+				buf = []byte(content)
+			case []byte:
+				buf = content
+			}
+			contents[NotNilString(row[0])] = buf
+		}
+		results.Close()
+
+		if len(contents) == 0 {
+			return count, tx.Commit()
+		}
+
+		for vSID, buf := range contents {
+			key := BlobKey(buf)
+			if err = store.Put(key, buf); err == nil {
+				err = DoOne(tx, `
+                    UPDATE ResourceContents SET Content=NULL,ContentHash=?
+                    WHERE VersionSID=?`, key, vSID)
+			}
+			if err != nil {
+				tx.Rollback()
+				return count, fmt.Errorf("Error moving document of %q: %s",
+					vSID, err)
+			}
+		}
+
+		if err = tx.Commit(); err != nil {
+			return count, err
+		}
+		count += len(contents)
+		log.VPrintf(2, "Moved %d documents", count)
+	}
+}
+
+// Add the ResourceContents.ContentHash column to DBs created before
+// BlobStores existed, see dbUpgrades
+func upgradeBlobs(tx *Tx) error {
+	found, err := hasColumn(tx, "ResourceContents", "ContentHash")
+	if err != nil || found {
+		return err
+	}
+
+	log.Printf("Adding ResourceContents.ContentHash for BlobStores")
+	if DB_Dialect.Name() == "mysql" {
+		return Do(tx, `ALTER TABLE ResourceContents
+            ADD COLUMN ContentHash VARCHAR(64), ADD INDEX (ContentHash)`)
+	}
+
+	err = Do(tx, `ALTER TABLE ResourceContents ADD COLUMN ContentHash VARCHAR(64)`)
+	if err == nil {
+		err = Do(tx, `CREATE INDEX ResourceContentsHash
+            ON ResourceContents (ContentHash)`)
+	}
+	return err
+}
+
+// Delete the blobs in 'store' that are no longer used by any Version.
+// Blobs Put less than 'minAge' before we looked at the DB are skipped
+// since they might belong to a Version whose Tx hasn't been committed yet.
+// So 'minAge' needs to be longer than any Tx, plus any difference between
+// our clock and the store's. Returns the number deleted.
+func GCBlobs(store BlobStore, minAge time.Duration) (int, error) {
+	log.VPrintf(3, ">Enter: GCBlobs(%s)", store)
+	defer log.VPrintf(3, "<Exit: GCBlobs")
+
+	// A Put (even of an existing blob) updates its ModTime, so any blob
+	// that an uncommitted Tx is using will have a ModTime after this
+	cutoff := time.Now().Add(-minAge)
+
+	tx, err := NewTx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	results, err := Query(tx, `
+        SELECT DISTINCT ContentHash FROM ResourceContents
+        WHERE ContentHash IS NOT NULL`)
+	if err != nil {
+		return 0, err
+	}
+	used := map[string]bool{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		used[NotNilString(row[0])] = true
+	}
+	results.Close()
+
+	// List them after querying the DB so that we see the ModTimes of the
+	// blobs that were Put while we were looking
+	blobs, err := store.List()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, blob := range blobs {
+		if used[blob.Key] || blob.ModTime.After(cutoff) {
+			continue
+		}
+		log.VPrintf(2, "Deleting unused blob: %s", blob.Key)
+		if err := store.Delete(blob.Key); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
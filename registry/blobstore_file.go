@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// A BlobStore that saves each blob as a file under Dir. To avoid having
+// too many files in one dir they're spread across sub-dirs named after the
+// first 2 chars of their keys, e.g. DIR/ab/abcdef...
+type FileBlobStore struct {
+	Dir string
+}
+
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("Missing blob store directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating blob store dir %q: %s", dir,
+			err)
+	}
+	return &FileBlobStore{Dir: dir}, nil
+}
+
+func (fbs *FileBlobStore) String() string {
+	return "file:" + fbs.Dir
+}
+
+func (fbs *FileBlobStore) path(key string) (string, error) {
+	if !IsBlobKey(key) {
+		return "", fmt.Errorf("Invalid blob key %q", key)
+	}
+	return filepath.Join(fbs.Dir, key[:2], key), nil
+}
+
+func (fbs *FileBlobStore) Put(key string, data []byte) error {
+	path, err := fbs.path(key)
+	if err != nil {
+		return err
+	}
+
+	// Already there, but touch it so GCBlobs doesn't delete it out from
+	// under a Version that's about to start using it
+	now := time.Now()
+	if err = os.Chtimes(path, now, now); err == nil {
+		return nil
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so no one ever sees a partial blob
+	file, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+func (fbs *FileBlobStore) Get(key string) (io.ReadCloser, error) {
+	path, err := fbs.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("Blob %q not found", key)
+	}
+	return file, err
+}
+
+func (fbs *FileBlobStore) Delete(key string) error {
+	path, err := fbs.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (fbs *FileBlobStore) List() ([]*BlobInfo, error) {
+	blobs := []*BlobInfo{}
+
+	err := filepath.WalkDir(fbs.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip anything that isn't ours, e.g. temp files
+		if d.IsDir() || !IsBlobKey(d.Name()) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, &BlobInfo{
+			Key:     d.Name(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+
+	return blobs, err
+}
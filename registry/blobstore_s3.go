@@ -0,0 +1,343 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A BlobStore that saves each blob as an object in an S3 (compatible)
+// bucket, as PREFIX/KEY. Requests use path-style URLs and are signed with
+// AWS Signature Version 4, which is all that's needed to talk to most
+// S3 compatible services (e.g. MinIO).
+type S3BlobStore struct {
+	Endpoint     string // e.g. https://s3.us-east-1.amazonaws.com
+	Region       string
+	Bucket       string
+	Prefix       string // Optional, w/o the trailing "/"
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // Optional
+
+	client *http.Client
+}
+
+func NewS3BlobStore(endpoint, region, bucket, prefix, accessKey, secretKey, token string) (*S3BlobStore, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("Missing S3 bucket name")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("Missing S3 credentials, set " +
+			"AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, fmt.Errorf("Invalid S3 endpoint %q: %s", endpoint, err)
+	}
+
+	return &S3BlobStore{
+		Endpoint:     strings.TrimRight(endpoint, "/"),
+		Region:       region,
+		Bucket:       bucket,
+		Prefix:       strings.Trim(prefix, "/"),
+		AccessKey:    accessKey,
+		SecretKey:    secretKey,
+		SessionToken: token,
+
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s3 *S3BlobStore) String() string {
+	return "s3://" + strings.TrimRight(s3.Bucket+"/"+s3.Prefix, "/")
+}
+
+func (s3 *S3BlobStore) objectName(key string) string {
+	if s3.Prefix == "" {
+		return key
+	}
+	return s3.Prefix + "/" + key
+}
+
+func (s3 *S3BlobStore) Put(key string, data []byte) error {
+	if !IsBlobKey(key) {
+		return fmt.Errorf("Invalid blob key %q", key)
+	}
+
+	// If it's already there then just update its LastModified, so that
+	// GCBlobs doesn't delete it out from under us, by copying it onto
+	// itself rather than uploading it again
+	object := s3.objectName(key)
+	res, err := s3.do("PUT", object, nil, http.Header{
+		"X-Amz-Copy-Source":        {s3EscapePath(s3.Bucket + "/" + object)},
+		"X-Amz-Metadata-Directive": {"REPLACE"},
+	}, nil)
+	if err == nil {
+		// A copy can fail after it has sent a 200, see the CopyObject docs
+		msg, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return fmt.Errorf("Error talking to S3: %s", err)
+		}
+		if bytes.Contains(msg, []byte("<Error>")) {
+			return fmt.Errorf("S3 copy of %s failed: %s", object,
+				strings.TrimSpace(string(msg)))
+		}
+		return nil
+	}
+	if res == nil || res.StatusCode != http.StatusNotFound {
+		return err
+	}
+
+	res, err = s3.do("PUT", object, nil, nil, data)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s3 *S3BlobStore) Get(key string) (io.ReadCloser, error) {
+	if !IsBlobKey(key) {
+		return nil, fmt.Errorf("Invalid blob key %q", key)
+	}
+
+	res, err := s3.do("GET", s3.objectName(key), nil, nil, nil)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("Blob %q not found", key)
+		}
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s3 *S3BlobStore) Delete(key string) error {
+	if !IsBlobKey(key) {
+		return fmt.Errorf("Invalid blob key %q", key)
+	}
+
+	res, err := s3.do("DELETE", s3.objectName(key), nil, nil, nil)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s3 *S3BlobStore) List() ([]*BlobInfo, error) {
+	blobs := []*BlobInfo{}
+
+	prefix := ""
+	if s3.Prefix != "" {
+		prefix = s3.Prefix + "/"
+	}
+
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+
+	for {
+		res, err := s3.do("GET", "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		list := struct {
+			Contents []struct {
+				Key          string
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}{}
+		err = xml.NewDecoder(res.Body).Decode(&list)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing S3 list response: %s", err)
+		}
+
+		for _, obj := range list.Contents {
+			key := strings.TrimPrefix(obj.Key, prefix)
+			if !IsBlobKey(key) {
+				continue // Not one of ours
+			}
+			blobs = append(blobs, &BlobInfo{
+				Key:     key,
+				ModTime: obj.LastModified,
+			})
+		}
+
+		if !list.IsTruncated || list.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", list.NextContinuationToken)
+	}
+
+	return blobs, nil
+}
+
+// Send a signed request for 'object' in our bucket, with any extra
+// 'header's. Any non-2xx response is returned as an error, along with the
+// response (w/o its body).
+func (s3 *S3BlobStore) do(method, object string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	path := "/" + s3.Bucket
+	if object != "" {
+		path += "/" + object
+	}
+
+	escPath := s3EscapePath(path)
+	escQuery := s3CanonicalQuery(query)
+
+	u := s3.Endpoint + escPath
+	if escQuery != "" {
+		u += "?" + escQuery
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for name, vals := range header {
+		req.Header[name] = vals
+	}
+
+	s3.sign(req, escPath, escQuery, body, time.Now().UTC())
+
+	res, err := s3.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error talking to S3: %s", err)
+	}
+
+	if res.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		return res, fmt.Errorf("S3 %s %s failed(%d): %s", method, path,
+			res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	return res, nil
+}
+
+// Add the AWS Signature Version 4 "Authorization" header (and the headers
+// it depends on) to 'req'. 'path' and 'query' must be escaped exactly as
+// they are in req.URL. See
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s3 *S3BlobStore) sign(req *http.Request, path, query string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := s3Hash(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s3.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s3.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, vals := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(vals, ","))
+		}
+	}
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonHeaders := ""
+	for _, name := range names {
+		canonHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonRequest := strings.Join([]string{
+		req.Method,
+		path,
+		query,
+		canonHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s3.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		s3Hash([]byte(canonRequest))
+
+	key := []byte("AWS4" + s3.SecretKey)
+	for _, part := range []string{date, s3.Region, "s3", "aws4_request"} {
+		key = s3HMAC(key, part)
+	}
+	signature := hex.EncodeToString(s3HMAC(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+
+		s3.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+func s3Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// S3 wants everything except the "unreserved" chars escaped
+func s3Escape(str string) string {
+	res := strings.Builder{}
+	for _, ch := range []byte(str) {
+		if ('A' <= ch && ch <= 'Z') || ('a' <= ch && ch <= 'z') ||
+			('0' <= ch && ch <= '9') || strings.IndexByte("-_.~", ch) >= 0 {
+			res.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&res, "%%%02X", ch)
+		}
+	}
+	return res.String()
+}
+
+func s3EscapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = s3Escape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// Sorted by name, as required for the signature
+func s3CanonicalQuery(query url.Values) string {
+	names := []string{}
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := []string{}
+	for _, name := range names {
+		for _, val := range query[name] {
+			res = append(res, s3Escape(name)+"="+s3Escape(val))
+		}
+	}
+	return strings.Join(res, "&")
+}
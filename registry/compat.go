@@ -0,0 +1,1104 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// When a Resource's meta.compatibilityauthority is "server" we verify that
+// each Version's document is compatible with its ancestor(s), per the
+// meta.compatibility mode:
+//   backward - a Version can read data written with its ancestor
+//   forward  - a Version's ancestor can read data written with the Version
+//   full     - both
+// The "_transitive" variants check against all ancestors, not just the
+// immediate one. Only JSON Schema and Avro documents are supported.
+
+const (
+	COMPAT_JSONSCHEMA = "jsonschema"
+	COMPAT_AVRO       = "avro"
+)
+
+// Remember that 'v' was added or changed so EnsureCompatibility will
+// check it against its ancestors, and its descendants against it
+func (tx *Tx) CompatCheckVersion(v *Version) {
+	if tx.compatVersions == nil {
+		tx.compatVersions = map[string]map[string]bool{}
+	}
+	vIDs, ok := tx.compatVersions[v.Resource.DbSID]
+	if ok && vIDs == nil {
+		return // Already checking all of them
+	}
+	if vIDs == nil {
+		vIDs = map[string]bool{}
+		tx.compatVersions[v.Resource.DbSID] = vIDs
+	}
+	vIDs[v.UID] = true
+}
+
+// Remember that all of the Resource's Versions need to be checked by
+// EnsureCompatibility. Used when the "compatibility" settings change, or
+// when a Version is deleted or re-parented, since that changes which
+// Versions need to be compatible with each other.
+func (tx *Tx) CompatCheckAll(r *Resource) {
+	if tx.compatVersions == nil {
+		tx.compatVersions = map[string]map[string]bool{}
+	}
+	tx.compatVersions[r.DbSID] = nil
+}
+
+// Check the Versions that were added or changed in this Tx (see
+// CompatCheckVersion) against their ancestors, and any of their
+// descendants against them, or all of the Versions if the ancestor chain
+// or the "compatibility" settings changed (see CompatCheckAll).
+func (r *Resource) EnsureCompatibility() error {
+	vIDs, ok := r.tx.compatVersions[r.DbSID]
+	if !ok {
+		return nil // Nothing changed
+	}
+	delete(r.tx.compatVersions, r.DbSID)
+
+	meta, err := r.FindMeta(false, FOR_WRITE)
+	if err != nil || meta == nil {
+		return err
+	}
+
+	if !IsNil(meta.Get("xref")) || meta.Get("compatibilityauthority") != "server" {
+		return nil
+	}
+
+	mode, _ := meta.Get("compatibility").(string)
+	if mode == "" || mode == "none" {
+		return nil
+	}
+
+	base, transitive := strings.CutSuffix(mode, "_transitive")
+	backward := (base == "backward" || base == "full")
+	forward := (base == "forward" || base == "full")
+	if !backward && !forward {
+		return fmt.Errorf("Compatibility %q can't be verified by the server",
+			mode)
+	}
+
+	docs := map[string]*compatDoc{}
+	getDoc := func(v *Version) (*compatDoc, error) {
+		if doc, ok := docs[v.UID]; ok {
+			return doc, nil
+		}
+		doc, err := newCompatDoc(v)
+		docs[v.UID] = doc
+		return doc, err
+	}
+
+	// Checks 'v' against its ancestor, or all of them if 'transitive'
+	checkAncestors := func(v *Version) error {
+		doc, err := getDoc(v)
+		if err != nil || doc == nil {
+			return err
+		}
+
+		seen := map[string]bool{v.UID: true}
+		for ancID := v.GetAsString("ancestor"); !seen[ancID]; {
+			seen[ancID] = true
+
+			anc, err := r.FindVersion(ancID, false, FOR_WRITE)
+			if err != nil {
+				return err
+			}
+			if anc == nil { // ANCESTOR_TBD or dangling
+				break
+			}
+
+			ancDoc, err := getDoc(anc)
+			if err != nil {
+				return err
+			}
+			if ancDoc != nil {
+				if err := checkCompat(doc, ancDoc, backward, forward,
+					mode); err != nil {
+					return err
+				}
+			}
+
+			if !transitive {
+				break
+			}
+			ancID = anc.GetAsString("ancestor")
+		}
+		return nil
+	}
+
+	if vIDs == nil {
+		vers, err := r.GetVersions()
+		if err != nil {
+			return err
+		}
+		sort.Slice(vers, func(i, j int) bool {
+			return vers[i].UID < vers[j].UID
+		})
+		for _, v := range vers {
+			if err := checkAncestors(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, vID := range SortedKeys(vIDs) {
+		v, err := r.FindVersion(vID, false, FOR_WRITE)
+		if err != nil {
+			return err
+		}
+		if v == nil {
+			continue // Deleted since then
+		}
+		if err := checkAncestors(v); err != nil {
+			return err
+		}
+
+		// Its children, or all of its descendants if 'transitive', were
+		// only checked against its old document. New Versions won't
+		// have any.
+		seen := map[string]bool{vID: true}
+		next := []string{vID}
+		for len(next) > 0 {
+			children, err := r.GetChildVersionIDs(next[0])
+			if err != nil {
+				return err
+			}
+			next = next[1:]
+			sort.Strings(children)
+			for _, childID := range children {
+				if seen[childID] {
+					continue
+				}
+				seen[childID] = true
+				if vIDs[childID] {
+					continue // Checked on its own
+				}
+
+				child, err := r.FindVersion(childID, false, FOR_WRITE)
+				if err != nil {
+					return err
+				}
+				if child == nil {
+					continue
+				}
+				childDoc, err := getDoc(child)
+				if err != nil {
+					return err
+				}
+				doc, err := getDoc(v)
+				if err != nil {
+					return err
+				}
+				if childDoc != nil && doc != nil {
+					if err := checkCompat(childDoc, doc, backward, forward,
+						mode); err != nil {
+						return err
+					}
+				}
+				if transitive {
+					next = append(next, childID)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+type compatDoc struct {
+	ID     string // Version's ID, for error messages
+	Format string // COMPAT_XXX
+	Doc    any    // Parsed JSON of the document
+}
+
+// Returns nil if the Version has no document to check
+func newCompatDoc(v *Version) (*compatDoc, error) {
+	buf, _ := v.Get(v.GetResourceSingular()).([]byte)
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	cd := &compatDoc{ID: v.UID}
+	if err := json.Unmarshal(buf, &cd.Doc); err != nil {
+		return nil, fmt.Errorf("Can't verify the compatibility of Version "+
+			"%q, its document isn't valid JSON: %s", v.UID, err)
+	}
+
+	format, _ := v.Get("format").(string)
+	format = strings.ToLower(format)
+	ct := strings.ToLower(v.GetAsString("contenttype"))
+
+	switch {
+	case strings.HasPrefix(format, "jsonschema"):
+		cd.Format = COMPAT_JSONSCHEMA
+	case strings.HasPrefix(format, "avro"):
+		cd.Format = COMPAT_AVRO
+	case format != "":
+		// Unknown format
+	case strings.Contains(ct, "schema+json"):
+		cd.Format = COMPAT_JSONSCHEMA
+	case strings.Contains(ct, "avro"):
+		cd.Format = COMPAT_AVRO
+	default:
+		// Guess based on the document itself
+		if m, ok := cd.Doc.(map[string]any); ok {
+			switch m["type"] {
+			case "record", "enum", "fixed":
+				cd.Format = COMPAT_AVRO
+			default:
+				cd.Format = COMPAT_JSONSCHEMA
+			}
+		} else if _, ok := cd.Doc.([]any); ok {
+			cd.Format = COMPAT_AVRO // Avro union
+		} else if _, ok := cd.Doc.(bool); ok {
+			cd.Format = COMPAT_JSONSCHEMA
+		}
+	}
+
+	if cd.Format == "" {
+		return nil, fmt.Errorf("Can't verify the compatibility of Version "+
+			"%q, only JSON Schema and Avro documents are supported", v.UID)
+	}
+
+	return cd, nil
+}
+
+func checkCompat(newDoc, oldDoc *compatDoc, backward, forward bool, mode string) error {
+	if newDoc.Format != oldDoc.Format {
+		return fmt.Errorf("Version %q isn't %s compatible with Version %q: "+
+			"the document formats differ", newDoc.ID, mode, oldDoc.ID)
+	}
+
+	issues := []string{}
+	if backward { // new can read old
+		issues = append(issues, compatIssues(oldDoc, newDoc)...)
+	}
+	if forward { // old can read new
+		issues = append(issues, compatIssues(newDoc, oldDoc)...)
+	}
+	sort.Strings(issues)
+
+	if len(issues) == 0 {
+		return nil
+	}
+
+	log.VPrintf(3, "Compat issues %q vs %q: %v", newDoc.ID, oldDoc.ID, issues)
+	return fmt.Errorf("Version %q isn't %s compatible with Version %q:\n%s",
+		newDoc.ID, mode, oldDoc.ID, strings.Join(issues, "\n"))
+}
+
+// Returns the reasons why data written with 'writer' can't be read by
+// 'reader'
+func compatIssues(writer, reader *compatDoc) []string {
+	if writer.Format == COMPAT_AVRO {
+		wSchema, err := parseAvro(writer.Doc)
+		if err != nil {
+			return []string{fmt.Sprintf("  %q isn't a valid Avro schema: %s",
+				writer.ID, err)}
+		}
+		rSchema, err := parseAvro(reader.Doc)
+		if err != nil {
+			return []string{fmt.Sprintf("  %q isn't a valid Avro schema: %s",
+				reader.ID, err)}
+		}
+		ac := &avroCompat{reader: reader.ID, seen: map[[2]*avroSchema]bool{}}
+		ac.check(wSchema, rSchema, "")
+		return ac.issues
+	}
+
+	jc := &jsonSchemaCompat{
+		reader: reader.ID,
+		wRoot:  writer.Doc,
+		rRoot:  reader.Doc,
+		seen:   map[string]bool{},
+	}
+	jc.check(writer.Doc, reader.Doc, "")
+	return jc.issues
+}
+
+// JSON Schema
+
+// Verifies that every instance that's valid against the writer's schema is
+// also valid against the reader's schema. This is conservative - if we're
+// not sure then it's flagged as an issue.
+type jsonSchemaCompat struct {
+	reader string
+	wRoot  any
+	rRoot  any
+	seen   map[string]bool
+	issues []string
+}
+
+// Keywords that don't affect validation, or that we check explicitly
+var jsonSchemaKnownKeywords = map[string]bool{
+	"$schema": true, "$id": true, "$defs": true, "definitions": true,
+	"$comment": true, "$anchor": true, "$dynamicAnchor": true,
+	"title": true, "description": true, "default": true, "examples": true,
+	"deprecated": true, "readOnly": true, "writeOnly": true, "format": true,
+	"contentMediaType": true, "contentEncoding": true,
+
+	"type": true, "enum": true, "const": true,
+	"minimum": true, "maximum": true, "exclusiveMinimum": true,
+	"exclusiveMaximum": true, "multipleOf": true,
+	"minLength": true, "maxLength": true, "pattern": true,
+	"items": true, "minItems": true, "maxItems": true, "uniqueItems": true,
+	"properties": true, "required": true, "additionalProperties": true,
+	"patternProperties": true, "minProperties": true, "maxProperties": true,
+	"allOf": true, "anyOf": true, "oneOf": true,
+}
+
+func (jc *jsonSchemaCompat) add(path string, format string, args ...any) {
+	jc.issues = append(jc.issues, fmt.Sprintf("  %q: %q ", path, jc.reader)+
+		fmt.Sprintf(format, args...))
+}
+
+// Resolve local "$ref"s (e.g. "#/$defs/foo")
+func (jc *jsonSchemaCompat) resolve(root any, schema any, path string) any {
+	for i := 0; i < 32; i++ {
+		m, ok := schema.(map[string]any)
+		if !ok {
+			return schema
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return schema
+		}
+
+		ptr, ok := strings.CutPrefix(ref, "#")
+		if !ok {
+			return schema // Leave it, we'll compare them as-is
+		}
+
+		next := root
+		for _, tok := range strings.Split(strings.TrimPrefix(ptr, "/"), "/") {
+			if tok == "" {
+				continue
+			}
+			tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"),
+				"~0", "~")
+			nextMap, ok := next.(map[string]any)
+			if !ok {
+				return schema
+			}
+			next = nextMap[tok]
+		}
+		if next == nil {
+			return schema
+		}
+
+		// Merge any sibling keywords with the referenced schema
+		if len(m) > 1 {
+			nextMap, ok := next.(map[string]any)
+			if !ok {
+				return schema
+			}
+			merged := map[string]any{}
+			for k, v := range nextMap {
+				merged[k] = v
+			}
+			for k, v := range m {
+				if k != "$ref" {
+					merged[k] = v
+				}
+			}
+			next = merged
+		}
+		schema = next
+	}
+	return schema
+}
+
+func (jc *jsonSchemaCompat) check(w any, r any, path string) {
+	w = jc.resolve(jc.wRoot, w, path)
+	r = jc.resolve(jc.rRoot, r, path)
+
+	// Don't recurse forever on recursive schemas
+	key := fmt.Sprintf("%s|%p|%p", path, w, r)
+	if wm, ok := w.(map[string]any); ok {
+		if rm, ok := r.(map[string]any); ok {
+			key = fmt.Sprintf("%p|%p", wm, rm)
+		}
+	}
+	if jc.seen[key] {
+		return
+	}
+	jc.seen[key] = true
+
+	if r == true || w == false {
+		return
+	}
+	if r == false {
+		jc.add(path, "doesn't allow any value")
+		return
+	}
+	if w == true {
+		w = map[string]any{}
+	}
+
+	wm, wok := w.(map[string]any)
+	rm, rok := r.(map[string]any)
+	if !wok || !rok {
+		jc.add(path, "isn't a valid schema")
+		return
+	}
+
+	if len(jsonSchemaWithout(rm, jsonSchemaAnnotations...)) == 0 ||
+		reflect.DeepEqual(wm, rm) {
+		return
+	}
+
+	// Combinators first
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if branches, ok := wm[key].([]any); ok {
+			// Each of the writer's options must be readable
+			rest := jsonSchemaWithout(wm, key)
+			for i, branch := range branches {
+				jc.check(jsonSchemaMerge(rest, branch), r,
+					fmt.Sprintf("%s/%s/%d", path, key, i))
+			}
+			return
+		}
+	}
+
+	if branches, ok := rm["allOf"].([]any); ok {
+		for i, branch := range branches {
+			jc.check(w, branch, fmt.Sprintf("%s/allOf/%d", path, i))
+		}
+		jc.check(w, jsonSchemaWithout(rm, "allOf"), path)
+		return
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		if branches, ok := rm[key].([]any); ok {
+			jc.check(w, jsonSchemaWithout(rm, key), path)
+
+			for _, branch := range branches {
+				sub := &jsonSchemaCompat{reader: jc.reader, wRoot: jc.wRoot,
+					rRoot: jc.rRoot, seen: map[string]bool{}}
+				sub.check(w, branch, path)
+				if len(sub.issues) == 0 {
+					return
+				}
+			}
+			jc.add(path, "doesn't allow all values, none of the %q "+
+				"options match", key)
+			return
+		}
+	}
+
+	if branches, ok := wm["allOf"].([]any); ok {
+		// Approximate it by merging them, which can only loosen the writer
+		merged := jsonSchemaWithout(wm, "allOf")
+		for _, branch := range branches {
+			branch = jc.resolve(jc.wRoot, branch, path)
+			if branch == false {
+				return // Nothing is valid, so nothing to read
+			}
+			if bm, ok := branch.(map[string]any); ok {
+				for k, v := range bm {
+					merged[k] = v
+				}
+			}
+		}
+		jc.check(merged, r, path)
+		return
+	}
+
+	// Any reader keyword we don't understand must be the same as the writer's
+	for _, k := range SortedKeys(rm) {
+		if !jsonSchemaKnownKeywords[k] && !reflect.DeepEqual(wm[k], rm[k]) {
+			jc.add(path, "has a %q that can't be verified", k)
+		}
+	}
+
+	// type
+	wTypes := jsonSchemaTypes(wm)
+	rTypes := jsonSchemaTypes(rm)
+	if rTypes != nil {
+		if wTypes == nil {
+			jc.add(path, "only allows type(s) %s", strings.Join(rTypes, ","))
+		} else {
+			for _, t := range wTypes {
+				if !jsonSchemaAllowsType(rTypes, t) {
+					jc.add(path, "doesn't allow type %q", t)
+				}
+			}
+		}
+	}
+
+	// enum/const
+	if rEnum, ok := jsonSchemaEnum(rm); ok {
+		if wEnum, ok := jsonSchemaEnum(wm); !ok {
+			jc.add(path, "only allows certain values")
+		} else {
+			for _, wVal := range wEnum {
+				found := false
+				for _, rVal := range rEnum {
+					if reflect.DeepEqual(wVal, rVal) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					buf, _ := json.Marshal(wVal)
+					jc.add(path, "doesn't allow the value %s", string(buf))
+				}
+			}
+		}
+	}
+
+	if jsonSchemaAllowsType(wTypes, "number") ||
+		jsonSchemaAllowsType(wTypes, "integer") {
+		jc.checkNumbers(wm, rm, path)
+	}
+
+	if jsonSchemaAllowsType(wTypes, "string") {
+		jc.checkMin(wm, rm, "minLength", path)
+		jc.checkMax(wm, rm, "maxLength", path)
+		if rPat, ok := rm["pattern"]; ok && rPat != wm["pattern"] {
+			jc.add(path, "has a different \"pattern\"")
+		}
+	}
+
+	if jsonSchemaAllowsType(wTypes, "array") {
+		jc.checkMin(wm, rm, "minItems", path)
+		jc.checkMax(wm, rm, "maxItems", path)
+		if rm["uniqueItems"] == true && wm["uniqueItems"] != true {
+			jc.add(path, "requires unique items")
+		}
+		if rItems, ok := rm["items"]; ok {
+			wItems, ok := wm["items"]
+			if !ok {
+				wItems = true
+			}
+			jc.check(wItems, rItems, path+"/items")
+		}
+	}
+
+	if jsonSchemaAllowsType(wTypes, "object") {
+		jc.checkObject(wm, rm, path)
+	}
+}
+
+func (jc *jsonSchemaCompat) checkNumbers(wm, rm map[string]any, path string) {
+	wMin, wExMin := jsonSchemaNum(wm, "minimum"), jsonSchemaNum(wm, "exclusiveMinimum")
+	wMax, wExMax := jsonSchemaNum(wm, "maximum"), jsonSchemaNum(wm, "exclusiveMaximum")
+
+	if rMin := jsonSchemaNum(rm, "minimum"); !math.IsNaN(rMin) {
+		if !(wMin >= rMin || wExMin >= rMin) {
+			jc.add(path, "has a larger \"minimum\"")
+		}
+	}
+	if rExMin := jsonSchemaNum(rm, "exclusiveMinimum"); !math.IsNaN(rExMin) {
+		if !(wMin > rExMin || wExMin >= rExMin) {
+			jc.add(path, "has a larger \"exclusiveMinimum\"")
+		}
+	}
+	if rMax := jsonSchemaNum(rm, "maximum"); !math.IsNaN(rMax) {
+		if !(wMax <= rMax || wExMax <= rMax) {
+			jc.add(path, "has a smaller \"maximum\"")
+		}
+	}
+	if rExMax := jsonSchemaNum(rm, "exclusiveMaximum"); !math.IsNaN(rExMax) {
+		if !(wMax < rExMax || wExMax <= rExMax) {
+			jc.add(path, "has a smaller \"exclusiveMaximum\"")
+		}
+	}
+	if rMult := jsonSchemaNum(rm, "multipleOf"); !math.IsNaN(rMult) {
+		wMult := jsonSchemaNum(wm, "multipleOf")
+		if math.IsNaN(wMult) || math.Mod(wMult, rMult) != 0 {
+			jc.add(path, "has an incompatible \"multipleOf\"")
+		}
+	}
+}
+
+func (jc *jsonSchemaCompat) checkMin(wm, rm map[string]any, key, path string) {
+	rVal := jsonSchemaNum(rm, key)
+	if math.IsNaN(rVal) {
+		return
+	}
+	wVal := jsonSchemaNum(wm, key)
+	if math.IsNaN(wVal) || wVal < rVal {
+		jc.add(path, "has a larger %q", key)
+	}
+}
+
+func (jc *jsonSchemaCompat) checkMax(wm, rm map[string]any, key, path string) {
+	rVal := jsonSchemaNum(rm, key)
+	if math.IsNaN(rVal) {
+		return
+	}
+	wVal := jsonSchemaNum(wm, key)
+	if math.IsNaN(wVal) || wVal > rVal {
+		jc.add(path, "has a smaller %q", key)
+	}
+}
+
+func (jc *jsonSchemaCompat) checkObject(wm, rm map[string]any, path string) {
+	jc.checkMin(wm, rm, "minProperties", path)
+	jc.checkMax(wm, rm, "maxProperties", path)
+
+	wReq := map[string]bool{}
+	for _, name := range jsonSchemaStrings(wm["required"]) {
+		wReq[name] = true
+	}
+	for _, name := range jsonSchemaStrings(rm["required"]) {
+		if !wReq[name] {
+			jc.add(path, "requires property %q", name)
+		}
+	}
+
+	wProps, _ := wm["properties"].(map[string]any)
+	rProps, _ := rm["properties"].(map[string]any)
+
+	// What schema applies to property 'name' if it's not in "properties"
+	other := func(m map[string]any, name string) any {
+		if pats, ok := m["patternProperties"].(map[string]any); ok {
+			for _, pat := range SortedKeys(pats) {
+				if re, err := regexp.Compile(pat); err == nil &&
+					re.MatchString(name) {
+					return pats[pat]
+				}
+			}
+		}
+		if addl, ok := m["additionalProperties"]; ok {
+			return addl
+		}
+		return true
+	}
+
+	for _, name := range SortedKeys(rProps) {
+		wProp, ok := wProps[name]
+		if !ok {
+			wProp = other(wm, name)
+		}
+		jc.check(wProp, rProps[name], path+"/properties/"+name)
+	}
+
+	for _, name := range SortedKeys(wProps) {
+		if _, ok := rProps[name]; ok {
+			continue
+		}
+		rProp := other(rm, name)
+		if rProp == false {
+			jc.add(path, "doesn't allow property %q", name)
+			continue
+		}
+		jc.check(wProps[name], rProp, path+"/properties/"+name)
+	}
+
+	rPats, _ := rm["patternProperties"].(map[string]any)
+	wPats, _ := wm["patternProperties"].(map[string]any)
+	for _, pat := range SortedKeys(rPats) {
+		wPat, ok := wPats[pat]
+		if !ok {
+			wPat = other(map[string]any{"additionalProperties": wm["additionalProperties"]}, "")
+		}
+		jc.check(wPat, rPats[pat], path+"/patternProperties/"+pat)
+	}
+
+	if rAddl, ok := rm["additionalProperties"]; ok {
+		wAddl, ok := wm["additionalProperties"]
+		if !ok {
+			wAddl = true
+		}
+		if rAddl == false && wAddl != false {
+			jc.add(path, "doesn't allow additional properties")
+		} else {
+			jc.check(wAddl, rAddl, path+"/additionalProperties")
+		}
+	}
+}
+
+var jsonSchemaAnnotations = []string{"$schema", "$id", "$defs",
+	"definitions", "$comment", "title", "description", "default",
+	"examples", "deprecated", "readOnly", "writeOnly", "format"}
+
+func jsonSchemaWithout(m map[string]any, keys ...string) map[string]any {
+	res := map[string]any{}
+	for k, v := range m {
+		res[k] = v
+	}
+	for _, k := range keys {
+		delete(res, k)
+	}
+	return res
+}
+
+// Shallow merge, 'extra' wins
+func jsonSchemaMerge(m map[string]any, extra any) any {
+	extraMap, ok := extra.(map[string]any)
+	if !ok {
+		if len(m) == 0 || extra == false {
+			return extra
+		}
+		return m
+	}
+	res := jsonSchemaWithout(m)
+	for k, v := range extraMap {
+		res[k] = v
+	}
+	return res
+}
+
+// nil means "any type"
+func jsonSchemaTypes(m map[string]any) []string {
+	switch t := m["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		return jsonSchemaStrings(t)
+	}
+
+	// Infer the type from const/enum if we can
+	if vals, ok := jsonSchemaEnum(m); ok {
+		types := []string{}
+		for _, val := range vals {
+			switch val.(type) {
+			case nil:
+				types = append(types, "null")
+			case bool:
+				types = append(types, "boolean")
+			case float64:
+				types = append(types, "number")
+			case string:
+				types = append(types, "string")
+			case []any:
+				types = append(types, "array")
+			case map[string]any:
+				types = append(types, "object")
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// Is a value of type 't' allowed by 'types'? "integer" is a "number"
+func jsonSchemaAllowsType(types []string, t string) bool {
+	if types == nil {
+		return true
+	}
+	for _, allowed := range types {
+		if allowed == t || (t == "integer" && allowed == "number") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonSchemaEnum(m map[string]any) ([]any, bool) {
+	if val, ok := m["const"]; ok {
+		return []any{val}, true
+	}
+	if vals, ok := m["enum"].([]any); ok {
+		return vals, true
+	}
+	return nil, false
+}
+
+func jsonSchemaNum(m map[string]any, key string) float64 {
+	if f, ok := m[key].(float64); ok {
+		return f
+	}
+	return math.NaN()
+}
+
+func jsonSchemaStrings(val any) []string {
+	list, _ := val.([]any)
+	res := []string{}
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// Avro
+
+type avroSchema struct {
+	Type     string // primitive, "record", "enum", "array", "map", "fixed", "union"
+	Name     string // Full name of named types
+	Aliases  []string
+	Fields   []*avroField
+	Symbols  []string
+	Default  string // enum's default symbol
+	Items    *avroSchema
+	Values   *avroSchema
+	Size     int
+	Branches []*avroSchema
+}
+
+type avroField struct {
+	Name       string
+	Aliases    []string
+	Type       *avroSchema
+	HasDefault bool
+}
+
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true, "float": true,
+	"double": true, "bytes": true, "string": true,
+}
+
+func parseAvro(doc any) (*avroSchema, error) {
+	return parseAvroType(doc, "", map[string]*avroSchema{})
+}
+
+func avroFullName(name string, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func parseAvroType(doc any, namespace string, names map[string]*avroSchema) (*avroSchema, error) {
+	switch d := doc.(type) {
+	case string:
+		if avroPrimitives[d] {
+			return &avroSchema{Type: d}, nil
+		}
+		if s := names[avroFullName(d, namespace)]; s != nil {
+			return s, nil
+		}
+		if s := names[d]; s != nil {
+			return s, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", d)
+
+	case []any:
+		union := &avroSchema{Type: "union"}
+		for _, branch := range d {
+			s, err := parseAvroType(branch, namespace, names)
+			if err != nil {
+				return nil, err
+			}
+			union.Branches = append(union.Branches, s)
+		}
+		return union, nil
+
+	case map[string]any:
+		t, ok := d["type"].(string)
+		if !ok {
+			// e.g. {"type": ["null","string"]} or {"type": {...}}
+			if inner, ok := d["type"]; ok {
+				return parseAvroType(inner, namespace, names)
+			}
+			return nil, fmt.Errorf("missing \"type\"")
+		}
+
+		s := &avroSchema{Type: t}
+		switch t {
+		case "record", "error", "enum", "fixed":
+			s.Type = t
+			if t == "error" {
+				s.Type = "record"
+			}
+			name, _ := d["name"].(string)
+			if name == "" {
+				return nil, fmt.Errorf("%s is missing a \"name\"", t)
+			}
+			if ns, ok := d["namespace"].(string); ok {
+				namespace = ns
+			}
+			s.Name = avroFullName(name, namespace)
+			if strings.Contains(s.Name, ".") {
+				namespace = s.Name[:strings.LastIndex(s.Name, ".")]
+			}
+			for _, alias := range jsonSchemaStrings(d["aliases"]) {
+				s.Aliases = append(s.Aliases, avroFullName(alias, namespace))
+			}
+			names[s.Name] = s
+		}
+
+		switch s.Type {
+		case "record":
+			fields, _ := d["fields"].([]any)
+			for _, f := range fields {
+				fm, ok := f.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("invalid field in %q", s.Name)
+				}
+				field := &avroField{
+					Aliases: jsonSchemaStrings(fm["aliases"]),
+				}
+				field.Name, _ = fm["name"].(string)
+				_, field.HasDefault = fm["default"]
+				ft, err := parseAvroType(fm["type"], namespace, names)
+				if err != nil {
+					return nil, err
+				}
+				field.Type = ft
+				s.Fields = append(s.Fields, field)
+			}
+		case "enum":
+			s.Symbols = jsonSchemaStrings(d["symbols"])
+			s.Default, _ = d["default"].(string)
+		case "fixed":
+			size, _ := d["size"].(float64)
+			s.Size = int(size)
+		case "array":
+			items, err := parseAvroType(d["items"], namespace, names)
+			if err != nil {
+				return nil, err
+			}
+			s.Items = items
+		case "map":
+			values, err := parseAvroType(d["values"], namespace, names)
+			if err != nil {
+				return nil, err
+			}
+			s.Values = values
+		default:
+			if !avroPrimitives[t] {
+				// Could be a reference to a named type
+				return parseAvroType(t, namespace, names)
+			}
+		}
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("invalid schema: %v", doc)
+}
+
+// Implements the Avro "schema resolution" rules to see if data written
+// with one schema can be read with another
+type avroCompat struct {
+	reader string
+	seen   map[[2]*avroSchema]bool
+	issues []string
+}
+
+func (ac *avroCompat) add(path string, format string, args ...any) {
+	ac.issues = append(ac.issues, fmt.Sprintf("  %q: %q ", path, ac.reader)+
+		fmt.Sprintf(format, args...))
+}
+
+var avroPromotions = map[string][]string{
+	"int":    {"long", "float", "double"},
+	"long":   {"float", "double"},
+	"float":  {"double"},
+	"string": {"bytes"},
+	"bytes":  {"string"},
+}
+
+func (ac *avroCompat) matches(w, r *avroSchema, path string) bool {
+	sub := &avroCompat{reader: ac.reader, seen: ac.seen}
+	sub.check(w, r, path)
+	return len(sub.issues) == 0
+}
+
+func (ac *avroCompat) check(w, r *avroSchema, path string) {
+	key := [2]*avroSchema{w, r}
+	if ac.seen[key] {
+		return
+	}
+	ac.seen[key] = true
+	defer delete(ac.seen, key)
+
+	if w.Type == "union" {
+		for _, branch := range w.Branches {
+			ac.check(branch, r, path)
+		}
+		return
+	}
+
+	if r.Type == "union" {
+		for _, branch := range r.Branches {
+			if ac.matches(w, branch, path) {
+				return
+			}
+		}
+		ac.add(path, "can't read a %q", avroTypeName(w))
+		return
+	}
+
+	if w.Type != r.Type {
+		for _, t := range avroPromotions[w.Type] {
+			if t == r.Type {
+				return
+			}
+		}
+		ac.add(path, "can't read a %q as a %q", avroTypeName(w),
+			avroTypeName(r))
+		return
+	}
+
+	switch r.Type {
+	case "record", "enum", "fixed":
+		if !avroNamesMatch(w, r) {
+			ac.add(path, "has a different name (%s vs %s)", r.Name, w.Name)
+			return
+		}
+	}
+
+	switch r.Type {
+	case "record":
+		for _, rf := range r.Fields {
+			var wf *avroField
+			for _, f := range w.Fields {
+				if f.Name == rf.Name || ArrayContains(rf.Aliases, f.Name) {
+					wf = f
+					break
+				}
+			}
+			if wf == nil {
+				if !rf.HasDefault {
+					ac.add(path, "field %q is missing a default value",
+						rf.Name)
+				}
+				continue
+			}
+			ac.check(wf.Type, rf.Type, path+"/"+rf.Name)
+		}
+	case "enum":
+		if r.Default != "" {
+			return
+		}
+		for _, sym := range w.Symbols {
+			if !ArrayContains(r.Symbols, sym) {
+				ac.add(path, "is missing enum symbol %q", sym)
+			}
+		}
+	case "fixed":
+		if w.Size != r.Size {
+			ac.add(path, "has a different size (%d vs %d)", r.Size, w.Size)
+		}
+	case "array":
+		ac.check(w.Items, r.Items, path+"/items")
+	case "map":
+		ac.check(w.Values, r.Values, path+"/values")
+	}
+}
+
+func avroTypeName(s *avroSchema) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Type
+}
+
+func avroNamesMatch(w, r *avroSchema) bool {
+	short := func(name string) string {
+		return name[strings.LastIndex(name, ".")+1:]
+	}
+	if w.Name == r.Name || ArrayContains(r.Aliases, w.Name) {
+		return true
+	}
+	// Allow for one of them not using a namespace
+	return short(w.Name) == short(r.Name) &&
+		(!strings.Contains(w.Name, ".") || !strings.Contains(r.Name, "."))
+}
@@ -20,6 +20,12 @@ const (
 	FILTER_ABSENT
 	FILTER_EQUAL
 	FILTER_NOT_EQUAL
+	FILTER_LESS          // <
+	FILTER_LESS_EQUAL    // <=
+	FILTER_GREATER       // >
+	FILTER_GREATER_EQUAL // >=
+	FILTER_LESS_GREATER  // <> - like != but the attribute must be present
+	FILTER_SEARCH        // ?search - only added by GenerateQuery
 )
 
 const HTML_EXP = "&#9662;" // Expanded json symbol for HTML output
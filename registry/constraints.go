@@ -0,0 +1,154 @@
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	. "github.com/xregistry/server/common"
+)
+
+// The declarative constraints that an attribute's model definition can
+// include (see Attribute.VerifyConstraints for what's allowed where):
+// - "pattern", "minlength", "maxlength", "minimum" and "maximum" are checked
+//   by ValidateScalar, so for arrays they're checked for each item
+// - "minitems" and "maxitems" are checked by ValidateAttribute
+// - "equals", "requires" and "excludes" involve sibling attributes so
+//   they're checked by ValidateObject once the entire object is processed
+
+// "pattern" -> compiled regexp, so we don't recompile them on each write
+var patternREs = sync.Map{}
+
+func getPatternRE(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternREs.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patternREs.Store(pattern, re)
+	return re, nil
+}
+
+func fmtNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Check the "pattern", "minlength", "maxlength", "minimum" and "maximum"
+// constraints of a scalar value that's already been type checked
+func CheckScalarConstraints(val any, attr *Attribute, path *PropPath) error {
+	if str, ok := val.(string); ok {
+		if attr.Pattern != "" {
+			re, err := getPatternRE(attr.Pattern)
+			if err != nil {
+				return fmt.Errorf("Attribute %q has an invalid \"pattern\" "+
+					"(%s): %s", path.UI(), attr.Pattern, err)
+			}
+			if !re.MatchString(str) {
+				return fmt.Errorf("Attribute %q(%s) must match the pattern: "+
+					"%s", path.UI(), str, attr.Pattern)
+			}
+		}
+
+		length := utf8.RuneCountInString(str)
+		if attr.MinLength != nil && length < *attr.MinLength {
+			return fmt.Errorf("Attribute %q must be at least %d "+
+				"characters long", path.UI(), *attr.MinLength)
+		}
+		if attr.MaxLength != nil && length > *attr.MaxLength {
+			return fmt.Errorf("Attribute %q must be at most %d "+
+				"characters long", path.UI(), *attr.MaxLength)
+		}
+	}
+
+	if attr.Minimum != nil || attr.Maximum != nil {
+		num := 0.0
+		switch v := val.(type) {
+		case int:
+			num = float64(v)
+		case float64:
+			num = v
+		default:
+			return nil
+		}
+
+		if attr.Minimum != nil && num < *attr.Minimum {
+			return fmt.Errorf("Attribute %q(%v) must be at least %s",
+				path.UI(), val, fmtNumber(*attr.Minimum))
+		}
+		if attr.Maximum != nil && num > *attr.Maximum {
+			return fmt.Errorf("Attribute %q(%v) must be at most %s",
+				path.UI(), val, fmtNumber(*attr.Maximum))
+		}
+	}
+
+	return nil
+}
+
+// Check the "minitems" and "maxitems" constraints of an array or map
+func CheckItemCount(val any, attr *Attribute, path *PropPath) error {
+	if attr.MinItems == nil && attr.MaxItems == nil {
+		return nil
+	}
+
+	valValue := reflect.ValueOf(val)
+	if valValue.Kind() != reflect.Slice && valValue.Kind() != reflect.Map {
+		return nil // Let the type checking flag it
+	}
+
+	count := valValue.Len()
+	if attr.MinItems != nil && count < *attr.MinItems {
+		return fmt.Errorf("Attribute %q must have at least %d item(s)",
+			path.UI(), *attr.MinItems)
+	}
+	if attr.MaxItems != nil && count > *attr.MaxItems {
+		return fmt.Errorf("Attribute %q must have at most %d item(s)",
+			path.UI(), *attr.MaxItems)
+	}
+	return nil
+}
+
+// Check the "equals", "requires" and "excludes" rules of the attributes
+// that are present in 'obj'. 'path' is the location of 'obj' itself.
+func CheckAttributeRules(obj map[string]any, attrs []*Attribute,
+	path *PropPath) error {
+
+	present := func(name string) bool {
+		val, ok := obj[name]
+		return ok && !IsNil(val)
+	}
+
+	for _, attr := range attrs {
+		if !present(attr.Name) {
+			continue
+		}
+
+		if attr.Equals != "" {
+			if !present(attr.Equals) ||
+				ToJSON(obj[attr.Name]) != ToJSON(obj[attr.Equals]) {
+				return fmt.Errorf("Attribute %q must be the same as %q",
+					path.P(attr.Name).UI(), path.P(attr.Equals).UI())
+			}
+		}
+
+		for _, name := range attr.Requires {
+			if !present(name) {
+				return fmt.Errorf("Attribute %q requires %q to be present",
+					path.P(attr.Name).UI(), path.P(name).UI())
+			}
+		}
+
+		for _, name := range attr.Excludes {
+			if present(name) {
+				return fmt.Errorf("Attributes %q and %q can't both be present",
+					path.P(attr.Name).UI(), path.P(name).UI())
+			}
+		}
+	}
+
+	return nil
+}
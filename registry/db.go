@@ -4,46 +4,33 @@ import (
 	"bytes"
 	"context"
 	"database/sql"
-	_ "embed"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"maps"
+	"net/http"
 	"os"
 	"reflect"
 	"regexp"
 	"runtime/pprof"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	log "github.com/duglin/dlog"
-	_ "github.com/go-sql-driver/mysql"
 	. "github.com/xregistry/server/common"
 )
 
 var DB *sql.DB
 var DB_Name = ""
 var DB_InitFunc func()
+var DB_Dialect Dialect // Set by OpenDB
 
-var DBUSER = "root"
-var DBHOST = "localhost"
-var DBPORT = "3306"
-var DBPASSWORD = "password"
-
-// TODO load these from a config file
-func init() {
-	if tmp := os.Getenv("DBUSER"); tmp != "" {
-		DBUSER = tmp
-	}
-	if tmp := os.Getenv("DBPASSWORD"); tmp != "" {
-		DBPASSWORD = tmp
-	}
-	if tmp := os.Getenv("DBHOST"); tmp != "" {
-		DBHOST = tmp
-	}
-	if tmp := os.Getenv("DBPORT"); tmp != "" {
-		DBPORT = tmp
-	}
-}
+// How many times, and how long to wait before the first retry, when an HTTP
+// request fails due to a transient DB error. The delay doubles each time
+var DB_Retries = 5
+var DB_RetryDelay = 50 * time.Millisecond
 
 // Active transaction - mainly for debugging and testing
 var TXs = map[string]*Tx{}
@@ -127,11 +114,21 @@ type Tx struct {
 	Registry                   *Registry
 	CreateTime                 string // use for entity timestamps too
 	User                       string
+	Role                       Role  // Set when auth is enabled, see auth.go
+	Auth                       *Auth // nil when auth is disabled
 	IgnoreEpoch                bool
 	IgnoreDefaultVersionSticky bool
 	IgnoreDefaultVersionID     bool
+	MigrateModel               bool // Fix-up entities when the model changes
 	RequestInfo                *RequestInfo
 
+	// First transient DB error (e.g. deadlock) seen, see IsRetryable()
+	DBError error
+
+	// Changes made in this Tx, published when it's committed. See events.go
+	events    []*Event
+	eventsMap map[string]*Event // RegUID/Path -> Event
+
 	// Cache of entities this Tx is dealing with. Things can get funky if
 	// we have more than one instance of the same entity in memory.
 	// TODO DUG expand this to save all types, not just Versions.
@@ -140,6 +137,16 @@ type Tx struct {
 	// explicitly
 	Cache map[string]*Entity // e.Path
 
+	// xid references set, and entities deleted, in this Tx that need to be
+	// checked by Validate(). See integrity.go
+	xidRefs     []*xidRef
+	deletedXIDs []string
+
+	// Resource.DbSID -> IDs of the Versions added or changed in this Tx
+	// whose compatibility needs to be checked, nil means all of them. See
+	// EnsureCompatibility
+	compatVersions map[string]map[string]bool
+
 	// For debugging
 	uuid  string   // just a unique ID for the TXs map key
 	stack []string // Stack at time NewTX
@@ -186,7 +193,7 @@ func (tx *Tx) NewTx() error {
 		}
 		err := OpenDB(DB_Name)
 		if err != nil {
-			return err
+			return tx.CheckDBError(err)
 		}
 	}
 
@@ -194,11 +201,10 @@ func (tx *Tx) NewTx() error {
 		return nil
 	}
 
-	t, err := DB.BeginTx(context.Background(),
-		&sql.TxOptions{sql.LevelReadCommitted, false})
+	t, err := DB.BeginTx(context.Background(), DB_Dialect.TxOptions())
 	if err != nil {
 		DB = nil
-		return err
+		return tx.CheckDBError(err)
 		// panic("Error talking to the DB: %s", err)
 	}
 
@@ -213,6 +219,64 @@ func (tx *Tx) NewTx() error {
 	return nil
 }
 
+// Remember 'err' if it's one where retrying the entire Tx might work.
+// Returns 'err' so it can be used inline.
+func (tx *Tx) CheckDBError(err error) error {
+	if err != nil && tx.DBError == nil && IsTransientDBError(err) {
+		log.VPrintf(2, "Transient DB error: %s", err)
+		tx.DBError = err
+	}
+	return err
+}
+
+// True if the Tx hit an error where rolling back and trying again might
+// work. Once set it stays set, even across Commit/Rollback, so that the
+// caller can check it after it's done with the Tx.
+func (tx *Tx) IsRetryable() bool {
+	return tx != nil && tx.DBError != nil
+}
+
+// Errors we expect to go away on their own - for example, the DB server
+// restarting or another Tx holding a lock we need for too long
+func IsTransientDBError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	d := DB_Dialect
+	if d == nil {
+		d, _ = GetDialect()
+	}
+	return d != nil && d.IsTransient(err)
+}
+
+// Wait up to 'timeout' for the DB server to be available
+func WaitForDB(timeout time.Duration) error {
+	d, err := GetDialect()
+	if err != nil {
+		return err
+	}
+
+	delay := 250 * time.Millisecond
+	end := time.Now().Add(timeout)
+	for {
+		if _, err = d.List(); err == nil {
+			return nil
+		}
+		if time.Now().Add(delay).After(end) {
+			return fmt.Errorf("DB isn't available after %s: %s", timeout, err)
+		}
+		log.VPrintf(1, "Waiting for the DB: %s", err)
+		time.Sleep(delay)
+		delay = min(2*delay, 5*time.Second)
+	}
+}
+
 func (tx *Tx) DumpCache() {
 	log.Printf("==== CACHE =====")
 	for _, path := range tx.Cache {
@@ -259,6 +323,14 @@ func (tx *Tx) Validate(info *RequestInfo) error {
 
 	PanicIf(tx.Registry.Model.GetChanged(), "Unwritten model")
 
+	if err := tx.CheckIntegrity(); err != nil {
+		if info != nil {
+			// ServeHTTP turns a ConflictError into a 409
+			info.StatusCode = http.StatusBadRequest
+		}
+		return err
+	}
+
 	// At one point we almost called a ValidateResources type of func to
 	// double check everthing is ok. We shouldn't need to, but something
 	// to think about if things get complicated
@@ -358,42 +430,49 @@ func (tx *Tx) Commit() error {
 		return err
 	}
 
+	// Even if the Commit() fails the sql.Tx is done, so clean-up regardless
 	err := tx.tx.Commit()
-	Must(err)
-	if err != nil {
-		return err
+	if err == nil {
+		tx.PublishEvents()
+	} else {
+		log.Printf("Error committing Tx: %s", err)
+		tx.ClearEvents()
 	}
+	tx.done()
 
-	TXsMutex.Lock()
-	delete(TXs, tx.uuid)
-	TXsMutex.Unlock()
-	tx.tx = nil
-	tx.CreateTime = ""
-	tx.Cache = nil
-	tx.uuid = ""
-
-	return nil
+	// Don't use CheckDBError() here. If we lost the connection during the
+	// commit we don't know if it actually worked, and retrying the request
+	// (e.g. a POST) could then do it twice.
+	return err
 }
 
 func (tx *Tx) Rollback() error {
 	if tx == nil || tx.tx == nil {
 		return nil
 	}
+
+	// If we lost the connection then the DB will do the rollback for us
 	err := tx.tx.Rollback()
-	Must(err)
-	if err != nil {
-		return err
+	if err != nil && !IsTransientDBError(err) {
+		Must(err)
 	}
+	tx.ClearEvents()
+	tx.done()
 
+	return err
+}
+
+func (tx *Tx) done() {
 	TXsMutex.Lock()
 	delete(TXs, tx.uuid)
 	TXsMutex.Unlock()
 	tx.tx = nil
 	tx.CreateTime = ""
 	tx.Cache = nil
+	tx.xidRefs = nil
+	tx.deletedXIDs = nil
+	tx.compatVersions = nil
 	tx.uuid = ""
-
-	return nil
 }
 
 func (tx *Tx) Conditional(err error) error {
@@ -411,9 +490,9 @@ func (tx *Tx) Prepare(query string) (*sql.Stmt, error) {
 			return nil, err
 		}
 	}
-	ps, err := tx.tx.Prepare(query)
+	ps, err := tx.tx.Prepare(DB_Dialect.Rewrite(query))
 
-	return ps, err
+	return ps, tx.CheckDBError(err)
 }
 
 func (tx *Tx) AddRegistry(r *Registry) { tx.AddToCache(&r.Entity) }
@@ -608,6 +687,7 @@ type queryTime struct {
 }
 
 var queryTimes = map[string]*queryTime{}
+var queryTimesMutex = sync.Mutex{}
 var doTime = os.Getenv("XR_TIMING") != ""
 
 func DumpTimings() string {
@@ -615,6 +695,9 @@ func DumpTimings() string {
 		return ""
 	}
 
+	queryTimesMutex.Lock()
+	defer queryTimesMutex.Unlock()
+
 	str := ""
 	str += fmt.Sprintf("Count|Prep|Prep Avg|Query|Query Avg|Get|Get Avg|Total|Total Avg|CMD\n")
 	for cmd, qt := range queryTimes {
@@ -663,6 +746,7 @@ func Query(tx *Tx, cmd string, args ...interface{}) (*Result, error) {
 	}
 
 	if err != nil {
+		tx.CheckDBError(err)
 		log.Printf("Error querying DB(%s)(%v)->%s\n", cmd, args, err)
 		return nil, fmt.Errorf("Error querying DB(%s)->%s\n", cmd, err)
 	}
@@ -692,6 +776,7 @@ func Query(tx *Tx, cmd string, args ...interface{}) (*Result, error) {
 	if doTime {
 		gTime = time.Now()
 
+		queryTimesMutex.Lock()
 		qt, ok := queryTimes[cmd]
 		if !ok {
 			qt = &queryTime{}
@@ -707,6 +792,7 @@ func Query(tx *Tx, cmd string, args ...interface{}) (*Result, error) {
 		qt.getDur += gDiff
 		qt.totalDur += tDiff
 		qt.count++
+		queryTimesMutex.Unlock()
 	}
 
 	return result, nil
@@ -724,6 +810,7 @@ func doCount(tx *Tx, cmd string, args ...interface{}) (int, error) {
 
 	result, err := ps.Exec(args...)
 	if err != nil {
+		tx.CheckDBError(err)
 		if log.GetVerbose() > 4 {
 			query := SubQuery(cmd, args)
 			log.Printf("doCount:Error DB(%s)->%s\n", query, err)
@@ -836,47 +923,37 @@ func DoCount(tx *Tx, num int, cmd string, args ...interface{}) error {
 func DBExists(name string) bool {
 	log.VPrintf(3, ">Enter: DBExists %q", name)
 	defer log.VPrintf(3, "<Exit: DBExists")
-	db, err := sql.Open("mysql",
-		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
-	if err != nil {
-		panic(err)
-	}
-	defer db.Close()
 
-	rows, err := db.Query(`
-		SELECT SCHEMA_NAME
-		FROM INFORMATION_SCHEMA.SCHEMATA
-		WHERE SCHEMA_NAME=?`, name)
+	found, err := MustGetDialect().Exists(name)
 	if err != nil {
 		panic(err)
 	}
-	defer rows.Close()
 
-	found := rows.Next()
 	log.VPrintf(3, "<Exit: found: %v", found)
 	return found
 }
 
-//go:embed init.sql
-var initDB string
 var firstTime = true
 
 func OpenDB(name string) error {
+	dialect, err := GetDialect()
+	if err != nil {
+		return err
+	}
+
 	if firstTime {
-		log.VPrintf(3, "Open DB: %s:%s", DBHOST, DBPORT)
+		if dialect.Name() == "mysql" {
+			log.VPrintf(3, "Open DB: %s:%s", DBHOST, DBPORT)
+		} else {
+			log.VPrintf(3, "Open DB: %s", DBDIR)
+		}
 		firstTime = false
 	}
 
 	log.VPrintf(3, ">Enter: OpenDB %q", name)
 	defer log.VPrintf(3, "<Exit: OpenDB")
 
-	// DB, err := sql.Open("mysql",
-	// DBUSER + ":"+DBPASSWORD+"@tcp(localhost:3306)/")
-	var err error
-
-	DB, err = sql.Open("mysql",
-		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/"+name)
-
+	DB, err = dialect.Open(name)
 	if err != nil {
 		DB = nil
 		err = fmt.Errorf("Error talking to SQL: %s\n", err)
@@ -885,8 +962,7 @@ func OpenDB(name string) error {
 	}
 
 	DB_Name = name
-	DB.SetMaxOpenConns(5)
-	DB.SetMaxIdleConns(5)
+	DB_Dialect = dialect
 
 	if DB_InitFunc != nil {
 		DB_InitFunc()
@@ -895,75 +971,50 @@ func OpenDB(name string) error {
 	return nil
 }
 
+// Close all connections to the DB. The next NewTx() will re-open it.
+func CloseDB() error {
+	if DB == nil {
+		return nil
+	}
+
+	log.VPrintf(3, "Closing DB: %s", DB_Name)
+	err := DB.Close()
+	DB = nil
+	return err
+}
+
 func ListDBs() ([]string, error) {
 	log.VPrintf(3, ">Enter: ListDBs")
 	defer log.VPrintf(3, "<Exit: ListDBs")
 
-	db, err := sql.Open("mysql",
-		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
+	dialect, err := GetDialect()
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
-
-	rows, err := db.Query("SHOW DATABASES")
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	sysNames := []string{"information_schema", "mysql",
-		"performance_schema", "sys"}
 
-	names := []string{}
-	for rows.Next() {
-		name := ""
-		if err := rows.Scan(&name); err != nil {
-			return nil, err
-		}
-		if !ArrayContains(sysNames, name) {
-			names = append(names, name)
-		}
-	}
-
-	return names, nil
+	return dialect.List()
 }
 
 func CreateDB(name string) error {
+	return CreateDBWithSchema(name, "")
+}
+
+// Create a DB using 'schema' rather than the Dialect's current one, for
+// testing UpgradeDB against the schema of an older version of the server
+func CreateDBWithSchema(name string, schema string) error {
 	log.VPrintf(3, ">Enter: CreateDB %q", name)
 	defer log.VPrintf(3, "<Exit: CreateDB")
 
-	db, err := sql.Open("mysql",
-		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
+	dialect, err := GetDialect()
 	if err != nil {
-		panic(err)
-	}
-	defer db.Close()
-
-	if _, err = db.Exec("CREATE DATABASE " + name); err != nil {
-		panic(err)
+		return err
 	}
-
-	if _, err = db.Exec("USE " + name); err != nil {
-		panic(err)
+	if schema == "" {
+		schema = dialect.Schema()
 	}
 
 	log.VPrintf(3, "Creating DB")
-
-	for _, cmd := range strings.Split(initDB, ";") {
-		cmd = strings.TrimSpace(cmd)
-		cmd = ReplaceVariables(cmd)
-		if cmd == "" {
-			continue
-		}
-
-		log.VPrintf(4, "CMD: %s", cmd)
-		if _, err := db.Exec(cmd); err != nil {
-			panic(fmt.Sprintf("Error on: %s\n%s", cmd, err))
-		}
-	}
-
-	return nil
+	return dialect.Create(name, schema)
 }
 
 func ReplaceVariables(str string) string {
@@ -992,18 +1043,12 @@ func ReplaceVariables(str string) string {
 func DeleteDB(name string) error {
 	log.VPrintf(3, "Deleting DB %q", name)
 
-	db, err := sql.Open("mysql",
-		DBUSER+":"+DBPASSWORD+"@tcp("+DBHOST+":"+DBPORT+")/")
+	dialect, err := GetDialect()
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer db.Close()
 
-	_, err = db.Exec("DROP DATABASE IF EXISTS " + name)
-	if err != nil {
-		panic(err)
-	}
-	return nil
+	return dialect.Delete(name)
 }
 
 func SubQuery(query string, args []interface{}) string {
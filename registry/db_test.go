@@ -0,0 +1,49 @@
+package registry
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsTransientDBError(t *testing.T) {
+	save := DB_Dialect
+	defer func() { DB_Dialect = save }()
+	DB_Dialect = &MySQLDialect{}
+
+	tests := []struct {
+		err       error
+		transient bool
+	}{
+		{nil, false},
+		{fmt.Errorf("oops"), false},
+		{driver.ErrBadConn, true},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{mysql.ErrInvalidConn, true},
+		{&mysql.MySQLError{Number: 1213, Message: "Deadlock"}, true},
+		{&mysql.MySQLError{Number: 1205, Message: "Lock wait"}, true},
+		{fmt.Errorf("wrapped: %w", &mysql.MySQLError{Number: 1213}), true},
+		{&mysql.MySQLError{Number: 1062, Message: "Duplicate"}, false},
+	}
+
+	for i, test := range tests {
+		if got := IsTransientDBError(test.err); got != test.transient {
+			t.Errorf("%d: %v: got %v, expected %v", i, test.err, got,
+				test.transient)
+		}
+	}
+
+	tx := &Tx{}
+	tx.CheckDBError(fmt.Errorf("oops"))
+	if tx.IsRetryable() {
+		t.Errorf("Non-transient error shouldn't be retryable")
+	}
+	tx.CheckDBError(driver.ErrBadConn)
+	if !tx.IsRetryable() {
+		t.Errorf("Transient error should be retryable")
+	}
+}
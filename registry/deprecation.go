@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// A Resource is deprecated when its meta has a "deprecated" attribute.
+// If "deprecated.effective" is set then it's not in effect until then.
+// Once in effect:
+// - GETs of the Resource, its meta and its Versions include the Deprecation,
+//   Sunset and Link headers (see AddDeprecationHeaders)
+// - the Resource model's "deprecationpolicy" can reject new Versions
+//   ("noversions") and new xrefs to it ("noxrefs")
+
+// Returns the meta's "deprecated" value, or nil if it's not set
+func (m *Meta) GetDeprecated() map[string]any {
+	deprecated, _ := m.Get("deprecated").(map[string]any)
+	return deprecated
+}
+
+// Returns the time of the "deprecated" attribute named 'key' ("effective"
+// or "removal"), if it's set and valid
+func DeprecatedTime(deprecated map[string]any, key string) (time.Time, bool) {
+	str, ok := deprecated[key].(string)
+	if !ok || str == "" {
+		return time.Time{}, false
+	}
+	t, err := ConvertStrToTime(str)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// True if 'deprecated' is set and its "effective" time, if any, has passed
+func DeprecationInEffect(deprecated map[string]any, now time.Time) bool {
+	if deprecated == nil {
+		return false
+	}
+	effective, ok := DeprecatedTime(deprecated, "effective")
+	return !ok || !effective.After(now)
+}
+
+// Returns an error if the Resource is deprecated and its model's
+// "deprecationpolicy" includes 'policy'
+func (r *Resource) CheckDeprecationPolicy(policy string) error {
+	rm := r.GetResourceModel()
+	if rm == nil || !rm.HasDeprecationPolicy(policy) {
+		return nil
+	}
+
+	meta, err := r.FindMeta(false, FOR_READ)
+	if err != nil || meta == nil {
+		return err
+	}
+	if !DeprecationInEffect(meta.GetDeprecated(), time.Now()) {
+		return nil
+	}
+
+	what := "Versions"
+	if policy == DEPRECATIONPOLICY_NOXREFS {
+		what = "xrefs to it"
+	}
+	return fmt.Errorf("Resource %q is deprecated, new %s aren't allowed",
+		"/"+r.Path, what)
+}
+
+// Returns an error if the Resource at 'xid' is deprecated and doesn't allow
+// new xrefs to it
+func (reg *Registry) CheckXrefDeprecation(xid *Xid) error {
+	group, err := reg.FindGroup(xid.Group, xid.GroupID, false, FOR_READ)
+	if err != nil || group == nil {
+		return err
+	}
+	resource, err := group.FindResource(xid.Resource, xid.ResourceID, false,
+		FOR_READ)
+	if err != nil || resource == nil {
+		return err
+	}
+	return resource.CheckDeprecationPolicy(DEPRECATIONPOLICY_NOXREFS)
+}
+
+// Adds the headers that tell clients that the Resource referenced by the
+// request is deprecated:
+// - Deprecation: @EFFECTIVE (RFC 9745), or "true" if there's no "effective"
+// - Sunset: REMOVAL (RFC 8594)
+// - Link: <ALTERNATIVE>; rel="successor-version" and/or
+// <DOCUMENTATION>; rel="deprecation"
+// Nothing is added until the deprecation is in effect.
+func (info *RequestInfo) AddDeprecationHeaders() error {
+	if info.ResourceUID == "" || info.RootPath != "" ||
+		(info.OriginalRequest.Method != "GET" &&
+			info.OriginalRequest.Method != "HEAD") {
+		return nil
+	}
+
+	group, err := info.Registry.FindGroup(info.GroupType, info.GroupUID,
+		false, FOR_READ)
+	if err != nil || group == nil {
+		return err
+	}
+	resource, err := group.FindResource(info.ResourceType, info.ResourceUID,
+		false, FOR_READ)
+	if err != nil || resource == nil {
+		return err
+	}
+	meta, err := resource.FindMeta(false, FOR_READ)
+	if err != nil || meta == nil {
+		return err
+	}
+
+	deprecated := meta.GetDeprecated()
+	if !DeprecationInEffect(deprecated, time.Now()) {
+		return nil
+	}
+	log.VPrintf(3, "%q is deprecated", resource.Path)
+
+	if effective, ok := DeprecatedTime(deprecated, "effective"); ok {
+		info.AddHeader("Deprecation", fmt.Sprintf("@%d", effective.Unix()))
+	} else {
+		info.AddHeader("Deprecation", "true")
+	}
+
+	if removal, ok := DeprecatedTime(deprecated, "removal"); ok {
+		info.AddHeader("Sunset", removal.UTC().Format(http.TimeFormat))
+	}
+
+	links := []string{}
+	if alt, ok := deprecated["alternative"].(string); ok && alt != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="successor-version"`,
+			alt))
+	}
+	if doc, ok := deprecated["documentation"].(string); ok && doc != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="deprecation"`, doc))
+	}
+	if len(links) > 0 {
+		info.AddHeader("Link", strings.Join(links, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Which Dialect (storage backend) to use. See RegisterDialect
+var DBDRIVER = "mysql"
+
+func init() {
+	if tmp := os.Getenv("DBDRIVER"); tmp != "" {
+		DBDRIVER = tmp
+	}
+}
+
+// A Dialect hides the differences between the DBs we support. All SQL in
+// the code (and in init.sql) is written in MySQL's flavor, so a Dialect
+// only needs to manage its DBs and Rewrite any statements it can't run
+// as-is. It's used by OpenDB, CreateDB, etc. and by Tx.Prepare (so by
+// Query() and all of the Do*() funcs too).
+type Dialect interface {
+	Name() string
+
+	Exists(name string) (bool, error)
+	List() ([]string, error)
+	Create(name string, schema string) error // Create the DB and its schema
+	Schema() string                          // Current schema, for Create
+	Delete(name string) error
+	Open(name string) (*sql.DB, error)
+
+	TxOptions() *sql.TxOptions
+	Rewrite(query string) string
+
+	// True if 'err' is DB specific and is one where a retry of the entire
+	// Tx might work (e.g. a deadlock). See IsTransientDBError()
+	IsTransient(err error) bool
+}
+
+var Dialects = map[string]Dialect{}
+
+func RegisterDialect(d Dialect) {
+	Dialects[d.Name()] = d
+}
+
+func DialectNames() []string {
+	names := []string{}
+	for name, _ := range Dialects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func GetDialect() (Dialect, error) {
+	d, ok := Dialects[DBDRIVER]
+	if !ok {
+		return nil, fmt.Errorf("Unknown DB driver %q, must be one of: %s",
+			DBDRIVER, strings.Join(DialectNames(), ", "))
+	}
+	return d, nil
+}
+
+func MustGetDialect() Dialect {
+	d, err := GetDialect()
+	Must(err)
+	return d
+}
+
+// Split a schema file into its statements, replacing any variables
+func SplitSchema(schema string) []string {
+	cmds := []string{}
+	for _, cmd := range strings.Split(schema, ";") {
+		cmd = strings.TrimSpace(cmd)
+		cmd = ReplaceVariables(cmd)
+		if cmd == "" {
+			continue
+		}
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// Run each statement of a schema against 'db'
+func ExecSchema(db *sql.DB, schema string) error {
+	for _, cmd := range SplitSchema(schema) {
+		log.VPrintf(4, "CMD: %s", cmd)
+		if _, err := db.Exec(cmd); err != nil {
+			return fmt.Errorf("Error on: %s\n%s", cmd, err)
+		}
+	}
+	return nil
+}
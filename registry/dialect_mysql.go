@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"database/sql"
+	_ "embed"
+	"errors"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+	. "github.com/xregistry/server/common"
+)
+
+var DBUSER = "root"
+var DBHOST = "localhost"
+var DBPORT = "3306"
+var DBPASSWORD = "password"
+
+// TODO load these from a config file
+func init() {
+	if tmp := os.Getenv("DBUSER"); tmp != "" {
+		DBUSER = tmp
+	}
+	if tmp := os.Getenv("DBPASSWORD"); tmp != "" {
+		DBPASSWORD = tmp
+	}
+	if tmp := os.Getenv("DBHOST"); tmp != "" {
+		DBHOST = tmp
+	}
+	if tmp := os.Getenv("DBPORT"); tmp != "" {
+		DBPORT = tmp
+	}
+
+	RegisterDialect(&MySQLDialect{})
+}
+
+//go:embed init.sql
+var initDB string
+
+type MySQLDialect struct{}
+
+func (d *MySQLDialect) Name() string { return "mysql" }
+
+func (d *MySQLDialect) dsn(name string) string {
+	return DBUSER + ":" + DBPASSWORD + "@tcp(" + DBHOST + ":" + DBPORT + ")/" +
+		name
+}
+
+func (d *MySQLDialect) Exists(name string) (bool, error) {
+	db, err := sql.Open("mysql", d.dsn(""))
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT SCHEMA_NAME
+		FROM INFORMATION_SCHEMA.SCHEMATA
+		WHERE SCHEMA_NAME=?`, name)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), nil
+}
+
+func (d *MySQLDialect) List() ([]string, error) {
+	db, err := sql.Open("mysql", d.dsn(""))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SHOW DATABASES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sysNames := []string{"information_schema", "mysql",
+		"performance_schema", "sys"}
+
+	names := []string{}
+	for rows.Next() {
+		name := ""
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !ArrayContains(sysNames, name) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+func (d *MySQLDialect) Schema() string { return initDB }
+
+func (d *MySQLDialect) Create(name string, schema string) error {
+	db, err := sql.Open("mysql", d.dsn(""))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err = db.Exec("CREATE DATABASE " + name); err != nil {
+		return err
+	}
+
+	if _, err = db.Exec("USE " + name); err != nil {
+		return err
+	}
+
+	return ExecSchema(db, schema)
+}
+
+func (d *MySQLDialect) Delete(name string) error {
+	db, err := sql.Open("mysql", d.dsn(""))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec("DROP DATABASE IF EXISTS " + name)
+	return err
+}
+
+func (d *MySQLDialect) Open(name string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", d.dsn(name))
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(5)
+	db.SetMaxIdleConns(5)
+	return db, nil
+}
+
+func (d *MySQLDialect) TxOptions() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: sql.LevelReadCommitted}
+}
+
+// All of our SQL is written for MySQL so there's nothing to do
+func (d *MySQLDialect) Rewrite(query string) string {
+	return query
+}
+
+// Deadlocks (1213) and lock wait timeouts (1205) roll back the statement
+// (or Tx) but a 2nd try will most likely work
+func (d *MySQLDialect) IsTransient(err error) bool {
+	myErr := (*mysql.MySQLError)(nil)
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205
+	}
+	return errors.Is(err, mysql.ErrInvalidConn)
+}
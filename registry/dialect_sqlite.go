@@ -0,0 +1,234 @@
+package registry
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Directory holding the SQLite DB files, one "NAME.db" file per DB
+var DBDIR = "."
+
+func init() {
+	if tmp := os.Getenv("DBDIR"); tmp != "" {
+		DBDIR = tmp
+	}
+
+	// MySQL funcs that SQLite doesn't have
+	sqlite.MustRegisterDeterministicScalarFunction("substring_index", 3,
+		sqliteSubstringIndex)
+	sqlite.MustRegisterDeterministicScalarFunction("xr_match", 2,
+		sqliteMatch)
+
+	RegisterDialect(&SQLiteDialect{})
+}
+
+//go:embed init-sqlite.sql
+var initSQLiteDB string
+
+type SQLiteDialect struct{}
+
+func (d *SQLiteDialect) Name() string { return "sqlite" }
+
+func (d *SQLiteDialect) file(name string) string {
+	return filepath.Join(DBDIR, name+".db")
+}
+
+func (d *SQLiteDialect) dsn(name string) string {
+	// Immediate Txs means we'll wait (busy_timeout) for the write lock when
+	// the Tx starts rather than fail later on when we try to upgrade to one
+	return "file:" + d.file(name) + "?_txlock=immediate" +
+		"&_pragma=busy_timeout(30000)" +
+		"&_pragma=journal_mode(WAL)" +
+		"&_pragma=case_sensitive_like(1)"
+}
+
+func (d *SQLiteDialect) Exists(name string) (bool, error) {
+	_, err := os.Stat(d.file(name))
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (d *SQLiteDialect) List() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(DBDIR, "*.db"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), ".db"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (d *SQLiteDialect) Schema() string { return initSQLiteDB }
+
+func (d *SQLiteDialect) Create(name string, schema string) error {
+	if exists, err := d.Exists(name); err != nil || exists {
+		if err == nil {
+			err = fmt.Errorf("DB %q already exists", name)
+		}
+		return err
+	}
+
+	db, err := sql.Open("sqlite", d.dsn(name))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return ExecSchema(db, schema)
+}
+
+func (d *SQLiteDialect) Delete(name string) error {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		err := os.Remove(d.file(name) + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *SQLiteDialect) Open(name string) (*sql.DB, error) {
+	if exists, err := d.Exists(name); err != nil || !exists {
+		if err == nil {
+			err = fmt.Errorf("DB %q doesn't exist", name)
+		}
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite", d.dsn(name))
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(5)
+	db.SetMaxIdleConns(5)
+	return db, nil
+}
+
+// SQLite only has one isolation level (serializable)
+func (d *SQLiteDialect) TxOptions() *sql.TxOptions {
+	return nil
+}
+
+// We'd only see these if we waited longer than the busy_timeout
+func (d *SQLiteDialect) IsTransient(err error) bool {
+	sqlErr := (*sqlite.Error)(nil)
+	if errors.As(err, &sqlErr) {
+		code := sqlErr.Code() & 0xff // Remove the extended code bits
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
+
+var sqliteRewrites = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// SQLite is case-sensitive by default
+	{regexp.MustCompile(`\bBINARY\s+`), ``},
+	{regexp.MustCompile(`\bCOLLATE\s+utf8mb4_\w+`), `COLLATE NOCASE`},
+
+	// LIKE is case-sensitive (see dsn()) for Paths, like MySQL's
+	// utf8mb4_bin columns, but not for values
+	{regexp.MustCompile(`\bPropValue LIKE \?`),
+		`LOWER(PropValue) LIKE LOWER(?)`},
+
+	// SQLite locks the entire DB on writes
+	{regexp.MustCompile(`\s+FOR\s+UPDATE\b`), ``},
+
+	{regexp.MustCompile(`\bON\s+DUPLICATE\s+KEY\s+UPDATE\b`),
+		`ON CONFLICT DO UPDATE SET`},
+
+	// No FULLTEXT indexes, see sqliteMatch
+	{regexp.MustCompile(`\bMATCH\(([\w.]+)\)\s+AGAINST\(\?\)`),
+		`xr_match($1, ?)`},
+
+	// See the TIMESTAMP filters in GenerateQuery
+	{regexp.MustCompile(`CAST\(REPLACE\(REPLACE\(([\w.?]+),'T',' '\),` +
+		`'Z',''\) AS DATETIME\(6\)\)`), `julianday($1)`},
+}
+
+func (d *SQLiteDialect) Rewrite(query string) string {
+	for _, rw := range sqliteRewrites {
+		query = rw.re.ReplaceAllString(query, rw.repl)
+	}
+	return query
+}
+
+// substring_index(str, delim, count) - count > 0 means return everything
+// before the count'th delim, count < 0 means everything after the count'th
+// delim from the end. Same as MySQL's.
+func sqliteSubstringIndex(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if args[0] == nil || args[1] == nil || args[2] == nil {
+		return nil, nil
+	}
+
+	str := fmt.Sprintf("%s", args[0])
+	delim := fmt.Sprintf("%s", args[1])
+	count, ok := args[2].(int64)
+	if !ok {
+		return nil, fmt.Errorf("substring_index: count must be an integer")
+	}
+
+	if delim == "" || count == 0 {
+		return "", nil
+	}
+
+	parts := strings.Split(str, delim)
+	if int(count) >= len(parts) || int(-count) >= len(parts) {
+		return str, nil
+	}
+	if count > 0 {
+		return strings.Join(parts[:count], delim), nil
+	}
+	return strings.Join(parts[len(parts)+int(count):], delim), nil
+}
+
+// xr_match(text, terms) - a poor man's version of MySQL's natural language
+// MATCH(text) AGAINST(terms). Returns the number of times any of the words
+// in 'terms' appear in 'text', ignoring case, so 0 means no match.
+func sqliteMatch(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	if args[0] == nil || args[1] == nil {
+		return int64(0), nil
+	}
+
+	words := func(str string) []string {
+		return strings.FieldsFunc(strings.ToLower(str), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+	}
+
+	terms := map[string]bool{}
+	for _, term := range words(fmt.Sprintf("%s", args[1])) {
+		terms[term] = true
+	}
+
+	count := int64(0)
+	for _, word := range words(fmt.Sprintf("%s", args[0])) {
+		if terms[word] {
+			count++
+		}
+	}
+	return count, nil
+}
@@ -0,0 +1,240 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// The response of a GET .../versions/vID?diff=OTHER. "Metadata" is the
+// JSON Patch that turns OTHER's attributes into vID's. The documents are
+// only compared when the request wasn't for "$details", and then either
+// "Document" (a JSON Patch, when both are JSON or YAML) or "DocumentDiff"
+// (a unified diff) is set, but only if they're different. Documents held
+// at a URL aren't compared, "DocumentDiff" just says so if the URLs differ.
+type VersionDiff struct {
+	From         string    `json:"from"`
+	To           string    `json:"to"`
+	Metadata     []PatchOp `json:"metadata"`
+	Document     []PatchOp `json:"document,omitempty"`
+	DocumentDiff string    `json:"documentdiff,omitempty"`
+}
+
+// GET /GROUPS/gID/RESOURCES/rID[/versions/vID][$details]?diff=OTHER
+// OTHER is either the ID of a Version of the same Resource or the XID of
+// any Resource or Version. A Resource means its default Version.
+func HTTPGETDiff(info *RequestInfo) error {
+	log.VPrintf(3, ">Enter: HTTPGETDiff(%s)", info.DiffFrom)
+	defer log.VPrintf(3, "<Exit: HTTPGETDiff")
+
+	resource, err := findDiffResource(info, info.GroupType, info.GroupUID,
+		info.ResourceType, info.ResourceUID)
+	if err != nil {
+		return err
+	}
+	to, err := findDiffVersion(info, resource, info.VersionUID)
+	if err != nil {
+		return err
+	}
+
+	fromResource, fromVID := resource, info.DiffFrom
+	if strings.Contains(info.DiffFrom, "/") {
+		xid, err := ParseXid(info.DiffFrom)
+		if err != nil {
+			info.StatusCode = http.StatusBadRequest
+			return fmt.Errorf("Invalid ?diff value %q: %s", info.DiffFrom, err)
+		}
+		if xid.ResourceID == "" || !xid.IsEntity || xid.Version == "meta" {
+			info.StatusCode = http.StatusBadRequest
+			return fmt.Errorf("?diff value %q must reference a Resource "+
+				"or Version", info.DiffFrom)
+		}
+		if !info.tx.CanRead(xid.Group) {
+			info.StatusCode = http.StatusForbidden
+			return fmt.Errorf("User %q is not allowed to read %q",
+				info.tx.User, info.DiffFrom)
+		}
+		fromResource, err = findDiffResource(info, xid.Group, xid.GroupID,
+			xid.Resource, xid.ResourceID)
+		if err != nil {
+			return err
+		}
+		fromVID = xid.VersionID
+	}
+	from, err := findDiffVersion(info, fromResource, fromVID)
+	if err != nil {
+		return err
+	}
+
+	diff := &VersionDiff{
+		From: "/" + from.Path,
+		To:   "/" + to.Path,
+	}
+
+	fromJSON, _ := json.Marshal(diffableObject(from))
+	toJSON, _ := json.Marshal(diffableObject(to))
+	if diff.Metadata, err = CreateJSONPatch(fromJSON, toJSON); err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	if !info.ShowDetails && info.ResourceModel.GetHasDocument() {
+		if err = diffDocuments(info, diff, from, to); err != nil {
+			info.StatusCode = http.StatusInternalServerError
+			return err
+		}
+	}
+
+	buf, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	info.AddHeader("Content-Type", "application/json")
+	info.Write(buf)
+	info.Write([]byte("\n"))
+	return nil
+}
+
+func findDiffResource(info *RequestInfo, gType, gID, rType, rID string) (*Resource, error) {
+	group, err := info.Registry.FindGroup(gType, gID, false, FOR_READ)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return nil, fmt.Errorf("Error finding group(%s): %s", gID, err)
+	}
+	if group == nil {
+		info.StatusCode = http.StatusNotFound
+		return nil, fmt.Errorf("Group %q not found", "/"+gType+"/"+gID)
+	}
+
+	resource, err := group.FindResource(rType, rID, false, FOR_READ)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return nil, fmt.Errorf("Error finding resource(%s): %s", rID, err)
+	}
+	if resource == nil {
+		info.StatusCode = http.StatusNotFound
+		return nil, fmt.Errorf("Resource %q not found",
+			"/"+gType+"/"+gID+"/"+rType+"/"+rID)
+	}
+	return resource, nil
+}
+
+// An empty 'vID' means the Resource's default Version
+func findDiffVersion(info *RequestInfo, resource *Resource, vID string) (*Version, error) {
+	version := (*Version)(nil)
+	err := error(nil)
+
+	if vID == "" {
+		version, err = resource.GetDefault(FOR_READ)
+	} else {
+		version, err = resource.FindVersion(vID, false, FOR_READ)
+	}
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return nil, err
+	}
+	if version == nil {
+		info.StatusCode = http.StatusNotFound
+		return nil, fmt.Errorf("Version %q not found",
+			"/"+resource.Path+"/versions/"+vID)
+	}
+	return version, nil
+}
+
+// Returns the user-facing attributes of the Version. The document isn't
+// included since it's compared separately.
+func diffableObject(version *Version) map[string]any {
+	res := map[string]any{}
+	for key, val := range version.Object {
+		if key != "" && key[0] != '#' {
+			res[key] = val
+		}
+	}
+	return res
+}
+
+func diffDocuments(info *RequestInfo, diff *VersionDiff, from *Version, to *Version) error {
+	// The Versions might be of different Resource types. Returns the
+	// document, or its <singular>url if it's held outside of the registry
+	getDoc := func(v *Version) ([]byte, string, error) {
+		singular := v.GetResourceModel().Singular
+		if url := v.GetAsString(singular + "url"); url != "" {
+			return nil, url, nil
+		}
+		switch doc := v.Get(singular).(type) {
+		case []byte:
+			return doc, "", nil
+		case error:
+			return nil, "", doc
+		}
+		return []byte{}, "", nil
+	}
+
+	fromDoc, fromURL, err := getDoc(from)
+	if err != nil {
+		return err
+	}
+	toDoc, toURL, err := getDoc(to)
+	if err != nil {
+		return err
+	}
+
+	// We don't fetch external documents, so all we can tell is whether
+	// they're at the same URL. Any URL changes show up in "Metadata" too.
+	if fromURL != "" || toURL != "" {
+		if fromURL != toURL {
+			diff.DocumentDiff = "Documents stored outside of the registry " +
+				"can't be compared\n"
+		}
+		return nil
+	}
+
+	if string(fromDoc) == string(toDoc) {
+		return nil
+	}
+
+	// JSON and YAML docs get a structural diff, as long as they're valid
+	fromJSON := structuredDoc(from.GetAsString("contenttype"), fromDoc)
+	toJSON := structuredDoc(to.GetAsString("contenttype"), toDoc)
+	if fromJSON != nil && toJSON != nil {
+		ops, err := CreateJSONPatch(fromJSON, toJSON)
+		if err == nil {
+			diff.Document = ops
+			return nil
+		}
+	}
+
+	if !utf8.Valid(fromDoc) || !utf8.Valid(toDoc) {
+		diff.DocumentDiff = "Binary documents differ\n"
+		return nil
+	}
+
+	diff.DocumentDiff = UnifiedDiff(diff.From, diff.To, string(fromDoc),
+		string(toDoc))
+	return nil
+}
+
+// Returns 'doc' as JSON if it's a JSON or YAML document, otherwise nil
+func structuredDoc(contentType string, doc []byte) []byte {
+	if IsYAMLMediaType(contentType) {
+		if buf, err := YAMLToJSON(doc); err == nil {
+			return buf
+		}
+		return nil
+	}
+
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if (mt == "application/json" || strings.HasSuffix(mt, "+json")) &&
+		json.Valid(doc) {
+		return doc
+	}
+	return nil
+}
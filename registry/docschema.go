@@ -0,0 +1,182 @@
+package registry
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	. "github.com/xregistry/server/common"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var schemaErrPrinter = message.NewPrinter(language.English)
+
+// If the Version's Resource model has a "schemaattribute" then the Version's
+// document must validate (using JSON Schema, draft 2020-12 by default)
+// against the document of the schema Resource/Version whose XID is in that
+// attribute. If the attribute isn't set, or there's no local document
+// (e.g. RESOURCEurl is used), then there's nothing to check.
+func (e *Entity) ValidateDocument() error {
+	if e.Type != ENTITY_VERSION {
+		return nil
+	}
+
+	rm := e.GetResourceModel()
+	if rm == nil || rm.GetSchemaAttribute() == "" || !rm.GetHasDocument() {
+		return nil
+	}
+
+	val := e.NewObject[rm.GetSchemaAttribute()]
+	if IsNil(val) {
+		return nil
+	}
+	if reflect.ValueOf(val).Kind() != reflect.String {
+		return fmt.Errorf("Attribute %q must be a string",
+			rm.GetSchemaAttribute())
+	}
+	schemaXID := val.(string)
+
+	// Use the new document if there is one, otherwise the existing one
+	var doc any
+	if tmp, ok := e.NewObject[rm.Singular]; ok {
+		doc = tmp
+	} else {
+		doc = e.Get(rm.Singular)
+	}
+	if IsNil(doc) {
+		return nil
+	}
+	docBuf, ok := doc.([]byte)
+	if !ok {
+		return nil
+	}
+
+	schema, err := e.Registry.LoadDocSchema(schemaXID)
+	if err != nil {
+		return fmt.Errorf("Attribute %q: %s", rm.GetSchemaAttribute(), err)
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(docBuf))
+	if err != nil {
+		return fmt.Errorf("Document of %q isn't valid JSON: %s", "/"+e.Path,
+			err)
+	}
+
+	err = schema.Validate(inst)
+	if err == nil {
+		return nil
+	}
+
+	valErr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+
+	return fmt.Errorf("Document of %q doesn't validate against schema "+
+		"%q:\n%s", "/"+e.Path, schemaXID, schemaErrorList(valErr))
+}
+
+// List each failing JSON pointer, and why, one per line
+func schemaErrorList(valErr *jsonschema.ValidationError) string {
+	list := []string{}
+	var walk func(ve *jsonschema.ValidationError)
+	walk = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			list = append(list, fmt.Sprintf("  %q: %s",
+				jsonPointer(ve.InstanceLocation),
+				ve.ErrorKind.LocalizedString(schemaErrPrinter)))
+		}
+		for _, cause := range ve.Causes {
+			walk(cause)
+		}
+	}
+	walk(valErr)
+	sort.Strings(list)
+	return strings.Join(list, "\n")
+}
+
+// Find the schema document referenced by 'xidStr' and compile it. 'xidStr'
+// can reference a Resource (meaning its default Version) or a Version.
+func (reg *Registry) LoadDocSchema(xidStr string) (*jsonschema.Schema, error) {
+	xid, err := ParseXid(xidStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var version *Version
+	switch xid.Type {
+	case ENTITY_RESOURCE:
+		resource, err := reg.FindResourceByXID(xidStr)
+		if err != nil {
+			return nil, err
+		}
+		if resource != nil {
+			version, err = resource.GetDefault(FOR_READ)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case ENTITY_VERSION:
+		version, err = reg.FindXIDVersion(xidStr)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%q must be the xid of a Resource or Version",
+			xidStr)
+	}
+
+	if version == nil {
+		return nil, fmt.Errorf("Schema %q can't be found", xidStr)
+	}
+
+	if !version.GetResourceModel().GetHasDocument() {
+		return nil, fmt.Errorf("Schema %q has no document", xidStr)
+	}
+	buf, _ := version.Get(version.GetResourceSingular()).([]byte)
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("Schema %q has no document", xidStr)
+	}
+
+	schemaDoc, err := jsonschema.UnmarshalJSON(bytes.NewReader(buf))
+	if err != nil {
+		return nil, fmt.Errorf("Schema %q isn't valid JSON: %s", xidStr, err)
+	}
+
+	// Don't allow the schema to pull in other files/URLs
+	url := "xregistry:/" + version.Path
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.UseLoader(jsonschema.SchemeURLLoader{})
+
+	if err = compiler.AddResource(url, schemaDoc); err == nil {
+		var schema *jsonschema.Schema
+		if schema, err = compiler.Compile(url); err == nil {
+			return schema, nil
+		}
+	}
+
+	log.VPrintf(2, "Error compiling schema %q: %s", xidStr, err)
+	if schErr, ok := err.(*jsonschema.SchemaValidationError); ok {
+		if valErr, ok := schErr.Err.(*jsonschema.ValidationError); ok {
+			return nil, fmt.Errorf("Schema %q isn't a valid JSON Schema:\n%s",
+				xidStr, schemaErrorList(valErr))
+		}
+	}
+	return nil, fmt.Errorf("Schema %q isn't a valid JSON Schema: %s", xidStr,
+		err)
+}
+
+func jsonPointer(tokens []string) string {
+	ptr := ""
+	for _, tok := range tokens {
+		tok = strings.ReplaceAll(tok, "~", "~0")
+		ptr += "/" + strings.ReplaceAll(tok, "/", "~1")
+	}
+	return ptr
+}
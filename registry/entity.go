@@ -2,6 +2,7 @@ package registry
 
 import (
 	"fmt"
+	"io"
 	"maps"
 	"reflect"
 	"strconv"
@@ -161,31 +162,32 @@ func (e *Entity) GetAsInt(path string) int {
 	return i
 }
 
+// Returns a reader for the Version's document, or nil if there isn't one.
+// Use this instead of Get(RESOURCE) to avoid loading it all into memory.
+func (e *Entity) OpenContent() (io.ReadCloser, error) {
+	return openContent(e.tx, e.Get("#contentid"))
+}
+
 func (e *Entity) GetPP(pp *PropPath) any {
 	if (e.Type == ENTITY_RESOURCE || e.Type == ENTITY_VERSION) && pp.Len() == 1 {
 		rm := e.GetResourceModel()
 		if rm.GetHasDocument() && pp.Top() == rm.Singular {
-			contentID := e.Get("#contentid")
-			results, err := Query(e.tx, `
-            SELECT Content FROM ResourceContents WHERE VersionSID=? `,
-				contentID)
-			defer results.Close()
-
+			rc, err := e.OpenContent()
 			if err != nil {
-				return fmt.Errorf("Error finding contents %q: %s", e.DbSID, err)
+				return err
 			}
-
-			row := results.NextRow()
-			if row == nil {
+			if rc == nil {
 				// No data so just return
 				return nil
 			}
+			defer rc.Close()
 
-			if results.NextRow() != nil {
-				panic("too many results")
+			buf, err := io.ReadAll(rc)
+			if err != nil {
+				return fmt.Errorf("Error reading contents %q: %s", e.DbSID,
+					err)
 			}
-
-			return (*(row[0])).([]byte)
+			return buf
 		}
 	}
 
@@ -494,6 +496,10 @@ func (e *Entity) ValidateAndSave() error {
 		return err
 	}
 
+	if err := e.ValidateDocument(); err != nil {
+		return err
+	}
+
 	return e.Save()
 }
 
@@ -565,10 +571,7 @@ func (e *Entity) SetDBProperty(pp *PropPath, val any) error {
 				return err
 			} else {
 				// Update the content
-				err = DoOneTwo(e.tx, `
-                REPLACE INTO ResourceContents(VersionSID, Content)
-            	VALUES(?,?)`, e.DbSID, val)
-				if err != nil {
+				if err = saveContent(e.tx, e.DbSID, val); err != nil {
 					return err
 				}
 
@@ -914,38 +917,36 @@ var PropsFuncs = []*Attribute{
 			},
 		},
 	},
-	/*
-				{
-					Name:           "shortself",
-					internals: &AttrInternals{
-						getFn: func(e *Entity) any {
-							path := e.Path
-							base := ""
-		                    info := e.GetRequestInfo()
-							if info != nil {
-								base = info.BaseURL
-							}
+	{
+		Name: "shortself",
+		internals: &AttrInternals{
+			getFn: func(e *Entity) any {
+				info := e.GetRequestInfo()
 
-							if e.Type == ENTITY_RESOURCE || e.Type == ENTITY_VERSION {
-								meta := info != nil && (info.ShowDetails ||
-								info.DoDocView() ||
-								info.ResourceUID == "" || len(info.Parts) == 5)
+				// Relative URLs (docview) can't be shortened
+				if info == nil || info.DoDocView() ||
+					!info.Registry.Capabilities.ShortSelfEnabled("") {
+					return nil
+				}
 
-								if e.GetResourceModel().GetHasDocument() == false {
-									meta = false
-								}
+				path := e.Path
+				if e.Type == ENTITY_RESOURCE || e.Type == ENTITY_VERSION {
+					details := info.ShowDetails || info.ResourceUID == "" ||
+						len(info.Parts) == 5
 
-								if meta {
-									path += "$details"
-								}
-							}
+					if e.GetResourceModel().GetHasDocument() == false {
+						details = false
+					}
 
-							shortself := MD5(path)
-							return base + "/r?u=" + shortself
-						},
-					},
-				},
-	*/
+					if details {
+						path += "$details"
+					}
+				}
+
+				return info.BaseURL + "/r?u=" + MD5(path)
+			},
+		},
+	},
 	{
 		Name:      "xid",
 		internals: &AttrInternals{},
@@ -1132,6 +1133,10 @@ var PropsFuncs = []*Attribute{
 				// map[string]any <-> Capabilities  is really annoying
 				val := e.NewObject["capabilities"]
 				if !IsNil(val) {
+					if err := e.tx.CheckAdmin(`"capabilities"`); err != nil {
+						return err
+					}
+
 					// If speed is ever a concern here, just save the raw
 					// json from the input stream instead from http processing
 					valStr := ToJSON(val)
@@ -1199,8 +1204,17 @@ var PropsFuncs = []*Attribute{
 		},
 	},
 	{
-		Name:      "readonly",
-		internals: &AttrInternals{},
+		Name: "readonly",
+		internals: &AttrInternals{
+			checkFn: func(e *Entity) error {
+				newVal := e.NewObject["readonly"] == true
+				oldVal := e.Object["readonly"] == true
+				if newVal != oldVal {
+					return e.tx.CheckAdmin(`"readonly"`)
+				}
+				return nil
+			},
+		},
 	},
 	{
 		Name:      "compatibility",
@@ -1217,7 +1231,7 @@ var PropsFuncs = []*Attribute{
 				isDefault := (compat == SpecProps["compatibility"].Default)
 				if IsNil(compat) || isDefault {
 					delete(e.NewObject, "compatibilityauthority")
-				} else {
+				} else if IsNil(e.NewObject["compatibilityauthority"]) {
 					e.NewObject["compatibilityauthority"] = "external"
 				}
 				return nil
@@ -1434,7 +1448,7 @@ var PropsFuncs = []*Attribute{
 						isAbsURL = true
 					}
 
-					if len(info.Filters) > 0 && seenDefVid != valStr {
+					if info.HasFilters() && seenDefVid != valStr {
 						isAbsURL = true
 					}
 
@@ -1732,6 +1746,14 @@ func (e *Entity) Save() error {
 
 	err = traverse(NewPP(), newObj, e.NewObject)
 	if err == nil {
+		err = e.SaveXIDRefs(newObj)
+	}
+	if err == nil {
+		action := EVENT_UPDATED
+		if len(e.Object) == 0 {
+			action = EVENT_CREATED
+		}
+
 		// Copy 'newObj', removing all 'nil' attributes
 		e.Object = map[string]any{}
 		for k, v := range newObj {
@@ -1740,6 +1762,8 @@ func (e *Entity) Save() error {
 			}
 		}
 		e.NewObject = nil
+
+		e.tx.AddEvent(e, action)
 	}
 	return err
 }
@@ -1942,11 +1966,16 @@ func (e *Entity) ValidateObject(val any, namecharset string, origAttrs Attribute
 
 	attr := (*Attribute)(nil)
 	key := ""
+	checked := []*Attribute{} // for the "equals", "requires"... rules
 	for len(attrs) > 0 {
 		l := len(attrs)
 		attr = attrs[l-1] // grab last one & remove it
 		attrs = attrs[:l-1]
 
+		if attr.Name != "*" {
+			checked = append(checked, attr)
+		}
+
 		// Keys are all of the attribute names in newObj we need to check.
 		// Normally there's just one (attr.Name) but if attr.Name is "*"
 		// then we'll have a list of all remaining attribute names in newObj to
@@ -2042,8 +2071,6 @@ func (e *Entity) ValidateObject(val any, namecharset string, origAttrs Attribute
 
 			// Skip/remove 'dontStore' attrs
 			if attr.internals != nil && attr.internals.dontStore {
-				// TODO find a way to allow an admin to set the
-				// meta.ReadOnly flag itself
 				delete(objKeys, key) // Remove from to-process list
 				delete(newObj, key)
 				continue
@@ -2124,7 +2151,7 @@ func (e *Entity) ValidateObject(val any, namecharset string, origAttrs Attribute
 			strings.Join(SortedKeys(objKeys), ","))
 	}
 
-	return nil
+	return CheckAttributeRules(newObj, checked, path)
 }
 
 // Return: error, haveReplaceValue, newValue
@@ -2137,6 +2164,10 @@ func (e *Entity) ValidateAttribute(val any, attr *Attribute, path *PropPath) (er
 		log.VPrintf(0, " attr: %v", ToJSON(attr))
 	}
 
+	if err := CheckItemCount(val, attr, path); err != nil {
+		return err, false, nil
+	}
+
 	if attr.Type == ANY {
 		// All good - let it thru
 		return nil, false, nil
@@ -2240,6 +2271,11 @@ func (e *Entity) ValidateArray(arrayAttr *Attribute, val any, path *PropPath) er
 		Attributes: arrayAttr.Item.Attributes,
 		Enum:       arrayAttr.Enum,
 		Strict:     arrayAttr.Strict,
+		Pattern:    arrayAttr.Pattern,
+		MinLength:  arrayAttr.MinLength,
+		MaxLength:  arrayAttr.MaxLength,
+		Minimum:    arrayAttr.Minimum,
+		Maximum:    arrayAttr.Maximum,
 	}
 
 	for i := 0; i < valValue.Len(); i++ {
@@ -2360,6 +2396,11 @@ func (e *Entity) ValidateScalar(val any, attr *Attribute, path *PropPath) (error
 			}
 		}
 
+		// Make sure it exists once we're done with the Tx
+		if attr.GetIntegrity() != INTEGRITY_NONE {
+			e.tx.AddXIDRef(e, path, str)
+		}
+
 	case XIDTYPE:
 		if valKind != reflect.String {
 			return fmt.Errorf("Attribute %q must be an xidtype", path.UI()),
@@ -2455,6 +2496,10 @@ func (e *Entity) ValidateScalar(val any, attr *Attribute, path *PropPath) (error
 		}
 	}
 
+	if err := CheckScalarConstraints(val, attr, path); err != nil {
+		return err, false, nil
+	}
+
 	return nil, replace, newValue
 }
 
@@ -0,0 +1,206 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	log "github.com/duglin/dlog"
+)
+
+// Query parameters that change what's returned for an entity, beyond the
+// ones (e.g. ?inline) that mean it won't have an ETag at all
+var etagParams = []string{"collections", "doc", "html", "noprops",
+	"specversion", "ui"}
+
+// Returns the ETag of the entity referenced by the request's URL, or ""
+// if the URL isn't for a single entity or the entity doesn't exist.
+// The ETag is based on the entity's epoch. For Resources it's the epoch
+// of the "meta" sub-object plus the epoch of the default Version since
+// changes to either will change what's returned. When the Resource's (or
+// Version's) document is being returned, instead of its xRegistry metadata,
+// a hash of the document is included too. Any etagParams, or a YAML
+// response, add a hash of those so each representation has its own ETag.
+// Reads with ?inline or ?filter don't get one since their response depends
+// on other entities whose changes don't touch this entity's epoch.
+func (info *RequestInfo) GetETag(accessMode int) (string, error) {
+	if info.RootPath != "" || info.What == "Coll" {
+		return "", nil
+	}
+
+	if accessMode == FOR_READ &&
+		(len(info.Inlines) > 0 || len(info.Filters) > 0) {
+		return "", nil
+	}
+
+	tag, isDoc, err := info.getEntityETag(accessMode)
+	if err != nil || tag == "" {
+		return "", err
+	}
+
+	params := info.OriginalRequest.URL.Query()
+	rep := ""
+	for _, name := range etagParams {
+		if params.Has(name) {
+			rep += fmt.Sprintf("&%s=%s", name, params.Get(name))
+		}
+	}
+	if !isDoc && info.WantsYAML() {
+		rep += "&yaml"
+	}
+	if rep != "" {
+		sum := sha256.Sum256([]byte(rep))
+		tag += "-" + hex.EncodeToString(sum[:])[:16]
+	}
+
+	return `"` + tag + `"`, nil
+}
+
+// Returns the unquoted ETag of the entity itself, see GetETag, and whether
+// the Resource's document (rather than its metadata) is what's returned
+func (info *RequestInfo) getEntityETag(accessMode int) (string, bool, error) {
+	if info.What == "Registry" {
+		return fmt.Sprintf("%v", info.Registry.Get("epoch")), false, nil
+	}
+
+	group, err := info.Registry.FindGroup(info.GroupType, info.GroupUID,
+		false, accessMode)
+	if err != nil || group == nil {
+		return "", false, err
+	}
+	if info.ResourceUID == "" {
+		return fmt.Sprintf("%v", group.Get("epoch")), false, nil
+	}
+
+	resource, err := group.FindResource(info.ResourceType, info.ResourceUID,
+		false, accessMode)
+	if err != nil || resource == nil {
+		return "", false, err
+	}
+
+	meta, err := resource.FindMeta(false, accessMode)
+	if err != nil || meta == nil {
+		return "", false, err
+	}
+
+	if len(info.Parts) == 5 && info.Parts[4] == "meta" {
+		return fmt.Sprintf("%v", meta.Get("epoch")), false, nil
+	}
+
+	version := (*Version)(nil)
+	tag := ""
+
+	if info.VersionUID == "" {
+		if resource.IsXref() {
+			// Nothing to hash, and the target has its own ETag
+			return fmt.Sprintf("%v", meta.Get("epoch")), false, nil
+		}
+		version, err = resource.GetDefault(accessMode)
+		if err != nil || version == nil {
+			return "", false, err
+		}
+		tag = fmt.Sprintf("%v.%v", meta.Get("epoch"), version.Get("epoch"))
+	} else {
+		version, err = resource.FindVersion(info.VersionUID, false, accessMode)
+		if err != nil || version == nil {
+			return "", false, err
+		}
+		tag = fmt.Sprintf("%v", version.Get("epoch"))
+	}
+
+	// Include a hash of the document if that's what's being returned
+	rm := info.ResourceModel
+	isDoc := rm != nil && rm.GetHasDocument() && !info.ShowDetails &&
+		!info.DoDocView()
+	if isDoc {
+		if buf, ok := version.Get(rm.Singular).([]byte); ok {
+			sum := sha256.Sum256(buf)
+			tag += "-" + hex.EncodeToString(sum[:])[:16]
+		}
+	}
+
+	return tag, isDoc, nil
+}
+
+// Returns true if 'etag' is in the list of entity-tags in 'header' (the
+// value of an If-Match or If-None-Match header). "*" matches any existing
+// entity. 'weak' means weak comparison is used, so a "W/" prefix is ignored,
+// otherwise weak tags never match (per RFC 9110).
+func ETagMatches(header string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// Process any If-Match and If-None-Match headers. Returns true if the
+// response has already been determined (e.g. a 304) and the request should
+// not be processed any further. An error means the precondition failed.
+func CheckPreconditions(info *RequestInfo) (bool, error) {
+	req := info.OriginalRequest
+	ifMatch := req.Header.Get("If-Match")
+	ifNoneMatch := req.Header.Get("If-None-Match")
+
+	if ifMatch == "" && ifNoneMatch == "" {
+		return false, nil
+	}
+
+	// Only single entities have ETags
+	if info.RootPath != "" || info.What == "Coll" || req.Method == "POST" {
+		return false, nil
+	}
+
+	accessMode := FOR_WRITE
+	if req.Method == "GET" {
+		accessMode = FOR_READ
+	}
+
+	etag, err := info.GetETag(accessMode)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return false, err
+	}
+	log.VPrintf(3, "ETag: %s If-Match: %s If-None-Match: %s",
+		etag, ifMatch, ifNoneMatch)
+
+	if ifMatch != "" && !ETagMatches(ifMatch, etag, false) {
+		info.StatusCode = http.StatusPreconditionFailed
+		if etag == "" {
+			return false, fmt.Errorf("If-Match failed, %q doesn't exist",
+				"/"+strings.Join(info.Parts, "/"))
+		}
+		return false, fmt.Errorf("If-Match (%s) doesn't match the "+
+			"current ETag (%s)", ifMatch, etag)
+	}
+
+	if ifNoneMatch != "" && ETagMatches(ifNoneMatch, etag, true) {
+		if req.Method == "GET" {
+			info.AddHeader("ETag", etag)
+			info.StatusCode = http.StatusNotModified
+			return true, nil
+		}
+		info.StatusCode = http.StatusPreconditionFailed
+		return false, fmt.Errorf("If-None-Match (%s) matches the "+
+			"current ETag (%s)", ifNoneMatch, etag)
+	}
+
+	return false, nil
+}
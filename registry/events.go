@@ -0,0 +1,421 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Every create/update/delete of an entity is recorded in the Tx and then
+// once the Tx is committed they're published as CloudEvents to the
+// in-memory EventLog. From there they're available via the "/events"
+// server-sent-events stream and any registered Webhooks.
+
+const (
+	EVENT_CREATED = "created"
+	EVENT_UPDATED = "updated"
+	EVENT_DELETED = "deleted"
+)
+
+// Sent when a client asks for events we no longer have (or never had, e.g.
+// the server restarted), meaning it needs to resync its view of things
+const EVENT_RESET = "io.xregistry.events.reset"
+
+// Max # of events to keep around for clients to resume from
+var MaxEvents = 10000
+
+// How often to send a keep-alive comment on the "/events" stream
+var EventsKeepAlive = 15 * time.Second
+
+var entityTypeNames = map[int]string{
+	ENTITY_REGISTRY: "registry",
+	ENTITY_GROUP:    "group",
+	ENTITY_RESOURCE: "resource",
+	ENTITY_META:     "meta",
+	ENTITY_VERSION:  "version",
+}
+
+type Event struct {
+	Seq        int64
+	Registry   string // Registry's UID
+	Action     string // EVENT_XXX, "" means it was undone in the same Tx
+	EntityType string // "registry", "group", "resource", "meta", "version"
+	XID        string
+	Epoch      *int
+	Time       string
+}
+
+type EventData struct {
+	XID        string `json:"xid"`
+	EntityType string `json:"entitytype"`
+	Epoch      *int   `json:"epoch,omitempty"`
+}
+
+type CloudEvent struct {
+	SpecVersion     string `json:"specversion"`
+	ID              string `json:"id"`
+	Source          string `json:"source"`
+	Type            string `json:"type"`
+	Subject         string `json:"subject,omitempty"`
+	Time            string `json:"time,omitempty"`
+	DataContentType string `json:"datacontenttype,omitempty"`
+	Data            any    `json:"data,omitempty"`
+}
+
+func (ev *Event) Type() string {
+	return "io.xregistry." + ev.EntityType + "." + ev.Action
+}
+
+func (ev *Event) CloudEvent() *CloudEvent {
+	return &CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              strconv.FormatInt(ev.Seq, 10),
+		Source:          "/reg-" + ev.Registry,
+		Type:            ev.Type(),
+		Subject:         ev.XID,
+		Time:            ev.Time,
+		DataContentType: "application/json",
+		Data: &EventData{
+			XID:        ev.XID,
+			EntityType: ev.EntityType,
+			Epoch:      ev.Epoch,
+		},
+	}
+}
+
+// 'regUID' of "" means the event isn't for any one Registry
+func ResetCloudEvent(seq int64, regUID string) *CloudEvent {
+	source := "/"
+	if regUID != "" {
+		source = "/reg-" + regUID
+	}
+	return &CloudEvent{
+		SpecVersion: "1.0",
+		ID:          strconv.FormatInt(seq, 10),
+		Source:      source,
+		Type:        EVENT_RESET,
+		Time:        time.Now().UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// Record a change to 'e' in the Tx. Multiple changes to the same entity
+// within one Tx are merged into one event.
+func (tx *Tx) AddEvent(e *Entity, action string) {
+	typeName, ok := entityTypeNames[e.Type]
+	if !ok {
+		return
+	}
+
+	var epoch *int
+	if val, ok := e.Object["epoch"]; ok && !IsNil(val) {
+		tmp := NotNilInt(&val)
+		epoch = &tmp
+	}
+
+	key := e.Registry.UID + "/" + e.Path
+	if tx.eventsMap == nil {
+		tx.eventsMap = map[string]*Event{}
+	}
+
+	if ev := tx.eventsMap[key]; ev != nil {
+		ev.Epoch = epoch
+		switch {
+		case ev.Action == EVENT_CREATED && action == EVENT_UPDATED:
+			// Still new as far as everyone else is concerned
+		case ev.Action == EVENT_CREATED && action == EVENT_DELETED:
+			ev.Action = "" // Never existed as far as anyone knows
+		case ev.Action == "" && action == EVENT_UPDATED:
+			ev.Action = EVENT_CREATED
+		case ev.Action == EVENT_DELETED && action != EVENT_DELETED:
+			ev.Action = EVENT_UPDATED // Deleted and then recreated
+		default:
+			ev.Action = action
+		}
+		return
+	}
+
+	ev := &Event{
+		Registry:   e.Registry.UID,
+		Action:     action,
+		EntityType: typeName,
+		XID:        "/" + e.Path,
+		Epoch:      epoch,
+	}
+	tx.events = append(tx.events, ev)
+	tx.eventsMap[key] = ev
+}
+
+func (tx *Tx) ClearEvents() {
+	tx.events = nil
+	tx.eventsMap = nil
+}
+
+// Called once the Tx has been committed
+func (tx *Tx) PublishEvents() {
+	if len(tx.events) > 0 {
+		Events.Publish(tx.events)
+	}
+	tx.ClearEvents()
+}
+
+type EventLog struct {
+	mutex   sync.Mutex
+	events  []*Event      // Oldest first, at most MaxEvents of them
+	lastSeq int64         // Seq of the last event published
+	notify  chan struct{} // Closed, and replaced, each time we Publish
+}
+
+var Events = NewEventLog()
+
+func NewEventLog() *EventLog {
+	return &EventLog{
+		notify: make(chan struct{}),
+	}
+}
+
+func (el *EventLog) Publish(events []*Event) {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	count := 0
+	for _, ev := range events {
+		if ev.Action == "" {
+			continue
+		}
+		el.lastSeq++
+		ev.Seq = el.lastSeq
+		ev.Time = now
+		el.events = append(el.events, ev)
+		count++
+	}
+
+	if count == 0 {
+		return
+	}
+
+	if len(el.events) > MaxEvents {
+		el.events = append([]*Event{}, el.events[len(el.events)-MaxEvents:]...)
+	}
+
+	close(el.notify)
+	el.notify = make(chan struct{})
+}
+
+func (el *EventLog) LastSeq() int64 {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+	return el.lastSeq
+}
+
+// Returns all events after 'since'. If we don't have all of them (they were
+// dropped, or 'since' is from before a server restart) then 'lost' will be
+// true and 'events' will be everything we do have.
+// 'next' will be closed when there are new events to get.
+func (el *EventLog) Since(since int64) (events []*Event, lost bool, next <-chan struct{}) {
+	el.mutex.Lock()
+	defer el.mutex.Unlock()
+
+	first := el.lastSeq + 1 // Seq of the oldest event we have
+	if len(el.events) > 0 {
+		first = el.events[0].Seq
+	}
+
+	if since > el.lastSeq || since < first-1 {
+		lost = true
+		since = first - 1
+	}
+
+	// Seq's are sequential so we can just index into the list
+	events = append(events, el.events[since-(first-1):]...)
+	return events, lost, el.notify
+}
+
+// GET /events - stream events, as CloudEvents, for the Registry using
+// server-sent-events. Clients can resume via "Last-Event-ID" or "?since".
+func HTTPEvents(info *RequestInfo) error {
+	req := info.OriginalRequest
+	res := info.OriginalResponse
+
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		info.StatusCode = http.StatusInternalServerError
+		return fmt.Errorf("Streaming isn't supported")
+	}
+
+	since := Events.LastSeq()
+	sinceStr := req.Header.Get("Last-Event-ID")
+	if tmp := req.URL.Query().Get("since"); tmp != "" {
+		sinceStr = tmp
+	}
+	if sinceStr != "" {
+		tmp, err := strconv.ParseInt(sinceStr, 10, 64)
+		if err != nil || tmp < 0 {
+			info.StatusCode = http.StatusBadRequest
+			return fmt.Errorf("Invalid event sequence number %q", sinceStr)
+		}
+		since = tmp
+	}
+
+	regUID := info.Registry.UID
+
+	// This request could be around for a long time so don't hold onto
+	// the DB while we're waiting for events
+	if err := info.tx.Rollback(); err != nil {
+		return err
+	}
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	info.StatusCode = http.StatusOK
+	info.SentStatus = true
+	res.WriteHeader(info.StatusCode)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(EventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		events, lost, next := Events.Since(since)
+
+		if lost {
+			first := since
+			if len(events) > 0 {
+				first = events[0].Seq - 1
+			}
+			writeSSE(res, first, ResetCloudEvent(first, regUID))
+		}
+
+		for _, ev := range events {
+			since = ev.Seq
+			if ev.Registry != regUID {
+				continue
+			}
+			writeSSE(res, ev.Seq, ev.CloudEvent())
+		}
+		flusher.Flush()
+
+		select {
+		case <-next:
+		case <-keepAlive.C:
+			fmt.Fprintf(res, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-req.Context().Done():
+			return nil
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, seq int64, ce *CloudEvent) {
+	buf, _ := json.Marshal(ce)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", seq, ce.Type, buf)
+}
+
+// Delivers all events (for all Registries) to URL as structured-mode
+// CloudEvents. Failed deliveries are retried, with exponential backoff,
+// up to Retries times before the event is dropped.
+type Webhook struct {
+	URL     string
+	Retries int
+	Backoff time.Duration // Delay before the first retry
+
+	client *http.Client
+	stop   chan struct{}
+}
+
+var WebhookRetries = 5
+var WebhookBackoff = time.Second
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		URL:     url,
+		Retries: WebhookRetries,
+		Backoff: WebhookBackoff,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start delivering events published from now on
+func (wh *Webhook) Start() *Webhook {
+	go wh.run(Events.LastSeq())
+	return wh
+}
+
+func (wh *Webhook) Stop() {
+	close(wh.stop)
+}
+
+func (wh *Webhook) run(since int64) {
+	log.VPrintf(2, "Webhook started: %s", wh.URL)
+	defer log.VPrintf(2, "Webhook stopped: %s", wh.URL)
+
+	for {
+		events, lost, next := Events.Since(since)
+
+		if lost {
+			log.Printf("Webhook %s: events were lost, sending reset", wh.URL)
+			first := since
+			if len(events) > 0 {
+				first = events[0].Seq - 1
+			}
+			if !wh.deliver(ResetCloudEvent(first, "")) {
+				return
+			}
+		}
+
+		for _, ev := range events {
+			since = ev.Seq
+			if !wh.deliver(ev.CloudEvent()) {
+				return
+			}
+		}
+
+		select {
+		case <-next:
+		case <-wh.stop:
+			return
+		}
+	}
+}
+
+// Returns false if we were stopped
+func (wh *Webhook) deliver(ce *CloudEvent) bool {
+	buf, _ := json.Marshal(ce)
+	delay := wh.Backoff
+
+	for try := 0; ; try++ {
+		res, err := wh.client.Post(wh.URL, "application/cloudevents+json",
+			bytes.NewReader(buf))
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode/100 == 2 {
+				return true
+			}
+			err = fmt.Errorf("%s", res.Status)
+		}
+
+		if try >= wh.Retries {
+			log.Printf("Webhook %s: dropping event %s (%s): %s", wh.URL,
+				ce.ID, ce.Type, err)
+			return true
+		}
+
+		log.VPrintf(2, "Webhook %s: event %s failed (%s), retrying in %s",
+			wh.URL, ce.ID, err, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-wh.stop:
+			return false
+		}
+		delay *= 2
+	}
+}
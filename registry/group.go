@@ -27,34 +27,41 @@ func (g *Group) Delete() error {
 	log.VPrintf(3, ">Enter: Group.Delete(%s)", g.UID)
 	defer log.VPrintf(3, "<Exit: Group.Delete")
 
-	// Make sure we don't have any readonly Resources
-	results, err := Query(g.tx, `
+	// Make sure we don't have any readonly Resources, admins can delete them
+	if !g.tx.IsAdmin() {
+		results, err := Query(g.tx, `
 	    SELECT EXISTS(SELECT 1 FROM FullTree
 		WHERE RegSID=? AND Type=`+StrTypes(ENTITY_META)+` AND
 		  Path LIKE '`+g.Path+`/%' AND
 		  PropName='readonly`+string(DB_IN)+`' AND
 		  PropValue='true')`,
-		g.Registry.DbSID)
-	defer results.Close()
-	if err != nil {
-		return err
+			g.Registry.DbSID)
+		defer results.Close()
+		if err != nil {
+			return err
+		}
+		row := results.NextRow()
+		if NotNilInt(row[0]) != 0 {
+			return fmt.Errorf("Delete operations on read-only " +
+				"resources are not allowed")
+		}
 	}
-	row := results.NextRow()
-	if NotNilInt(row[0]) != 0 {
-		return fmt.Errorf("Delete operations on read-only " +
-			"resources are not allowed")
+
+	if err := g.CheckIntegrityOnDelete(); err != nil {
+		return err
 	}
 
 	if g.Registry.Touch() {
-		if err = g.Registry.ValidateAndSave(); err != nil {
+		if err := g.Registry.ValidateAndSave(); err != nil {
 			return err
 		}
 	}
 
-	err = DoOne(g.tx, `DELETE FROM "Groups" WHERE SID=?`, g.DbSID)
+	err := DoOne(g.tx, `DELETE FROM "Groups" WHERE SID=?`, g.DbSID)
 	if err != nil {
 		return err
 	}
+	g.tx.AddEvent(&g.Entity, EVENT_DELETED)
 	g.tx.RemoveFromCache(&g.Entity)
 	return nil
 }
@@ -267,6 +274,10 @@ func (g *Group) UpsertResourceWithObject(rType string, id string, vID string, ob
 		if err != nil {
 			return nil, false, fmt.Errorf("Error adding Resource: %s", err)
 		}
+		err = AddShortSelf(r.tx, g.Registry.DbSID, r.DbSID, r.Path, true)
+		if err != nil {
+			return nil, false, err
+		}
 		isNew = true
 		r.tx.AddResource(r)
 		g.Touch()
@@ -314,6 +325,10 @@ func (g *Group) UpsertResourceWithObject(rType string, id string, vID string, ob
 		if err != nil {
 			return nil, false, fmt.Errorf("Error adding Meta: %s", err)
 		}
+		err = AddShortSelf(r.tx, g.Registry.DbSID, r.DbSID, m.Path, false)
+		if err != nil {
+			return nil, false, err
+		}
 
 		err = m.JustSet(r.Singular+"id", r.UID)
 		if err != nil {
@@ -394,7 +409,7 @@ func (g *Group) UpsertResourceWithObject(rType string, id string, vID string, ob
 	PanicIf(err != nil, "No meta %q: %s", r.UID, err)
 
 	// Kind of late in the process but oh well
-	if meta.Get("readonly") == true {
+	if meta.Get("readonly") == true && !g.tx.IsAdmin() {
 		return nil, false, fmt.Errorf("Write operations on read-only " +
 			"resources are not allowed")
 	}
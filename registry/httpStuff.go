@@ -2,10 +2,13 @@ package registry
 
 import (
 	"bytes"
+	"context"
 	// "encoding/base64"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	// "os"
@@ -22,6 +25,7 @@ import (
 type Server struct {
 	Port       int
 	HTTPServer *http.Server
+	Auth       *Auth // nil means auth is disabled
 }
 
 func NewServer(port int) *Server {
@@ -32,6 +36,15 @@ func NewServer(port int) *Server {
 		},
 	}
 	server.HTTPServer.Handler = server
+
+	// Long running requests (e.g. GET /events) watch their request's
+	// context, so cancel them all once we start to shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	server.HTTPServer.BaseContext = func(net.Listener) context.Context {
+		return ctx
+	}
+	server.HTTPServer.RegisterOnShutdown(cancel)
+
 	return server
 }
 
@@ -39,28 +52,166 @@ func (s *Server) Close() {
 	s.HTTPServer.Close()
 }
 
+// Start listening right away, so clients can connect as soon as this
+// returns, but process the requests in the background
 func (s *Server) Start() *Server {
-	go s.Serve()
-	/*
-		for {
-			_, err := http.Get(fmt.Sprintf("http://localhost:%d", s.Port))
-			if err == nil || !strings.Contains(err.Error(), "refused") {
-				break
-			}
-		}
-	*/
+	listener, err := net.Listen("tcp", s.HTTPServer.Addr)
+	if err != nil {
+		log.Printf("Serve: %s", err)
+		return s
+	}
+	go s.serve(listener)
 	return s
 }
 
-func (s *Server) Serve() {
-	log.VPrintf(1, "Listening on %d", s.Port)
-	err := s.HTTPServer.ListenAndServe()
-	if err != http.ErrServerClosed {
-		log.Printf("Serve: %s", err)
+// Listen and process requests until the server is shutdown
+func (s *Server) Serve() error {
+	listener, err := net.Listen("tcp", s.HTTPServer.Addr)
+	if err != nil {
+		return err
+	}
+	return s.serve(listener)
+}
+
+func (s *Server) serve(listener net.Listener) error {
+	var err error
+	if s.HTTPServer.TLSConfig != nil {
+		log.VPrintf(1, "Listening on %d (https)", s.Port)
+		err = s.HTTPServer.ServeTLS(listener, "", "")
+	} else {
+		log.VPrintf(1, "Listening on %d", s.Port)
+		err = s.HTTPServer.Serve(listener)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	log.Printf("Serve: %s", err)
+	return err
+}
+
+// Stop accepting new requests and wait, up to 'timeout', for the in-flight
+// ones (and their Txs) to finish. Returns an error if we had to give up
+// on some of them.
+func (s *Server) Shutdown(timeout time.Duration) error {
+	log.VPrintf(1, "Shutting down, waiting up to %s", timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := s.HTTPServer.Shutdown(ctx)
+	if err != nil {
+		s.HTTPServer.Close()
+		return fmt.Errorf("Error waiting for requests to finish: %s", err)
+	}
+
+	// Anything not tied to a request (e.g. loading samples) gets the rest
+	// of the time to commit too
+	for {
+		TXsMutex.RLock()
+		count := len(TXs)
+		TXsMutex.RUnlock()
+
+		if count == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Gave up waiting for %d transaction(s)", count)
+		case <-time.After(50 * time.Millisecond):
+		}
 	}
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+
+	saveVerbose := log.GetVerbose()
+	if tmp := r.URL.Query().Get("verbose"); tmp != "" {
+		if v, err := strconv.Atoi(tmp); err == nil {
+			log.SetVerbose(v)
+		}
+		defer log.SetVerbose(saveVerbose)
+	}
+
+	log.VPrintf(2, "%s %s", r.Method, r.URL)
+
+	// These are for the infrastructure (e.g. k8s probes and Prometheus)
+	// so they aren't included in the metrics. Only /metrics needs auth
+	if r.Method == "GET" {
+		switch r.URL.Path {
+		case "/healthz":
+			HTTPHealthz(w, r)
+			return
+		case "/readyz":
+			HTTPReadyz(w, r)
+			return
+		case "/metrics":
+			if s.Auth == nil || s.Auth.AuthorizeMetrics(w, r) {
+				HTTPMetrics(w, r)
+			}
+			return
+		}
+	}
+
+	start := time.Now()
+	mw := newMetricsWriter(w)
+	defer func() {
+		Metrics.Observe(r.Method, mw.path, mw.code, time.Since(start))
+	}()
+	w = mw
+
+	// Figure out who the client is now, but we can't check what they're
+	// allowed to do until after we've parsed the request
+	user := ""
+	if s.Auth != nil {
+		user, err = s.Auth.Authenticate(r)
+		if err == nil && !s.Auth.HasAnyRole(user) {
+			err = fmt.Errorf("Authentication required")
+		}
+		if err != nil {
+			s.Auth.AddChallenges(w)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(err.Error() + "\n"))
+			return
+		}
+	}
+
+	if r.URL.Path == "/proxy" {
+		err := HTTPProxy(w, r)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Error proxying: " + err.Error() + "\n"))
+		}
+		return
+	}
+
+	// Hold onto the body in case we need to retry the request
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("Error reading request: " + err.Error() + "\n"))
+		return
+	}
+
+	delay := DB_RetryDelay
+	for attempt := 0; ; attempt++ {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if !s.serveRequest(mw, r, user, attempt < DB_Retries) {
+			return
+		}
+		log.VPrintf(1, "Retrying %s %s in %s", r.Method, r.URL, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// Process one attempt at handling the request. If the request failed due to
+// a transient DB error (e.g. a deadlock) before anything was sent to the
+// client, and 'canRetry' is true, then this returns true so that the
+// caller can try again. Since the Tx was rolled back, and nothing was sent,
+// this is safe regardless of the HTTP method. Errors from the commit itself
+// are never retried (see Tx.Commit) since it might have worked.
+func (s *Server) serveRequest(w *metricsWriter, r *http.Request, user string, canRetry bool) (retry bool) {
 	var info *RequestInfo
 	var err error
 	var tx *Tx
@@ -89,48 +240,40 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		tx.Rollback()
 	}()
 
-	saveVerbose := log.GetVerbose()
-	if tmp := r.URL.Query().Get("verbose"); tmp != "" {
-		if v, err := strconv.Atoi(tmp); err == nil {
-			log.SetVerbose(v)
-		}
-		defer log.SetVerbose(saveVerbose)
-	}
-
-	log.VPrintf(2, "%s %s", r.Method, r.URL)
-
-	if r.URL.Path == "/proxy" {
-		err := HTTPProxy(w, r)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Error proxying: " + err.Error() + "\n"))
-		}
-		return
-	}
-
 	tx, err = NewTx()
 	if err != nil {
+		if canRetry && IsTransientDBError(err) {
+			return true
+		}
 		w.WriteHeader(http.StatusInternalServerError)
 		w.Write([]byte("Error talking to DB, try again later\n"))
-		return
+		return false
 	}
 
 	info, err = ParseRequest(tx, w, r)
 	tx.RequestInfo = info
+	if err == nil {
+		w.path = info.MetricsPath()
+	}
 
 	if err != nil {
+		if canRetry && tx.IsRetryable() {
+			return true
+		}
 		if info.StatusCode == 0 {
 			info.StatusCode = http.StatusBadRequest
 		}
 		w.WriteHeader(info.StatusCode)
 		w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
-		return
+		return false
 	}
 
 	defer func() {
 		// If we haven't written anything, this will force the HTTP status code
 		// to be written and not default to 200
-		info.HTTPWriter.Done()
+		if !retry {
+			info.HTTPWriter.Done()
+		}
 	}()
 
 	if r.URL.Query().Has("ui") { // Wrap in html page
@@ -139,6 +282,14 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if r.URL.Query().Has("html") || r.URL.Query().Has("noprops") { //HTMLify it
 		info.HTTPWriter = NewBufferedWriter(info)
+	} else if info.WantsYAML() && !r.URL.Query().Has("ui") &&
+		info.RootPath != "events" {
+		// Convert any JSON response into YAML
+		info.HTTPWriter = NewYAMLWriter(info)
+	}
+
+	if err == nil && s.Auth != nil {
+		err = s.Auth.Authorize(info, user)
 	}
 
 	if err == nil {
@@ -150,7 +301,14 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	done := false
 	if err == nil {
+		// Check If-Match/If-None-Match. 'done' means we already know
+		// the response (e.g. 304) so don't process the request
+		done, err = CheckPreconditions(info)
+	}
+
+	if err == nil && !done {
 		// These should only return an error if they didn't already
 		// send a response back to the client.
 		switch r.Method {
@@ -170,15 +328,31 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	Must(tx.Conditional(err))
+	if txErr := tx.Conditional(err); txErr != nil && err == nil {
+		err = txErr
+		info.StatusCode = http.StatusInternalServerError
+	}
+
+	if err != nil && canRetry && tx.IsRetryable() && !info.SentStatus {
+		// Toss any headers we set, the next attempt will set them again
+		for name := range w.Header() {
+			w.Header().Del(name)
+		}
+		return true
+	}
 
 	if err != nil {
-		if info.StatusCode == 0 {
+		if _, ok := err.(*ForbiddenError); ok {
+			info.StatusCode = http.StatusForbidden
+		} else if _, ok := err.(*ConflictError); ok {
+			info.StatusCode = http.StatusConflict
+		} else if info.StatusCode == 0 {
 			// Only default to BadRequest if not set by someone else
 			info.StatusCode = http.StatusBadRequest
 		}
 		info.Write([]byte(err.Error() + "\n"))
 	}
+	return false
 }
 
 type HTTPWriter interface {
@@ -191,6 +365,7 @@ var _ HTTPWriter = &DefaultWriter{}
 var _ HTTPWriter = &BufferedWriter{}
 var _ HTTPWriter = &DiscardWriter{}
 var _ HTTPWriter = &PageWriter{}
+var _ HTTPWriter = &YAMLWriter{}
 
 func DefaultHTTPWriter(info *RequestInfo) HTTPWriter {
 	return &DefaultWriter{
@@ -264,6 +439,62 @@ func (bw *BufferedWriter) Done() {
 	bw.OldWriter.Write(buf)
 }
 
+// YAMLWriter holds onto any xRegistry metadata response (see
+// AddMetadataHeaders) so that it can be converted into YAML once we have
+// all of it. Anything else (e.g. Resource documents, even JSON ones) is
+// passed along untouched.
+type YAMLWriter struct {
+	Info      *RequestInfo
+	OldWriter HTTPWriter
+	Buffer    *bytes.Buffer
+	IsJSON    bool
+}
+
+func NewYAMLWriter(info *RequestInfo) *YAMLWriter {
+	return &YAMLWriter{
+		Info:      info,
+		OldWriter: info.HTTPWriter,
+		Buffer:    &bytes.Buffer{},
+	}
+}
+
+func (yw *YAMLWriter) Write(b []byte) (int, error) {
+	if !yw.IsJSON {
+		return yw.OldWriter.Write(b)
+	}
+	return yw.Buffer.Write(b)
+}
+
+func (yw *YAMLWriter) AddHeader(name, value string) {
+	if strings.EqualFold(name, "Content-Type") {
+		// Don't set it yet, we won't know which one to use until Done()
+		yw.IsJSON = yw.Info.IsMetadata && value == "application/json"
+		if yw.IsJSON {
+			return
+		}
+	}
+	yw.OldWriter.AddHeader(name, value)
+}
+
+func (yw *YAMLWriter) Done() {
+	if yw.IsJSON {
+		buf := yw.Buffer.Bytes()
+		contentType := "application/json"
+
+		// Errors are just text so only convert successful responses
+		if yw.Info.StatusCode < 300 && len(buf) > 0 {
+			if yamlBuf, err := JSONToYAML(buf); err == nil {
+				buf = yamlBuf
+				contentType = "application/yaml"
+			}
+		}
+
+		yw.OldWriter.AddHeader("Content-Type", contentType)
+		yw.OldWriter.Write(buf)
+	}
+	yw.OldWriter.Done()
+}
+
 type DiscardWriter struct{}
 
 func (dw *DiscardWriter) Write(b []byte) (int, error)  { return len(b), nil }
@@ -382,6 +613,7 @@ func GenerateUI(info *RequestInfo, data []byte) []byte {
 	options := ""
 	filters := ""
 	sortKey := ""
+	search := ""
 	inlines := ""
 	apply := ""
 
@@ -462,6 +694,23 @@ func GenerateUI(info *RequestInfo, data []byte) []byte {
 			"</div>\n"
 	}
 
+	if info.What == "Coll" && info.RootPath == "" {
+		terms, checked := "", ""
+		if info.Search != nil {
+			terms = html.EscapeString(info.Search.Terms)
+			if info.Search.Docs {
+				checked = " checked"
+			}
+		}
+		search = "<div class=sortsection>\n" +
+			"  <b>Search:</b>\n" +
+			"  <input type=text id=search value='" + terms + "'>\n" +
+			"  <div class=sortsectioncheckbox>\n" +
+			"    <input id=searchdocs type='checkbox'" + checked + "/>docs\n" +
+			"  </div>\n" +
+			"</div>\n"
+	}
+
 	if info.FlagEnabled("filter") && (info.RootPath == "" || info.RootPath == "export") {
 		prefix := MustPropPathFromPath(info.Abstract).UI()
 		if prefix != "" {
@@ -478,10 +727,7 @@ func GenerateUI(info *RequestInfo, data []byte) []byte {
 				}
 				next := MustPropPathFromDB(FE.Path).UI()
 				next, _ = strings.CutPrefix(next, prefix)
-				subF += next
-				if FE.Operator == FILTER_EQUAL {
-					subF += "=" + FE.Value
-				}
+				subF += next + FE.OpString()
 			}
 			filters += subF
 		}
@@ -660,13 +906,15 @@ func GenerateUI(info *RequestInfo, data []byte) []byte {
 	}
 
 	applyBtn := ""
-	if options != "" || filters != "" || sortKey != "" || inlines != "" {
+	if options != "" || filters != "" || sortKey != "" || search != "" ||
+		inlines != "" {
 		applyBtn = `<fieldset>
     <legend align=center>
       <button id=applyBtn onclick='apply()'>Apply</button>
     </legend>
     ` + options + `
     ` + sortKey + `
+    ` + search + `
     ` + filters + `
     ` + inlines + `
     ` + apply + `
@@ -1058,6 +1306,15 @@ function apply() {
     }
   }
 
+  var elem = document.getElementById("search")
+  if (elem != null && elem.value != "") {
+    loc += "` + AMP + `search=" + encodeURIComponent(elem.value)
+    elem = document.getElementById("searchdocs")
+    if (elem != null && elem.checked) {
+      loc += "` + AMP + `searchdocs"
+    }
+  }
+
   var elem = document.getElementById("filters")
   if (elem != null) {
     var filters = elem.value
@@ -1232,7 +1489,7 @@ func HTTPGETCapabilities(info *RequestInfo) error {
 		return err
 	}
 
-	info.AddHeader("Content-Type", "application/json")
+	info.AddMetadataHeaders()
 	info.Write(buf)
 	info.Write([]byte("\n"))
 	return nil
@@ -1264,7 +1521,7 @@ func HTTPGETModel(info *RequestInfo) error {
 		return err
 	}
 
-	info.AddHeader("Content-Type", "application/json")
+	info.AddMetadataHeaders()
 	info.Write(buf)
 	info.Write([]byte("\n"))
 	return nil
@@ -1290,7 +1547,7 @@ func HTTPGETModelSource(info *RequestInfo) error {
 		return err
 	}
 
-	info.AddHeader("Content-Type", "application/json")
+	info.AddMetadataHeaders()
 	info.Write(buf)
 	info.Write([]byte("\n"))
 	return nil
@@ -1450,6 +1707,15 @@ FROM FullTree WHERE RegSID=? AND `
 	info.AddHeader("Content-Location", info.BaseURL+"/"+version.Path)
 	info.AddHeader("Content-Disposition", info.ResourceUID)
 
+	if etag, err := info.GetETag(FOR_READ); err == nil && etag != "" {
+		info.AddHeader("ETag", etag)
+	}
+
+	if err := info.AddDeprecationHeaders(); err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
 	url := ""
 	singular := info.ResourceModel.Singular
 	if url = entity.GetAsString(singular + "url"); url != "" {
@@ -1472,9 +1738,9 @@ FROM FullTree WHERE RegSID=? AND `
 	log.VPrintf(3, singular+"proxyurl: %s", url)
 	if url != "" {
 		// Just act as a proxy and copy the remote resource as our response
-		resp, err := http.Get(url)
+		resp, err := Proxy.Get(url)
 		if err != nil {
-			info.StatusCode = http.StatusInternalServerError
+			info.StatusCode = http.StatusBadGateway
 			return err
 		}
 		if resp.StatusCode/100 != 2 {
@@ -1482,22 +1748,26 @@ FROM FullTree WHERE RegSID=? AND `
 			return fmt.Errorf("Remote error")
 		}
 
-		// Copy all HTTP headers
+		// Copy the HTTP headers, minus the hop-by-hop ones
 		for header, value := range resp.Header {
 			info.AddHeader(header, strings.Join(value, ","))
 		}
 
 		// Now copy the body
-		_, err = io.Copy(info, resp.Body)
-		if err != nil {
+		if _, err = info.Write(resp.Body); err != nil {
 			info.StatusCode = http.StatusInternalServerError
 			return err
 		}
 		return nil
 	}
 
-	buf := version.Get(singular)
-	if buf == nil {
+	// Stream it since it might be large, and in a BlobStore
+	rc, err := version.OpenContent()
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+	if rc == nil {
 		// No data so just return
 		/*
 			if info.StatusCode == 0 {
@@ -1506,8 +1776,12 @@ FROM FullTree WHERE RegSID=? AND `
 		*/
 		return nil
 	}
+	defer rc.Close()
 
-	info.Write(buf.([]byte))
+	if _, err = io.Copy(info, rc); err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
 
 	return nil
 }
@@ -1550,6 +1824,22 @@ func HTTPGet(info *RequestInfo) error {
 		return SerializeQuery(info, nil, "Registry", info.Filters)
 	}
 
+	if info.RootPath == "events" {
+		return HTTPEvents(info)
+	}
+
+	if info.RootPath == "r" {
+		return HTTPShortSelf(info)
+	}
+
+	if info.DiffFrom != "" {
+		return HTTPGETDiff(info)
+	}
+
+	if info.ReferencedBy != nil {
+		return HTTPGETReferencedBy(info)
+	}
+
 	// 'metaInBody' tells us whether xReg metadata should be in the http
 	// response body or not (meaning, the hasDoc doc)
 	metaInBody := (info.ResourceModel == nil) ||
@@ -1615,7 +1905,22 @@ func SerializeQuery(info *RequestInfo, resPaths map[string][]string,
 		info.AddInline("modelsource")
 	}
 
-	info.AddHeader("Content-Type", "application/json")
+	info.AddMetadataHeaders()
+
+	// Single entities get an ETag so clients can do conditional requests
+	if what == "Entity" || what == "Registry" {
+		if etag, err := info.GetETag(FOR_READ); err == nil && etag != "" {
+			info.AddHeader("ETag", etag)
+		}
+	}
+
+	if what == "Entity" {
+		if err := info.AddDeprecationHeaders(); err != nil {
+			info.StatusCode = http.StatusInternalServerError
+			return err
+		}
+	}
+
 	var jw *JsonWriter
 	hasData := false
 	keys := SortedKeys(resPaths)
@@ -1628,8 +1933,24 @@ func SerializeQuery(info *RequestInfo, resPaths map[string][]string,
 
 		// "!" is special - it means skip the query and just produce: {}
 		if len(paths) != 1 || paths[0] != "!" {
+			if info.Search != nil && what == "Coll" && len(paths) > 0 {
+				if err := info.LoadSearchScores(paths[0]); err != nil {
+					info.StatusCode = http.StatusInternalServerError
+					return err
+				}
+			}
+
+			// Only top-level collections on a GET are paginated
+			offset, limit := 0, 0
+			paginate := what == "Coll" && key == "" && info.Limit > 0 &&
+				info.OriginalRequest.Method == "GET" && len(paths) > 0
+			if paginate {
+				offset, limit = info.PageOffset, info.Limit
+			}
+
 			query, args, err := GenerateQuery(info.Registry, what, paths,
-				filters, info.DoDocView(), info.SortKey)
+				filters, info.DoDocView(), info.SortKey, info.Search,
+				offset, limit)
 			if err != nil {
 				return err
 			}
@@ -1647,6 +1968,19 @@ func SerializeQuery(info *RequestInfo, resPaths map[string][]string,
 				log.Printf("  Query: # results: %d (time: %s)",
 					len(results.AllRows), diff)
 			}
+
+			if paginate {
+				total, err := CountCollection(info.tx, info.Registry,
+					paths[0], filters, info.Search)
+				if err != nil {
+					info.StatusCode = http.StatusInternalServerError
+					return err
+				}
+				if offset+limit < total {
+					info.AddHeader("Link", fmt.Sprintf(`<%s>; rel="next"; `+
+						`count=%d`, info.NextPageURL(offset+limit), total))
+				}
+			}
 		}
 
 		jw = NewJsonWriter(info, results)
@@ -1796,6 +2130,12 @@ func HTTPPutPost(info *RequestInfo) error {
 		return fmt.Errorf("Use \"/modelsource\" instead of \"/model\"")
 	}
 
+	if info.RootPath == "events" || info.RootPath == "r" {
+		info.StatusCode = http.StatusMethodNotAllowed
+		return fmt.Errorf("%s is not allowed on \"/%s\"",
+			info.OriginalRequest.Method, info.RootPath)
+	}
+
 	// The model has its own special func
 	if info.RootPath == "modelsource" {
 		if !info.APIEnabled("/modelsource") {
@@ -1838,6 +2178,17 @@ func HTTPPutPost(info *RequestInfo) error {
 		return fmt.Errorf("PATCH is not allowed on Resource documents")
 	}
 
+	// A JSON Patch or Merge Patch is turned into a normal PATCH body
+	patchType, err := info.CheckPatchMediaType()
+	if err != nil {
+		return err
+	}
+	if patchType != "" {
+		if body, err = PatchToEntityBody(info, patchType, body); err != nil {
+			return err
+		}
+	}
+
 	// Ok, now start to deal with the incoming request
 	//////////////////////////////////////////////////
 
@@ -2336,7 +2687,7 @@ func HTTPPutPost(info *RequestInfo) error {
 			meta, err := resource.FindMeta(false, FOR_WRITE)
 			PanicIf(err != nil, "No meta %q: %s", resource.UID, err)
 
-			if meta.Get("readonly") == true {
+			if meta.Get("readonly") == true && !info.tx.IsAdmin() {
 				return fmt.Errorf("Write operations on read-only " +
 					"resources are not allowed")
 			}
@@ -2489,16 +2840,35 @@ func HTTPPUTCapabilities(info *RequestInfo) error {
 		return err
 	}
 
-	reqBody, err = RemoveSchema(reqBody)
+	patchType, err := info.CheckPatchMediaType()
+	if err != nil {
+		return err
+	}
+
+	reqBody, err = info.NormalizeBody(reqBody)
 	if err != nil {
 		return err
 	}
 
+	if patchType == "" {
+		reqBody, err = RemoveSchema(reqBody)
+		if err != nil {
+			return err
+		}
+	}
+
 	cap := &Capabilities{}
 
 	method := info.OriginalRequest.Method
 	if method == "PUT" {
 		// Fall thru
+	} else if patchType != "" {
+		// Apply the patch to the current capabilities
+		curJSON, _ := json.Marshal(info.Registry.Capabilities)
+		reqBody, err = info.ApplyPatch(patchType, curJSON, reqBody)
+		if err != nil {
+			return err
+		}
 	} else if method == "PATCH" {
 		// put current capabilities into a simple map
 		tmp := map[string]any{}
@@ -2542,7 +2912,13 @@ func HTTPPUTModelSource(info *RequestInfo) error {
 		return fmt.Errorf("%q not found", strings.Join(info.Parts, "/"))
 	}
 
-	if info.OriginalRequest.Method != "PUT" {
+	patchType, err := info.CheckPatchMediaType()
+	if err != nil {
+		return err
+	}
+
+	// PATCH is only allowed via a JSON Patch or Merge Patch
+	if info.OriginalRequest.Method != "PUT" && patchType == "" {
 		info.StatusCode = http.StatusMethodNotAllowed
 		return fmt.Errorf("%s not allowed on '/modelsource'",
 			info.OriginalRequest.Method)
@@ -2554,6 +2930,22 @@ func HTTPPUTModelSource(info *RequestInfo) error {
 		return err
 	}
 
+	reqBody, err = info.NormalizeBody(reqBody)
+	if err != nil {
+		return err
+	}
+
+	if patchType != "" {
+		modelSrc := info.Registry.Model.Source
+		if modelSrc == "" {
+			modelSrc = "{}"
+		}
+		reqBody, err = info.ApplyPatch(patchType, []byte(modelSrc), reqBody)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = info.Registry.Model.ApplyNewModelFromJSON(reqBody)
 	if err != nil {
 		return err
@@ -2631,6 +3023,11 @@ func HTTPDelete(info *RequestInfo) error {
 		return fmt.Errorf("Can't delete an entire registry")
 	}
 
+	if info.RootPath == "events" || info.RootPath == "r" {
+		info.StatusCode = http.StatusMethodNotAllowed
+		return fmt.Errorf("DELETE is not allowed on \"/%s\"", info.RootPath)
+	}
+
 	var err error
 	epochStr := info.GetFlag("epoch")
 	epochInt := -1
@@ -3146,7 +3543,12 @@ func ExtractIncomingObject(info *RequestInfo, body []byte) (Object, error) {
 			}
 		}
 
-		err := Unmarshal(body, &IncomingObj)
+		body, err := info.NormalizeBody(body)
+		if err != nil {
+			return nil, err
+		}
+
+		err = Unmarshal(body, &IncomingObj)
 		if err != nil {
 			return nil, err
 		}
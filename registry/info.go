@@ -1,9 +1,12 @@
 package registry
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/duglin/dlog"
 	. "github.com/xregistry/server/common"
@@ -33,14 +36,23 @@ type RequestInfo struct {
 	Filters          [][]*FilterExpr // [OR][AND] filter=e,e(and) &(or) filter=e
 	ShowDetails      bool            //	is $details present
 	SortKey          string          // [-]AttrName  - => descending
+	Limit            int             // ?limit, 0 means no pagination
+	PageOffset       int             // decoded from ?pagetoken
+	DiffFrom         string          // ?diff, Version to compare against
+	Search           *SearchExpr     // ?search, nil if not present
+	ReferencedBy     *RefsExpr       // ?referencedby, nil if not present
 
 	StatusCode int
 	SentStatus bool
 	HTTPWriter HTTPWriter `json:"-"`
+	IsMetadata bool       // xRegistry metadata, see AddMetadataHeaders
 
 	ProxyHost string
 	ProxyPath string
 
+	// Path -> ?search score of each matching entity in the collection
+	SearchScores map[string]float64
+
 	// extra stuff if we ever need to pass around data while processing
 	extras map[string]any
 }
@@ -48,7 +60,7 @@ type RequestInfo struct {
 var explicitInlines = []string{"capabilities", "model", "modelsource"}
 var nonModelInlines = append([]string{"*"}, explicitInlines...)
 var rootPaths = []string{"capabilities", "model", "modelsource",
-	"export", "proxy"}
+	"export", "proxy", "events", "r"}
 
 type Inline struct {
 	Path    string    // value from ?inline query param
@@ -200,6 +212,15 @@ func (ri *RequestInfo) AddHeader(name, value string) {
 	ri.HTTPWriter.AddHeader(name, value)
 }
 
+// Used for responses that are xRegistry metadata (entities, collections,
+// the model, capabilities, etc.) rather than a Resource's document. Only
+// these can be sent as YAML (see YAMLWriter), so they vary by Accept
+func (ri *RequestInfo) AddMetadataHeaders() {
+	ri.IsMetadata = true
+	ri.AddHeader("Vary", "Accept")
+	ri.AddHeader("Content-Type", "application/json")
+}
+
 type FilterExpr struct {
 	// User provided
 	Path     string // endpoints.id  TODO store a PropPath?
@@ -209,6 +230,66 @@ type FilterExpr struct {
 	// helpers
 	Abstract string
 	PropName string
+	Type     string // relational ops only: DECIMAL, TIMESTAMP or STRING
+}
+
+type SearchExpr struct {
+	Terms string // Words to look for, any of them can match
+	Docs  bool   // Search the Resource documents too (?searchdocs)
+}
+
+type RefsExpr struct {
+	From *string // Only from entities with this Abstract, nil means all
+}
+
+// Order matters, longer operators need to be checked first
+var filterOps = []struct {
+	Str string
+	Op  int
+}{
+	{"!=", FILTER_NOT_EQUAL},
+	{"<=", FILTER_LESS_EQUAL},
+	{">=", FILTER_GREATER_EQUAL},
+	{"<>", FILTER_LESS_GREATER},
+	{"<", FILTER_LESS},
+	{">", FILTER_GREATER},
+	{"=", FILTER_EQUAL},
+}
+
+func IsRelationalFilterOp(op int) bool {
+	return op == FILTER_LESS || op == FILTER_LESS_EQUAL ||
+		op == FILTER_GREATER || op == FILTER_GREATER_EQUAL ||
+		op == FILTER_LESS_GREATER
+}
+
+// Returns the string version of the filter's operator and value, used when
+// we need to show the filter back to the user (eg. in the UI)
+func (fe *FilterExpr) OpString() string {
+	switch fe.Operator {
+	case FILTER_PRESENT:
+		return ""
+	case FILTER_ABSENT:
+		return "=null"
+	}
+	for _, fo := range filterOps {
+		if fo.Op == fe.Operator {
+			return fo.Str + fe.Value
+		}
+	}
+	return ""
+}
+
+// Split a filter expression into its path, operator and value. The first
+// operator found wins, so "a=b<c" is "a" "=" "b<c".
+func SplitFilterExpr(expr string) (string, int, string) {
+	for i := 0; i < len(expr); i++ {
+		for _, fo := range filterOps {
+			if strings.HasPrefix(expr[i:], fo.Str) {
+				return expr[:i], fo.Op, expr[i+len(fo.Str):]
+			}
+		}
+	}
+	return expr, FILTER_PRESENT, ""
 }
 
 func ParseRequest(tx *Tx, w http.ResponseWriter, r *http.Request) (*RequestInfo, error) {
@@ -276,24 +357,16 @@ func (info *RequestInfo) ParseFilters() error {
 				continue
 			}
 
-			filterOp := FILTER_PRESENT
+			// No operator means FILTER_PRESENT
+			path, filterOp, value := SplitFilterExpr(expr)
 
-			path, value, found := strings.Cut(expr, "!=")
-			if found {
-				// Note that "xxx!=null" is the same as "xxx"
-				if value != "null" {
-					filterOp = FILTER_NOT_EQUAL
+			if value == "null" {
+				if filterOp == FILTER_NOT_EQUAL {
+					// Note that "xxx!=null" is the same as "xxx"
+					filterOp = FILTER_PRESENT
+				} else if filterOp == FILTER_EQUAL {
+					filterOp = FILTER_ABSENT
 				}
-			} else {
-				path, value, found = strings.Cut(expr, "=")
-				if found {
-					if value == "null" {
-						filterOp = FILTER_ABSENT
-					} else {
-						filterOp = FILTER_EQUAL
-					}
-				}
-				// No "=" or "!=" means FILTER_PRESENT
 			}
 
 			pp, err := PropPathFromUI(path)
@@ -323,6 +396,12 @@ func (info *RequestInfo) ParseFilters() error {
 			}
 			filter.Abstract, filter.PropName = SplitProp(info.Registry, path)
 
+			if IsRelationalFilterOp(filterOp) {
+				if err := info.SetFilterType(filter, pp.UI()); err != nil {
+					return err
+				}
+			}
+
 			if AndFilters == nil {
 				AndFilters = []*FilterExpr{}
 			}
@@ -339,6 +418,150 @@ func (info *RequestInfo) ParseFilters() error {
 	return nil
 }
 
+// Relational operators (<, <=, >, >=, <>) compare values based on the type
+// of the attribute being checked. If the attribute is defined in the model
+// then its type is used, otherwise we'll guess based on the value itself.
+// The filter's Value is normalized so it can be directly compared to what's
+// in the DB.
+func (info *RequestInfo) SetFilterType(filter *FilterExpr, name string) error {
+	value := filter.Value
+
+	if value == "" || value == "null" {
+		return fmt.Errorf("A value must be specified for filter %q when "+
+			"using a relational operator", name)
+	}
+
+	if _, wild := WildcardIt(value); wild {
+		return fmt.Errorf("Wildcards aren't allowed in filter %q when "+
+			"using a relational operator", name)
+	}
+
+	attrType := ""
+	if attr := FindFilterAttribute(info.Registry, filter.Abstract,
+		filter.PropName); attr != nil {
+		attrType = attr.Type
+	}
+
+	// "now" is a shortcut for the current time, for things like:
+	// ?filter=meta.deprecated.removal<now
+	// Only for timestamp attributes since for any others "now" might be a
+	// real value
+	if value == "now" && attrType == TIMESTAMP {
+		filter.Value = time.Now().UTC().Format(time.RFC3339Nano)
+		filter.Type = TIMESTAMP
+		return nil
+	}
+
+	switch attrType {
+	case INTEGER, UINTEGER, DECIMAL:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("Value (%s) for filter %q must be a number",
+				value, name)
+		}
+		filter.Type = DECIMAL
+
+	case TIMESTAMP:
+		ts, err := NormalizeStrTime(value)
+		if err != nil {
+			return fmt.Errorf("Value (%s) for filter %q must be a timestamp",
+				value, name)
+		}
+		filter.Value = ts
+		filter.Type = TIMESTAMP
+
+	case STRING, URI, URI_REFERENCE, URI_TEMPLATE, URL, XID, XIDTYPE:
+		filter.Type = STRING
+
+	case "", ANY:
+		// Unknown type so guess based on the value
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			filter.Type = DECIMAL
+		} else if ts, err := NormalizeStrTime(value); err == nil {
+			filter.Value = ts
+			filter.Type = TIMESTAMP
+		} else {
+			filter.Type = STRING
+		}
+
+	default:
+		return fmt.Errorf("Relational operators aren't allowed on %q "+
+			"since it is of type %q", name, attrType)
+	}
+
+	return nil
+}
+
+// Returns the model's definition of the attribute that a filter
+// references, or nil if there isn't one (eg. it's an extension that isn't
+// defined). Nested attributes (eg. meta.deprecated.removal) are found by
+// walking down through their parent objects, maps and arrays.
+func FindFilterAttribute(reg *Registry, abstract string, propName string) *Attribute {
+	pp, err := PropPathFromDB(propName)
+	if err != nil || pp.Len() == 0 {
+		return nil
+	}
+
+	// Spec defined attributes first, then the user defined ones
+	attrs := map[string]*Attribute(nil)
+	userAttrs := []Attributes{}
+
+	parts := strings.Split(abstract, string(DB_IN))
+	if abstract == "" {
+		_, attrs = reg.Model.GetPropsOrdered()
+		userAttrs = append(userAttrs, reg.Model.Attributes)
+	} else if len(parts) == 1 {
+		if gm := reg.Model.FindGroupModel(parts[0]); gm != nil {
+			_, attrs = gm.GetPropsOrdered()
+			userAttrs = append(userAttrs, gm.Attributes)
+		}
+	} else if rm := reg.Model.FindResourceModel(parts[0],
+		parts[1]); rm != nil {
+
+		if len(parts) > 2 && parts[2] == "meta" {
+			_, attrs = rm.GetMetaPropsOrdered()
+			userAttrs = append(userAttrs, rm.MetaAttributes)
+		} else if len(parts) > 2 && parts[2] == "versions" {
+			_, attrs = rm.GetVersionPropsOrdered()
+			userAttrs = append(userAttrs, rm.VersionAttributes)
+		} else {
+			_, attrs = rm.GetPropsOrdered()
+			userAttrs = append(userAttrs, rm.ResourceAttributes,
+				rm.VersionAttributes)
+		}
+	}
+
+	attr := attrs[pp.Top()]
+	for _, ua := range userAttrs {
+		if attr != nil {
+			break
+		}
+		attr = ua[pp.Top()]
+	}
+
+	for pp = pp.Next(); attr != nil && pp != nil; pp = pp.Next() {
+		switch attr.Type {
+		case OBJECT:
+			next := attr.Attributes[pp.Top()]
+			if next == nil {
+				next = attr.Attributes["*"]
+			}
+			attr = next
+		case MAP, ARRAY:
+			if attr.Item == nil {
+				return nil
+			}
+			attr = &Attribute{
+				Type:       attr.Item.Type,
+				Attributes: attr.Item.Attributes,
+				Item:       attr.Item.Item,
+			}
+		default:
+			return nil
+		}
+	}
+	return attr
+}
+
 // path.DB() -> abstract.Abstract() + propName.DB()
 func SplitProp(reg *Registry, path string) (string, string) {
 	pp := MustPropPathFromDB(path)
@@ -414,6 +637,12 @@ func (info *RequestInfo) ParseRequestURL() error {
 		return err
 	}
 
+	// "/r" passes the rest of the query parameters along to the entity
+	// it redirects to, so let that request verify them
+	if info.RootPath == "r" {
+		return nil
+	}
+
 	// Some of these have to come after we parse the path so that the
 	// group/resource info is setup - for verification
 
@@ -423,6 +652,7 @@ func (info *RequestInfo) ParseRequestURL() error {
 		info.tx.IgnoreEpoch = info.HasFlag("noepoch")
 		info.tx.IgnoreDefaultVersionSticky = info.HasFlag("nodefaultversionsticky")
 		info.tx.IgnoreDefaultVersionID = info.HasFlag("nodefaultversionid")
+		info.tx.MigrateModel = info.HasFlag("migrate")
 	}
 
 	if info.HasFlag("inline") {
@@ -488,9 +718,208 @@ func (info *RequestInfo) ParseRequestURL() error {
 		}
 	}
 
+	if err := info.ParseReferencedBy(); err != nil {
+		return err
+	}
+
+	if err := info.ParsePagination(); err != nil {
+		return err
+	}
+
+	if err := info.ParseDiff(); err != nil {
+		return err
+	}
+
+	if err := info.ParseSearch(); err != nil {
+		return err
+	}
+
 	return info.ParseFilters()
 }
 
+// Look for ?limit and ?pagetoken. These aren't "flags" in the capabilities
+// sense, they're controlled by the "pagination" capability instead.
+func (info *RequestInfo) ParsePagination() error {
+	params := info.OriginalRequest.URL.Query()
+
+	if !params.Has("limit") && !params.Has("pagetoken") {
+		return nil
+	}
+
+	if info.Registry == nil || info.Registry.Capabilities == nil ||
+		!info.Registry.Capabilities.PaginationEnabled() {
+		return nil
+	}
+
+	if info.What != "Coll" && info.ReferencedBy == nil {
+		return fmt.Errorf("Pagination is only allowed on collections")
+	}
+
+	limitStr := params.Get("limit")
+	if limitStr == "" {
+		return fmt.Errorf("?pagetoken requires ?limit to be specified")
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		return fmt.Errorf("Invalid ?limit value %q, must be a positive "+
+			"integer", limitStr)
+	}
+	info.Limit = limit
+
+	if token := params.Get("pagetoken"); token != "" {
+		offset, err := DecodePageToken(token)
+		if err != nil {
+			return err
+		}
+		info.PageOffset = offset
+	}
+
+	return nil
+}
+
+// Page tokens are opaque to clients. For now they just wrap the offset
+// of the first entity in the next page, but clients shouldn't depend on that.
+func EncodePageToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("o:%d", offset)))
+}
+
+func DecodePageToken(token string) (int, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		if str, ok := strings.CutPrefix(string(buf), "o:"); ok {
+			offset, err := strconv.Atoi(str)
+			if err == nil && offset >= 0 {
+				return offset, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("Invalid ?pagetoken value %q", token)
+}
+
+// Look for ?diff. Like ?limit, this isn't a spec defined flag so it's not
+// controlled by the "flags" capability.
+func (info *RequestInfo) ParseDiff() error {
+	params := info.OriginalRequest.URL.Query()
+	if !params.Has("diff") {
+		return nil
+	}
+
+	if info.OriginalRequest.Method != "GET" {
+		return fmt.Errorf("?diff is only allowed on a GET")
+	}
+
+	if info.What != "Entity" || info.ResourceUID == "" ||
+		(len(info.Parts) == 5 && info.Parts[4] == "meta") {
+		return fmt.Errorf("?diff is only allowed on a Resource or Version")
+	}
+
+	if info.DiffFrom = params.Get("diff"); info.DiffFrom == "" {
+		return fmt.Errorf("Missing ?diff value")
+	}
+	return nil
+}
+
+// Look for ?referencedby[=XIDTYPE]. Like ?limit, this isn't a spec defined
+// flag so it's not controlled by the "flags" capability.
+func (info *RequestInfo) ParseReferencedBy() error {
+	params := info.OriginalRequest.URL.Query()
+	if !params.Has("referencedby") {
+		return nil
+	}
+
+	if info.OriginalRequest.Method != "GET" ||
+		(info.What != "Entity" && info.What != "Registry") {
+		return fmt.Errorf("?referencedby is only allowed on a GET of an " +
+			"entity")
+	}
+
+	info.ReferencedBy = &RefsExpr{}
+
+	str := params.Get("referencedby")
+	if str == "" {
+		return nil
+	}
+
+	xidType, err := ParseXidType(str)
+	if err != nil {
+		return fmt.Errorf("Invalid ?referencedby value %q: %s", str, err)
+	}
+
+	parts := []string{}
+	if xidType.Group != "" {
+		gm := info.Registry.Model.FindGroupModel(xidType.Group)
+		if gm == nil {
+			return fmt.Errorf("Invalid ?referencedby value %q: unknown "+
+				"Group type %q", str, xidType.Group)
+		}
+		parts = append(parts, gm.Plural)
+
+		if xidType.Resource != "" {
+			rm := gm.FindResourceModel(xidType.Resource)
+			if rm == nil {
+				return fmt.Errorf("Invalid ?referencedby value %q: unknown "+
+					"Resource type %q", str, xidType.Resource)
+			}
+			parts = append(parts, rm.Plural)
+
+			if xidType.Version != "" {
+				parts = append(parts, xidType.Version)
+			}
+		}
+	}
+
+	abstract := strings.Join(parts, string(DB_IN))
+	info.ReferencedBy.From = &abstract
+	return nil
+}
+
+// Look for ?search and ?searchdocs. Like ?limit these aren't spec defined
+// flags so they're not controlled by the "flags" capability.
+func (info *RequestInfo) ParseSearch() error {
+	params := info.OriginalRequest.URL.Query()
+	if !params.Has("search") {
+		if params.Has("searchdocs") {
+			return fmt.Errorf("?searchdocs requires ?search to be specified")
+		}
+		return nil
+	}
+
+	if info.OriginalRequest.Method != "GET" || info.What != "Coll" {
+		return fmt.Errorf("?search is only allowed on a GET of a collection")
+	}
+
+	terms := strings.TrimSpace(params.Get("search"))
+	if terms == "" {
+		return fmt.Errorf("Missing ?search value")
+	}
+
+	info.Search = &SearchExpr{
+		Terms: terms,
+		Docs:  params.Has("searchdocs"),
+	}
+	return nil
+}
+
+// Returns true if the results are being limited by ?filter or ?search
+func (info *RequestInfo) HasFilters() bool {
+	return len(info.Filters) > 0 || info.Search != nil
+}
+
+// Returns the URL of the next page of the current (collection) request.
+// All query parameters, other than ?pagetoken, are preserved so things like
+// ?filter, ?sort and ?inline apply to the next page too.
+func (info *RequestInfo) NextPageURL(offset int) string {
+	params := info.OriginalRequest.URL.Query()
+	params.Set("pagetoken", EncodePageToken(offset))
+
+	path := strings.Join(info.Parts, "/")
+	if path != "" {
+		path = "/" + path
+	}
+	return info.BaseURL + path + "?" + params.Encode()
+}
+
 func (info *RequestInfo) ParseRequestPath() error {
 	// Now process the URL path
 	log.VPrintf(4, "ParseRequestPath: %q", info.OriginalPath)
@@ -699,3 +1128,23 @@ func (info *RequestInfo) APIEnabled(name string) bool {
 func (info *RequestInfo) DoDocView() bool {
 	return info.HasFlag("doc") || info.RootPath == "export"
 }
+
+// Returns true if the client sent the request body as YAML
+func (info *RequestInfo) HasYAMLBody() bool {
+	return IsYAMLMediaType(info.OriginalRequest.Header.Get("Content-Type"))
+}
+
+// Returns true if the client would prefer the response in YAML
+func (info *RequestInfo) WantsYAML() bool {
+	return AcceptsYAML(info.OriginalRequest.Header.Get("Accept"))
+}
+
+// If the incoming body is YAML then convert it to JSON so the rest of the
+// code only ever needs to deal with JSON. This should only be used on
+// bodies that hold xRegistry data (not Resource documents)
+func (info *RequestInfo) NormalizeBody(body []byte) ([]byte, error) {
+	if len(body) == 0 || !info.HasYAMLBody() {
+		return body, nil
+	}
+	return YAMLToJSON(body)
+}
@@ -0,0 +1,565 @@
+-- SQLite version of init.sql. See init.sql for the details of each table
+-- and view. The two files need to be kept in sync.
+
+-- Differences from the MySQL version:
+-- - columns that use MySQL's default (case-insensitive) collation use
+--   NOCASE, while the utf8mb4_bin ones use SQLite's default (BINARY)
+-- - SQLite triggers don't support IF statements so those are split into
+--   one trigger per condition (using WHEN)
+-- - indexes need to be created outside of CREATE TABLE
+
+CREATE TABLE Registries (
+    SID     VARCHAR(255) NOT NULL COLLATE NOCASE,  -- System ID
+    UID     VARCHAR(255) NOT NULL COLLATE NOCASE,  -- User defined
+
+    PRIMARY KEY (SID),
+    UNIQUE (UID)
+);
+
+CREATE TRIGGER RegistryTrigger BEFORE DELETE ON Registries
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props    WHERE RegistrySID=OLD.SID $$
+    DELETE FROM "Groups" WHERE RegistrySID=OLD.SID $$
+    DELETE FROM Models   WHERE RegistrySID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE RegistrySID=OLD.SID $$
+    DELETE FROM XIDRefs WHERE RegistrySID=OLD.SID $$
+END ;
+
+CREATE TABLE Models (
+    RegistrySID VARCHAR(64) NOT NULL COLLATE NOCASE,
+    Model       JSON,                     -- Full model, not just Registry
+
+    PRIMARY KEY (RegistrySID)
+);
+
+CREATE TRIGGER ModelsTrigger BEFORE DELETE ON Models
+FOR EACH ROW
+BEGIN
+    DELETE FROM ModelEntities WHERE RegistrySID=OLD.RegistrySID $$
+END ;
+
+CREATE TABLE ModelEntities (        -- Group or Resource (no parentSID=Group)
+    SID               VARCHAR(255) COLLATE NOCASE,  -- my System ID
+    RegistrySID       VARCHAR(64) COLLATE NOCASE,
+    ParentSID         VARCHAR(64) COLLATE NOCASE,   -- ID of parent
+    Abstract          VARCHAR(255) COLLATE NOCASE,  -- /GROUPS, /GROUPS/RESOURCES
+
+    -- For Groups and Resources
+    Plural            VARCHAR(64) COLLATE NOCASE,
+    Singular          VARCHAR(64) COLLATE NOCASE,
+    Description       VARCHAR(255) COLLATE NOCASE,
+    ModelVersion      VARCHAR(255) COLLATE NOCASE,
+    CompatibleWith    VARCHAR(255) COLLATE NOCASE,
+    Labels            JSON,
+    XImportResources  VARCHAR($MAX_VARCHAR) COLLATE NOCASE,
+    Attributes        JSON,               -- Until we use the Attributes table
+
+    -- For Resources
+    MaxVersions       INT,
+    SetVersionId      BOOL,
+    SetDefaultSticky  BOOL,
+    HasDocument       BOOL,
+    SingleVersionRoot BOOL,
+    TypeMap           JSON,
+    MetaAttributes    JSON,
+
+    PRIMARY KEY(SID),
+    UNIQUE (RegistrySID, ParentSID, Plural),
+    UNIQUE (RegistrySID, Abstract),
+    CONSTRAINT UC_Singular UNIQUE (RegistrySID, ParentSID, Singular)
+);
+
+CREATE TRIGGER ModelTrigger BEFORE DELETE ON ModelEntities
+FOR EACH ROW
+BEGIN
+    DELETE FROM "Groups"        WHERE ModelSID=OLD.SID $$
+    DELETE FROM Resources       WHERE ModelSID=OLD.SID $$
+END ;
+
+CREATE TABLE "Groups" (
+    SID             VARCHAR(64) NOT NULL COLLATE NOCASE,   -- System ID
+    UID             VARCHAR(64) NOT NULL COLLATE NOCASE,   -- User defined
+    RegistrySID     VARCHAR(64) NOT NULL COLLATE NOCASE,
+    ModelSID        VARCHAR(64) NOT NULL COLLATE NOCASE,
+    Path            VARCHAR(255) NOT NULL,
+    Abstract        VARCHAR(255) NOT NULL,
+    Plural          VARCHAR(64) NOT NULL COLLATE NOCASE,
+    Singular        VARCHAR(64) NOT NULL COLLATE NOCASE,
+
+    PRIMARY KEY (SID),
+    UNIQUE (RegistrySID, ModelSID, UID)
+);
+
+CREATE INDEX GroupsRegUID ON "Groups" (RegistrySID, UID);
+
+CREATE TRIGGER GroupTrigger BEFORE DELETE ON "Groups"
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props WHERE EntitySID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE EntitySID=OLD.SID $$
+    DELETE FROM Resources WHERE GroupSID=OLD.SID $$
+END ;
+
+CREATE TABLE Resources (
+    SID             VARCHAR(64) NOT NULL COLLATE NOCASE,   -- System ID
+    UID             VARCHAR(64) NOT NULL COLLATE NOCASE,   -- User defined
+    RegistrySID     VARCHAR(64) NOT NULL COLLATE NOCASE,
+    GroupSID        VARCHAR(64) NOT NULL COLLATE NOCASE,   -- System ID
+    ModelSID        VARCHAR(64) NOT NULL COLLATE NOCASE,
+    Path            VARCHAR(255) NOT NULL,
+    Abstract        VARCHAR(255) NOT NULL,
+    Plural          VARCHAR(64) NOT NULL COLLATE NOCASE,
+    Singular        VARCHAR(64) NOT NULL COLLATE NOCASE,
+
+    PRIMARY KEY (SID),
+    UNIQUE (RegistrySID,SID),
+    UNIQUE (GroupSID, ModelSID, UID)
+);
+
+CREATE INDEX ResourcesGroupUID ON Resources (GroupSID, UID);
+CREATE INDEX ResourcesPath ON Resources (Path);
+CREATE INDEX ResourcesReg ON Resources (RegistrySID);
+
+CREATE TRIGGER ResourcesTrigger BEFORE DELETE ON Resources
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props WHERE EntitySID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE EntitySID=OLD.SID $$
+    DELETE FROM Metas WHERE ResourceSID=OLD.SID $$
+    DELETE FROM Versions WHERE ResourceSID=OLD.SID $$
+END ;
+
+CREATE TABLE Metas (
+    SID             VARCHAR(64) NOT NULL COLLATE NOCASE,   -- System ID
+    RegistrySID     VARCHAR(64) NOT NULL COLLATE NOCASE,
+    ResourceSID     VARCHAR(64) NOT NULL COLLATE NOCASE,   -- System ID
+    Path            VARCHAR(255) NOT NULL,
+    Abstract        VARCHAR(255) NOT NULL,
+    Plural          VARCHAR(64) NOT NULL COLLATE NOCASE,
+    Singular        VARCHAR(64) NOT NULL COLLATE NOCASE,
+
+    xRefSID         VARCHAR(64) COLLATE NOCASE,           -- Generated
+    defaultVID      VARCHAR(64) COLLATE NOCASE,           -- Generated
+
+    PRIMARY KEY (SID),
+    UNIQUE (RegistrySID,SID)
+);
+
+CREATE INDEX MetasRegResource ON Metas (RegistrySID, ResourceSID);
+CREATE INDEX MetasRegPath ON Metas (RegistrySID, Path);
+CREATE INDEX MetasReg ON Metas (RegistrySID);
+CREATE INDEX MetasRegXRef ON Metas (RegistrySID,xRefSID);
+
+CREATE TABLE Versions (
+    SID                 VARCHAR(64) NOT NULL COLLATE NOCASE,   -- System ID
+    UID                 VARCHAR(64) NOT NULL COLLATE NOCASE,   -- User defined
+    RegistrySID         VARCHAR(64) NOT NULL COLLATE NOCASE,
+    ResourceSID         VARCHAR(64) NOT NULL COLLATE NOCASE,   -- System ID
+    Path                VARCHAR(255) NOT NULL,
+    Abstract            VARCHAR(255) NOT NULL,
+
+    Ancestor            VARCHAR(65) NOT NULL DEFAULT '',       -- Generated
+    CreatedAt           VARCHAR(255) COLLATE NOCASE,           -- Generated
+
+    PRIMARY KEY (SID),
+    UNIQUE (ResourceSID, UID),
+    UNIQUE (RegistrySID, SID)
+);
+
+CREATE INDEX VersionsResource ON Versions (ResourceSID);
+CREATE INDEX VersionsAncestor ON Versions (RegistrySID, ResourceSID, Ancestor);
+
+CREATE TABLE Props (
+    RegistrySID VARCHAR(64) NOT NULL COLLATE NOCASE,
+    EntitySID   VARCHAR(64) NOT NULL COLLATE NOCASE,  -- Reg,Group,Res,Ver SID
+    eType       INT NOT NULL,
+    PropName    VARCHAR($MAX_PROPNAME) NOT NULL COLLATE NOCASE,
+    PropValue   VARCHAR($MAX_VARCHAR) COLLATE NOCASE,
+    PropType    CHAR(64) NOT NULL COLLATE NOCASE,     -- string, boolean, ...
+    DocView     BOOL NOT NULL,              -- Should include during doc view?
+
+    PRIMARY KEY (EntitySID, PropName)
+);
+
+CREATE INDEX PropsEntity ON Props (EntitySID);
+CREATE INDEX PropsRegName ON Props (RegistrySID, PropName);
+
+CREATE TRIGGER PropsAncestor BEFORE INSERT ON Props
+FOR EACH ROW
+WHEN NEW.eType=$ENTITY_VERSION AND NEW.PropName='ancestor$DB_IN'
+BEGIN
+    UPDATE Versions SET Ancestor=NEW.PropValue WHERE SID=NEW.EntitySID $$
+END ;
+
+CREATE TRIGGER PropsCreatedAt BEFORE INSERT ON Props
+FOR EACH ROW
+WHEN NEW.eType=$ENTITY_VERSION AND NEW.PropName='createdat$DB_IN'
+BEGIN
+    UPDATE Versions SET CreatedAt=NEW.PropValue WHERE SID=NEW.EntitySID $$
+END ;
+
+CREATE TRIGGER PropsXrefInsert BEFORE INSERT ON Props
+FOR EACH ROW
+WHEN NEW.eType=$ENTITY_META AND NEW.PropName='xref$DB_IN'
+BEGIN
+    -- Remove leading /
+    UPDATE Metas SET xRefSID=(
+        SELECT SID FROM Resources WHERE
+            RegistrySID=NEW.RegistrySID AND
+            Path=SUBSTR(NEW.PropValue,2))
+      WHERE SID=NEW.EntitySID $$
+END ;
+
+CREATE TRIGGER PropsDefaultInsert BEFORE INSERT ON Props
+FOR EACH ROW
+WHEN NEW.eType=$ENTITY_META AND NEW.PropName='defaultversionid$DB_IN'
+BEGIN
+    UPDATE Metas SET defaultVID=NEW.PropValue WHERE SID=NEW.EntitySID $$
+END ;
+
+CREATE TRIGGER PropsXref BEFORE DELETE ON Props
+FOR EACH ROW
+WHEN OLD.eType=$ENTITY_META AND OLD.PropName='xref$DB_IN'
+BEGIN
+    UPDATE Metas SET xRefSID=NULL WHERE SID=OLD.EntitySID $$
+END ;
+
+CREATE TRIGGER PropsDefaultDelete BEFORE DELETE ON Props
+FOR EACH ROW
+WHEN OLD.eType=$ENTITY_META AND OLD.PropName='defaultversionid$DB_IN'
+BEGIN
+    UPDATE Metas SET defaultVID=NULL WHERE SID=OLD.EntitySID $$
+END ;
+
+CREATE TRIGGER PropsXIDRefs BEFORE DELETE ON Props
+FOR EACH ROW
+BEGIN
+    DELETE FROM XIDRefs
+      WHERE EntitySID=OLD.EntitySID AND PropName=OLD.PropName $$
+END ;
+
+CREATE TRIGGER VersionsTrigger BEFORE DELETE ON Versions
+FOR EACH ROW
+BEGIN
+    DELETE FROM Props WHERE EntitySID=OLD.SID $$
+    DELETE FROM ResourceContents WHERE VersionSID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE EntitySID=OLD.SID $$
+END ;
+
+CREATE VIEW Entities AS
+SELECT                          -- Gather Registries
+    r.SID AS RegSID,
+    $ENTITY_REGISTRY AS Type,
+    'registries' AS Plural,
+    'registry' AS Singular,
+    NULL AS ParentSID,
+    r.SID AS eSID,
+    r.UID AS UID,
+    '' AS Abstract,
+    '' AS Path
+FROM Registries AS r
+
+UNION ALL SELECT                -- Gather Groups
+    g.RegistrySID AS RegSID,
+    $ENTITY_GROUP AS Type,
+    g.Plural AS Plural,
+    g.Singular AS Singular,
+    g.RegistrySID AS ParentSID,
+    g.SID AS eSID,
+    g.UID AS UID,
+    g.Abstract,
+    g.Path
+FROM "Groups" AS g
+
+UNION ALL SELECT                -- Add Resources
+    r.RegistrySID AS RegSID,
+    $ENTITY_RESOURCE AS Type,
+    r.Plural AS Plural,
+    r.Singular AS Singular,
+    r.GroupSID AS ParentSID,
+    r.SID AS eSID,
+    r.UID AS UID,
+    r.Abstract,
+    r.Path
+FROM Resources AS r
+
+UNION ALL SELECT                -- Add Metas
+    metas.RegistrySID AS RegSID,
+    $ENTITY_META AS Type,
+    'metas' AS Plural,
+    'meta' AS Singular,
+    metas.ResourceSID AS ParentSID,
+    metas.SID AS eSID,
+    'meta',
+    metas.Abstract,
+    metas.Path
+FROM Metas AS metas
+
+UNION ALL SELECT                -- Add Versions for non-xref Resources
+    v.RegistrySID AS RegSID,
+    $ENTITY_VERSION AS Type,
+    'versions' AS Plural,
+    'version' AS Singular,
+    v.ResourceSID AS ParentSID,
+    v.SID AS eSID,
+    v.UID AS UID,
+    v.Abstract,
+    v.Path
+FROM Versions AS v
+
+UNION ALL SELECT                -- Add Versions for xref Resources
+    v.RegistrySID AS RegSID,
+    $ENTITY_VERSION AS Type,
+    'versions' AS Plural,
+    'version' AS Singular,
+    m.ResourceSID AS ParentSID,
+    CONCAT('-', m.ResourceSID, '-', v.SID) AS eSID,
+    v.UID AS UID,
+    CONCAT(sR.Abstract, ',versions') AS Abstract,
+    CONCAT(sR.Path, '/versions/', v.UID) AS Path
+FROM Metas AS m
+JOIN Versions AS v ON (v.ResourceSID=m.xRefSID)
+JOIN Resources AS sR ON (sR.SID=m.ResourceSID)
+WHERE m.xRefSID IS NOT NULL ;
+
+CREATE TABLE ResourceContents (
+    VersionSID      VARCHAR(255) COLLATE NOCASE,
+    Content         BLOB,                   -- NULL if in the BlobStore
+    ContentHash     VARCHAR(64),            -- BlobStore key (SHA-256)
+    SearchText      TEXT,                   -- Content, if it's text. ?search
+
+    PRIMARY KEY (VersionSID)
+);
+
+CREATE INDEX ResourceContentsHash ON ResourceContents (ContentHash);
+
+-- Maps the "u" value of a "shortself" URL (MD5 of the Path) back to the
+-- entity's Path. Metas use their Resource's SID so they go away with it
+CREATE TABLE ShortSelfs (
+    RegistrySID     VARCHAR(64) NOT NULL COLLATE NOCASE,
+    ShortSelf       VARCHAR(64) NOT NULL,
+    EntitySID       VARCHAR(64) NOT NULL COLLATE NOCASE,   -- Group,Res,Ver System ID
+    Path            VARCHAR($MAX_VARCHAR) NOT NULL,
+
+    PRIMARY KEY (RegistrySID, ShortSelf)
+);
+
+CREATE INDEX ShortSelfsEntity ON ShortSelfs (EntitySID);
+
+-- The "xid" attribute values of each entity, for ?referencedby. Maintained
+-- by Entity.Save() since the Props don't know their attribute's type, and
+-- the rows go away with their Props. Metas.xRefSID covers "xref"
+CREATE TABLE XIDRefs (
+    RegistrySID     VARCHAR(64) NOT NULL COLLATE NOCASE,
+    EntitySID       VARCHAR(64) NOT NULL COLLATE NOCASE,  -- Reg,Group,Res,Ver
+    PropName        VARCHAR($MAX_PROPNAME) NOT NULL COLLATE NOCASE,
+    Target          VARCHAR($MAX_VARCHAR) NOT NULL,
+
+    PRIMARY KEY (EntitySID, PropName)
+);
+
+CREATE INDEX XIDRefsTarget ON XIDRefs (RegistrySID, Target);
+
+-- Names of the dbUpgrades that have already been applied, see upgrade.go
+CREATE TABLE DBUpgrades (
+    Name    VARCHAR(64) NOT NULL,
+
+    PRIMARY KEY (Name)
+);
+
+-- This pulls-in or creates all props in Resources due to default Ver processing
+CREATE VIEW DefaultProps AS
+SELECT                             -- Get default prop for non-xref resources
+    p.RegistrySID,
+    m.ResourceSID AS EntitySID,
+    p.PropName,
+    p.PropValue,
+    p.PropType,
+    false                          -- DocView
+FROM Metas AS m
+JOIN Versions AS v
+  ON (m.ResourceSID=v.ResourceSID AND v.UID=m.defaultVID)
+JOIN Props AS p ON (p.EntitySID=v.SID)
+WHERE m.xRefSID IS NULL
+
+UNION ALL SELECT                   -- Get default prop for xref resources
+    p.RegistrySID,
+    m.ResourceSID AS EntitySID,
+    p.PropName,
+    p.PropValue,
+    p.PropType,
+    false                          -- DocView
+FROM Metas AS m
+JOIN Versions AS v
+  ON (
+    m.xRefSID=v.ResourceSID AND
+        v.UID=(SELECT defaultVID FROM Metas WHERE ResourceSID=m.xRefSID)
+  )
+JOIN Props AS p ON (p.EntitySID=v.SID)
+WHERE m.xRefSID IS NOT NULL
+
+UNION ALL SELECT                -- Add Resource.isdefault, always 'true'
+    m.RegistrySID,
+    m.ResourceSID,
+    'isdefault$DB_IN',
+    'true',
+    'boolean',
+    false                       -- DocView
+FROM Metas AS m ;
+
+CREATE VIEW AllProps AS
+SELECT                          -- Base props
+    RegistrySID,
+    EntitySID,
+    PropName,
+    PropValue,
+    PropType,
+    DocView
+FROM Props
+
+UNION ALL SELECT                -- Add Props for xRef resources
+    mS.RegistrySID AS RegistrySID,
+    mS.SID AS EntitySID,
+    p.PropName AS PropName,
+    p.PropValue AS PropValue,
+    p.PropType AS PropType,
+    false AS DocView
+FROM Metas AS mS
+JOIN Metas AS mT ON (mT.ResourceSID=mS.xRefSID)
+JOIN Props AS p ON (p.EntitySID=mT.SID AND
+       p.PropName NOT IN ('xref$DB_IN',CONCAT(mT.Singular,'id$DB_IN')))
+WHERE mS.xRefSID IS NOT NULL
+
+UNION ALL SELECT               -- Add Version props for xRef resources
+    mS.RegistrySID AS RegistrySID,
+    CONCAT('-', mS.ResourceSID, '-', p.EntitySID) AS EntitySID,
+    p.PropName AS PropName,
+    p.PropValue AS PropValue,
+    p.PropType AS PropType,
+    false AS DocView
+FROM Metas as mS
+JOIN Props as p ON (p.EntitySID IN (
+       SELECT eSID FROM Entities WHERE ParentSID=mS.xRefSID AND
+                                       Type=$ENTITY_VERSION
+     ) AND p.PropName<>'xref$DB_IN')
+WHERE mS.xRefSID IS NOT NULL
+
+UNION ALL SELECT * FROM DefaultProps
+
+UNION ALL SELECT                -- Add Version.isdefault, which is calculated
+  v.RegSID,
+  v.eSID,
+  'isdefault$DB_IN',
+  IF(
+      (m.defaultVID IS NOT NULL AND v.UID=m.defaultVID) OR
+      (m.defaultVID IS NULL AND m.xRefSID IS NOT NULL AND
+        v.UID=(SELECT defaultVID FROM Metas WHERE ResourceSID=m.xRefSID)
+      ),
+      'true', 'false'
+    ),
+  'boolean',                    -- Type
+  IF(SUBSTR(v.eSID,1,1)='-',false,true)  -- DocView,Lie if not xref'd prop/ver
+FROM Entities AS v
+JOIN Metas AS m ON (m.ResourceSID=v.ParentSID AND v.Type=$ENTITY_VERSION)
+
+UNION ALL SELECT               -- Add *.xid, which is calculated
+  e.RegSID,
+  e.eSID,
+  'xid$DB_IN',
+  CONCAT('/', e.Path),
+  'string',
+  IF(SUBSTR(e.eSID,1,1)='-',false,true)   -- A bit of a lie for DocView mode
+FROM Entities AS e
+
+UNION ALL SELECT               -- Add in Version.RESOURCEid, which is calculated
+  v.RegSID,
+  v.eSID,
+  CONCAT(r.Singular, 'id$DB_IN'),
+  r.UID,
+  'string',
+  IF(SUBSTR(v.eSID,1,1)='-',false,true)  -- Lie if it's not an xref'd prop/ver
+FROM Entities AS v
+JOIN Resources AS r ON (r.SID=v.ParentSID)
+WHERE v.Type=$ENTITY_VERSION;
+
+CREATE VIEW FullTree AS
+SELECT
+    e.RegSID,
+    e.Type,
+    e.Plural,
+    e.Singular,
+    e.ParentSID,
+    e.eSID,
+    e.UID,
+    e.Path,
+    p.PropName,
+    p.PropValue,
+    p.PropType,
+    e.Abstract,
+    p.DocView
+FROM Entities AS e
+JOIN AllProps AS p ON (p.EntitySID=e.eSID)
+ORDER by Path, PropName;
+
+CREATE VIEW Leaves AS
+SELECT eSID FROM Entities
+WHERE eSID NOT IN (
+    SELECT DISTINCT ParentSID FROM Entities WHERE ParentSID IS NOT NULL
+);
+
+-- Just for debugging purposes
+CREATE VIEW VerboseProps AS
+SELECT
+    p.RegistrySID,
+    p.EntitySID,
+    e.Abstract,
+    e.Path,
+    p.PropName,
+    p.PropValue,
+    p.PropType
+FROM Props as p
+JOIN Entities as e ON (e.eSID=p.EntitySID)
+ORDER by Path ;
+
+CREATE VIEW VersionAncestors AS
+SELECT
+    v.RegistrySID AS RegistrySID,
+    v.ResourceSID AS ResourceSID,
+    v.SID AS VersionSID,
+    v.UID AS VersionUID,
+    v.Ancestor AS Ancestor,
+    v.CreatedAt AS Time,
+    CASE
+        WHEN v.UID=v.Ancestor THEN '0-root'
+        WHEN EXISTS(SELECT 1 FROM Versions AS v2 WHERE
+                    v2.ResourceSID=v.ResourceSID AND v2.Ancestor=v.UID)
+             THEN '1-middle'
+        ELSE '2-leaf'
+    END AS Pos
+FROM Versions AS v ;
+
+CREATE VIEW VersionCircles AS
+WITH RECURSIVE cte (RegistrySID,ResourceSID,UID) AS
+(
+    -- Start with the roots and leaves, they can never be part of a circle
+    SELECT v.RegistrySID,v.ResourceSID,v.UID FROM Versions AS v
+    WHERE v.Ancestor=UID OR
+        NOT EXISTS(SELECT 1 FROM Versions AS v2 WHERE
+                   v2.RegistrySID=v.RegistrySID AND
+                   v2.ResourceSID=v.ResourceSID AND
+                   v2.Ancestor=v.UID)
+    UNION
+    -- Now find all Versions whose Ancestor is in cte
+    SELECT v3.RegistrySID,v3.ResourceSID,v3.UID FROM Versions AS v3
+    INNER JOIN cte ON (
+        v3.RegistrySID=cte.RegistrySID AND
+        v3.ResourceSID=cte.ResourceSID AND
+        v3.Ancestor=cte.UID )
+)
+-- And finally, return all Version UID that are NOT in cte (these are circular)
+SELECT v.RegistrySID, v.ResourceSID, v.UID FROM Versions AS v
+WHERE NOT EXISTS(SELECT 1 FROM cte
+                 WHERE cte.RegistrySID=v.RegistrySID AND
+                       cte.ResourceSID=v.ResourceSID AND
+                       cte.UID=v.UID);
@@ -46,6 +46,8 @@ BEGIN
     DELETE FROM Props    WHERE RegistrySID=OLD.SID $$
     DELETE FROM "Groups" WHERE RegistrySID=OLD.SID $$
     DELETE FROM Models   WHERE RegistrySID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE RegistrySID=OLD.SID $$
+    DELETE FROM XIDRefs WHERE RegistrySID=OLD.SID $$
 END ;
 
 CREATE TABLE Models (
@@ -118,6 +120,7 @@ CREATE TRIGGER GroupTrigger BEFORE DELETE ON "Groups"
 FOR EACH ROW
 BEGIN
     DELETE FROM Props WHERE EntitySID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE EntitySID=OLD.SID $$
     DELETE FROM Resources WHERE GroupSID=OLD.SID $$
 END ;
 
@@ -144,6 +147,7 @@ CREATE TRIGGER ResourcesTrigger BEFORE DELETE ON Resources
 FOR EACH ROW
 BEGIN
     DELETE FROM Props WHERE EntitySID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE EntitySID=OLD.SID $$
     DELETE FROM Metas WHERE ResourceSID=OLD.SID $$
     DELETE FROM Versions WHERE ResourceSID=OLD.SID $$
 END ;
@@ -212,7 +216,8 @@ CREATE TABLE Props (
 
     PRIMARY KEY (EntitySID, PropName),
     INDEX (EntitySID),
-    INDEX (RegistrySID, PropName)
+    INDEX (RegistrySID, PropName),
+    FULLTEXT (PropValue)                    # For ?search
 );
 
 CREATE TRIGGER PropsAncestor BEFORE INSERT ON Props
@@ -259,6 +264,9 @@ BEGIN
             WHERE m.SID=OLD.EntitySID $$
         END IF $$
     END IF $$
+
+    DELETE FROM XIDRefs
+      WHERE EntitySID=OLD.EntitySID AND PropName=OLD.PropName $$
 END ;
 
 CREATE TRIGGER VersionsTrigger BEFORE DELETE ON Versions
@@ -266,6 +274,7 @@ FOR EACH ROW
 BEGIN
     DELETE FROM Props WHERE EntitySID=OLD.SID $$
     DELETE FROM ResourceContents WHERE VersionSID=OLD.SID $$
+    DELETE FROM ShortSelfs WHERE EntitySID=OLD.SID $$
 END ;
 
 CREATE VIEW Entities AS
@@ -346,9 +355,45 @@ WHERE m.xRefSID IS NOT NULL ;
 
 CREATE TABLE ResourceContents (
     VersionSID      VARCHAR(255),
-    Content         MEDIUMBLOB,
+    Content         MEDIUMBLOB,             # NULL if in the BlobStore
+    ContentHash     VARCHAR(64),            # BlobStore key (SHA-256)
+    SearchText      MEDIUMTEXT,             # Content, if it's text. ?search
+
+    PRIMARY KEY (VersionSID),
+    INDEX (ContentHash),
+    FULLTEXT (SearchText)
+);
+
+# Maps the "u" value of a "shortself" URL (MD5 of the Path) back to the
+# entity's Path. Metas use their Resource's SID so they go away with it
+CREATE TABLE ShortSelfs (
+    RegistrySID     VARCHAR(64) NOT NULL,
+    ShortSelf       VARCHAR(64) NOT NULL COLLATE utf8mb4_bin,
+    EntitySID       VARCHAR(64) NOT NULL,   # Group,Res,Ver System ID
+    Path            VARCHAR($MAX_VARCHAR) NOT NULL COLLATE utf8mb4_bin,
+
+    PRIMARY KEY (RegistrySID, ShortSelf),
+    INDEX (EntitySID)
+);
+
+# The "xid" attribute values of each entity, for ?referencedby. Maintained
+# by Entity.Save() since the Props don't know their attribute's type, and
+# the rows go away with their Props. Metas.xRefSID covers "xref"
+CREATE TABLE XIDRefs (
+    RegistrySID     VARCHAR(64) NOT NULL,
+    EntitySID       VARCHAR(64) NOT NULL,   # Reg,Group,Res,Ver System ID
+    PropName        VARCHAR($MAX_PROPNAME) NOT NULL,
+    Target          VARCHAR($MAX_VARCHAR) NOT NULL COLLATE utf8mb4_bin,
+
+    PRIMARY KEY (EntitySID, PropName),
+    INDEX (RegistrySID, Target(255))
+);
+
+# Names of the dbUpgrades that have already been applied, see upgrade.go
+CREATE TABLE DBUpgrades (
+    Name    VARCHAR(64) NOT NULL,
 
-    PRIMARY KEY (VersionSID)
+    PRIMARY KEY (Name)
 );
 
 # This pulls-in or creates all props in Resources due to default Ver processing
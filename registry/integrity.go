@@ -0,0 +1,405 @@
+package registry
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Referential integrity of "xid" attributes that have an "integrity" value
+// other than "none":
+// - When one is set, the entity it references must exist by the end of
+//   the Tx (see Tx.Validate). Checking it then, rather than as each entity is
+//   saved, lets one request create both ends of a reference.
+// - When an entity is deleted, "cascade-null" references to it (or to one
+//   of its children) are removed right away, while "restrict" ones cause the
+//   Tx to fail with a 409 if they're still there by the end of it. This
+//   allows one request to delete both ends of a reference.
+
+// Returned when a "restrict" reference prevents a delete. Like
+// ForbiddenError, ServeHTTP will turn it into the right status code (409).
+type ConflictError struct {
+	Message string
+}
+
+func (ce *ConflictError) Error() string {
+	return ce.Message
+}
+
+// An enforced reference that was just set, to be checked in Tx.Validate
+type xidRef struct {
+	path  string // XID of the entity with the attribute
+	attr  string // UI path of the attribute
+	value string // XID it references
+}
+
+// An existing reference found in the DB
+type xidUse struct {
+	path      string // Entity.Path of the entity with the attribute
+	pp        *PropPath
+	value     string
+	integrity string
+}
+
+// Remember that 'e' references 'xid' via the attribute at 'pp'
+func (tx *Tx) AddXIDRef(e *Entity, pp *PropPath, xid string) {
+	tx.xidRefs = append(tx.xidRefs, &xidRef{
+		path:  "/" + e.Path,
+		attr:  pp.UI(),
+		value: xid,
+	})
+}
+
+// Returns Abstract -> DB prop name -> "integrity" value for all of the
+// enforced xid attributes in the model. Map keys (and "*" attributes) show
+// up in the name as "*", and array indexes as "#*", see integrityOf
+func IntegrityAttributes(m *Model) map[string]map[string]string {
+	res := map[string]map[string]string{}
+
+	var walk func(abstract string, pp *PropPath, attrs Attributes)
+	var walkItem func(abstract string, pp *PropPath, daType string,
+		item *Item)
+
+	walk = func(abstract string, pp *PropPath, attrs Attributes) {
+		for name, attr := range attrs {
+			if attr == nil {
+				continue
+			}
+			if attr.Type == XID && attr.GetIntegrity() != INTEGRITY_NONE {
+				if res[abstract] == nil {
+					res[abstract] = map[string]string{}
+				}
+				res[abstract][pp.P(name).DB()] = attr.GetIntegrity()
+			}
+			if attr.Type == OBJECT {
+				walk(abstract, pp.P(name), attr.Attributes)
+			}
+			walkItem(abstract, pp.P(name), attr.Type, attr.Item)
+			for _, ifValue := range attr.IfValues {
+				walk(abstract, pp, ifValue.SiblingAttributes)
+			}
+		}
+	}
+
+	// Items can't have an "integrity" of their own, but object ones can
+	// have attributes that do
+	walkItem = func(abstract string, pp *PropPath, daType string,
+		item *Item) {
+
+		if item == nil {
+			return
+		}
+		switch daType {
+		case MAP:
+			pp = pp.P("*")
+		case ARRAY:
+			pp = pp.P("#*")
+		default:
+			return
+		}
+		if item.Type == OBJECT {
+			walk(abstract, pp, item.Attributes)
+		}
+		walkItem(abstract, pp, item.Type, item.Item)
+	}
+
+	walk("", NewPP(), m.Attributes)
+	for _, gm := range m.Groups {
+		walk(gm.Plural, NewPP(), gm.Attributes)
+
+		// Imported Resources are stored under the importing Group
+		rms := map[string]*ResourceModel{}
+		maps.Copy(rms, gm.Resources)
+		maps.Copy(rms, gm.GetImports())
+		for plural, rm := range rms {
+			abs := gm.Plural + string(DB_IN) + plural
+			walk(abs+string(DB_IN)+"versions", NewPP(), rm.VersionAttributes)
+			walk(abs+string(DB_IN)+"meta", NewPP(), rm.MetaAttributes)
+		}
+	}
+
+	return res
+}
+
+// Returns the "integrity" of the DB prop 'name' of an entity whose Abstract
+// is 'abstract', or "" if it's not enforced. See IntegrityAttributes
+func integrityOf(attrs map[string]map[string]string, abstract string,
+	name string) string {
+
+	props := attrs[abstract]
+	if integrity, ok := props[name]; ok {
+		return integrity
+	}
+
+	parts := strings.Split(name, string(DB_IN))
+	for pattern, integrity := range props {
+		pParts := strings.Split(pattern, string(DB_IN))
+		if len(pParts) != len(parts) {
+			continue
+		}
+		match := true
+		for i, pPart := range pParts {
+			isIndex := strings.HasPrefix(parts[i], string(DB_INDEX))
+			if pPart == parts[i] || (pPart == "*" && !isIndex) ||
+				(pPart == string(DB_INDEX)+"*" && isIndex) {
+				continue
+			}
+			match = false
+			break
+		}
+		if match {
+			return integrity
+		}
+	}
+	return ""
+}
+
+// Returns true if the entity, or collection, at 'xidStr' exists
+func (reg *Registry) XIDExists(tx *Tx, xidStr string) (bool, error) {
+	parts := strings.Split(strings.Trim(xidStr, "/"), "/")
+
+	// Collections exist if their parent does
+	if len(parts)%2 == 1 && parts[len(parts)-1] != "meta" {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 0 {
+		return true, nil // Registry
+	}
+
+	results, err := Query(tx, `
+		SELECT COUNT(*) FROM Entities WHERE RegSID=? AND Path=?`,
+		reg.DbSID, strings.Join(parts, "/"))
+	defer results.Close()
+	if err != nil {
+		return false, err
+	}
+	row := results.NextRow()
+	return row != nil && NotNilInt(row[0]) > 0, nil
+}
+
+// Returns the enforced references to the entity at 'path', or any of its
+// children, from entities outside of it
+func (reg *Registry) findXIDUses(tx *Tx, path string) ([]*xidUse, error) {
+	attrs := IntegrityAttributes(reg.Model)
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+
+	// Names with map keys or array indexes in them need a LIKE
+	names := map[string]bool{}
+	likes := map[string]bool{}
+	for _, props := range attrs {
+		for name := range props {
+			parts := strings.Split(name, string(DB_IN))
+			for i, part := range parts {
+				if part == "*" || part == string(DB_INDEX)+"*" {
+					parts[i] = part[:len(part)-1] + "%"
+				}
+			}
+			if like := strings.Join(parts, string(DB_IN)); like != name {
+				likes[like] = true
+			} else {
+				names[name] = true
+			}
+		}
+	}
+	args := []any{reg.DbSID}
+	nameChecks := []string{}
+	if len(names) > 0 {
+		nameChecks = append(nameChecks,
+			"PropName IN (?"+strings.Repeat(",?", len(names)-1)+")")
+		for name := range names {
+			args = append(args, name)
+		}
+	}
+	for like := range likes {
+		nameChecks = append(nameChecks, "PropName LIKE ?")
+		args = append(args, like)
+	}
+	xid := "/" + path
+	args = append(args, xid, xid+"/%")
+
+	results, err := Query(tx, `
+		SELECT e.Path, e.Abstract, PropName, PropValue
+		FROM Props
+		JOIN Entities AS e ON (e.eSID=EntitySID)
+		WHERE RegistrySID=? AND
+		  (`+strings.Join(nameChecks, " OR ")+`) AND
+		  (PropValue=? OR PropValue LIKE ?)
+		ORDER BY e.Path, PropName`, args...)
+	defer results.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	uses := []*xidUse{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		use := &xidUse{
+			path:  NotNilString(row[0]),
+			value: NotNilString(row[3]),
+			integrity: integrityOf(attrs, NotNilString(row[1]),
+				NotNilString(row[2])),
+		}
+
+		// LIKE treats "_" as a wildcard, and might ignore case, so
+		// double check it
+		if use.integrity == "" ||
+			(use.value != xid && !strings.HasPrefix(use.value, xid+"/")) {
+			continue
+		}
+
+		// Skip the ones that'll be deleted too
+		if use.path == path || strings.HasPrefix(use.path, path+"/") {
+			continue
+		}
+
+		use.pp, err = PropPathFromDB(NotNilString(row[2]))
+		if err != nil {
+			return nil, err
+		}
+		uses = append(uses, use)
+	}
+
+	return uses, nil
+}
+
+// Called just before 'e' is deleted. Removes any "cascade-null"
+// references to it and remembers it so that Tx.Validate can look for
+// any "restrict" ones.
+func (e *Entity) CheckIntegrityOnDelete() error {
+	log.VPrintf(3, ">Enter: CheckIntegrityOnDelete(%s)", e.Path)
+	defer log.VPrintf(3, "<Exit: CheckIntegrityOnDelete")
+
+	uses, err := e.Registry.findXIDUses(e.tx, e.Path)
+	if err != nil {
+		return err
+	}
+
+	e.tx.deletedXIDs = append(e.tx.deletedXIDs, "/"+e.Path)
+
+	for _, use := range uses {
+		if use.integrity != INTEGRITY_CASCADE_NULL {
+			continue
+		}
+
+		ref, err := e.Registry.findEntityForWrite(use.path)
+		if err != nil {
+			return err
+		}
+		if ref == nil {
+			continue
+		}
+
+		log.VPrintf(3, "Removing %s.%s (%s)", use.path, use.pp.UI(),
+			use.value)
+		ref.Touch()
+		if err = ref.eJustSet(use.pp, nil); err != nil {
+			return err
+		}
+		if err = ref.ValidateAndSave(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Called from Tx.Validate to make sure all of the enforced references set,
+// or deleted, in this Tx are still valid
+func (tx *Tx) CheckIntegrity() error {
+	refs, deleted := tx.xidRefs, tx.deletedXIDs
+	tx.xidRefs, tx.deletedXIDs = nil, nil
+
+	// Anything still referencing what was deleted must be a "restrict".
+	// Check these first since a ref that was set (e.g. due to a
+	// "cascade-null" on a sibling attribute) might point to one of them.
+	for _, xid := range deleted {
+		uses, err := tx.Registry.findXIDUses(tx, xid[1:])
+		if err != nil {
+			return err
+		}
+
+		list := []string{}
+		for _, use := range uses {
+			// In case it was recreated
+			ok, err := tx.Registry.XIDExists(tx, use.value)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				list = append(list, fmt.Sprintf("/%s (%s)", use.path,
+					use.pp.UI()))
+			}
+		}
+
+		if len(list) > 0 {
+			sort.Strings(list)
+			return &ConflictError{
+				Message: fmt.Sprintf("Can't delete %q since it's "+
+					"referenced by: %s", xid, strings.Join(list, ", ")),
+			}
+		}
+	}
+
+	// Everything that was set needs to reference something that exists
+	return tx.checkXIDRefs(refs)
+}
+
+// Returns an error for the first of 'refs' that references a non-existing
+// entity
+func (tx *Tx) checkXIDRefs(refs []*xidRef) error {
+	for _, ref := range refs {
+		ok, err := tx.Registry.XIDExists(tx, ref.value)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("Attribute %q of %q references %q which "+
+				"doesn't exist", ref.attr, ref.path, ref.value)
+		}
+	}
+
+	return nil
+}
+
+// Like XID2Entity, but for a Path and the entity is FOR_WRITE
+func (reg *Registry) findEntityForWrite(path string) (*Entity, error) {
+	if path == "" {
+		return &reg.Entity, nil
+	}
+	parts := strings.Split(path, "/")
+
+	g, err := reg.FindGroup(parts[0], parts[1], false, FOR_WRITE)
+	if err != nil || g == nil {
+		return nil, err
+	}
+	if len(parts) == 2 {
+		return &g.Entity, nil
+	}
+
+	r, err := g.FindResource(parts[2], parts[3], false, FOR_WRITE)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	if len(parts) == 4 {
+		return &r.Entity, nil
+	}
+
+	if parts[4] == "meta" {
+		m, err := r.FindMeta(false, FOR_WRITE)
+		if err != nil || m == nil {
+			return nil, err
+		}
+		return &m.Entity, nil
+	}
+
+	v, err := r.FindVersion(parts[5], false, FOR_WRITE)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return &v.Entity, nil
+}
@@ -120,7 +120,17 @@ func (ug *UserGroupModel) MarshalJSON() ([]byte, error) {
 	buf.Write(data)
 
 	if len(ug.XImportResources) > 0 {
-		b, _ := json.Marshal(ug.XImportResources)
+		// Show where each Resource type is defined rather than the Group
+		// it was imported thru. The modelsource keeps what the user sent.
+		gm := (*GroupModel)(ug)
+		list := []string{}
+		for _, grName := range ug.XImportResources {
+			if _, origin, err := gm.ResolveXImport(grName); err == nil {
+				grName = origin
+			}
+			list = append(list, grName)
+		}
+		b, _ := json.Marshal(list)
 		buf.WriteString(`,"ximportresources":`)
 		buf.Write(b)
 	}
@@ -206,6 +216,21 @@ func (ur *UserResourceModel) MarshalJSON() ([]byte, error) {
 		b, _ := json.Marshal(ur.TypeMap)
 		buf.Write(b)
 	}
+	if ur.VersionMode != "" {
+		buf.WriteString(`,"versionmode":`)
+		b, _ := json.Marshal(ur.VersionMode)
+		buf.Write(b)
+	}
+	if len(ur.DeprecationPolicy) > 0 {
+		buf.WriteString(`,"deprecationpolicy":`)
+		b, _ := json.Marshal(ur.DeprecationPolicy)
+		buf.Write(b)
+	}
+	if ur.SchemaAttribute != "" {
+		buf.WriteString(`,"schemaattribute":`)
+		b, _ := json.Marshal(ur.SchemaAttribute)
+		buf.Write(b)
+	}
 
 	extra = ","
 
@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"path"
 	"strings"
@@ -350,10 +349,29 @@ func (jw *JsonWriter) WriteEntity() error {
 	// Skip serializing the root entity's attributes if ?collections is set
 	// AND we're on the root entity of the response
 	if !jw.info.HasFlag("collections") || jw.info.Root != jw.Entity.Path {
-		err := jw.Entity.SerializeProps(jw.info, jsonIt)
+		// "searchscore" isn't a real attribute, just how well the entity
+		// matched the ?search. Show it at the end of the regular attributes,
+		// which is the first "$space".
+		serFn := jsonIt
+		score, hasScore := jw.info.SearchScores[jw.Entity.Path]
+		if hasScore {
+			serFn = func(e *Entity, info *RequestInfo, key string, val any, attr *Attribute) error {
+				if hasScore && key == "$space" {
+					jsonIt(e, info, "searchscore", score, nil)
+					hasScore = false
+				}
+				return jsonIt(e, info, key, val, attr)
+			}
+		}
+
+		err := jw.Entity.SerializeProps(jw.info, serFn)
 		if err != nil {
 			panic(err)
 		}
+
+		if hasScore {
+			jsonIt(jw.Entity, jw.info, "searchscore", score, nil)
+		}
 	}
 
 	// Now show all of the nested collections
@@ -389,7 +407,7 @@ func (jw *JsonWriter) WriteEntity() error {
 			// then we'll serialize "meta" after "versions" so we know if the
 			// default Version was included or not. If not then the
 			// defaultversionurl needs to be absolute, not relative
-			if jw.info.DoDocView() && len(jw.info.Filters) > 0 && jw.info.ShouldInline(versProp.DB()) {
+			if jw.info.DoDocView() && jw.info.HasFilters() && jw.info.ShouldInline(versProp.DB()) {
 				cachedMeta = jw.Entity
 				if _, err = jw.NextEntity(); err != nil {
 					return err
@@ -483,16 +501,14 @@ func SerializeResourceContents(jw *JsonWriter, e *Entity, info *RequestInfo, ext
 		}
 
 		if url := jw.Entity.GetAsString(singular + "proxyurl"); url != "" {
-			resp, err := http.Get(url)
+			resp, err := Proxy.Get(url)
 			if err != nil {
 				data = []byte("GET error:" + err.Error())
 			} else if resp.StatusCode/100 != 2 {
-				data = []byte("GET error:" + resp.Status)
+				data = []byte(fmt.Sprintf("GET error:%d %s", resp.StatusCode,
+					http.StatusText(resp.StatusCode)))
 			} else {
-				data, err = io.ReadAll(resp.Body)
-				if err != nil {
-					data = []byte("GET error:" + err.Error())
-				}
+				data = resp.Body
 			}
 		}
 
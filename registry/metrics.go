@@ -0,0 +1,289 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Upper bounds (in seconds) of the request latency histogram buckets
+var MetricsBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+type requestKey struct {
+	method string
+	path   string
+}
+
+type requestStats struct {
+	codes   map[int]int // HTTP status code -> count
+	buckets []int       // Not cumulative, the last one is "+Inf"
+	sum     float64
+	count   int
+}
+
+type RequestMetrics struct {
+	mutex    sync.Mutex
+	requests map[requestKey]*requestStats
+}
+
+var Metrics = &RequestMetrics{
+	requests: map[requestKey]*requestStats{},
+}
+
+func (m *RequestMetrics) Observe(method string, path string, code int,
+	dur time.Duration) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := requestKey{method, path}
+	stats := m.requests[key]
+	if stats == nil {
+		stats = &requestStats{
+			codes:   map[int]int{},
+			buckets: make([]int, len(MetricsBuckets)+1),
+		}
+		m.requests[key] = stats
+	}
+
+	secs := dur.Seconds()
+	i := sort.SearchFloat64s(MetricsBuckets, secs)
+	stats.buckets[i]++
+	stats.codes[code]++
+	stats.sum += secs
+	stats.count++
+}
+
+// Returns all of the metrics in Prometheus' text exposition format
+func (m *RequestMetrics) String() string {
+	sb := &strings.Builder{}
+
+	m.mutex.Lock()
+	keys := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	fmt.Fprintf(sb, "# HELP xrserver_http_requests_total "+
+		"Number of HTTP requests processed.\n")
+	fmt.Fprintf(sb, "# TYPE xrserver_http_requests_total counter\n")
+	for _, key := range keys {
+		stats := m.requests[key]
+		codes := make([]int, 0, len(stats.codes))
+		for code := range stats.codes {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(sb, "xrserver_http_requests_total{%s,code=\"%d\"} %d\n",
+				key.labels(), code, stats.codes[code])
+		}
+	}
+
+	fmt.Fprintf(sb, "# HELP xrserver_http_request_duration_seconds "+
+		"Time taken to process HTTP requests.\n")
+	fmt.Fprintf(sb, "# TYPE xrserver_http_request_duration_seconds "+
+		"histogram\n")
+	for _, key := range keys {
+		stats := m.requests[key]
+		total := 0
+		for i, count := range stats.buckets {
+			total += count
+			le := "+Inf"
+			if i < len(MetricsBuckets) {
+				le = fmt.Sprintf("%g", MetricsBuckets[i])
+			}
+			fmt.Fprintf(sb, "xrserver_http_request_duration_seconds_bucket"+
+				"{%s,le=%q} %d\n", key.labels(), le, total)
+		}
+		fmt.Fprintf(sb, "xrserver_http_request_duration_seconds_sum{%s} %g\n",
+			key.labels(), stats.sum)
+		fmt.Fprintf(sb, "xrserver_http_request_duration_seconds_count{%s} "+
+			"%d\n", key.labels(), stats.count)
+	}
+	m.mutex.Unlock()
+
+	TXsMutex.RLock()
+	txCount := len(TXs)
+	TXsMutex.RUnlock()
+
+	fmt.Fprintf(sb, "# HELP xrserver_db_open_transactions "+
+		"Number of DB transactions currently open.\n")
+	fmt.Fprintf(sb, "# TYPE xrserver_db_open_transactions gauge\n")
+	fmt.Fprintf(sb, "xrserver_db_open_transactions %d\n", txCount)
+
+	// Only available when XR_TIMING is set, see Query()
+	if doTime {
+		queryTimesMutex.Lock()
+		cmds := SortedKeys(queryTimes)
+
+		fmt.Fprintf(sb, "# HELP xrserver_db_queries_total "+
+			"Number of times each SQL query was run.\n")
+		fmt.Fprintf(sb, "# TYPE xrserver_db_queries_total counter\n")
+		for _, cmd := range cmds {
+			fmt.Fprintf(sb, "xrserver_db_queries_total{query=%s} %d\n",
+				metricsQuote(cmd), queryTimes[cmd].count)
+		}
+
+		fmt.Fprintf(sb, "# HELP xrserver_db_query_seconds_total "+
+			"Time spent running each SQL query, by phase.\n")
+		fmt.Fprintf(sb, "# TYPE xrserver_db_query_seconds_total counter\n")
+		for _, cmd := range cmds {
+			qt := queryTimes[cmd]
+			for _, phase := range []struct {
+				name string
+				dur  time.Duration
+			}{
+				{"prepare", qt.prepDur},
+				{"query", qt.queryDur},
+				{"get", qt.getDur},
+				{"total", qt.totalDur},
+			} {
+				fmt.Fprintf(sb, "xrserver_db_query_seconds_total"+
+					"{query=%s,phase=%q} %g\n", metricsQuote(cmd),
+					phase.name, phase.dur.Seconds())
+			}
+		}
+		queryTimesMutex.Unlock()
+	}
+
+	return sb.String()
+}
+
+func (key requestKey) labels() string {
+	return fmt.Sprintf("method=%s,path=%s", metricsQuote(key.method),
+		metricsQuote(key.path))
+}
+
+// Label values can only escape backslash, double-quote and line feed.
+// SQL queries are squashed onto one line to make them more readable.
+func metricsQuote(str string) string {
+	str = strings.Join(strings.Fields(str), " ")
+	str = strings.ReplaceAll(str, `\`, `\\`)
+	str = strings.ReplaceAll(str, `"`, `\"`)
+	return `"` + str + `"`
+}
+
+// Used to grab the HTTP status code so we can include it in the metrics
+type metricsWriter struct {
+	http.ResponseWriter
+	code int
+	path string // Abstract path of the request, see MetricsPath()
+}
+
+func newMetricsWriter(w http.ResponseWriter) *metricsWriter {
+	// Requests that fail before we know what they're for (e.g. bad path)
+	return &metricsWriter{ResponseWriter: w, path: "unknown"}
+}
+
+func (mw *metricsWriter) WriteHeader(code int) {
+	if mw.code == 0 {
+		mw.code = code
+	}
+	mw.ResponseWriter.WriteHeader(code)
+}
+
+func (mw *metricsWriter) Write(b []byte) (int, error) {
+	if mw.code == 0 {
+		mw.code = http.StatusOK
+	}
+	return mw.ResponseWriter.Write(b)
+}
+
+// Needed for streaming, see HTTPEvents()
+func (mw *metricsWriter) Flush() {
+	if flusher, ok := mw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (mw *metricsWriter) Unwrap() http.ResponseWriter {
+	return mw.ResponseWriter
+}
+
+// Returns the request's path with all IDs replaced with "{id}" so that
+// we don't end up with a new metric for every entity, e.g.
+// /dirs/{id}/files/{id}$details
+func (info *RequestInfo) MetricsPath() string {
+	if info.RootPath != "" {
+		return "/" + info.RootPath
+	}
+
+	path := ""
+	for i, part := range info.Parts {
+		if i%2 == 1 {
+			part = "{id}"
+		}
+		path += "/" + part
+	}
+	if path == "" {
+		path = "/"
+	}
+	if info.ShowDetails {
+		path += "$details"
+	}
+	return path
+}
+
+// GET /healthz - the process is up
+func HTTPHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// GET /readyz - the DB is reachable and the default Registry is loaded
+func HTTPReadyz(w http.ResponseWriter, r *http.Request) {
+	err := checkReady()
+	w.Header().Set("Content-Type", "text/plain")
+	if err != nil {
+		log.VPrintf(2, "Not ready: %s", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(err.Error() + "\n"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+func checkReady() error {
+	if DefaultRegDbSID == "" {
+		return fmt.Errorf("No default registry")
+	}
+
+	tx, err := NewTx()
+	if err != nil {
+		return fmt.Errorf("Error talking to the DB: %s", err)
+	}
+	defer tx.Rollback()
+
+	reg, err := FindRegistryBySID(tx, DefaultRegDbSID, FOR_READ)
+	if err != nil {
+		return fmt.Errorf("Error talking to the DB: %s", err)
+	}
+	if reg == nil {
+		return fmt.Errorf("No default registry")
+	}
+	return nil
+}
+
+// GET /metrics - Prometheus metrics
+func HTTPMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(Metrics.String()))
+}
@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Verify that all of the Registry's entities are still valid per the
+// (possibly new) model. If 'migrate' is true then, before validating, any
+// missing attributes that have a default value are added and any attributes
+// that are no longer defined in the model are removed - and the entities
+// that were changed are saved. All invalid entities are reported in the
+// returned error, not just the first one.
+func (reg *Registry) VerifyEntities(migrate bool) error {
+	log.VPrintf(3, ">Enter: VerifyEntities(%s, %v)", reg.UID, migrate)
+	defer log.VPrintf(3, "<Exit: VerifyEntities")
+
+	entities, err := RawEntitiesFromQuery(reg.tx, reg.DbSID, FOR_WRITE, ``)
+	if err != nil {
+		return err
+	}
+
+	problems := []string{}
+	for _, e := range entities {
+		// Resources are never validated on their own, see Validate()
+		if e.Type == ENTITY_RESOURCE {
+			continue
+		}
+
+		if err := e.verifyForModel(migrate); err != nil {
+			problems = append(problems, fmt.Sprintf("  %s: %s", "/"+e.Path,
+				err))
+		}
+	}
+
+	if len(problems) > 0 {
+		msg := "The model change would make the following entities invalid"
+		if !migrate {
+			msg += " (?migrate can add defaults and remove old attributes)"
+		}
+		return fmt.Errorf("%s:\n%s", msg, strings.Join(problems, "\n"))
+	}
+
+	return nil
+}
+
+func (e *Entity) verifyForModel(migrate bool) error {
+	e.tx.NewTx()
+
+	obj := maps.Clone(e.Object)
+	changed := false
+	if migrate {
+		changed = e.migrateObject(obj, e.GetAttributes(obj))
+	}
+
+	e.SetNewObject(obj)
+	defer func() { e.NewObject = nil }()
+
+	e.tx.xidRefs = nil
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	// Nothing is created during a model change so check the refs now
+	refs := e.tx.xidRefs
+	e.tx.xidRefs = nil
+	if err := e.tx.checkXIDRefs(refs); err != nil {
+		return err
+	}
+
+	if err := e.ValidateDocument(); err != nil {
+		return err
+	}
+
+	if changed {
+		log.VPrintf(2, "Migrating %q", e.Path)
+		return e.Save()
+	}
+
+	// An attribute might have changed to, or from, an "xid"
+	return e.SaveXIDRefs(obj)
+}
+
+// Add default values and remove unknown attributes, recursively. Returns
+// true if 'obj' was changed.
+func (e *Entity) migrateObject(obj map[string]any, attrs Attributes) bool {
+	changed := false
+
+	if attrs["*"] == nil {
+		for key := range obj {
+			if len(key) > 0 && key[0] == '#' {
+				continue // System attributes
+			}
+			if attrs[key] == nil {
+				delete(obj, key)
+				changed = true
+			}
+		}
+	}
+
+	for name, attr := range attrs {
+		if name == "*" {
+			continue
+		}
+
+		val := obj[name]
+		if IsNil(val) {
+			// Like Validate(), xref'd metas don't get default values
+			if !IsNil(attr.Default) &&
+				(e.Type != ENTITY_META || IsNil(obj["xref"])) {
+				obj[name] = attr.Default
+				changed = true
+			}
+			continue
+		}
+
+		if e.migrateValue(val, attr.Type, attr.Attributes, attr.Item) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+func (e *Entity) migrateValue(val any, daType string, attrs Attributes, item *Item) bool {
+	changed := false
+
+	switch daType {
+	case OBJECT:
+		if obj, ok := val.(map[string]any); ok {
+			tmp := maps.Clone(attrs)
+			tmp.AddIfValuesAttributes(obj)
+			changed = e.migrateObject(obj, tmp)
+		}
+	case MAP:
+		if m, ok := val.(map[string]any); ok && item != nil {
+			for _, v := range m {
+				if e.migrateValue(v, item.Type, item.Attributes, item.Item) {
+					changed = true
+				}
+			}
+		}
+	case ARRAY:
+		if a, ok := val.([]any); ok && item != nil {
+			for _, v := range a {
+				if e.migrateValue(v, item.Type, item.Attributes, item.Item) {
+					changed = true
+				}
+			}
+		}
+	}
+
+	return changed
+}
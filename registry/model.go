@@ -53,7 +53,8 @@ func (m *Model) Save() error {
 	modelStr := string(buf)
 
 	// log.Printf("Saving model itself")
-	err := DoZeroTwo(m.Registry.tx, `
+	// Not all DBs count the rows changed by an upsert the same way
+	err := Do(m.Registry.tx, `
         INSERT INTO Models(RegistrySID, Model)
         VALUES(?,?)
         ON DUPLICATE KEY UPDATE Model=?`,
@@ -232,6 +233,12 @@ func LoadModel(reg *Registry) *Model {
 }
 
 func (m *Model) ApplyNewModel(newM *Model) error {
+	return m.applyNewModel(newM, true)
+}
+
+// 'verify' indicates whether we should verify the existing entities against
+// the new model. The caller should only skip it if it'll do it itself later.
+func (m *Model) applyNewModel(newM *Model, verify bool) error {
 	newM.Registry = m.Registry
 	// log.Printf("ApplyNewModel:\n%s\n", ToJSON(newM))
 
@@ -258,10 +265,19 @@ func (m *Model) ApplyNewModel(newM *Model) error {
 		return err
 	}
 
-	return nil
+	if !verify {
+		return nil
+	}
+
+	// Make sure the existing entities are still valid under the new model
+	return m.Registry.VerifyEntities(m.Registry.tx.MigrateModel)
 }
 
 func (m *Model) ApplyNewModelFromJSON(buf []byte) error {
+	return m.applyNewModelFromJSON(buf, true)
+}
+
+func (m *Model) applyNewModelFromJSON(buf []byte, verify bool) error {
 	modelSource := string(buf)
 	if modelSource == "" {
 		modelSource = "{}"
@@ -279,7 +295,7 @@ func (m *Model) ApplyNewModelFromJSON(buf []byte) error {
 	}
 	model.Source = modelSource
 
-	return m.ApplyNewModel(model)
+	return m.applyNewModel(model, verify)
 }
 
 func (rm *ResourceModel) VerifyData() error {
@@ -313,6 +329,13 @@ func (rm *ResourceModel) VerifyData() error {
 				return err
 			}
 
+			// "versionmode" may have changed which Version is the newest
+			if !resource.IsXref() {
+				if err = resource.EnsureLatest(); err != nil {
+					return err
+				}
+			}
+
 			if err = resource.EnsureMaxVersions(); err != nil {
 				return err
 			}
@@ -506,6 +506,111 @@ func TestModelVerifyEnum(t *testing.T) {
 	}
 }
 
+func TestModelVerifyConstraints(t *testing.T) {
+	type Test struct {
+		name  string
+		model Model
+		err   string
+	}
+
+	one, five := 1.0, 5.0
+
+	tests := []Test{
+		{"pattern - string", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Pattern: "^a.*$"}}}, ""},
+		{"pattern - array of uris", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: ARRAY, Item: &Item{Type: URI},
+				Pattern: "^http"}}}, ""},
+		{"pattern - bad regexp", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Pattern: "a("}}},
+			`"model.x" has an invalid "pattern" value (a(): error parsing ` +
+				"regexp: missing closing ): `a(`"},
+		{"pattern - int", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: INTEGER, Pattern: "a"}}},
+			`"model.x" is not a string, or an array of strings, so ` +
+				`"pattern", "minlength" and "maxlength" are not allowed`},
+		{"minlength - map", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: MAP, Item: &Item{Type: STRING},
+				MinLength: PtrInt(1)}}},
+			`"model.x" is not a string, or an array of strings, so ` +
+				`"pattern", "minlength" and "maxlength" are not allowed`},
+		{"minlength - negative", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, MinLength: PtrInt(-1)}}},
+			`"model.x" "minlength" must not be negative`},
+		{"minlength > maxlength", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, MinLength: PtrInt(5),
+				MaxLength: PtrInt(1)}}},
+			`"model.x" "minlength" must not be greater than "maxlength"`},
+
+		{"minimum - decimal", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: DECIMAL, Minimum: &one,
+				Maximum: &five}}}, ""},
+		{"minimum - string", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Minimum: &one}}},
+			`"model.x" is not a number, or an array of numbers, so ` +
+				`"minimum" and "maximum" are not allowed`},
+		{"minimum > maximum", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: INTEGER, Minimum: &five,
+				Maximum: &one}}},
+			`"model.x" "minimum" must not be greater than "maximum"`},
+
+		{"minitems - map", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: MAP, Item: &Item{Type: STRING},
+				MinItems: PtrInt(1), MaxItems: PtrInt(2)}}}, ""},
+		{"minitems - string", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, MinItems: PtrInt(1)}}},
+			`"model.x" is not an array or map, so "minitems" and ` +
+				`"maxitems" are not allowed`},
+		{"maxitems - negative", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: ARRAY, Item: &Item{Type: STRING},
+				MaxItems: PtrInt(-1)}}},
+			`"model.x" "maxitems" must not be negative`},
+
+		{"rules - ok", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Equals: "y",
+				Requires: []string{"y"}, Excludes: []string{"z"}},
+			"y": {Name: "y", Type: STRING},
+			"z": {Name: "z", Type: STRING}}}, ""},
+		{"rules - unknown", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Requires: []string{"y"}}}},
+			`"model.x" has a rule that references an unknown sibling ` +
+				`attribute: y`},
+		{"rules - self", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Excludes: []string{"x"}}}},
+			`"model.x" has a rule that references itself`},
+		{"rules - star", Model{Attributes: Attributes{
+			"*": {Name: "*", Type: STRING, Requires: []string{"x"}},
+			"x": {Name: "x", Type: STRING}}},
+			`"model.*" can't have "equals", "requires" or "excludes" rules`},
+		{"rules - ifvalues", Model{Attributes: Attributes{
+			"x": {Name: "x", Type: STRING, Requires: []string{"y"}},
+			"t": {Name: "t", Type: STRING, IfValues: IfValues{
+				"a": &IfValue{SiblingAttributes: Attributes{
+					"y": {Name: "y", Type: STRING}}}}}}}, ""},
+		{"rules - nested", Model{Attributes: Attributes{
+			"o": {Name: "o", Type: OBJECT, Attributes: Attributes{
+				"x": {Name: "x", Type: STRING, Equals: "y"}}}}},
+			`"model.o.x" has a rule that references an unknown sibling ` +
+				`attribute: y`},
+	}
+
+	for _, test := range tests {
+		err := test.model.Verify()
+		if test.err == "" && err != nil {
+			t.Fatalf("ModelVerify: %s - should have worked, got: %s",
+				test.name, err)
+		}
+		if test.err != "" && err == nil {
+			t.Fatalf("ModelVerify: %s - should have failed with: %s",
+				test.name, test.err)
+		}
+		if err != nil && test.err != err.Error() {
+			t.Fatalf("ModifyVerify: %s\nExp: %s\nGot: %s", test.name,
+				test.err, err.Error())
+		}
+	}
+}
+
 func TestGetModelSerializer(t *testing.T) {
 	type Match struct {
 		format string
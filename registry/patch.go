@@ -0,0 +1,172 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Returns the patch media type (RFC 6902 JSON Patch or RFC 7396 Merge Patch)
+// of the request's body, or "" if it's not a patch
+func (info *RequestInfo) PatchMediaType() string {
+	return PatchMediaType(info.OriginalRequest.Header.Get("Content-Type"))
+}
+
+// Make sure a patch media type is only used with PATCH. Returns the
+// media type, or "" if the request isn't a patch.
+func (info *RequestInfo) CheckPatchMediaType() (string, error) {
+	mediaType := info.PatchMediaType()
+	if mediaType != "" && info.OriginalRequest.Method != "PATCH" {
+		info.StatusCode = http.StatusUnsupportedMediaType
+		return "", fmt.Errorf("Content-Type %q is only allowed on PATCH",
+			mediaType)
+	}
+	return mediaType, nil
+}
+
+// Apply the JSON or Merge patch to the JSON 'doc'. Any error is the client's
+// fault since 'doc' is what we already have.
+func (info *RequestInfo) ApplyPatch(mediaType string, doc []byte, patch []byte) ([]byte, error) {
+	res, err := ApplyPatch(mediaType, doc, patch)
+	if err == nil && !IsJSONObject(res) {
+		err = fmt.Errorf("The result of the patch must be a JSON object")
+	}
+	if err != nil {
+		info.StatusCode = http.StatusUnprocessableEntity
+		if strings.HasPrefix(err.Error(), "Error parsing") {
+			info.StatusCode = http.StatusBadRequest
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+// Converts a JSON Patch or Merge Patch for the entity referenced by the
+// request into a normal xRegistry PATCH body. The patch is applied to the
+// entity's current attributes and then only the top-level attributes that
+// changed (or were removed, as 'null') are included in the result. This
+// means the rest of the PATCH logic (validation, epoch checks, etc.) is the
+// same regardless of how the client asked for the changes.
+func PatchToEntityBody(info *RequestInfo, mediaType string, patch []byte) ([]byte, error) {
+	if info.What != "Entity" && info.What != "Registry" {
+		info.StatusCode = http.StatusUnsupportedMediaType
+		return nil, fmt.Errorf("Content-Type %q is only allowed on a single "+
+			"entity", mediaType)
+	}
+
+	current, err := GetPatchableObject(info)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return nil, err
+	}
+
+	curJSON, err := json.Marshal(current)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return nil, err
+	}
+
+	newJSON, err := info.ApplyPatch(mediaType, curJSON, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	// Round-trip 'current' too so both sides have the same golang types
+	curObj, newObj := map[string]any{}, map[string]any{}
+	Must(Unmarshal(curJSON, &curObj))
+	Must(Unmarshal(newJSON, &newObj))
+
+	changes := map[string]any{}
+	for key, val := range newObj {
+		if old, ok := curObj[key]; !ok || !reflect.DeepEqual(old, val) {
+			changes[key] = val
+		}
+	}
+	for key, _ := range curObj {
+		if _, ok := newObj[key]; !ok {
+			changes[key] = nil
+		}
+	}
+
+	log.VPrintf(3, "Patch changes: %s", ToJSON(changes))
+	return json.Marshal(changes)
+}
+
+// Returns the user-facing attributes of the entity referenced by the
+// request, or an empty map if it doesn't exist yet. Things that are
+// calculated (e.g. "self") aren't included.
+func GetPatchableObject(info *RequestInfo) (map[string]any, error) {
+	objs := []map[string]any{}
+
+	switch len(info.Parts) {
+	case 0:
+		objs = append(objs, info.Registry.Object)
+
+	case 2:
+		group, err := info.Registry.FindGroup(info.GroupType, info.GroupUID,
+			false, FOR_WRITE)
+		if err != nil {
+			return nil, err
+		}
+		if group != nil {
+			objs = append(objs, group.Object)
+		}
+
+	case 4, 5, 6:
+		group, err := info.Registry.FindGroup(info.GroupType, info.GroupUID,
+			false, FOR_WRITE)
+		if err != nil || group == nil {
+			return map[string]any{}, err
+		}
+
+		resource, err := group.FindResource(info.ResourceType,
+			info.ResourceUID, false, FOR_WRITE)
+		if err != nil || resource == nil {
+			return map[string]any{}, err
+		}
+
+		if len(info.Parts) != 6 {
+			meta, err := resource.FindMeta(false, FOR_WRITE)
+			if err != nil {
+				return nil, err
+			}
+			if meta != nil {
+				objs = append(objs, meta.Object)
+			}
+		}
+
+		version := (*Version)(nil)
+		if len(info.Parts) == 4 {
+			// A Resource's attributes are its 'meta' + default Version's
+			version, err = resource.GetDefault(FOR_WRITE)
+		} else if len(info.Parts) == 6 {
+			version, err = resource.FindVersion(info.VersionUID, false,
+				FOR_WRITE)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if version != nil {
+			objs = append(objs, version.Object)
+		}
+	}
+
+	res := map[string]any{}
+	for _, obj := range objs {
+		maps.Copy(res, obj)
+	}
+
+	// Remove our internal stuff
+	for key, _ := range res {
+		if key == "" || key[0] == '#' {
+			delete(res, key)
+		}
+	}
+	return res, nil
+}
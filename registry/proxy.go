@@ -0,0 +1,455 @@
+package registry
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/duglin/dlog"
+)
+
+// Used to fetch the documents of Versions that have a RESOURCEproxyurl
+var Proxy = NewProxyFetcher()
+
+// Headers that only apply to a single connection and must not be passed
+// along, see RFC 9110 section 7.6.1. Plus Content-Length since we'll set
+// our own.
+var hopByHopHeaders = []string{"Connection", "Keep-Alive",
+	"Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection", "TE",
+	"Trailer", "Transfer-Encoding", "Upgrade", "Content-Length"}
+
+// Fetches, and caches, the documents for RESOURCEproxyurl. The config
+// fields need to be set before it's first used.
+type ProxyFetcher struct {
+	Timeout   time.Duration // Of the entire fetch, 0=no timeout
+	MaxSize   int64         // Max size of a document, 0=no limit
+	CacheSize int64         // Max size of all cached documents, 0=no cache
+
+	// Which upstream hosts are allowed. An entry is a hostname, a
+	// "*.domain" wildcard, an IP, a CIDR, or "private" (for loopback,
+	// private and link-local IPs). A host is allowed if it doesn't match any
+	// Deny entry, and if there are Allow entries, it matches one of them.
+	// An entry that's in both lists is allowed, and on its own doesn't
+	// limit the hosts to just the Allow entries, so that an Allow of
+	// "private" undoes the default Deny of "private".
+	// IPs and CIDRs are checked against the IPs the host resolves to.
+	Allow []string
+	Deny  []string
+
+	client *http.Client
+
+	mutex     sync.Mutex
+	cache     map[string]*list.Element // URL -> *proxyEntry
+	lru       *list.List               // Front is most recently used
+	cacheUsed int64
+}
+
+type ProxyResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+type proxyEntry struct {
+	url          string
+	res          *ProxyResponse
+	expires      time.Time
+	etag         string
+	lastModified string
+}
+
+func NewProxyFetcher() *ProxyFetcher {
+	pf := &ProxyFetcher{
+		Timeout:   30 * time.Second,
+		MaxSize:   16 * 1024 * 1024,
+		CacheSize: 64 * 1024 * 1024,
+
+		// Don't let clients use us to get to internal services by default
+		Deny: []string{"private"},
+
+		cache: map[string]*list.Element{},
+		lru:   list.New(),
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	pf.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy: nil, // Don't let HTTP_PROXY get around Allow/Deny
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(addr)
+				if err != nil {
+					return nil, err
+				}
+				ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+				if err != nil {
+					return nil, err
+				}
+				// Check the IPs we're actually going to use so that DNS
+				// can't be used to get around the checks
+				if err = pf.CheckHost(host, ips); err != nil {
+					return nil, err
+				}
+				for _, ip := range ips {
+					var conn net.Conn
+					conn, err = dialer.DialContext(ctx, network,
+						net.JoinHostPort(ip.String(), port))
+					if err == nil {
+						return conn, nil
+					}
+				}
+				return nil, err
+			},
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("Too many redirects")
+			}
+			return pf.checkURL(req.URL)
+		},
+	}
+
+	return pf
+}
+
+func (pf *ProxyFetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Unsupported URL scheme %q", u.Scheme)
+	}
+	// Check the hostname now so we don't even try to resolve it if it's
+	// not allowed. The IPs are checked as we connect.
+	return pf.CheckHost(u.Hostname(), nil)
+}
+
+// Returns an error if 'host', or any of its 'ips', isn't allowed
+func (pf *ProxyFetcher) CheckHost(host string, ips []net.IP) error {
+	if ip := net.ParseIP(host); ip != nil && len(ips) == 0 {
+		ips = []net.IP{ip}
+	}
+
+	limited := false
+	for _, rule := range pf.Allow {
+		if !hasHostRule(pf.Deny, rule) {
+			limited = true
+			break
+		}
+	}
+
+	for _, rule := range pf.Deny {
+		if hasHostRule(pf.Allow, rule) {
+			continue
+		}
+		if matchHostRule(rule, host, ips) {
+			return fmt.Errorf("Host %q is not allowed", host)
+		}
+	}
+
+	if !limited {
+		return nil
+	}
+
+	for _, rule := range pf.Allow {
+		if matchHostRule(rule, host, ips) {
+			return nil
+		}
+	}
+
+	// Without its IPs we can't know if it'll match an IP rule yet
+	if len(ips) == 0 && net.ParseIP(host) == nil {
+		for _, rule := range pf.Allow {
+			if isIPRule(rule) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("Host %q is not allowed", host)
+}
+
+func hasHostRule(rules []string, rule string) bool {
+	for _, r := range rules {
+		if strings.EqualFold(r, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIPRule(rule string) bool {
+	if rule == "private" || net.ParseIP(rule) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(rule)
+	return err == nil
+}
+
+func matchHostRule(rule string, host string, ips []net.IP) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	rule = strings.ToLower(rule)
+
+	if rule == "private" {
+		for _, ip := range ips {
+			if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+				return true
+			}
+		}
+		return false
+	}
+
+	if _, ipNet, err := net.ParseCIDR(rule); err == nil {
+		for _, ip := range ips {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ruleIP := net.ParseIP(rule); ruleIP != nil {
+		for _, ip := range ips {
+			if ruleIP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if domain, ok := strings.CutPrefix(rule, "*."); ok {
+		return strings.HasSuffix(host, "."+domain)
+	}
+
+	return host == rule
+}
+
+// Returns the document at 'u', from the cache if it's still fresh there.
+// Non-2xx responses are returned as-is (w/o an error) but aren't cached.
+func (pf *ProxyFetcher) Get(u string) (*ProxyResponse, error) {
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid URL %q: %s", u, err)
+	}
+	if err = pf.checkURL(parsedURL); err != nil {
+		return nil, err
+	}
+
+	entry, fresh := pf.getCached(u)
+	if fresh {
+		log.VPrintf(3, "Proxy cache hit: %s", u)
+		return entry.res, nil
+	}
+
+	ctx := context.Background()
+	if pf.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pf.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Revalidate what we have, if we can
+	if entry != nil {
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := pf.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching %q: %s", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		log.VPrintf(3, "Proxy cache revalidated: %s", u)
+		pf.revalidated(entry, resp.Header)
+		return entry.res, nil
+	}
+
+	maxSize := pf.MaxSize
+	if maxSize <= 0 {
+		maxSize = math.MaxInt64 - 1
+	}
+	if resp.ContentLength > maxSize {
+		return nil, fmt.Errorf("Document at %q is too large (%d > %d)", u,
+			resp.ContentLength, maxSize)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching %q: %s", u, err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("Document at %q is too large (> %d)", u,
+			maxSize)
+	}
+
+	res := &ProxyResponse{
+		StatusCode: resp.StatusCode,
+		Header:     filterHeaders(resp.Header),
+		Body:       body,
+	}
+
+	if resp.StatusCode/100 == 2 {
+		pf.putCache(u, res, resp.Header)
+	} else {
+		pf.removeCache(u)
+	}
+
+	return res, nil
+}
+
+// Remove the hop-by-hop headers, including any listed in "Connection"
+func filterHeaders(header http.Header) http.Header {
+	res := header.Clone()
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			res.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		res.Del(name)
+	}
+	return res
+}
+
+// Returns when a response with these headers should no longer be used
+// w/o revalidating it, or the zero time if it shouldn't be cached at all
+func cacheExpiry(header http.Header) time.Time {
+	now := time.Now()
+	noCache := false
+	maxAge, sMaxAge := -1, -1
+
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, val, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			age, err := strconv.Atoi(strings.Trim(val, `"`))
+			if err != nil {
+				age = -1
+			}
+
+			switch strings.ToLower(name) {
+			case "no-store", "private":
+				return time.Time{}
+			case "no-cache":
+				noCache = true
+			case "max-age":
+				maxAge = age
+			case "s-maxage": // For shared caches, like us
+				sMaxAge = age
+			}
+		}
+	}
+
+	if noCache {
+		return now
+	}
+	if sMaxAge >= 0 {
+		maxAge = sMaxAge
+	}
+	if maxAge >= 0 {
+		return now.Add(time.Duration(maxAge) * time.Second)
+	}
+
+	if expires := header.Get("Expires"); expires != "" {
+		if t, err := http.ParseTime(expires); err == nil {
+			return t
+		}
+		return now // Invalid means already expired
+	}
+
+	return now
+}
+
+// Returns the cache entry for 'u', if there is one, and whether it can be
+// used w/o revalidating it
+func (pf *ProxyFetcher) getCached(u string) (*proxyEntry, bool) {
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+
+	if elem, ok := pf.cache[u]; ok {
+		pf.lru.MoveToFront(elem)
+		entry := elem.Value.(*proxyEntry)
+		return entry, time.Now().Before(entry.expires)
+	}
+	return nil, false
+}
+
+// Upstream said our cached copy is still good, 'header' might have new
+// caching info
+func (pf *ProxyFetcher) revalidated(entry *proxyEntry, header http.Header) {
+	expires := cacheExpiry(header)
+	if expires.IsZero() {
+		pf.removeCache(entry.url)
+		return
+	}
+
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+	entry.expires = expires
+}
+
+func (pf *ProxyFetcher) putCache(u string, res *ProxyResponse, header http.Header) {
+	entry := &proxyEntry{
+		url:          u,
+		res:          res,
+		expires:      cacheExpiry(header),
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+	}
+
+	// Not worth keeping if we can't use it w/o fetching it all again
+	if entry.expires.IsZero() || (!time.Now().Before(entry.expires) &&
+		entry.etag == "" && entry.lastModified == "") {
+		pf.removeCache(u)
+		return
+	}
+
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+
+	pf.removeCacheLocked(u)
+
+	size := int64(len(res.Body))
+	if size > pf.CacheSize {
+		return
+	}
+
+	pf.cache[u] = pf.lru.PushFront(entry)
+	pf.cacheUsed += size
+
+	for pf.cacheUsed > pf.CacheSize {
+		pf.removeCacheLocked(pf.lru.Back().Value.(*proxyEntry).url)
+	}
+}
+
+func (pf *ProxyFetcher) removeCache(u string) {
+	pf.mutex.Lock()
+	defer pf.mutex.Unlock()
+	pf.removeCacheLocked(u)
+}
+
+func (pf *ProxyFetcher) removeCacheLocked(u string) {
+	if elem, ok := pf.cache[u]; ok {
+		pf.lru.Remove(elem)
+		delete(pf.cache, u)
+		pf.cacheUsed -= int64(len(elem.Value.(*proxyEntry).res.Body))
+	}
+}
@@ -0,0 +1,345 @@
+package registry
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// An upstream server that counts the requests it gets
+func newUpstream(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *int32) {
+	count := new(int32)
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(count, 1)
+			handler(w, r)
+		}))
+	t.Cleanup(server.Close)
+	return server, count
+}
+
+// The upstream servers are on loopback addresses, which are denied by default
+func newTestFetcher() *ProxyFetcher {
+	pf := NewProxyFetcher()
+	pf.Allow = []string{"private"}
+	return pf
+}
+
+func proxyGet(t *testing.T, pf *ProxyFetcher, u string, exp string) *ProxyResponse {
+	t.Helper()
+	res, err := pf.Get(u)
+	if err != nil {
+		t.Fatalf("Get(%s): %s", u, err)
+	}
+	if string(res.Body) != exp {
+		t.Fatalf("Get(%s) body:\nexp: %q\ngot: %q", u, exp, string(res.Body))
+	}
+	return res
+}
+
+func checkCount(t *testing.T, count *int32, exp int32) {
+	t.Helper()
+	if got := atomic.LoadInt32(count); got != exp {
+		t.Fatalf("Upstream count: exp %d, got %d", exp, got)
+	}
+}
+
+func TestProxyMaxAge(t *testing.T) {
+	pf := newTestFetcher()
+	server, count := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/shared":
+			w.Header().Set("Cache-Control", "max-age=60, s-maxage=0")
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/expires":
+			w.Header().Set("Expires",
+				time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		}
+		w.Write([]byte("data" + r.URL.Path))
+	})
+
+	proxyGet(t, pf, server.URL+"/fresh", "data/fresh")
+	proxyGet(t, pf, server.URL+"/fresh", "data/fresh")
+	checkCount(t, count, 1)
+
+	proxyGet(t, pf, server.URL+"/expires", "data/expires")
+	proxyGet(t, pf, server.URL+"/expires", "data/expires")
+	checkCount(t, count, 2)
+
+	// s-maxage wins, and w/o a validator it's not kept at all
+	proxyGet(t, pf, server.URL+"/shared", "data/shared")
+	proxyGet(t, pf, server.URL+"/shared", "data/shared")
+	checkCount(t, count, 4)
+
+	proxyGet(t, pf, server.URL+"/nostore", "data/nostore")
+	proxyGet(t, pf, server.URL+"/nostore", "data/nostore")
+	checkCount(t, count, 6)
+	if len(pf.cache) != 2 {
+		t.Errorf("Cache should have 2 entries: %v", pf.cache)
+	}
+
+	// No cache at all
+	pf = newTestFetcher()
+	pf.CacheSize = 0
+	proxyGet(t, pf, server.URL+"/fresh", "data/fresh")
+	proxyGet(t, pf, server.URL+"/fresh", "data/fresh")
+	checkCount(t, count, 8)
+}
+
+func TestProxyRevalidate(t *testing.T) {
+	pf := newTestFetcher()
+	version := "1"
+	server, count := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/etag" {
+			w.Header().Set("ETag", `"`+version+`"`)
+			w.Header().Set("Cache-Control", "no-cache")
+			if r.Header.Get("If-None-Match") == `"`+version+`"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		} else {
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			if r.Header.Get("If-Modified-Since") != "" {
+				// Now it's good for a while
+				w.Header().Set("Cache-Control", "max-age=60")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Write([]byte("v" + version))
+	})
+
+	proxyGet(t, pf, server.URL+"/etag", "v1")
+	proxyGet(t, pf, server.URL+"/etag", "v1")
+	checkCount(t, count, 2)
+
+	version = "2"
+	proxyGet(t, pf, server.URL+"/etag", "v2")
+	checkCount(t, count, 3)
+
+	proxyGet(t, pf, server.URL+"/lastmod", "v2")
+	proxyGet(t, pf, server.URL+"/lastmod", "v2")
+	proxyGet(t, pf, server.URL+"/lastmod", "v2")
+	checkCount(t, count, 5)
+}
+
+func TestProxyErrors(t *testing.T) {
+	pf := newTestFetcher()
+	server, count := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not here"))
+		case "/big":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case "/chunked":
+			w.Write([]byte(strings.Repeat("x", 50)))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("x", 50)))
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+			w.Write([]byte("slow"))
+		}
+	})
+
+	// Errors from upstream aren't cached
+	res, err := pf.Get(server.URL + "/missing")
+	if err != nil || res.StatusCode != 404 || string(res.Body) != "not here" {
+		t.Fatalf("missing: %v %v", res, err)
+	}
+	pf.Get(server.URL + "/missing")
+	checkCount(t, count, 2)
+
+	pf.MaxSize = 99
+	for _, path := range []string{"/big", "/chunked"} {
+		_, err = pf.Get(server.URL + path)
+		if err == nil || !strings.Contains(err.Error(), "is too large") {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	pf.MaxSize = 100
+	proxyGet(t, pf, server.URL+"/chunked", strings.Repeat("x", 100))
+
+	pf.Timeout = 100 * time.Millisecond
+	_, err = pf.Get(server.URL + "/slow")
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("slow: %v", err)
+	}
+
+	_, err = pf.Get("file:///etc/passwd")
+	if err == nil || err.Error() != `Unsupported URL scheme "file"` {
+		t.Errorf("file: %v", err)
+	}
+}
+
+func TestProxyLRU(t *testing.T) {
+	pf := newTestFetcher()
+	pf.CacheSize = 10
+	server, count := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(strings.Repeat("x", len(r.URL.Path)-1)))
+	})
+
+	proxyGet(t, pf, server.URL+"/aaaa", "xxxx")
+	proxyGet(t, pf, server.URL+"/bbbb", "xxxx")
+	proxyGet(t, pf, server.URL+"/aaaa", "xxxx") // Now 'b' is the oldest
+	proxyGet(t, pf, server.URL+"/ccc", "xxx")   // Pushes out 'b'
+	checkCount(t, count, 3)
+	if pf.cacheUsed != 7 || len(pf.cache) != 2 {
+		t.Errorf("Cache used: %d %v", pf.cacheUsed, pf.cache)
+	}
+
+	proxyGet(t, pf, server.URL+"/aaaa", "xxxx")
+	proxyGet(t, pf, server.URL+"/ccc", "xxx")
+	checkCount(t, count, 3)
+	proxyGet(t, pf, server.URL+"/bbbb", "xxxx")
+	checkCount(t, count, 4)
+
+	// Too big to cache at all
+	proxyGet(t, pf, server.URL+"/ddddddddddd", "xxxxxxxxxxx")
+	proxyGet(t, pf, server.URL+"/ddddddddddd", "xxxxxxxxxxx")
+	checkCount(t, count, 6)
+	if pf.cacheUsed > pf.CacheSize {
+		t.Errorf("Cache too big: %d", pf.cacheUsed)
+	}
+}
+
+func TestProxyHeaders(t *testing.T) {
+	pf := newTestFetcher()
+	server, _ := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Keep-Alive", "timeout=5")
+		w.Header().Set("Proxy-Authenticate", "Basic")
+		w.Header().Set("Upgrade", "h2c")
+		w.Header().Set("X-Custom", "yes")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hi"))
+	})
+
+	res := proxyGet(t, pf, server.URL, "hi")
+	for _, name := range []string{"Keep-Alive", "Proxy-Authenticate",
+		"Upgrade", "Content-Length"} {
+		if res.Header.Get(name) != "" {
+			t.Errorf("Header %q should have been removed", name)
+		}
+	}
+	if res.Header.Get("X-Custom") != "yes" ||
+		res.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("Missing headers: %v", res.Header)
+	}
+
+	// Go's server rewrites "Connection" so check this one directly
+	header := http.Header{
+		"Connection":  {"X-Conn-Only, close", "X-Other"},
+		"X-Conn-Only": {"1"},
+		"X-Other":     {"1"},
+		"X-Custom":    {"yes"},
+	}
+	if res := filterHeaders(header); len(res) != 1 ||
+		res.Get("X-Custom") != "yes" || header.Get("X-Other") != "1" {
+		t.Errorf("filterHeaders: %v", res)
+	}
+}
+
+func TestProxyHosts(t *testing.T) {
+	ip := func(str string) []net.IP { return []net.IP{net.ParseIP(str)} }
+
+	for _, test := range []struct {
+		allow []string
+		deny  []string
+		host  string
+		ips   []net.IP
+		ok    bool
+	}{
+		{nil, nil, "example.com", nil, true},
+		{nil, []string{"private"}, "example.com", ip("93.184.216.34"), true},
+		{nil, []string{"private"}, "localhost", ip("127.0.0.1"), false},
+		{nil, []string{"private"}, "10.1.2.3", nil, false},
+		{nil, []string{"private"}, "169.254.169.254", nil, false},
+		{nil, []string{"private"}, "::1", nil, false},
+		{nil, []string{"Example.com"}, "example.COM.", nil, false},
+		{nil, []string{"*.example.com"}, "example.com", nil, true},
+		{nil, []string{"*.example.com"}, "a.b.example.com", nil, false},
+		{nil, []string{"10.0.0.0/8"}, "a.com", ip("10.9.9.9"), false},
+		{nil, []string{"10.0.0.0/8"}, "a.com", ip("11.9.9.9"), true},
+
+		{[]string{"*.example.com"}, nil, "a.example.com", nil, true},
+		{[]string{"*.example.com"}, nil, "example.org", nil, false},
+		{[]string{"*.example.com"}, []string{"bad.example.com"},
+			"bad.example.com", nil, false},
+		{[]string{"1.2.3.4"}, nil, "1.2.3.4", nil, true},
+		{[]string{"1.2.3.4"}, nil, "1.2.3.5", nil, false},
+		// Can't tell until we know its IPs
+		{[]string{"1.2.3.0/24"}, nil, "a.com", nil, true},
+		{[]string{"1.2.3.0/24"}, nil, "a.com", ip("1.2.3.9"), true},
+		{[]string{"1.2.3.0/24"}, nil, "a.com", ip("1.2.4.9"), false},
+
+		// An Allow of a Deny entry undoes it, without limiting the hosts
+		{[]string{"private"}, []string{"private"}, "localhost",
+			ip("127.0.0.1"), true},
+		{[]string{"private"}, []string{"private"}, "example.com",
+			ip("93.184.216.34"), true},
+		{[]string{"Private"}, []string{"private", "bad.com"}, "bad.com", nil,
+			false},
+		{[]string{"private", "*.example.com"}, []string{"private"},
+			"example.org", ip("93.184.216.34"), false},
+		{[]string{"private", "*.example.com"}, []string{"private"},
+			"localhost", ip("127.0.0.1"), true},
+	} {
+		pf := &ProxyFetcher{Allow: test.allow, Deny: test.deny}
+		err := pf.CheckHost(test.host, test.ips)
+		if (err == nil) != test.ok {
+			t.Errorf("Allow: %v Deny: %v Host: %s IPs: %v - %v", test.allow,
+				test.deny, test.host, test.ips, err)
+		}
+	}
+}
+
+func TestProxyDefaultDeny(t *testing.T) {
+	server, count := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	pf := NewProxyFetcher()
+	_, err := pf.Get(server.URL)
+	if err == nil || !strings.Contains(err.Error(), `Host "127.0.0.1" is not allowed`) {
+		t.Fatalf("Expected a not allowed error, got: %v", err)
+	}
+	checkCount(t, count, 0)
+
+	pf.Allow = []string{"private"}
+	proxyGet(t, pf, server.URL, "ok")
+}
+
+func TestProxyRedirect(t *testing.T) {
+	pf := newTestFetcher()
+	pf.Deny = []string{"localhost"}
+	server, _ := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			target := strings.Replace(r.Host, "127.0.0.1", "localhost", 1)
+			http.Redirect(w, r, "http://"+target+"/", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	proxyGet(t, pf, server.URL, "ok")
+	_, err := pf.Get(server.URL + "/redirect")
+	if err == nil || !strings.Contains(err.Error(), `Host "localhost" is not allowed`) {
+		t.Errorf("redirect: %v", err)
+	}
+
+	// Checked at dial time too, via its IP
+	pf.Deny = []string{"127.0.0.0/8"}
+	_, err = pf.Get(strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	if err == nil || !strings.Contains(err.Error(), `Host "localhost" is not allowed`) {
+		t.Errorf("dial: %v", err)
+	}
+}
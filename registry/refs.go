@@ -0,0 +1,251 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// The XIDRefs table is an index of all "xid" attribute values, so we can
+// find out who references an entity without scanning all of the Props.
+// Along with the Metas.xRefSID column (for "xref") it's used by
+// GET <entity>?referencedby.
+
+// One entity that references the one asked about, and via which attributes
+type XIDReferrer struct {
+	XID        string   `json:"xid"`
+	Attributes []string `json:"attributes"`
+}
+
+// Replace the XIDRefs rows of 'e' with the "xid" attribute values in 'obj'.
+// Called by Save(), and during model changes since an attribute's type
+// might have changed.
+func (e *Entity) SaveXIDRefs(obj map[string]any) error {
+	err := Do(e.tx, `DELETE FROM XIDRefs WHERE EntitySID=?`, e.DbSID)
+	if err != nil {
+		return fmt.Errorf("Error deleting xid refs(%s): %s", e.Path, err)
+	}
+
+	for name, xid := range e.findXIDProps(obj) {
+		err = DoOne(e.tx, `
+            INSERT INTO XIDRefs(RegistrySID,EntitySID,PropName,Target)
+            VALUES(?,?,?,?)`, e.Registry.DbSID, e.DbSID, name, xid)
+		if err != nil {
+			return fmt.Errorf("Error saving xid ref(%s): %s", e.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns DB prop name -> value of each "xid" attribute in 'obj'
+func (e *Entity) findXIDProps(obj map[string]any) map[string]string {
+	res := map[string]string{}
+
+	var walk func(pp *PropPath, val any, daType string, attrs Attributes,
+		item *Item)
+	walkObject := func(pp *PropPath, obj map[string]any, attrs Attributes) {
+		for key, val := range obj {
+			if len(key) == 0 || key[0] == '#' {
+				continue // System attributes
+			}
+			attr := attrs[key]
+			if attr == nil {
+				if attr = attrs["*"]; attr == nil {
+					continue
+				}
+			}
+			walk(pp.P(key), val, attr.Type, attr.Attributes, attr.Item)
+		}
+	}
+
+	walk = func(pp *PropPath, val any, daType string, attrs Attributes,
+		item *Item) {
+
+		switch daType {
+		case XID:
+			if str, ok := val.(string); ok && str != "" {
+				res[pp.DB()] = str
+			}
+		case OBJECT:
+			if obj, ok := val.(map[string]any); ok {
+				tmp := maps.Clone(attrs)
+				tmp.AddIfValuesAttributes(obj)
+				walkObject(pp, obj, tmp)
+			}
+		case MAP:
+			if m, ok := val.(map[string]any); ok && item != nil {
+				for k, v := range m {
+					walk(pp.P(k), v, item.Type, item.Attributes, item.Item)
+				}
+			}
+		case ARRAY:
+			if a, ok := val.([]any); ok && item != nil {
+				for i, v := range a {
+					walk(pp.I(i), v, item.Type, item.Attributes, item.Item)
+				}
+			}
+		}
+	}
+
+	walkObject(NewPP(), obj, e.GetAttributes(obj))
+	return res
+}
+
+// Returns the entities that reference the entity at 'path' via an "xid"
+// attribute, or a "meta" that has it as its "xref". If 'from' isn't nil
+// then only the entities with that Abstract are included, and entities in
+// Group types the client can't read are always skipped. Sorted by XID.
+func (reg *Registry) FindReferrers(tx *Tx, path string, from *string) ([]*XIDReferrer, error) {
+	log.VPrintf(3, ">Enter: FindReferrers(%s)", path)
+	defer log.VPrintf(3, "<Exit: FindReferrers")
+
+	results, err := Query(tx, `
+        SELECT e.Path, e.Abstract, x.PropName
+        FROM XIDRefs AS x
+        JOIN Entities AS e ON (e.eSID=x.EntitySID)
+        WHERE x.RegistrySID=? AND x.Target=?
+        UNION ALL SELECT m.Path, m.Abstract, 'xref`+string(DB_IN)+`'
+        FROM Metas AS m
+        JOIN Resources AS r ON (r.SID=m.xRefSID)
+        WHERE m.RegistrySID=? AND r.Path=?`,
+		reg.DbSID, "/"+path, reg.DbSID, path)
+	defer results.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	referrers := map[string]*XIDReferrer{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		if from != nil && NotNilString(row[1]) != *from {
+			continue
+		}
+
+		ePath := NotNilString(row[0])
+		groupType, _, _ := strings.Cut(ePath, "/")
+		if !tx.CanRead(groupType) {
+			continue
+		}
+
+		xid := "/" + ePath
+		pp, err := PropPathFromDB(NotNilString(row[2]))
+		if err != nil {
+			return nil, err
+		}
+
+		referrer := referrers[xid]
+		if referrer == nil {
+			referrer = &XIDReferrer{XID: xid}
+			referrers[xid] = referrer
+		}
+		referrer.Attributes = append(referrer.Attributes, pp.UI())
+	}
+
+	list := []*XIDReferrer{}
+	for _, referrer := range referrers {
+		sort.Strings(referrer.Attributes)
+		list = append(list, referrer)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].XID < list[j].XID
+	})
+
+	return list, nil
+}
+
+// GET <entity>?referencedby[=XIDTYPE]
+// Returns a map of XID -> XIDReferrer of the entities that reference this
+// one. Like a collection, it's paginated via ?limit.
+func HTTPGETReferencedBy(info *RequestInfo) error {
+	log.VPrintf(3, ">Enter: HTTPGETReferencedBy")
+	defer log.VPrintf(3, "<Exit: HTTPGETReferencedBy")
+
+	path := strings.Join(info.Parts, "/")
+	found, err := info.Registry.XIDExists(info.tx, "/"+path)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+	if !found {
+		info.StatusCode = http.StatusNotFound
+		return fmt.Errorf("Not found")
+	}
+
+	list, err := info.Registry.FindReferrers(info.tx, path,
+		info.ReferencedBy.From)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	if info.Limit > 0 {
+		total := len(list)
+		start := min(info.PageOffset, total)
+		end := min(start+info.Limit, total)
+		list = list[start:end]
+		if end < total {
+			info.AddHeader("Link", fmt.Sprintf(`<%s>; rel="next"; `+
+				`count=%d`, info.NextPageURL(end), total))
+		}
+	}
+
+	res := map[string]*XIDReferrer{}
+	for _, referrer := range list {
+		res[referrer.XID] = referrer
+	}
+
+	buf, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+
+	info.AddHeader("Content-Type", "application/json")
+	info.Write(buf)
+	info.Write([]byte("\n"))
+	return nil
+}
+
+// Add the XIDRefs table, and the triggers that keep it in sync, to DBs
+// created before it existed and then fill it in. See dbUpgrades
+func upgradeXIDRefs(tx *Tx) error {
+	if err := upgradeSchema(tx, "XIDRefs"); err != nil {
+		return err
+	}
+
+	results, err := Query(tx, `SELECT UID FROM Registries`)
+	defer results.Close()
+	if err != nil {
+		return err
+	}
+	regIDs := []string{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		regIDs = append(regIDs, NotNilString(row[0]))
+	}
+	results.Close()
+
+	for _, regID := range regIDs {
+		tx.Registry = nil // So FindRegistry makes this one the current one
+		reg, err := FindRegistry(tx, regID, FOR_WRITE)
+		if err != nil {
+			return err
+		}
+
+		entities, err := RawEntitiesFromQuery(tx, reg.DbSID, FOR_WRITE, ``)
+		if err != nil {
+			return err
+		}
+		for _, e := range entities {
+			if err := e.SaveXIDRefs(e.Object); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
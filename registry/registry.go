@@ -122,6 +122,9 @@ func NewRegistry(tx *Tx, id string, regOpts ...RegOpt) (*Registry, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = AddShortSelf(tx, dbSID, dbSID, "", false); err != nil {
+		return nil, err
+	}
 
 	reg := &Registry{
 		Entity: Entity{
@@ -250,6 +253,7 @@ func (reg *Registry) Delete() error {
 	if err != nil {
 		return err
 	}
+	reg.tx.AddEvent(&reg.Entity, EVENT_DELETED)
 	reg.tx.EraseCache()
 	return nil
 }
@@ -453,8 +457,13 @@ func (reg *Registry) Update(obj Object, addType AddType) error {
 
 	// Need to do it here instead of under the checkFn because doing it
 	// in checkfn causes a circular reference that golang doesn't like
+	verifyEntities := false
 	val, ok := reg.NewObject["modelsource"]
 	if ok {
+		if err := reg.tx.CheckAdmin(`"modelsource"`); err != nil {
+			return err
+		}
+
 		// Notice that "null" means erase it, not "keep it as is"
 		var rawJson []byte
 
@@ -469,10 +478,13 @@ func (reg *Registry) Update(obj Object, addType AddType) error {
 			}
 		}
 
-		err := reg.Model.ApplyNewModelFromJSON(rawJson)
+		// Verify the existing entities after we've processed the rest of
+		// the request since it might fix the ones the new model breaks
+		err := reg.Model.applyNewModelFromJSON(rawJson, false)
 		if err != nil {
 			return err
 		}
+		verifyEntities = true
 
 		delete(reg.NewObject, "modelsource")
 	}
@@ -535,7 +547,14 @@ func (reg *Registry) Update(obj Object, addType AddType) error {
 		}
 	}
 
-	return reg.ValidateAndSave()
+	if err := reg.ValidateAndSave(); err != nil {
+		return err
+	}
+
+	if verifyEntities {
+		return reg.VerifyEntities(reg.tx.MigrateModel)
+	}
+	return nil
 }
 
 func (reg *Registry) FindGroup(gType string, id string, anyCase bool, accessMode int) (*Group, error) {
@@ -668,6 +687,10 @@ func (reg *Registry) UpsertGroupWithObject(gType string, id string, obj Object,
 			log.Print(err)
 			return nil, false, err
 		}
+		err = AddShortSelf(reg.tx, reg.DbSID, g.DbSID, g.Path, false)
+		if err != nil {
+			return nil, false, err
+		}
 
 		// Use the ID passed as an arg, not from the metadata, as the true
 		// ID. If the one in the metadata differs we'll flag it down below
@@ -755,7 +778,9 @@ func (reg *Registry) UpsertGroupWithObject(gType string, id string, obj Object,
 }
 
 // sortKey = attribute name, -NAME means descending, no "-" means ascending
-func GenerateQuery(reg *Registry, what string, paths []string, filters [][]*FilterExpr, docView bool, sortKey string) (string, []interface{}, error) {
+// limit > 0 means only return the 'limit' entities of the collection (and
+// their children) starting with the one at 'offset'
+func GenerateQuery(reg *Registry, what string, paths []string, filters [][]*FilterExpr, docView bool, sortKey string, search *SearchExpr, offset int, limit int) (string, []interface{}, error) {
 	query := ""
 	args := []any{}
 
@@ -773,15 +798,7 @@ func GenerateQuery(reg *Registry, what string, paths []string, filters [][]*Filt
 			sortKey = sortKey[1:]
 		}
 
-		count := strings.Count(paths[0], "/")
-		if count == 0 {
-			count = 2
-		} else if count == 2 {
-			count = 4
-		} else {
-			count = 6
-		}
-		slashCount := fmt.Sprintf("%d", count)
+		slashCount := fmt.Sprintf("%d", collectionDepth(paths[0]))
 
 		/*
 					sortOrder = `
@@ -812,11 +829,32 @@ func GenerateQuery(reg *Registry, what string, paths []string, filters [][]*Filt
 `
 	}
 
-	args = []interface{}{reg.DbSID}
+	// ?search acts like one more filter and, unless there's a ?sort, the
+	// entities in the collection are ordered by their Score
+	searchJoin := ""
+	if search != nil {
+		filters = addSearchFilter(filters)
+
+		if what == "Coll" && sortKey == "" && len(paths) > 0 {
+			scoreQuery, scoreArgs := searchScoreQuery(reg, search, paths[0])
+			args = append(args, scoreArgs...)
+			searchJoin = `
+  LEFT JOIN (` + scoreQuery + `
+  ) AS ss ON (
+    ss.Path = substring_index(ft.Path, '/', ` +
+				fmt.Sprintf("%d", collectionDepth(paths[0])) + `))
+`
+			sortOrder = `
+    IFNULL(ss.Score, 0) DESC,
+`
+		}
+	}
+
+	args = append(args, reg.DbSID)
 	query = `
 SELECT
   ft.RegSID,ft.Type,ft.Plural,ft.Singular,ft.eSID,ft.UID,ft.PropName,ft.PropValue,ft.PropType,ft.Path,ft.Abstract
-  FROM FullTree AS ft` + sortJoin + `
+  FROM FullTree AS ft` + sortJoin + searchJoin + `
   WHERE ft.RegSID=?
 `
 
@@ -843,111 +881,240 @@ SELECT
 	}
 
 	if len(filters) != 0 {
-		query += `
-AND
-(
-eSID IN ( -- eSID from query
+		filterSQL, filterArgs := filterQuery(reg, "eSID", filters, search)
+		args = append(args, filterArgs...)
+		query += "AND\n(\n" + filterSQL + "\n)\n"
+	}
+
+	// Only one page of the collection. Find the entities in it using the
+	// same order as the ORDER BY below, then grab all of their rows.
+	if what == "Coll" && limit > 0 && len(paths) > 0 {
+		depth := fmt.Sprintf("%d", collectionDepth(paths[0]))
+		pageJoin := ""
+		if sortJoin != "" {
+			pageJoin = `
+      LEFT JOIN FullTree AS sj ON (
+        sj.RegSID=e.RegSID AND
+        sj.Path=e.Path AND
+        sj.PropName='` + sortKey + `')`
+		} else if searchJoin != "" {
+			scoreQuery, scoreArgs := searchScoreQuery(reg, search, paths[0])
+			args = append(args, scoreArgs...)
+			pageJoin = `
+      LEFT JOIN (` + scoreQuery + `
+      ) AS ss ON (ss.Path=e.Path)`
+		}
+
+		where, whereArgs := collectionWhere(reg, paths[0], filters, search)
+		args = append(args, whereArgs...)
+		args = append(args, limit, offset)
+
+		query += `  AND substring_index(ft.Path,'/',` + depth + `) IN (
+    SELECT Path FROM (
+      SELECT e.Path FROM Entities AS e` + pageJoin + where + `
+      ORDER BY ` + sortOrder + `
+        e.Path COLLATE utf8mb4_general_ci ASC
+      LIMIT ? OFFSET ?
+    ) AS pg)
+`
+	}
+
+	query += `  ORDER BY ` + sortOrder +
+		`    ft.Path COLLATE utf8mb4_general_ci ASC;`
+
+	log.VPrintf(3, "Query:\n%s\n\n", SubQuery(query, args))
+	return query, args, nil
+}
+
+// Returns the WHERE clause that selects the entities (e) in the collection
+// at 'path' that match 'filters'
+func collectionWhere(reg *Registry, path string, filters [][]*FilterExpr, search *SearchExpr) (string, []any) {
+	query := `
+      WHERE e.RegSID=? AND e.Path LIKE ? AND e.Path NOT LIKE ?`
+	args := []any{reg.DbSID, path + "/%", path + "/%/%"}
+
+	if len(filters) != 0 {
+		filterSQL, filterArgs := filterQuery(reg, "e.eSID", filters, search)
+		args = append(args, filterArgs...)
+		query += " AND\n" + filterSQL
+	}
+	return query, args
+}
+
+// Returns the number of entities in the collection at 'path' that match
+// 'filters' and 'search'. Used for the "count" of paginated collections.
+func CountCollection(tx *Tx, reg *Registry, path string, filters [][]*FilterExpr, search *SearchExpr) (int, error) {
+	if search != nil {
+		filters = addSearchFilter(filters)
+	}
+
+	where, args := collectionWhere(reg, path, filters, search)
+	results, err := Query(tx, `
+      SELECT COUNT(*) FROM Entities AS e`+where, args...)
+	defer results.Close()
+	if err != nil {
+		return 0, fmt.Errorf("Error counting %q: %s", path, err)
+	}
+
+	row := results.NextRow()
+	if row == nil {
+		return 0, nil
+	}
+	return NotNilInt(row[0]), nil
+}
+
+// Returns an expression that's true when 'column' is the eSID of an entity
+// that matches 'filters', or is an ancestor (or "meta") of one that does.
+// It's the ?filter (and ?search) part of the WHERE clauses of the queries
+// from GenerateQuery and collectionWhere.
+func filterQuery(reg *Registry, column string, filters [][]*FilterExpr, search *SearchExpr) (string, []any) {
+	args := []any{}
+	query := column + ` IN ( -- eSID from query
   -- Find all entities that match the filters, and then grab all parents
   -- This "RECURSIVE" stuff finds all parents
   WITH RECURSIVE cte(eSID,Type,ParentSID,Path) AS (
     -- This defines the init set of rows of the query. We'll recurse later on
     SELECT eSID,Type,ParentSID,Path FROM Entities
     WHERE eSID in ( -- start of the OR Filter groupings`
-		// This section will find all matching entities
-		firstOr := true
-		for _, OrFilters := range filters {
-			if !firstOr {
-				query += `
-      UNION -- Adding another OR`
-			}
-			firstOr = false
+	// This section will find all matching entities
+	firstOr := true
+	for _, OrFilters := range filters {
+		if !firstOr {
 			query += `
+      UNION -- Adding another OR`
+		}
+		firstOr = false
+		query += `
       -- start of one Filter AND grouping (expr1 AND expr2).
       -- Find all SIDs for the leaves for entities (SIDs) of interest.
       SELECT list.eSID FROM (
         SELECT count(*) as cnt,e2.eSID,e2.Path FROM Entities AS e1
         RIGHT JOIN (
           -- start of expr1 - below finds SearchNodes/SIDs of interest`
-			firstAnd := true
-			andCount := 0
-			for _, filter := range OrFilters { // AndFilters
-				andCount++
-				if !firstAnd {
-					query += `
+		firstAnd := true
+		andCount := 0
+		for _, filter := range OrFilters { // AndFilters
+			andCount++
+			if !firstAnd {
+				query += `
           UNION ALL`
-				}
-				firstAnd = false
+			}
+			firstAnd = false
 
-				if filter.Operator == FILTER_PRESENT { // ?filter=xxx
-					// BINARY means case-sensitive for that operand
-					check := "(BINARY Abstract=? AND PropName=? AND "
+			if filter.Operator == FILTER_PRESENT { // ?filter=xxx
+				// BINARY means case-sensitive for that operand
+				check := "(BINARY Abstract=? AND PropName=? AND "
 
-					args = append(args, reg.DbSID, filter.Abstract,
-						filter.PropName)
-					check += "PropValue IS NOT NULL)"
-					query += `
+				args = append(args, reg.DbSID, filter.Abstract,
+					filter.PropName)
+				check += "PropValue IS NOT NULL)"
+				query += `
           SELECT eSID,Type,Path FROM FullTree WHERE RegSID=? AND ` + check
 
-				} else if filter.Operator == FILTER_ABSENT { // ?filter=xxx=null
-					// Look for non-existing prop
-					args = append(args, reg.DbSID, filter.Abstract,
-						filter.PropName)
+			} else if filter.Operator == FILTER_ABSENT { // ?filter=xxx=null
+				// Look for non-existing prop
+				args = append(args, reg.DbSID, filter.Abstract,
+					filter.PropName)
 
-					// BINARY means case-sensitive for that operand
-					query += `
+				// BINARY means case-sensitive for that operand
+				query += `
           -- Entities that don't have the specified prop
           SELECT e.eSID,e.Type,e.Path FROM Entities AS e
           WHERE e.RegSID=? AND e.Abstract=? AND
             NOT EXISTS (SELECT 1 FROM FullTree WHERE
               RegSID=e.RegSID AND eSID=e.eSID AND (BINARY PropName=?))`
 
-				} else if filter.Operator == FILTER_EQUAL { // ?filter=xxx=zzz
-					// BINARY means case-sensitive for that operand
-					check := "(BINARY Abstract=? AND PropName=? AND "
+			} else if filter.Operator == FILTER_EQUAL { // ?filter=xxx=zzz
+				// BINARY means case-sensitive for that operand
+				check := "(BINARY Abstract=? AND PropName=? AND "
 
-					args = append(args, reg.DbSID, filter.Abstract,
-						filter.PropName)
-					value, wildcard := WildcardIt(filter.Value)
+				args = append(args, reg.DbSID, filter.Abstract,
+					filter.PropName)
+				value, wildcard := WildcardIt(filter.Value)
+				args = append(args, value)
+				if !wildcard {
+					check += "PropValue=?"
+				} else {
 					args = append(args, value)
-					if !wildcard {
-						check += "PropValue=?"
-					} else {
-						args = append(args, value)
-						check += "((PropType<>'string' AND PropValue=?) " +
-							" OR (PropType='string' AND PropValue LIKE ?))"
-					}
-					check += ")"
-					query += `
+					check += "((PropType<>'string' AND PropValue=?) " +
+						" OR (PropType='string' AND PropValue LIKE ?))"
+				}
+				check += ")"
+				query += `
           SELECT eSID,Type,Path FROM FullTree
             WHERE RegSID=? AND ` + check
 
-				} else if filter.Operator == FILTER_NOT_EQUAL { // ?filter=x!=z
-					args = append(args, reg.DbSID, filter.Abstract,
-						filter.PropName)
-					// BINARY means case-sensitive for that operand
-					query += `
+			} else if filter.Operator == FILTER_NOT_EQUAL { // ?filter=x!=z
+				args = append(args, reg.DbSID, filter.Abstract,
+					filter.PropName)
+				// BINARY means case-sensitive for that operand
+				query += `
           -- Entities that don't have the specified prop
           SELECT e.eSID,e.Type,e.Path FROM Entities AS e
           WHERE e.RegSID=? AND e.Abstract=? AND
             NOT EXISTS (SELECT 1 FROM FullTree WHERE
               RegSID=e.RegSID AND eSID=e.eSID AND (BINARY PropName=? AND `
 
-					value, wildcard := WildcardIt(filter.Value)
-					args = append(args, value)
-					if !wildcard {
-						query += "PropValue=?"
-					} else {
-						args = append(args, value)
-						query += "((PropType<>'string' AND PropValue=?) " +
-							" OR (PropType='string' AND PropValue LIKE ?))"
-					}
-					query += "))"
-
+				value, wildcard := WildcardIt(filter.Value)
+				args = append(args, value)
+				if !wildcard {
+					query += "PropValue=?"
 				} else {
-					PanicIf(true, "Bad filter.op: %#v", filter)
+					args = append(args, value)
+					query += "((PropType<>'string' AND PropValue=?) " +
+						" OR (PropType='string' AND PropValue LIKE ?))"
 				}
-			} // end of AndFilter
-			query += `
+				query += "))"
+
+			} else if IsRelationalFilterOp(filter.Operator) { // ?filter=x<z
+				// BINARY means case-sensitive for that operand
+				check := "(BINARY Abstract=? AND PropName=? AND "
+				args = append(args, reg.DbSID, filter.Abstract,
+					filter.PropName)
+
+				op := filterSQLOps[filter.Operator]
+				switch filter.Type {
+				case DECIMAL:
+					check += "PropType IN ('integer','decimal','uinteger') " +
+						"AND CAST(PropValue AS DECIMAL(65,30))" + op +
+						"CAST(? AS DECIMAL(65,30))"
+					args = append(args, filter.Value)
+				case TIMESTAMP:
+					// Timestamps are saved in normalized UTC form, so
+					// just drop the 'T' and 'Z' so MySQL can parse them.
+					// Other Dialects Rewrite this exact expression.
+					// Timestamp values are saved as strings
+					check += "PropType IN ('string','timestamp') AND " +
+						"CAST(REPLACE(REPLACE(PropValue,'T',' '),'Z','') " +
+						"AS DATETIME(6))" + op +
+						"CAST(REPLACE(REPLACE(?,'T',' '),'Z','') " +
+						"AS DATETIME(6))"
+					args = append(args, filter.Value)
+				default:
+					check += "PropType IN ('string','uri','urireference'," +
+						"'uritemplate','url','xid','xidtype') AND " +
+						"PropValue" + op + "?"
+					args = append(args, filter.Value)
+				}
+				check += ")"
+				query += `
+          SELECT eSID,Type,Path FROM FullTree
+            WHERE RegSID=? AND ` + check
+
+			} else if filter.Operator == FILTER_SEARCH { // ?search=terms
+				matchQuery, matchArgs := searchMatchQuery(reg, search)
+				args = append(args, matchArgs...)
+				args = append(args, reg.DbSID)
+				query += `
+          SELECT e.eSID,e.Type,e.Path FROM Entities AS e
+          WHERE e.eSID IN (SELECT EntitySID FROM (` + matchQuery + `
+            ) AS s) AND e.RegSID=?`
+
+			} else {
+				PanicIf(true, "Bad filter.op: %#v", filter)
+			}
+		} // end of AndFilter
+		query += `
           -- end of expr1
         ) AS result ON ( result.eSID=e1.eSID )
         -- For each result found, find all Leaves under the matching entity.
@@ -968,8 +1135,8 @@ eSID IN ( -- eSID from query
             (
               -- For 'meta' objects, compare it's parent's Path
               result.Type=` + StrTypes(ENTITY_META) + ` AND
-              ( e2.Path=TRIM(TRAILING '/meta' FROM result.Path) OR
-                e2.Path LIKE CONCAT(TRIM(TRAILING 'meta' FROM result.Path),'%')
+              ( e2.Path=SUBSTR(result.Path,1,LENGTH(result.Path)-5) OR
+                e2.Path LIKE CONCAT(SUBSTR(result.Path,1,LENGTH(result.Path)-4),'%')
               )
             )
           )
@@ -979,16 +1146,16 @@ eSID IN ( -- eSID from query
       ) as list
       WHERE list.cnt=?   -- cnt is the # of operands in the AND filter
       -- end of one Filter AND grouping (expr1 AND expr2 ...)`
-			args = append(args, andCount)
-		} // end of OrFilter
+		args = append(args, andCount)
+	} // end of OrFilter
 
-		query += `
+	query += `
     ) -- end of all OR Filter groupings
 
     -- This is the recusive part of the query.
     -- Find all of the parents (and 'meta' sub-objects) of the found
     -- entities, up to root of Reg.
-    UNION DISTINCT SELECT
+    UNION SELECT
       e.eSID,e.Type,e.ParentSID,e.Path
     FROM Entities AS e
     INNER JOIN cte ON
@@ -1003,16 +1170,17 @@ eSID IN ( -- eSID from query
         )
       )
   )
-  SELECT DISTINCT eSID FROM cte )
-)
-`
-	}
+  SELECT DISTINCT eSID FROM cte )`
 
-	query += `  ORDER BY ` + sortOrder +
-		`    ft.Path COLLATE utf8mb4_general_ci ASC;`
+	return query, args
+}
 
-	log.VPrintf(3, "Query:\n%s\n\n", SubQuery(query, args))
-	return query, args, nil
+var filterSQLOps = map[int]string{
+	FILTER_LESS:          "<",
+	FILTER_LESS_EQUAL:    "<=",
+	FILTER_GREATER:       ">",
+	FILTER_GREATER_EQUAL: ">=",
+	FILTER_LESS_GREATER:  "<>",
 }
 
 func WildcardIt(str string) (string, bool) {
@@ -450,11 +450,14 @@ func (r *Resource) UpsertMetaWithObject(obj Object, addType AddType, createVersi
 	meta, err := r.FindMeta(false, FOR_WRITE)
 	PanicIf(err != nil, "No meta %q: %s", r.UID, err)
 
-	if meta.Get("readonly") == true {
+	if meta.Get("readonly") == true && !r.tx.IsAdmin() {
 		return nil, false, fmt.Errorf("Write operations on read-only " +
 			"resources are not allowed")
 	}
 
+	oldCompat := meta.Get("compatibility")
+	oldCompatAuth := meta.Get("compatibilityauthority")
+
 	if obj != nil {
 		if val, ok := obj[r.Singular+"id"]; ok {
 			if val != r.UID {
@@ -573,6 +576,14 @@ func (r *Resource) UpsertMetaWithObject(obj Object, addType AddType, createVersi
 							"type %q not %q",
 							xref, targetAbsModel, xrefAbsModel)
 				}
+
+				// Existing xrefs keep working if the target is deprecated
+				if meta == nil || meta.Object["xref"] != xref {
+					err = r.Registry.CheckXrefDeprecation(xid)
+					if err != nil {
+						return nil, false, err
+					}
+				}
 			}
 		}
 	}
@@ -609,6 +620,10 @@ func (r *Resource) UpsertMetaWithObject(obj Object, addType AddType, createVersi
 		if err != nil {
 			return nil, false, fmt.Errorf("Error adding Meta: %s", err)
 		}
+		err = AddShortSelf(r.tx, r.Registry.DbSID, r.DbSID, meta.Path, false)
+		if err != nil {
+			return nil, false, err
+		}
 
 		if err = meta.JustSet(r.Singular+"id", r.UID); err != nil {
 			return nil, false, err
@@ -623,6 +638,10 @@ func (r *Resource) UpsertMetaWithObject(obj Object, addType AddType, createVersi
 
 	// Process any xref
 	if hasXref {
+		if err = r.SyncXrefShortSelfs(xref); err != nil {
+			return nil, false, err
+		}
+
 		if IsNil(xrefAny) || xref == "" {
 			newEpochAny := meta.Object["#epoch"]
 			newEpoch := NotNilInt(&newEpochAny)
@@ -752,6 +771,15 @@ func (r *Resource) UpsertMetaWithObject(obj Object, addType AddType, createVersi
 		if err = meta.ValidateAndSave(); err != nil {
 			return nil, false, err
 		}
+
+		// Changing the compatibility mode means we need to re-verify
+		if meta.Get("compatibility") != oldCompat ||
+			meta.Get("compatibilityauthority") != oldCompatAuth {
+			r.tx.CompatCheckAll(r)
+		}
+		if err = r.EnsureCompatibility(); err != nil {
+			return nil, false, err
+		}
 	}
 
 	return meta, isNew, nil
@@ -854,7 +882,7 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object,
 	meta, err := r.FindMeta(false, FOR_WRITE)
 	PanicIf(err != nil, "No meta %q: %s", r.UID, err)
 
-	if meta.Get("readonly") == true {
+	if meta.Get("readonly") == true && !r.tx.IsAdmin() {
 		return nil, false, fmt.Errorf("Write operations on read-only " +
 			"resources are not allowed")
 	}
@@ -930,6 +958,16 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object,
 	// If Version doesn't exist, create it
 	isNew := (v == nil)
 	if v == nil {
+		// A brand new Resource is always allowed to have Versions
+		if count, err := r.GetNumberOfVersions(); err != nil {
+			return nil, false, err
+		} else if count > 0 {
+			err = r.CheckDeprecationPolicy(DEPRECATIONPOLICY_NOVERSIONS)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+
 		v = &Version{
 			Entity: Entity{
 				EntityExtensions: EntityExtensions{
@@ -965,6 +1003,13 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object,
 			log.Print(err)
 			return nil, false, err
 		}
+		err = AddShortSelf(r.tx, r.Registry.DbSID, v.DbSID, v.Path, true)
+		if err != nil {
+			return nil, false, err
+		}
+		if err = r.AddXrefShortSelfs(v.UID); err != nil {
+			return nil, false, err
+		}
 
 		v.tx.AddVersion(v)
 
@@ -1102,11 +1147,21 @@ func (r *Resource) UpsertVersionWithObject(id string, obj Object,
 	// _, touchedTS := v.NewObject["createdat"]
 	// if touchedTS -> call EnsureLatest
 
+	oldAncestor := v.Object["ancestor"]
+
 	// Make sure we always have an ID
 	if err = v.ValidateAndSave(); err != nil {
 		return nil, false, err
 	}
 
+	// Moving a Version changes which ones need to be compatible
+	if !isNew && !IsNil(oldAncestor) && oldAncestor != ANCESTOR_TBD &&
+		v.Get("ancestor") != oldAncestor {
+		r.tx.CompatCheckAll(r)
+	} else {
+		r.tx.CompatCheckVersion(v)
+	}
+
 	// If there are no more versions to be processed for this Resource in
 	// this transaction, go ahead and clean-up the versions wrt the latest
 	// and ancestor pointers
@@ -1162,6 +1217,11 @@ func (r *Resource) CompleteUpsertVersions() error {
 		}
 	}
 
+	// Verify compatibility if the server is the authority for it
+	if err := r.EnsureCompatibility(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -1191,12 +1251,22 @@ type VersionAncestor struct {
 // - newest (lowest) createdat timestamp first
 // If more than one share the same timestamp, then it's sorted as:
 // - lowest alphabetically (case insensitive) first
+// That's for the default "manual" versionmode. For "createdat" the tree
+// position is ignored, and for "semver" the IDs are sorted as semvers, with
+// createdat breaking ties.
 func (r *Resource) GetOrderedVersionIDs() ([]*VersionAncestor, error) {
+	mode := r.GetResourceModel().GetVersionMode()
+
+	order := "Pos ASC, Time ASC, VersionUID ASC"
+	if mode != VERSIONMODE_MANUAL {
+		order = "Time ASC, VersionUID ASC"
+	}
+
 	results, err := Query(r.tx, `
             SELECT VersionUID, Ancestor, Pos, Time FROM VersionAncestors
 			WHERE RegistrySID=? AND ResourceSID=? AND
 			  Ancestor<>'`+ANCESTOR_TBD+`'
-			ORDER BY Pos ASC, Time ASC, VersionUID ASC`,
+			ORDER BY `+order,
 		r.Registry.DbSID, r.DbSID)
 	defer results.Close()
 
@@ -1218,6 +1288,12 @@ func (r *Resource) GetOrderedVersionIDs() ([]*VersionAncestor, error) {
 		})
 	}
 
+	if mode == VERSIONMODE_SEMVER {
+		sort.SliceStable(vers, func(i, j int) bool {
+			return CompareSemVer(vers[i].VID, vers[j].VID) < 0
+		})
+	}
+
 	return vers, nil
 }
 
@@ -1442,11 +1518,15 @@ func (r *Resource) Delete() error {
 	meta, err := r.FindMeta(false, FOR_WRITE)
 	PanicIf(err != nil, "No meta %q: %s", r.UID, err)
 
-	if meta.Get("readonly") == true {
+	if meta.Get("readonly") == true && !r.tx.IsAdmin() {
 		return fmt.Errorf("Delete operations on read-only " +
 			"resources are not allowed")
 	}
 
+	if err = r.CheckIntegrityOnDelete(); err != nil {
+		return err
+	}
+
 	if err = meta.Delete(); err != nil {
 		return err
 	}
@@ -1461,6 +1541,7 @@ func (r *Resource) Delete() error {
 	if err != nil {
 		return err
 	}
+	r.tx.AddEvent(&r.Entity, EVENT_DELETED)
 	r.tx.RemoveFromCache(&r.Entity)
 	return nil
 }
@@ -1479,6 +1560,7 @@ func (m *Meta) Delete() error {
 	if err != nil {
 		return err
 	}
+	m.tx.AddEvent(&m.Entity, EVENT_DELETED)
 	m.tx.RemoveFromCache(&m.Entity)
 	return nil
 }
@@ -1572,6 +1654,7 @@ func (r *Resource) CheckAncestors() error {
 		}
 
 		v.SetSave("ancestor", newestVerID)
+		r.tx.CompatCheckVersion(v)
 		newestVerID = v.UID // This one is now the latest
 	}
 
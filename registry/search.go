@@ -0,0 +1,224 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// The attributes that ?search looks at. Any label's value is included too.
+var searchAttrs = []string{"name", "description"}
+
+// Returns a query that finds the entities (EntitySID) that match 'search',
+// and their relevance Score. An entity can show up more than once (e.g. a
+// match on its name and one on its description) so callers need to SUM()
+// them. Only Groups and Versions have attributes of their own, Resources
+// and "meta" will match via their Versions.
+//
+// All SQL is MySQL's (see Dialect) which uses the FULLTEXT indexes on
+// Props.PropValue and ResourceContents.SearchText. Other Dialects need to
+// Rewrite the MATCH() AGAINST() expressions.
+func searchMatchQuery(reg *Registry, search *SearchExpr) (string, []any) {
+	query := `
+            SELECT EntitySID, MATCH(PropValue) AGAINST(?) AS Score
+            FROM Props
+            WHERE RegistrySID=? AND
+              eType IN (` + StrTypes(ENTITY_GROUP) + `,` +
+		StrTypes(ENTITY_VERSION) + `) AND
+              (PropName IN (?,?) OR PropName LIKE ?) AND
+              MATCH(PropValue) AGAINST(?)`
+
+	args := []any{search.Terms, reg.DbSID,
+		NewPPP(searchAttrs[0]).DB(), NewPPP(searchAttrs[1]).DB(),
+		NewPPP("labels").DB() + "%", search.Terms}
+
+	if search.Docs {
+		// Only textual documents have a SearchText, see SetDBProperty
+		query += `
+            UNION ALL
+            SELECT VersionSID, MATCH(SearchText) AGAINST(?) AS Score
+            FROM ResourceContents
+            WHERE MATCH(SearchText) AGAINST(?)`
+		args = append(args, search.Terms, search.Terms)
+	}
+
+	return query, args
+}
+
+// Returns a query that sums up the Scores of the matching entities, and
+// their descendants, for each entity in the collection at 'path'. So a
+// Group's Score will include the Scores of its Resources' Versions.
+func searchScoreQuery(reg *Registry, search *SearchExpr, path string) (string, []any) {
+	depth := fmt.Sprintf("%d", collectionDepth(path))
+	matchQuery, args := searchMatchQuery(reg, search)
+
+	query := `
+    SELECT substring_index(e.Path,'/',` + depth + `) AS Path,
+      SUM(s.Score) AS Score
+    FROM (` + matchQuery + `
+    ) AS s
+    JOIN Entities AS e ON (e.eSID=s.EntitySID)
+    WHERE e.RegSID=? AND (e.Path=? OR e.Path LIKE ?)
+    GROUP BY substring_index(e.Path,'/',` + depth + `)`
+	args = append(args, reg.DbSID, path, path+"/%")
+
+	return query, args
+}
+
+// Add ?search as one more expression in each of the ?filter AND groupings,
+// or as the only filter if there aren't any
+func addSearchFilter(filters [][]*FilterExpr) [][]*FilterExpr {
+	searchFilter := &FilterExpr{Operator: FILTER_SEARCH}
+
+	if len(filters) == 0 {
+		return [][]*FilterExpr{{searchFilter}}
+	}
+
+	res := [][]*FilterExpr{}
+	for _, andFilters := range filters {
+		andFilters = append(append([]*FilterExpr{}, andFilters...),
+			searchFilter)
+		res = append(res, andFilters)
+	}
+	return res
+}
+
+// Save the ?search Score of each matching entity in the collection at
+// 'path' so the JsonWriter can include it in the output
+func (info *RequestInfo) LoadSearchScores(path string) error {
+	query, args := searchScoreQuery(info.Registry, info.Search, path)
+
+	results, err := Query(info.tx, query, args...)
+	defer results.Close()
+	if err != nil {
+		return fmt.Errorf("Error searching for %q: %s", info.Search.Terms,
+			err)
+	}
+
+	info.SearchScores = map[string]float64{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		score := 0.0
+		switch val := (*row[1]).(type) {
+		case float64:
+			score = val
+		case int64:
+			score = float64(val)
+		case []byte:
+			score, _ = strconv.ParseFloat(string(val), 64)
+		case string:
+			score, _ = strconv.ParseFloat(val, 64)
+		}
+		info.SearchScores[NotNilString(row[0])] = score
+	}
+
+	log.VPrintf(3, "Search scores: %v", info.SearchScores)
+	return nil
+}
+
+// Returns the number of parts in the Paths of the entities in the
+// collection at 'path', e.g. "dirs" -> 2 (dirs/d1)
+func collectionDepth(path string) int {
+	switch strings.Count(path, "/") {
+	case 0:
+		return 2
+	case 2:
+		return 4
+	}
+	return 6
+}
+
+// Add the ResourceContents.SearchText column, and on MySQL the FULLTEXT
+// indexes, to DBs created before ?search existed, see dbUpgrades. Then
+// fill in the SearchText of the existing textual documents. Note that on
+// MySQL the ALTERs commit the Tx, so this needs to be safe to re-run.
+func upgradeSearch(tx *Tx) error {
+	isMySQL := DB_Dialect.Name() == "mysql"
+
+	found, err := hasColumn(tx, "ResourceContents", "SearchText")
+	if err != nil {
+		return err
+	}
+	if !found {
+		log.Printf("Adding ResourceContents.SearchText for ?search")
+		alter := `ALTER TABLE ResourceContents ADD COLUMN SearchText TEXT`
+		if isMySQL {
+			alter = `ALTER TABLE ResourceContents
+                ADD COLUMN SearchText MEDIUMTEXT, ADD FULLTEXT (SearchText)`
+		}
+		if err = Do(tx, alter); err != nil {
+			return err
+		}
+	}
+
+	if isMySQL {
+		results, err := Query(tx, `
+            SELECT COUNT(*) FROM information_schema.STATISTICS
+            WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME='Props' AND
+              INDEX_TYPE='FULLTEXT'`)
+		if err != nil {
+			return err
+		}
+		row := results.NextRow()
+		results.Close()
+		if row == nil || NotNilInt(row[0]) == 0 {
+			log.Printf("Adding the FULLTEXT index on Props for ?search")
+			err = Do(tx, `ALTER TABLE Props ADD FULLTEXT (PropValue)`)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Documents in a BlobStore always have a SearchText, since BlobStores
+	// came after ?search, so only the ones in the DB need to be checked
+	count, last := 0, ""
+	for {
+		results, err := Query(tx, `
+            SELECT VersionSID,Content FROM ResourceContents
+            WHERE VersionSID>? AND SearchText IS NULL AND Content IS NOT NULL
+            ORDER BY VersionSID LIMIT 100`, last)
+		if err != nil {
+			return err
+		}
+
+		rows := 0
+		texts := map[string]string{}
+		for row := results.NextRow(); row != nil; row = results.NextRow() {
+			rows++
+			last = NotNilString(row[0])
+			buf := []byte{}
+			switch content := (*(row[1])).(type) {
+			case string:
+				buf = []byte(content)
+			case []byte:
+				buf = content
+			}
+			if utf8.Valid(buf) {
+				texts[last] = truncateUTF8(string(buf), MAX_SEARCH_TEXT)
+			}
+		}
+		results.Close()
+
+		for vSID, text := range texts {
+			err = DoOne(tx, `
+                UPDATE ResourceContents SET SearchText=? WHERE VersionSID=?`,
+				text, vSID)
+			if err != nil {
+				return err
+			}
+		}
+		count += len(texts)
+
+		if rows < 100 {
+			break
+		}
+	}
+	if count > 0 {
+		log.Printf("Added the SearchText of %d document(s)", count)
+	}
+	return nil
+}
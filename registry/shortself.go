@@ -0,0 +1,267 @@
+package registry
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// An entity's "shortself" is BaseURL + "/r?u=" + MD5(path), and since an
+// MD5 can't be reversed we keep a table (ShortSelfs) that maps it back to
+// the path. Resources and Versions get a 2nd entry for their "$details"
+// path since that's what their "shortself" points to when the request was
+// for the xRegistry metadata.
+func AddShortSelf(tx *Tx, regSID string, entitySID string, path string,
+	withDetails bool) error {
+
+	paths := []string{path}
+	if withDetails {
+		paths = append(paths, path+"$details")
+	}
+
+	for _, p := range paths {
+		err := Do(tx, `
+            REPLACE INTO ShortSelfs(RegistrySID, ShortSelf, EntitySID, Path)
+            VALUES(?,?,?,?)`, regSID, MD5(p), entitySID, p)
+		if err != nil {
+			return fmt.Errorf("Error adding shortself for %q: %s", p, err)
+		}
+	}
+	return nil
+}
+
+// The Versions of a Resource with an "xref" only exist in the Entities
+// view, as copies of the target's Versions, so their ShortSelfs are added
+// whenever the "xref" is set and whenever the target gets a new Version.
+// They're saved under the SID of the Resource with the "xref" so that they
+// go away with it, and since the target's Versions can be deleted w/o us
+// knowing, FindShortSelf makes sure they still exist.
+
+// Replaces the ShortSelfs of the Versions of 'r', which has (or had) an
+// "xref", with ones for the Versions of the Resource at 'xref'. An empty
+// 'xref' means it's being removed.
+func (r *Resource) SyncXrefShortSelfs(xref string) error {
+	err := Do(r.tx, `
+        DELETE FROM ShortSelfs
+        WHERE RegistrySID=? AND EntitySID=? AND Path LIKE ?`,
+		r.Registry.DbSID, r.DbSID, r.Path+"/versions/%")
+	if err != nil {
+		return fmt.Errorf("Error deleting shortselfs of %q: %s", r.Path, err)
+	}
+	if xref == "" {
+		return nil
+	}
+
+	results, err := Query(r.tx, `
+        SELECT v.UID FROM Versions AS v
+        JOIN Resources AS r ON (r.SID=v.ResourceSID)
+        WHERE r.RegistrySID=? AND r.Path=?`,
+		r.Registry.DbSID, strings.TrimPrefix(xref, "/"))
+	defer results.Close()
+	if err != nil {
+		return fmt.Errorf("Error finding Versions of %q: %s", xref, err)
+	}
+	vIDs := []string{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		vIDs = append(vIDs, NotNilString(row[0]))
+	}
+	results.Close()
+
+	for _, vID := range vIDs {
+		err = AddShortSelf(r.tx, r.Registry.DbSID, r.DbSID,
+			r.Path+"/versions/"+vID, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adds the ShortSelfs for Version 'vID' of 'r' to each Resource that has an
+// "xref" to 'r'
+func (r *Resource) AddXrefShortSelfs(vID string) error {
+	results, err := Query(r.tx, `
+        SELECT r.SID, r.Path FROM Metas AS m
+        JOIN Resources AS r ON (r.SID=m.ResourceSID)
+        WHERE m.RegistrySID=? AND m.xRefSID=?`,
+		r.Registry.DbSID, r.DbSID)
+	defer results.Close()
+	if err != nil {
+		return fmt.Errorf("Error finding xrefs to %q: %s", r.Path, err)
+	}
+	sources := [][]string{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		sources = append(sources,
+			[]string{NotNilString(row[0]), NotNilString(row[1])})
+	}
+	results.Close()
+
+	for _, source := range sources {
+		err = AddShortSelf(r.tx, r.Registry.DbSID, source[0],
+			source[1]+"/versions/"+vID, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the path (relative to the root of the Registry) for the 'short'
+// value of a "shortself" URL, and whether it was found
+func (reg *Registry) FindShortSelf(short string) (string, bool, error) {
+	results, err := Query(reg.tx, `
+        SELECT ss.Path, ss.EntitySID, r.Path FROM ShortSelfs AS ss
+        LEFT JOIN Resources AS r ON (r.SID=ss.EntitySID)
+        WHERE ss.RegistrySID=? AND ss.ShortSelf=?`,
+		reg.DbSID, short)
+	defer results.Close()
+	if err != nil {
+		return "", false, err
+	}
+	row := results.NextRow()
+	if row == nil {
+		return "", false, nil
+	}
+	path, eSID, rPath := NotNilString(row[0]), NotNilString(row[1]),
+		NotNilString(row[2])
+	results.Close()
+
+	// Versions of an xref'd Resource, make sure the target still has it
+	vID, isXref := strings.CutPrefix(path, rPath+"/versions/")
+	if rPath == "" || !isXref {
+		return path, true, nil
+	}
+	results, err = Query(reg.tx, `
+        SELECT COUNT(*) FROM Metas AS m
+        JOIN Versions AS v ON (v.ResourceSID=m.xRefSID)
+        WHERE m.ResourceSID=? AND v.UID=?`,
+		eSID, strings.TrimSuffix(vID, "$details"))
+	defer results.Close()
+	if err != nil {
+		return "", false, err
+	}
+	row = results.NextRow()
+	return path, row != nil && NotNilInt(row[0]) > 0, nil
+}
+
+// Adds the ShortSelfs table, and the triggers that clean it up, to DBs
+// created before it existed and then fills it in. See dbUpgrades
+func upgradeShortSelfs(tx *Tx) error {
+	if err := upgradeSchema(tx, "ShortSelfs"); err != nil {
+		return err
+	}
+	return backfillShortSelfs(tx)
+}
+
+// Adds any missing ShortSelfs
+func backfillShortSelfs(tx *Tx) error {
+	results, err := Query(tx, `SELECT RegistrySID, Path FROM ShortSelfs`)
+	defer results.Close()
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		have[NotNilString(row[0])+"/"+NotNilString(row[1])] = true
+	}
+	results.Close()
+
+	results, err = Query(tx, `
+        SELECT RegSID, eSID, ParentSID, Type, Path FROM Entities`)
+	defer results.Close()
+	if err != nil {
+		return err
+	}
+	type missing struct {
+		regSID, eSID, path string
+		details            bool
+	}
+	list := []missing{}
+	for row := results.NextRow(); row != nil; row = results.NextRow() {
+		regSID, path := NotNilString(row[0]), NotNilString(row[4])
+		if have[regSID+"/"+path] {
+			continue
+		}
+
+		// "meta" and the Versions of an xref'd Resource use the SID of
+		// the Resource, see AddShortSelf's callers and SyncXrefShortSelfs
+		eSID := NotNilString(row[1])
+		eType := NotNilInt(row[3])
+		if eType == ENTITY_META || strings.HasPrefix(eSID, "-") {
+			eSID = NotNilString(row[2])
+		}
+		list = append(list, missing{regSID, eSID, path,
+			eType == ENTITY_RESOURCE || eType == ENTITY_VERSION})
+	}
+	results.Close()
+
+	for _, m := range list {
+		err = AddShortSelf(tx, m.regSID, m.eSID, m.path, m.details)
+		if err != nil {
+			return err
+		}
+	}
+	if len(list) > 0 {
+		log.Printf("Added %d missing shortself(s)", len(list))
+	}
+	return nil
+}
+
+// GET /r?u=SHORT - redirect to the entity's full "self" URL. Any other
+// query parameters (e.g. ?inline or ?doc) are passed along
+func HTTPShortSelf(info *RequestInfo) error {
+	log.VPrintf(3, ">Enter: HTTPShortSelf")
+	defer log.VPrintf(3, "<Exit: HTTPShortSelf")
+
+	if !info.Registry.Capabilities.ShortSelfEnabled("") || len(info.Parts) > 1 {
+		info.StatusCode = http.StatusNotFound
+		return fmt.Errorf("Not found")
+	}
+
+	query := info.OriginalRequest.URL.Query()
+	short := query.Get("u")
+	if short == "" {
+		return fmt.Errorf("Missing the \"u\" query parameter")
+	}
+
+	path, found, err := info.Registry.FindShortSelf(short)
+	if err != nil {
+		info.StatusCode = http.StatusInternalServerError
+		return err
+	}
+	if !found {
+		info.StatusCode = http.StatusNotFound
+		return fmt.Errorf("Not found")
+	}
+
+	query.Del("u")
+	loc := info.BaseURL + "/" + path
+	if len(query) > 0 {
+		loc += "?" + encodeQuery(query)
+	}
+
+	info.AddHeader("Location", loc)
+	info.StatusCode = http.StatusMovedPermanently
+	return nil
+}
+
+// Like url.Values.Encode() except flags w/o values (e.g. ?inline) don't get
+// an "=" added
+func encodeQuery(query url.Values) string {
+	res := []string{}
+	for _, key := range SortedKeys(query) {
+		for _, val := range query[key] {
+			if val == "" {
+				res = append(res, url.QueryEscape(key))
+			} else {
+				res = append(res, url.QueryEscape(key)+"="+
+					url.QueryEscape(val))
+			}
+		}
+	}
+	return strings.Join(res, "&")
+}
@@ -0,0 +1,123 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/duglin/dlog"
+)
+
+// How often, at most, we check to see if the cert/key files have changed
+var CertCheckInterval = 5 * time.Second
+
+// Serves up the cert/key pair from the files, reloading them when either
+// file changes so that certs can be rotated w/o a restart. If the new files
+// are bad (e.g. we caught them mid-update) we keep using the old cert.
+type CertReloader struct {
+	CertFile string
+	KeyFile  string
+
+	mutex     sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time // Newest mod time of the 2 files
+	lastCheck time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	cr := &CertReloader{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+	}
+
+	modTime, err := cr.getModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err = cr.load(modTime); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+func (cr *CertReloader) getModTime() (time.Time, error) {
+	modTime := time.Time{}
+	for _, file := range []string{cr.CertFile, cr.KeyFile} {
+		stat, err := os.Stat(file)
+		if err != nil {
+			return modTime, err
+		}
+		if stat.ModTime().After(modTime) {
+			modTime = stat.ModTime()
+		}
+	}
+	return modTime, nil
+}
+
+func (cr *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cr.CertFile, cr.KeyFile)
+	if err != nil {
+		return fmt.Errorf("Error loading TLS cert(%s)/key(%s): %s",
+			cr.CertFile, cr.KeyFile, err)
+	}
+	cr.cert = &cert
+	cr.modTime = modTime
+	return nil
+}
+
+// For tls.Config.GetCertificate
+func (cr *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mutex.Lock()
+	defer cr.mutex.Unlock()
+
+	if time.Since(cr.lastCheck) >= CertCheckInterval {
+		cr.lastCheck = time.Now()
+
+		modTime, err := cr.getModTime()
+		if err == nil && !modTime.Equal(cr.modTime) {
+			if err = cr.load(modTime); err == nil {
+				log.VPrintf(1, "Reloaded TLS cert: %s", cr.CertFile)
+			}
+		}
+		if err != nil {
+			log.Printf("Keeping old TLS cert: %s", err)
+		}
+	}
+
+	return cr.cert, nil
+}
+
+// Turn on HTTPS for the server. If 'clientCAFile' isn't "" then clients must
+// present a cert signed by one of the CAs in that (PEM) file.
+func (s *Server) EnableTLS(certFile, keyFile, clientCAFile string) error {
+	cr, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+
+	if clientCAFile != "" {
+		buf, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return fmt.Errorf("Error reading client CA file(%s): %s",
+				clientCAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return fmt.Errorf("No certs found in client CA file(%s)",
+				clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	s.HTTPServer.TLSConfig = config
+	return nil
+}
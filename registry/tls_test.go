@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestCert(t *testing.T, dir string, name string, modTime time.Time) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey,
+		key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %s", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %s", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		0600)
+	os.Chtimes(certFile, modTime, modTime)
+	os.Chtimes(keyFile, modTime, modTime)
+
+	return certFile, keyFile
+}
+
+func getCertName(t *testing.T, cr *CertReloader) string {
+	t.Helper()
+	cert, err := cr.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate: %s", err)
+	}
+	x, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("ParseCertificate: %s", err)
+	}
+	return x.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	save := CertCheckInterval
+	defer func() { CertCheckInterval = save }()
+	CertCheckInterval = 0
+
+	dir := t.TempDir()
+	now := time.Now()
+	certFile, keyFile := writeTestCert(t, dir, "cert1", now.Add(-time.Minute))
+
+	cr, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader: %s", err)
+	}
+	if name := getCertName(t, cr); name != "cert1" {
+		t.Fatalf("Expected cert1, got %q", name)
+	}
+
+	// Rotated cert is picked up
+	writeTestCert(t, dir, "cert2", now)
+	if name := getCertName(t, cr); name != "cert2" {
+		t.Fatalf("Expected cert2, got %q", name)
+	}
+
+	// Bad files keep the old cert
+	os.WriteFile(keyFile, []byte("junk"), 0600)
+	os.Chtimes(keyFile, now.Add(time.Minute), now.Add(time.Minute))
+	if name := getCertName(t, cr); name != "cert2" {
+		t.Fatalf("Expected cert2 still, got %q", name)
+	}
+
+	if _, err := NewCertReloader(certFile, filepath.Join(dir, "missing")); err == nil {
+		t.Fatalf("Expected an error for a missing key file")
+	}
+}
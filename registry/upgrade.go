@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/duglin/dlog"
+	. "github.com/xregistry/server/common"
+)
+
+// Changes needed to bring a DB created by an older version of the server
+// up to date. Each one is run, in its own Tx, the first time the server
+// starts with that DB and is then recorded in the DBUpgrades table so it's
+// not run again. New DBs run them too, so they need to be cheap when
+// there's nothing to do. Only ever append to this list.
+var dbUpgrades = []struct {
+	Name string
+	Func func(tx *Tx) error
+}{
+	{"shortselfs", upgradeShortSelfs},
+	{"xidrefs", upgradeXIDRefs},
+	{"search", upgradeSearch},
+	{"blobs", upgradeBlobs},
+}
+
+// Run any dbUpgrades that haven't been applied to the current DB yet,
+// see OpenDB
+func UpgradeDB() error {
+	log.VPrintf(3, ">Enter: UpgradeDB")
+	defer log.VPrintf(3, "<Exit: UpgradeDB")
+
+	// DBs older than the DBUpgrades table won't have it
+	tx, err := NewTx()
+	if err != nil {
+		return err
+	}
+	err = Do(tx, `CREATE TABLE IF NOT EXISTS DBUpgrades (
+        Name VARCHAR(64) NOT NULL,
+        PRIMARY KEY (Name))`)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Error creating the DBUpgrades table: %s", err)
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("Error creating the DBUpgrades table: %s", err)
+	}
+
+	for _, upgrade := range dbUpgrades {
+		tx, err := NewTx()
+		if err != nil {
+			return err
+		}
+
+		results, err := Query(tx,
+			`SELECT Name FROM DBUpgrades WHERE Name=?`, upgrade.Name)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error upgrading the DB (%s): %s",
+				upgrade.Name, err)
+		}
+		done := results.NextRow() != nil
+		results.Close()
+		if done {
+			tx.Rollback()
+			continue
+		}
+
+		log.VPrintf(2, "Upgrading the DB: %s", upgrade.Name)
+		if err = upgrade.Func(tx); err == nil {
+			err = DoOne(tx, `INSERT INTO DBUpgrades(Name) VALUES(?)`,
+				upgrade.Name)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Error upgrading the DB (%s): %s",
+				upgrade.Name, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return fmt.Errorf("Error upgrading the DB (%s): %s",
+				upgrade.Name, err)
+		}
+	}
+	return nil
+}
+
+// True if 'table' has a 'column', for upgrades that add one
+func hasColumn(tx *Tx, table string, column string) (bool, error) {
+	query := `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name=?`
+	if DB_Dialect.Name() == "mysql" {
+		query = `
+            SELECT COUNT(*) FROM information_schema.COLUMNS
+            WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=? AND COLUMN_NAME=?`
+	}
+
+	results, err := Query(tx, query, table, column)
+	if err != nil {
+		return false, err
+	}
+	defer results.Close()
+
+	row := results.NextRow()
+	return row != nil && NotNilInt(row[0]) > 0, nil
+}
+
+// True if the DB has a table called 'table'
+func hasTable(tx *Tx, table string) (bool, error) {
+	query := `SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?`
+	if DB_Dialect.Name() == "mysql" {
+		query = `
+            SELECT COUNT(*) FROM information_schema.TABLES
+            WHERE TABLE_SCHEMA=DATABASE() AND TABLE_NAME=?`
+	}
+
+	results, err := Query(tx, query, table)
+	if err != nil {
+		return false, err
+	}
+	defer results.Close()
+
+	row := results.NextRow()
+	return row != nil && NotNilInt(row[0]) > 0, nil
+}
+
+var schemaCreateRE = regexp.MustCompile(
+	`^CREATE\s+(TABLE|INDEX|TRIGGER)\s+"?(\w+)"?\s+(?:ON\s+"?(\w+)"?)?`)
+var schemaWordRE = regexp.MustCompile(`\w+`)
+
+// Create the 'tables' that were added to the schema after the DB was
+// created, along with their indexes, using the Dialect's current Schema()
+// so the two can't get out of sync. Any triggers that use them are
+// re-created too since they're the ones whose bodies would have changed.
+// Tables that are already there are left alone, while triggers are always
+// re-created, so it's safe to run more than once.
+func upgradeSchema(tx *Tx, tables ...string) error {
+	type schemaCmd struct {
+		kind, name, on, cmd string
+	}
+	cmds := []schemaCmd{}
+	for _, cmd := range SplitSchema(DB_Dialect.Schema()) {
+		// Skip any leading comments
+		for strings.HasPrefix(cmd, "--") || strings.HasPrefix(cmd, "#") {
+			_, cmd, _ = strings.Cut(cmd, "\n")
+			cmd = strings.TrimSpace(cmd)
+		}
+		if m := schemaCreateRE.FindStringSubmatch(cmd); m != nil {
+			cmds = append(cmds, schemaCmd{m[1], m[2], m[3], cmd})
+		}
+	}
+
+	// Schema changes can't be prepared (MySQL won't run CREATE TRIGGER
+	// that way) and are already in the Dialect's SQL so just run them
+	exec := func(cmd string) error {
+		if tx.tx == nil {
+			if err := tx.NewTx(); err != nil {
+				return err
+			}
+		}
+		log.VPrintf(4, "CMD: %s", cmd)
+		if _, err := tx.tx.Exec(cmd); err != nil {
+			return fmt.Errorf("Error on: %s\n%s", cmd, err)
+		}
+		return nil
+	}
+
+	usesTable := map[string]bool{}
+	for _, table := range tables {
+		usesTable[table] = true
+
+		found, err := hasTable(tx, table)
+		if err != nil {
+			return err
+		}
+		if found {
+			continue
+		}
+
+		log.Printf("Adding the %s table", table)
+		for _, sc := range cmds {
+			if (sc.kind == "TABLE" && sc.name == table) ||
+				(sc.kind == "INDEX" && sc.on == table) {
+				if err := exec(sc.cmd); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, sc := range cmds {
+		if sc.kind != "TRIGGER" {
+			continue
+		}
+		uses := false
+		for _, word := range schemaWordRE.FindAllString(sc.cmd, -1) {
+			uses = uses || usesTable[word]
+		}
+		if !uses {
+			continue
+		}
+		if err := exec(`DROP TRIGGER IF EXISTS ` + sc.name); err != nil {
+			return err
+		}
+		if err := exec(sc.cmd); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
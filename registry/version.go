@@ -40,17 +40,25 @@ func (v *Version) JustDelete() error {
 		}
 	}
 
-	if meta.Get("readonly") == true {
+	if meta.Get("readonly") == true && !v.tx.IsAdmin() {
 		return fmt.Errorf("Delete operations on read-only " +
 			"resources are not allowed")
 	}
 
+	if err := v.CheckIntegrityOnDelete(); err != nil {
+		return err
+	}
+
 	// Zero is ok if it's already been deleted
 	err = DoZeroOne(v.tx, `DELETE FROM Versions WHERE SID=?`, v.DbSID)
 	if err != nil {
 		return fmt.Errorf("Error deleting Version %q: %s", v.UID, err)
 	}
+	v.tx.AddEvent(&v.Entity, EVENT_DELETED)
 	v.tx.RemoveFromCache(&v.Entity)
+
+	// Its descendants now have new ancestors
+	v.tx.CompatCheckAll(v.Resource)
 	return nil
 }
 
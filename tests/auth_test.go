@@ -0,0 +1,488 @@
+package tests
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/xregistry/server/registry"
+)
+
+func basicAuth(user, pwd string) string {
+	return "Authorization: Basic " +
+		base64.StdEncoding.EncodeToString([]byte(user+":"+pwd))
+}
+
+func setupAuth(t *testing.T, config *registry.AuthConfig) {
+	t.Helper()
+	auth, err := registry.NewAuth(config)
+	xNoErr(t, err)
+	TestServer.Auth = auth
+	t.Cleanup(func() { TestServer.Auth = nil })
+}
+
+func TestAuthConfig(t *testing.T) {
+	auth, err := registry.NewAuth(&registry.AuthConfig{})
+	xCheckEqual(t, "", err.Error(), "No authenticators or anonymous roles "+
+		"defined, nothing would be allowed")
+	xCheck(t, auth == nil, "auth should be nil")
+
+	_, err = registry.NewAuth(&registry.AuthConfig{
+		Tokens: map[string]string{"t1": "alice"},
+		Roles:  map[string][]string{"alice": {"boss"}},
+	})
+	xCheckEqual(t, "", err.Error(), `Role for user "alice": Unknown role `+
+		`"boss", must be one of: reader, writer, admin`)
+
+	_, err = registry.NewAuth(&registry.AuthConfig{
+		Htpasswd: "files/auth/missing",
+	})
+	xCheckEqual(t, "", err.Error(),
+		"open files/auth/missing: no such file or directory")
+
+	_, err = registry.NewAuth(&registry.AuthConfig{
+		Proxy: &registry.AuthProxyConfig{Header: "X-Forwarded-User"},
+	})
+	xCheckEqual(t, "", err.Error(),
+		"Missing the list of trusted proxy addresses")
+
+	_, err = registry.NewAuth(&registry.AuthConfig{
+		Proxy: &registry.AuthProxyConfig{
+			Header:  "X-Forwarded-User",
+			Trusted: []string{"1.2.3"},
+		},
+	})
+	xCheckEqual(t, "", err.Error(), `Invalid trusted proxy address `+
+		`"1.2.3/32": invalid CIDR address: 1.2.3/32`)
+
+	rb, err := registry.ParseRoleBinding("writer:reg1/dirs")
+	xNoErr(t, err)
+	xCheck(t, rb.Role == registry.ROLE_WRITER, "should be writer")
+	xCheck(t, rb.Matches("reg1", "dirs"), "should match reg1/dirs")
+	xCheck(t, !rb.Matches("reg1", ""), "shouldn't match reg1")
+	xCheck(t, !rb.Matches("reg2", "dirs"), "shouldn't match reg2/dirs")
+
+	rb, err = registry.ParseRoleBinding("admin:*/dirs")
+	xNoErr(t, err)
+	xCheck(t, rb.Matches("reg2", "dirs"), "should match reg2/dirs")
+	xCheck(t, !rb.Matches("reg2", "files"), "shouldn't match reg2/files")
+}
+
+func TestAuthRoles(t *testing.T) {
+	reg := NewRegistry("TestAuthRoles")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	gm, _ = reg.Model.AddGroupModel("schemagroups", "schemagroup")
+	gm.AddResourceModel("schemas", "schema", 0, true, true, true)
+
+	setupAuth(t, &registry.AuthConfig{
+		Tokens: map[string]string{
+			"admintoken":  "alice",
+			"readertoken": "dave",
+		},
+		Htpasswd: "files/auth/htpasswd",
+		Roles: map[string][]string{
+			"alice": {"admin"},
+			"bob":   {"reader", "writer:TestAuthRoles/dirs"},
+			"carol": {"writer:TestAuthRoles"},
+			"*":     {"reader:TestAuthRoles"},
+		},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "no creds",
+		URL:        "/",
+		Method:     "GET",
+		Code:       401,
+		ResHeaders: []string{`WWW-Authenticate: Bearer realm="xRegistry"`},
+		ResBody:    "Authentication required\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "bad token",
+		URL:        "/",
+		Method:     "GET",
+		ReqHeaders: []string{"Authorization: Bearer foo"},
+		ResHeaders: []string{"*"},
+		Code:       401,
+		ResBody:    "Invalid bearer token\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "bad password",
+		URL:        "/",
+		Method:     "GET",
+		ReqHeaders: []string{basicAuth("bob", "carolpwd")},
+		ResHeaders: []string{"*"},
+		Code:       401,
+		ResBody:    "Invalid user or password\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "reader GET",
+		URL:        "/dirs",
+		Method:     "GET",
+		ReqHeaders: []string{"Authorization: Bearer readertoken"},
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody:    "{}\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "reader PUT",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqHeaders: []string{"Authorization: Bearer readertoken"},
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `User "dave" is not allowed to PUT "/dirs/d1"` + "\n",
+	})
+
+	// bob (bcrypt) can only write to "dirs"
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "group writer PUT",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{basicAuth("bob", "bobpwd")},
+		ReqBody:    "hello",
+		ResHeaders: []string{"*"},
+		Code:       201,
+		ResBody:    "hello",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "group writer PUT other group",
+		URL:        "/schemagroups/sg1",
+		Method:     "PUT",
+		ReqHeaders: []string{basicAuth("bob", "bobpwd")},
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody: `User "bob" is not allowed to PUT "/schemagroups/sg1"` +
+			"\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "group writer PATCH registry",
+		URL:        "/",
+		Method:     "PATCH",
+		ReqHeaders: []string{basicAuth("bob", "bobpwd")},
+		ReqBody:    `{"description":"hi"}`,
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `User "bob" is not allowed to PATCH "/"` + "\n",
+	})
+
+	// carol (SHA1) can write anywhere in this registry, but not the model
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "reg writer PUT",
+		URL:        "/schemagroups/sg1",
+		Method:     "PUT",
+		ReqHeaders: []string{basicAuth("carol", "carolpwd")},
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       201,
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "reg writer PUT model",
+		URL:        "/modelsource",
+		Method:     "PUT",
+		ReqHeaders: []string{basicAuth("carol", "carolpwd")},
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `User "carol" is not allowed to PUT "/modelsource"` + "\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "reg writer PATCH modelsource attr",
+		URL:        "/",
+		Method:     "PATCH",
+		ReqHeaders: []string{basicAuth("carol", "carolpwd")},
+		ReqBody:    `{"modelsource":{}}`,
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `Only admins can change "modelsource"` + "\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "reg writer PATCH capabilities attr",
+		URL:        "/",
+		Method:     "PATCH",
+		ReqHeaders: []string{basicAuth("carol", "carolpwd")},
+		ReqBody:    `{"capabilities":{}}`,
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `Only admins can change "capabilities"` + "\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "admin DELETE",
+		URL:        "/schemagroups/sg1",
+		Method:     "DELETE",
+		ReqHeaders: []string{"Authorization: Bearer admintoken"},
+		ResHeaders: []string{"*"},
+		Code:       204,
+		ResBody:    "",
+	})
+
+	// The xRegistry~User header can't be used to spoof the user
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:   "spoofed user",
+		URL:    "/dirs/d2",
+		Method: "PUT",
+		ReqHeaders: []string{"Authorization: Bearer readertoken",
+			"xRegistry~User: alice"},
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `User "dave" is not allowed to PUT "/dirs/d2"` + "\n",
+	})
+
+	// Other registries only get what's been granted to all registries
+	reg2, err := registry.NewRegistry(nil, "TestAuthRoles2")
+	defer PassDeleteReg(t, reg2)
+	xNoErr(t, err)
+	reg2.SaveAllAndCommit()
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "other reg writer GET",
+		URL:        "/reg-TestAuthRoles2",
+		Method:     "GET",
+		ReqHeaders: []string{basicAuth("carol", "carolpwd")},
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `User "carol" is not allowed to GET "/"` + "\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "other reg reader GET",
+		URL:        "/reg-TestAuthRoles2",
+		Method:     "GET",
+		ReqHeaders: []string{basicAuth("bob", "bobpwd")},
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody:    "*",
+	})
+}
+
+func TestAuthAnonymousAndProxy(t *testing.T) {
+	reg := NewRegistry("TestAuthAnonymousAndProxy")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	setupAuth(t, &registry.AuthConfig{
+		Proxy: &registry.AuthProxyConfig{
+			Header:  "X-Forwarded-User",
+			Trusted: []string{"127.0.0.1", "::1"},
+		},
+		Roles: map[string][]string{
+			"erin": {"writer"},
+		},
+		Anonymous: []string{"reader"},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "anonymous GET",
+		URL:        "/dirs",
+		Method:     "GET",
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody:    "{}\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "anonymous PUT",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       401,
+		ResBody:    "Authentication required\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "proxy user PUT",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqHeaders: []string{"X-Forwarded-User: erin"},
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       201,
+		ResBody:    "*",
+	})
+
+	// The test client is local, so change the list of trusted addresses
+	// to make sure the header is ignored when it's from someone else
+	setupAuth(t, &registry.AuthConfig{
+		Proxy: &registry.AuthProxyConfig{
+			Header:  "X-Forwarded-User",
+			Trusted: []string{"10.0.0.0/8"},
+		},
+		Roles: map[string][]string{
+			"erin": {"writer"},
+		},
+		Anonymous: []string{"reader"},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "untrusted proxy PUT",
+		URL:        "/dirs/d2",
+		Method:     "PUT",
+		ReqHeaders: []string{"X-Forwarded-User: erin"},
+		ReqBody:    "{}",
+		ResHeaders: []string{"*"},
+		Code:       401,
+		ResBody:    "Authentication required\n",
+	})
+}
+
+func TestAuthReadOnly(t *testing.T) {
+	reg := NewRegistry("TestAuthReadOnly")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	setupAuth(t, &registry.AuthConfig{
+		Tokens: map[string]string{
+			"admintoken":  "alice",
+			"writertoken": "bob",
+		},
+		Roles: map[string][]string{
+			"alice": {"admin"},
+			"bob":   {"writer"},
+		},
+	})
+
+	admin := "Authorization: Bearer admintoken"
+	writer := "Authorization: Bearer writertoken"
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "create",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{writer},
+		ReqBody:    "hello",
+		ResHeaders: []string{"*"},
+		Code:       201,
+		ResBody:    "hello",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "writer sets readonly",
+		URL:        "/dirs/d1/files/f1/meta",
+		Method:     "PATCH",
+		ReqHeaders: []string{writer},
+		ReqBody:    `{"readonly":true}`,
+		ResHeaders: []string{"*"},
+		Code:       403,
+		ResBody:    `Only admins can change "readonly"` + "\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "admin sets readonly",
+		URL:        "/dirs/d1/files/f1/meta",
+		Method:     "PATCH",
+		ReqHeaders: []string{admin},
+		ReqBody:    `{"readonly":true}`,
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody: `{
+  "fileid": "f1",
+  "self": "http://localhost:8181/dirs/d1/files/f1/meta",
+  "xid": "/dirs/d1/files/f1/meta",
+  "epoch": 2,
+  "createdat": "2024-01-01T12:00:01Z",
+  "modifiedat": "2024-01-01T12:00:02Z",
+  "readonly": true,
+  "compatibility": "none",
+
+  "defaultversionid": "1",
+  "defaultversionurl": "http://localhost:8181/dirs/d1/files/f1/versions/1",
+  "defaultversionsticky": false
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "writer updates readonly",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{writer},
+		ReqBody:    "bye",
+		ResHeaders: []string{"*"},
+		Code:       400,
+		ResBody:    "Write operations on read-only resources are not allowed\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "writer deletes readonly",
+		URL:        "/dirs/d1",
+		Method:     "DELETE",
+		ReqHeaders: []string{writer},
+		ResHeaders: []string{"*"},
+		Code:       400,
+		ResBody:    "Delete operations on read-only resources are not allowed\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "admin updates readonly",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{admin},
+		ReqBody:    "bye",
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody:    "bye",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "admin clears readonly",
+		URL:        "/dirs/d1/files/f1/meta",
+		Method:     "PATCH",
+		ReqHeaders: []string{admin},
+		ReqBody:    `{"readonly":false}`,
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "writer updates",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{writer},
+		ReqBody:    "again",
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody:    "again",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "admin sets readonly again",
+		URL:        "/dirs/d1/files/f1/meta",
+		Method:     "PATCH",
+		ReqHeaders: []string{admin},
+		ReqBody:    `{"readonly":true}`,
+		ResHeaders: []string{"*"},
+		Code:       200,
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "admin deletes readonly",
+		URL:        "/dirs/d1",
+		Method:     "DELETE",
+		ReqHeaders: []string{admin},
+		ResHeaders: []string{"*"},
+		Code:       204,
+		ResBody:    "",
+	})
+}
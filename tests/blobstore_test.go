@@ -0,0 +1,97 @@
+package tests
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/xregistry/server/registry"
+)
+
+func TestBlobStore(t *testing.T) {
+	reg := NewRegistry("TestBlobStore")
+	defer PassDeleteReg(t, reg)
+	defer func() { registry.Blobs = nil }()
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	// Saved in the DB
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1", "hello", 201, "hello")
+
+	store, err := registry.NewFileBlobStore(t.TempDir())
+	xNoErr(t, err)
+	registry.Blobs = store
+
+	// Saved in the BlobStore, same doc only stored once
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2", "world", 201, "world")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/v2", "world", 201,
+		"world")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f3", "other", 201, "other")
+
+	blobs, err := store.List()
+	xNoErr(t, err)
+	xCheckEqual(t, "", len(blobs), 2)
+
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", "", 200, "hello")
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f2/versions/1", "", 200, "world")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/dirs/d1/files/f2$details?inline=file",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("filebase64": "[^"]*").*$||$1`},
+		ResBody:   `"filebase64": "d29ybGQ="`,
+	})
+
+	// Move the rest of the docs out of the DB
+	count, err := registry.MigrateBlobs(store)
+	xNoErr(t, err)
+	xCheck(t, count >= 1, "Should have moved f1's doc, moved: %d", count)
+	count, err = registry.MigrateBlobs(store)
+	xNoErr(t, err)
+	xCheckEqual(t, "", count, 0)
+
+	blobs, _ = store.List()
+	xCheckEqual(t, "", len(blobs), 3)
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", "", 200, "hello")
+
+	// Only unused blobs are deleted
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f3", "", 204, "")
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f2/versions/1", "", 204, "")
+
+	count, err = registry.GCBlobs(store, 0)
+	xNoErr(t, err)
+	xCheckEqual(t, "", count, 1)
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f2", "", 200, "world")
+
+	// Too new to delete
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f2", "", 204, "")
+	count, err = registry.GCBlobs(store, time.Hour)
+	xNoErr(t, err)
+	xCheckEqual(t, "", count, 0)
+
+	registry.Blobs = nil
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", "", 500,
+		"Document \"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824\" "+
+			"is in a blob store but none is configured\n")
+}
+
+func TestBlobStoreCmds(t *testing.T) {
+	cmd := exec.Command("../xrserver", "db", "migrate-blobs", "testreg")
+	out, err := cmd.CombinedOutput()
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", string(out), "Missing --blobstore\n")
+
+	cmd = exec.Command("../xrserver", "db", "gc-blobs", "--blobstore",
+		t.TempDir())
+	out, err = cmd.CombinedOutput()
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", string(out), "Missing DB NAME argument\n")
+
+	cmd = exec.Command("../xrserver", "db", "migrate-blobs", "testreg",
+		"--blobstore", "ftp://example.com")
+	out, err = cmd.CombinedOutput()
+	xCheck(t, err != nil, "Should have failed")
+	xCheckEqual(t, "", string(out), "Unsupported blob store "+
+		"\"ftp://example.com\", must be a directory or an s3:// URL\n")
+}
@@ -27,6 +27,7 @@ func TestCapabilitySimple(t *testing.T) {
     "epoch",
     "filter",
     "inline",
+    "migrate",
     "nodefaultversionid",
     "nodefaultversionsticky",
     "noepoch",
@@ -42,7 +43,7 @@ func TestCapabilitySimple(t *testing.T) {
     "entities",
     "model"
   ],
-  "pagination": false,
+  "pagination": true,
   "schemas": [
     "xregistry-json/`+SPECVERSION+`"
   ],
@@ -76,6 +77,7 @@ func TestCapabilitySimple(t *testing.T) {
       "epoch",
       "filter",
       "inline",
+      "migrate",
       "nodefaultversionid",
       "nodefaultversionsticky",
       "noepoch",
@@ -91,7 +93,7 @@ func TestCapabilitySimple(t *testing.T) {
       "entities",
       "model"
     ],
-    "pagination": false,
+    "pagination": true,
     "schemas": [
       "xregistry-json/`+SPECVERSION+`"
     ],
@@ -288,6 +290,7 @@ func TestCapabilityPath(t *testing.T) {
     "epoch",
     "filter",
     "inline",
+    "migrate",
     "nodefaultversionid",
     "nodefaultversionsticky",
     "noepoch",
@@ -303,7 +306,7 @@ func TestCapabilityPath(t *testing.T) {
     "entities",
     "model"
   ],
-  "pagination": false,
+  "pagination": true,
   "schemas": [
     "xregistry-json/`+SPECVERSION+`"
   ],
@@ -463,8 +466,9 @@ func TestCapabilityPath(t *testing.T) {
     "/capabilities", "/export", "/model", "/modelsource"
   ],
   "flags": [
-    "collections", "doc", "epoch", "filter", "inline", "nodefaultversionid",
-    "nodefaultversionsticky", "noepoch", "noreadonly", "offered", "schema",
+    "collections", "doc", "epoch", "filter", "inline", "migrate",
+    "nodefaultversionid", "nodefaultversionsticky", "noepoch", "noreadonly",
+    "offered", "schema",
 	"setdefaultversionid", "sort", "specversion"
   ],
   "mutable": [ "capabilities", "entities", "model" ],
@@ -487,6 +491,7 @@ func TestCapabilityPath(t *testing.T) {
     "epoch",
     "filter",
     "inline",
+    "migrate",
     "nodefaultversionid",
     "nodefaultversionsticky",
     "noepoch",
@@ -527,6 +532,7 @@ func TestCapabilityPath(t *testing.T) {
     "epoch",
     "filter",
     "inline",
+    "migrate",
     "nodefaultversionid",
     "nodefaultversionsticky",
     "noepoch",
@@ -638,11 +644,26 @@ func TestCapabilityPath(t *testing.T) {
 }
 `)
 
-	xHTTP(t, reg, "PUT", "/capabilities", `{"pagination":true}`, 400,
-		`"pagination" must be "false"`+"\n")
+	xHTTP(t, reg, "PUT", "/capabilities",
+		`{"apis":["/capabilities"],"pagination":true}`, 200, `{
+  "apis": [
+    "/capabilities"
+  ],
+  "flags": [],
+  "mutable": [],
+  "pagination": true,
+  "schemas": [
+    "xregistry-json/`+SPECVERSION+`"
+  ],
+  "shortself": false,
+  "specversions": [
+    "`+SPECVERSION+`"
+  ],
+  "sticky": true
+}
+`)
 
-	xHTTP(t, reg, "PUT", "/capabilities", `{"shortself":true}`, 400,
-		`"shortself" must be "false"`+"\n")
+	xHTTP(t, reg, "PATCH", "/capabilities", `{"shortself":true}`, 200, "*")
 
 	// Setting some arrays to [] are an error because we can't do what they
 	// asked - which is different from "null"/absent - which means "default"
@@ -752,8 +773,9 @@ func TestCapabilityAttr(t *testing.T) {
 	xHTTP(t, reg, "PUT", "/?inline=capabilities", `{ "capabilities": {
   "apis": ["/export", "/model", "/modelsource", "/capabilities"],
   "flags": [
-    "collections", "doc", "epoch", "filter", "inline", "nodefaultversionid",
-    "nodefaultversionsticky", "noepoch", "noreadonly", "offered", "schema",
+    "collections", "doc", "epoch", "filter", "inline", "migrate",
+    "nodefaultversionid", "nodefaultversionsticky", "noepoch", "noreadonly",
+    "offered", "schema",
 	"setdefaultversionid", "sort", "specversion"
   ],
   "mutable": [ "capabilities", "entities", "model" ],
@@ -787,6 +809,7 @@ func TestCapabilityAttr(t *testing.T) {
     "epoch",
     "filter",
     "inline",
+    "migrate",
     "nodefaultversionid",
     "nodefaultversionsticky",
     "noepoch",
@@ -892,7 +915,7 @@ func TestCapabilityAttr(t *testing.T) {
 
 }
 
-// "collections", "doc", "epoch", "filter", "inline",
+// "collections", "doc", "epoch", "filter", "inline", "migrate",
 // "nodefaultversionid", "nodefaultversionsticky",
 // "noepoch", "noreadonly", "offered", "schema", "setdefaultversionid",
 // "sort", "specversion"})
@@ -1045,6 +1068,7 @@ func TestCapabilityOffered(t *testing.T) {
       "epoch",
       "filter",
       "inline",
+      "migrate",
       "nodefaultversionid",
       "nodefaultversionsticky",
       "noepoch",
@@ -1067,7 +1091,8 @@ func TestCapabilityOffered(t *testing.T) {
   "pagination": {
     "type": "boolean",
     "enum": [
-      false
+      false,
+      true
     ]
   },
   "schemas": {
@@ -1079,7 +1104,8 @@ func TestCapabilityOffered(t *testing.T) {
   "shortself": {
     "type": "boolean",
     "enum": [
-      false
+      false,
+      true
     ]
   },
   "specversions": {
@@ -1199,6 +1225,12 @@ func TestCapabilityAPIs(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -1320,7 +1352,7 @@ func TestCapabilityPatch(t *testing.T) {
     "entities",
     "model"
   ],
-  "pagination": false,
+  "pagination": true,
   "schemas": [
     "xregistry-json/`+SPECVERSION+`"
   ],
@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"testing"
+)
+
+func TestCompatJSONSchema(t *testing.T) {
+	reg := NewRegistry("TestCompatJSONSchema")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1", `{
+  "type": "object",
+  "properties": {
+    "name": { "type": "string" },
+    "age": { "type": "integer", "minimum": 0 }
+  },
+  "required": [ "name" ],
+  "additionalProperties": false
+}`, 201, "*")
+
+	// "server" is kept, not replaced with "external"
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibility":"backward","compatibilityauthority":"server"}`,
+		200, `{
+  "fileid": "f1",
+  "self": "http://localhost:8181/dirs/d1/files/f1/meta",
+  "xid": "/dirs/d1/files/f1/meta",
+  "epoch": 2,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+  "readonly": false,
+  "compatibility": "backward",
+  "compatibilityauthority": "server",
+
+  "defaultversionid": "v1",
+  "defaultversionurl": "http://localhost:8181/dirs/d1/files/f1/versions/v1",
+  "defaultversionsticky": false
+}
+`)
+
+	// New required property - v2 can't read v1 data
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", `{
+  "type": "object",
+  "properties": {
+    "name": { "type": "string" },
+    "age": { "type": "integer", "minimum": 0 },
+    "email": { "type": "string" }
+  },
+  "required": [ "name", "email" ]
+}`, 400, `Version "v2" isn't backward compatible with Version "v1":
+  "": "v2" requires property "email"
+`)
+
+	// Narrower type and range
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", `{
+  "type": "object",
+  "properties": {
+    "name": { "type": "string", "maxLength": 10 },
+    "age": { "type": "integer", "minimum": 18 }
+  }
+}`, 400, `Version "v2" isn't backward compatible with Version "v1":
+  "/properties/age": "v2" has a larger "minimum"
+  "/properties/name": "v2" has a smaller "maxLength"
+`)
+
+	// New optional property and fewer constraints are ok
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", `{
+  "type": "object",
+  "properties": {
+    "name": { "type": "string" },
+    "age": { "type": "number" },
+    "email": { "type": "string" }
+  }
+}`, 201, "*")
+
+	// Switching to "forward" re-verifies the existing Versions. v1 can't
+	// read much of what v2 allows
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibility":"forward"}`, 400,
+		`Version "v2" isn't forward compatible with Version "v1":
+  "": "v1" doesn't allow additional properties
+  "": "v1" doesn't allow property "email"
+  "": "v1" requires property "name"
+  "/properties/age": "v1" doesn't allow type "number"
+  "/properties/age": "v1" has a larger "minimum"
+`)
+
+	// Non-server authority means we don't check
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibilityauthority":"external"}`, 200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v3",
+		`{"type":"string"}`, 201, "*")
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f1/versions/v3", ``, 204, ``)
+
+	// Combinators and $refs
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/v1", `{
+  "$defs": { "id": { "type": "string" } },
+  "anyOf": [ { "$ref": "#/$defs/id" }, { "type": "integer" } ]
+}`, 201, "*")
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f2/meta",
+		`{"compatibility":"full","compatibilityauthority":"server"}`,
+		200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/v2", `{
+  "oneOf": [ { "type": "integer" }, { "type": "string" } ]
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/v3", `{
+  "oneOf": [ { "type": "integer" }, { "type": "boolean" } ]
+}`, 400, `Version "v3" isn't full compatible with Version "v2":
+  "/oneOf/1": "v2" doesn't allow all values, none of the "oneOf" options match
+  "/oneOf/1": "v3" doesn't allow all values, none of the "oneOf" options match
+`)
+
+	// Not JSON Schema or Avro
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/v3", `hello`, 400,
+		`Can't verify the compatibility of Version "v3", its document isn't valid JSON: invalid character 'h' looking for beginning of value
+`)
+
+	// Custom compatibility values can't be verified by the server
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f2/meta",
+		`{"compatibility":"mine"}`, 400,
+		`Compatibility "mine" can't be verified by the server
+`)
+}
+
+func TestCompatAvro(t *testing.T) {
+	reg := NewRegistry("TestCompatAvro")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1", `{
+  "type": "record",
+  "name": "User",
+  "namespace": "com.example",
+  "fields": [
+    { "name": "name", "type": "string" },
+    { "name": "age", "type": "int" },
+    { "name": "color", "type": { "type": "enum", "name": "Color",
+                                 "symbols": [ "RED", "GREEN" ] } }
+  ]
+}`, 201, "*")
+
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibility":"backward","compatibilityauthority":"server"}`,
+		200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", `{
+  "type": "record",
+  "name": "User",
+  "namespace": "com.example",
+  "fields": [
+    { "name": "name", "type": "string" },
+    { "name": "age", "type": "string" },
+    { "name": "email", "type": "string" },
+    { "name": "color", "type": { "type": "enum", "name": "Color",
+                                 "symbols": [ "RED" ] } }
+  ]
+}`, 400, `Version "v2" isn't backward compatible with Version "v1":
+  "": "v2" field "email" is missing a default value
+  "/age": "v2" can't read a "int" as a "string"
+  "/color": "v2" is missing enum symbol "GREEN"
+`)
+
+	// Type promotion, new fields w/defaults, optional unions
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", `{
+  "type": "record",
+  "name": "User",
+  "namespace": "com.example",
+  "fields": [
+    { "name": "name", "type": "string" },
+    { "name": "age", "type": [ "null", "long" ] },
+    { "name": "email", "type": "string", "default": "" },
+    { "name": "color", "type": { "type": "enum", "name": "Color",
+                                 "symbols": [ "RED", "GREEN", "BLUE" ] } }
+  ]
+}`, 201, "*")
+
+	// Can't read v2's "BLUE" or "null" age with v1
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibility":"full"}`, 400,
+		`Version "v2" isn't full compatible with Version "v1":
+  "/age": "v1" can't read a "long" as a "int"
+  "/age": "v1" can't read a "null" as a "int"
+  "/color": "v1" is missing enum symbol "BLUE"
+`)
+
+	// Transitive checks go all the way back. v3 can read v2's data, which
+	// always has "email", but not v1's
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibility":"backward_transitive"}`, 200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v3", `{
+  "type": "record",
+  "name": "User",
+  "namespace": "com.example",
+  "fields": [
+    { "name": "name", "type": "string" },
+    { "name": "age", "type": [ "null", "long" ] },
+    { "name": "email", "type": "string" },
+    { "name": "color", "type": { "type": "enum", "name": "Color",
+                                 "symbols": [ "RED", "GREEN", "BLUE" ] } }
+  ]
+}`, 400, `Version "v3" isn't backward_transitive compatible with Version "v1":
+  "": "v3" field "email" is missing a default value
+`)
+
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibility":"backward"}`, 200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v3", `{
+  "type": "record",
+  "name": "User",
+  "namespace": "com.example",
+  "fields": [
+    { "name": "name", "type": "string" },
+    { "name": "age", "type": [ "null", "long" ] },
+    { "name": "email", "type": "string" },
+    { "name": "color", "type": { "type": "enum", "name": "Color",
+                                 "symbols": [ "RED", "GREEN", "BLUE" ] } }
+  ]
+}`, 201, "*")
+
+	// Mixing formats isn't allowed
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v4",
+		`{"type":"object"}`, 400,
+		`Version "v4" isn't backward compatible with Version "v3": the document formats differ
+`)
+}
+
+// Only the Versions that changed are checked, along with the ones that
+// depend on them
+func TestCompatChanges(t *testing.T) {
+	reg := NewRegistry("TestCompatChanges")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1",
+		`{"type":"string"}`, 201, "*")
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"compatibility":"backward","compatibilityauthority":"server"}`,
+		200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2",
+		`{"type":["string","integer"]}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v3",
+		`{"type":["string","integer","boolean"]}`, 201, "*")
+
+	// Changing a Version's document checks its children against it
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2",
+		`{"type":["string","integer","null"]}`, 400,
+		`Version "v3" isn't backward compatible with Version "v2":
+  "": "v3" doesn't allow type "null"
+`)
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", `{}`, 400,
+		`Version "v3" isn't backward compatible with Version "v2":
+  "": "v3" only allows type(s) string,integer,boolean
+`)
+
+	// Moving a Version re-checks all of them
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v4",
+		`{"type":"null"}`, 400,
+		`Version "v4" isn't backward compatible with Version "v3":
+  "": "v4" doesn't allow type "boolean"
+  "": "v4" doesn't allow type "integer"
+  "": "v4" doesn't allow type "string"
+`)
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v4$details",
+		`{"ancestor":"v4","file":{"type":"null"}}`, 201, "*")
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/versions/v3$details",
+		`{"ancestor":"v4"}`, 400,
+		`Version "v3" isn't backward compatible with Version "v4":
+  "": "v3" doesn't allow type "null"
+`)
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"testing"
+)
+
+func TestAttributeConstraints(t *testing.T) {
+	reg := NewRegistry("TestAttributeConstraints")
+	defer PassDeleteReg(t, reg)
+
+	src := `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "code": { "type": "string", "pattern": "^[A-Z]{3}$" },
+        "title": { "type": "string", "minlength": 2, "maxlength": 5 },
+        "port": { "type": "integer", "minimum": 1, "maximum": 65535 },
+        "ratio": { "type": "decimal", "maximum": 0.5 },
+        "tags": {
+          "type": "array",
+          "item": { "type": "string" },
+          "pattern": "^[a-z]+$",
+          "minitems": 1,
+          "maxitems": 2
+        },
+        "labels": {
+          "type": "map",
+          "item": { "type": "string" },
+          "maxitems": 1
+        },
+        "obj": {
+          "type": "object",
+          "attributes": {
+            "email": { "type": "string", "equals": "confirm" },
+            "confirm": { "type": "string" },
+            "user": { "type": "string", "requires": [ "password" ] },
+            "password": { "type": "string" },
+            "token": { "type": "string", "excludes": [ "password" ] }
+          }
+        }
+      }
+    }
+  }
+}`
+	xHTTP(t, reg, "PUT", "/modelsource", src, 200, "*")
+
+	// Round-trips thru the modelsource, and shows up in the model
+	xHTTP(t, reg, "GET", "/modelsource", "", 200, `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "code": {
+          "type": "string",
+          "pattern": "^[A-Z]{3}$"
+        },
+        "title": {
+          "type": "string",
+          "minlength": 2,
+          "maxlength": 5
+        },
+        "port": {
+          "type": "integer",
+          "minimum": 1,
+          "maximum": 65535
+        },
+        "ratio": {
+          "type": "decimal",
+          "maximum": 0.5
+        },
+        "tags": {
+          "type": "array",
+          "item": {
+            "type": "string"
+          },
+          "pattern": "^[a-z]+$",
+          "minitems": 1,
+          "maxitems": 2
+        },
+        "labels": {
+          "type": "map",
+          "item": {
+            "type": "string"
+          },
+          "maxitems": 1
+        },
+        "obj": {
+          "type": "object",
+          "attributes": {
+            "email": {
+              "type": "string",
+              "equals": "confirm"
+            },
+            "confirm": {
+              "type": "string"
+            },
+            "user": {
+              "type": "string",
+              "requires": [
+                "password"
+              ]
+            },
+            "password": {
+              "type": "string"
+            },
+            "token": {
+              "type": "string",
+              "excludes": [
+                "password"
+              ]
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`)
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/model",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("port": {.*?}).*$||$1`},
+		ResBody: `"port": {
+          "name": "port",
+          "type": "integer",
+          "minimum": 1,
+          "maximum": 65535
+        }`,
+	})
+
+	// Invalid model
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": { "code": { "type": "boolean", "pattern": "x" } }
+    }
+  }
+}`, 400, `"groups.dirs.code" is not a string, or an array of strings, `+
+		`so "pattern", "minlength" and "maxlength" are not allowed`+"\n")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{
+  "code": "ABC",
+  "title": "abc",
+  "port": 80,
+  "ratio": 0.25,
+  "tags": [ "a", "b" ],
+  "labels": { "k": "v" },
+  "obj": { "email": "a@b", "confirm": "a@b", "user": "me", "password": "x" }
+}`, 201, "*")
+
+	type test struct {
+		body string
+		err  string
+	}
+	for _, test := range []test{
+		{`{"code":"abc"}`,
+			`Attribute "code"(abc) must match the pattern: ^[A-Z]{3}$`},
+		{`{"title":"a"}`,
+			`Attribute "title" must be at least 2 characters long`},
+		{`{"title":"abcdef"}`,
+			`Attribute "title" must be at most 5 characters long`},
+		{`{"port":0}`, `Attribute "port"(0) must be at least 1`},
+		{`{"port":70000}`, `Attribute "port"(70000) must be at most 65535`},
+		{`{"ratio":0.75}`, `Attribute "ratio"(0.75) must be at most 0.5`},
+		{`{"tags":[]}`, `Attribute "tags" must have at least 1 item(s)`},
+		{`{"tags":["a","b","c"]}`,
+			`Attribute "tags" must have at most 2 item(s)`},
+		{`{"tags":["a","B"]}`,
+			`Attribute "tags[1]"(B) must match the pattern: ^[a-z]+$`},
+		{`{"labels":{"a":"1","b":"2"}}`,
+			`Attribute "labels" must have at most 1 item(s)`},
+		{`{"obj":{"email":"a@b","confirm":"x@y"}}`,
+			`Attribute "obj.email" must be the same as "obj.confirm"`},
+		{`{"obj":{"email":"a@b"}}`,
+			`Attribute "obj.email" must be the same as "obj.confirm"`},
+		{`{"obj":{"user":"me"}}`,
+			`Attribute "obj.user" requires "obj.password" to be present`},
+		{`{"obj":{"token":"t","password":"x"}}`,
+			`Attributes "obj.token" and "obj.password" can't both be present`},
+	} {
+		xHTTP(t, reg, "PATCH", "/dirs/d1", test.body, 400, test.err+"\n")
+	}
+
+	// Absent attributes aren't checked
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{"obj":{"token":"t"}}`, 201, "*")
+	xHTTP(t, reg, "PATCH", "/dirs/d1", `{"obj":{"user":null,"token":"t",
+	  "password":null}}`, 200, "*")
+}
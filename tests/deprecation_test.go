@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeprecation(t *testing.T) {
+	reg := NewRegistry("TestDeprecation")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": { "singular": "file", "deprecationpolicy": [ "delete" ] }
+      }
+    }
+  }
+}`, 400, `Resource "files" has an invalid 'deprecationpolicy' value `+
+		`(delete). Must be one of 'noversions', 'noxrefs'`+"\n")
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "deprecationpolicy": [ "noversions", "noxrefs" ]
+        },
+        "docs": { "singular": "doc" }
+      }
+    }
+  }
+}`, 200, "*")
+
+	code, body := xGET(t, "/model")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"deprecationpolicy": [
+            "noversions",
+            "noxrefs"
+          ]`), "Missing deprecationpolicy:\n%s", body)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$details", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/fx/meta",
+		`{"xref":"/dirs/d1/files/f1"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/docs/c1$details", `{}`, 201, "*")
+
+	// Not in effect yet
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta", `{
+  "deprecated": { "effective": "2099-01-01T00:00:00Z" }
+}`, 200, "*")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1$details",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"-Deprecation", "-Sunset", "-Link"},
+		ResBody:    "*",
+	})
+	xHTTP(t, reg, "POST", "/dirs/d1/files/f1/versions", `{"2":{}}`, 200, "*")
+
+	// In effect
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta", `{
+  "deprecated": {
+    "effective": "2021-06-01T00:00:00Z",
+    "removal": "2099-01-01T00:00:00Z",
+    "alternative": "http://example.com/dirs/d1/files/f2",
+    "documentation": "http://example.com/docs"
+  }
+}`, 200, "*")
+
+	for _, url := range []string{
+		"/dirs/d1/files/f1",
+		"/dirs/d1/files/f1$details",
+		"/dirs/d1/files/f1/meta",
+		"/dirs/d1/files/f1/versions/1",
+		"/dirs/d1/files/f1/versions/1$details",
+	} {
+		res := xDoHTTP(t, reg, "GET", url, "")
+		xCheckEqual(t, url, res.StatusCode, 200)
+		xCheckEqual(t, url, res.Header.Get("Deprecation"), "@1622505600")
+		xCheckEqual(t, url, res.Header.Get("Sunset"),
+			"Thu, 01 Jan 2099 00:00:00 GMT")
+		xCheckEqual(t, url, res.Header.Get("Link"),
+			`<http://example.com/dirs/d1/files/f2>; rel="successor-version", `+
+				`<http://example.com/docs>; rel="deprecation"`)
+	}
+
+	// Not on the collection, or on a PUT
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"-Deprecation"},
+		ResBody:    "*",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/1$details",
+		Method:     "PUT",
+		ReqBody:    `{"description":"still ok"}`,
+		Code:       200,
+		ResHeaders: []string{"-Deprecation"},
+		ResBody:    "*",
+	})
+
+	// No "effective" means it's in effect right away
+	xHTTP(t, reg, "PUT", "/dirs/d1/docs/c1/meta", `{"deprecated":{}}`, 200,
+		"*")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/docs/c1$details",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"Deprecation: true", "-Sunset", "-Link"},
+		ResBody:    "*",
+	})
+
+	// "noversions"
+	xHTTP(t, reg, "POST", "/dirs/d1/files/f1/versions", `{"3":{}}`, 400,
+		`Resource "/dirs/d1/files/f1" is deprecated, new Versions aren't `+
+			`allowed`+"\n")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/3", `new doc`, 400,
+		`Resource "/dirs/d1/files/f1" is deprecated, new Versions aren't `+
+			`allowed`+"\n")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1", `new doc`, 200, "new doc")
+
+	// "noxrefs", existing ones are ok
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/fy/meta",
+		`{"xref":"/dirs/d1/files/f1"}`, 400,
+		`Resource "/dirs/d1/files/f1" is deprecated, new xrefs to it `+
+			`aren't allowed`+"\n")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/fx/meta",
+		`{"xref":"/dirs/d1/files/f1"}`, 200, "*")
+
+	// No "deprecationpolicy"
+	xHTTP(t, reg, "POST", "/dirs/d1/docs/c1/versions", `{"2":{}}`, 200, "*")
+
+	// Everything past its removal date
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f3/meta", `{
+  "deprecated": { "removal": "2022-01-01T00:00:00Z" }
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f4/meta", `{
+  "deprecated": { "removal": "2099-01-01T00:00:00Z" }
+}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/dirs/d1/files?filter=meta.deprecated.removal<now",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)("f3": {\n    "fileid": "f3").*$||$1`},
+		ResBody: `{
+  "f3": {
+    "fileid": "f3"`,
+	})
+	code, body = xGET(t, "/?filter=dirs.files.meta.deprecated.removal<now"+
+		"&inline=dirs.files")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"fileid": "f3"`) &&
+		!strings.Contains(body, `"fileid": "f4"`) &&
+		!strings.Contains(body, `"fileid": "f1"`),
+		"Wrong filter results:\n%s", body)
+	xHTTP(t, reg, "GET", "/dirs/d1/files?filter=meta.deprecated.removal<now"+
+		",meta.deprecated.removal>now", "", 200, "{}\n")
+
+	// "now" is only special for timestamp attributes
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f3$details",
+		`{"labels":{"stage":"now"}}`, 200, "*")
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f4$details",
+		`{"labels":{"stage":"later"}}`, 200, "*")
+	code, body = xGET(t, "/dirs/d1/files?filter=labels.stage>=now")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"fileid": "f3"`) &&
+		!strings.Contains(body, `"fileid": "f4"`),
+		"Wrong filter results:\n%s", body)
+}
@@ -0,0 +1,372 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/xregistry/server/registry"
+)
+
+// Removes the ops for the timestamps since they might, or might not, change
+const tsOpsMask = `(?s)\{\s*"op": "replace",\s*"path": "/(created|modified)at",` +
+	`\s*"value": "[^"]*"\s*\},\s*||`
+
+func TestDiffVersions(t *testing.T) {
+	reg := NewRegistry("TestDiffVersions")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1$details", `{
+  "description": "first",
+  "labels": { "a": "1", "b": "2" },
+  "contenttype": "application/json",
+  "file": { "name": "api", "paths": [ "/a", "/b" ] }
+}`, 201, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2$details", `{
+  "labels": { "a": "1", "c": "3" },
+  "contenttype": "application/json",
+  "file": { "name": "api", "paths": [ "/a", "/c", "/d" ] }
+}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v2?diff=v1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"Content-Type: application/json"},
+		BodyMasks:  []string{tsOpsMask},
+		ResBody: `{
+  "from": "/dirs/d1/files/f1/versions/v1",
+  "to": "/dirs/d1/files/f1/versions/v2",
+  "metadata": [
+    {
+      "op": "remove",
+      "path": "/description"
+    },
+    {
+      "op": "remove",
+      "path": "/labels/b"
+    },
+    {
+      "op": "add",
+      "path": "/labels/c",
+      "value": "3"
+    },
+    {
+      "op": "replace",
+      "path": "/versionid",
+      "value": "v2"
+    }
+  ],
+  "document": [
+    {
+      "op": "replace",
+      "path": "/paths/1",
+      "value": "/c"
+    },
+    {
+      "op": "add",
+      "path": "/paths/2",
+      "value": "/d"
+    }
+  ]
+}
+`,
+	})
+
+	// $details only compares the metadata
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v1$details?diff=v1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"Content-Type: application/json"},
+		ResBody: `{
+  "from": "/dirs/d1/files/f1/versions/v1",
+  "to": "/dirs/d1/files/f1/versions/v1",
+  "metadata": []
+}
+`,
+	})
+
+	// Non-JSON docs get a unified diff, and a Resource means its default
+	// Version
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v3$details", `{
+  "contenttype": "text/plain",
+  "file": "line1\nline2\nline3\n"
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/x1$details", `{
+  "contenttype": "text/plain",
+  "file": "line1\nLINE2\nline3\n"
+}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1$details?diff=/dirs/d1/files/f2",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"Content-Type: application/json"},
+		BodyMasks:  []string{tsOpsMask},
+		ResBody: `{
+  "from": "/dirs/d1/files/f2/versions/x1",
+  "to": "/dirs/d1/files/f1/versions/v3",
+  "metadata": [
+    {
+      "op": "replace",
+      "path": "/ancestor",
+      "value": "v2"
+    },
+    {
+      "op": "replace",
+      "path": "/versionid",
+      "value": "v3"
+    }
+  ]
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1/files/f1?diff=/dirs/d1/files/f2/versions/x1",
+		Method: "GET",
+		Code:   200,
+		BodyMasks: []string{
+			`(?s)^.*("documentdiff".*?)\n.*$||$1`,
+		},
+		ResBody: `"documentdiff": "--- /dirs/d1/files/f2/versions/x1\n+++ /dirs/d1/files/f1/versions/v3\n@@ -1,3 +1,3 @@\n line1\n-LINE2\n+line2\n line3\n"`,
+	})
+
+	// YAML docs are compared structurally
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f3/versions/y1$details", `{
+  "contenttype": "application/yaml",
+  "file": "name: one\nlist:\n- a\n"
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f3/versions/y2$details", `{
+  "contenttype": "application/yaml",
+  "file": "list:\n- a\nname: two\n"
+}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f3/versions/y2?diff=y1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks: []string{
+			`(?s)^.*("document": .*?\]).*$||$1`,
+		},
+		ResBody: `"document": [
+    {
+      "op": "replace",
+      "path": "/name",
+      "value": "two"
+    }
+  ]`,
+	})
+
+	// Across Resource types
+	gm.AddResourceModel("schemas", "schema", 0, true, true, true)
+	xHTTP(t, reg, "PUT", "/dirs/d1/schemas/s1/versions/z1$details", `{
+  "contenttype": "text/plain",
+  "schema": "line1\nline2\nline3\n"
+}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1?diff=/dirs/d1/schemas/s1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks: []string{
+			`(?s)^.*"metadata": .*?\n  \](.*)$||$1`,
+		},
+		ResBody: "\n}\n",
+	})
+
+	// Documents held at a URL can't be compared
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f4/versions/u1$details",
+		`{"fileurl": "http://example.com/one"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f4/versions/u2$details",
+		`{"fileurl": "http://example.com/two"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f4/versions/u3$details",
+		`{"fileurl": "http://example.com/two"}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f4/versions/u2?diff=u1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks: []string{
+			`(?s)^.*("documentdiff".*?)\n.*$||$1`,
+		},
+		ResBody: `"documentdiff": "Documents stored outside of the registry can't be compared\n"`,
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f4/versions/u3?diff=u2",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks: []string{
+			`(?s)^.*"metadata": .*?\n  \](.*)$||$1`,
+		},
+		ResBody: "\n}\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f4/versions/u3?diff=/dirs/d1/files/f1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks: []string{
+			`(?s)^.*("documentdiff".*?)\n.*$||$1`,
+		},
+		ResBody: `"documentdiff": "Documents stored outside of the registry can't be compared\n"`,
+	})
+
+	// Errors
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v2?diff=v9",
+		Method:     "GET",
+		Code:       404,
+		ResHeaders: []string{"*"},
+		ResBody:    "Version \"/dirs/d1/files/f1/versions/v9\" not found\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v2?diff=/dirs/d9/files/f1",
+		Method:     "GET",
+		Code:       404,
+		ResHeaders: []string{"*"},
+		ResBody:    "Group \"/dirs/d9\" not found\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v2?diff=/dirs/d1",
+		Method:     "GET",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody: "?diff value \"/dirs/d1\" must reference a Resource or " +
+			"Version\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1?diff=v1",
+		Method:     "GET",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "?diff is only allowed on a Resource or Version\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/meta?diff=v1",
+		Method:     "GET",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "?diff is only allowed on a Resource or Version\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v2?diff",
+		Method:     "GET",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "Missing ?diff value\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v2$details?diff=v1",
+		Method:     "PATCH",
+		ReqBody:    "{}",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "?diff is only allowed on a GET\n",
+	})
+}
+
+func TestDiffAuth(t *testing.T) {
+	reg := NewRegistry("TestDiffAuth")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	gm, _ = reg.Model.AddGroupModel("schemagroups", "schemagroup")
+	gm.AddResourceModel("schemas", "schema", 0, true, true, true)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1", "one", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", "two", 201, "*")
+	xHTTP(t, reg, "PUT", "/schemagroups/g1/schemas/s1", "secret", 201, "*")
+
+	setupAuth(t, &registry.AuthConfig{
+		Tokens: map[string]string{"dirstoken": "bob"},
+		Roles: map[string][]string{
+			"bob": {"reader:TestDiffAuth/dirs"},
+		},
+	})
+	bob := "Authorization: Bearer dirstoken"
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/v2$details?diff=v1",
+		Method:     "GET",
+		ReqHeaders: []string{bob},
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody:    "*",
+	})
+
+	// Can't read "schemagroups" so can't diff against them either
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1?diff=/schemagroups/g1/schemas/s1",
+		Method:     "GET",
+		ReqHeaders: []string{bob},
+		Code:       403,
+		ResHeaders: []string{"*"},
+		ResBody: `User "bob" is not allowed to read ` +
+			`"/schemagroups/g1/schemas/s1"` + "\n",
+	})
+}
+
+func TestDiffXR(t *testing.T) {
+	reg := NewRegistry("TestDiffXR")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1$details", `{
+  "createdat": "2024-01-01T12:00:00Z",
+  "modifiedat": "2024-01-01T12:00:00Z",
+  "description": "first",
+  "contenttype": "text/plain",
+  "file": "a\nb\n"
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2$details", `{
+  "createdat": "2024-01-01T12:00:00Z",
+  "modifiedat": "2024-01-01T12:00:00Z",
+  "contenttype": "text/plain",
+  "file": "a\nc\n"
+}`, 201, "*")
+
+	xCLIServer("localhost:8181")
+
+	xCLI(t, "diff /dirs/d1/files/f1/versions/v1 /dirs/d1/files/f1/versions/v2",
+		"", `Metadata:
+  remove /description
+  replace /versionid: "v2"
+Document:
+--- /dirs/d1/files/f1/versions/v1
++++ /dirs/d1/files/f1/versions/v2
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`, "", true)
+
+	xCLI(t, "diff /dirs/d1/files/f1/versions/v1 /dirs/d1/files/f1 -m -o yaml",
+		"", `from: /dirs/d1/files/f1/versions/v1
+to: /dirs/d1/files/f1/versions/v2
+metadata:
+  - op: remove
+    path: /description
+  - op: replace
+    path: /versionid
+    value: v2
+`, "", true)
+
+	xCLI(t, "diff /dirs/d1/files/f1/versions/v1 /dirs/d1/files/f1/versions/v1",
+		"", "", "", true)
+
+	xCLI(t, "diff /dirs/d1 /dirs/d1/files/f1", "", "",
+		"\"/dirs/d1\" must reference a Resource or Version\n", false)
+	xCLI(t, "diff /dirs/d1/files/f1", "", "",
+		"Two XIDs must be specified\n", false)
+}
@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocSchemaModel(t *testing.T) {
+	reg := NewRegistry("TestDocSchemaModel")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "schemaattribute": "bogus"
+        }
+      }
+    }
+  }
+}`, 400, `Resource "files" has a 'schemaattribute' value (bogus) that isn't a defined attribute
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": { "myschema": { "type": "integer" } },
+          "schemaattribute": "myschema"
+        }
+      }
+    }
+  }
+}`, 400, `Resource "files" has a 'schemaattribute' value (myschema) that isn't of type 'xid' or 'string'
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "hasdocument": false,
+          "attributes": { "myschema": { "type": "xid" } },
+          "schemaattribute": "myschema"
+        }
+      }
+    }
+  }
+}`, 400, `Resource "files" has a 'schemaattribute' value but 'hasdocument' is 'false'
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": { "myschema": { "type": "xid" } },
+          "schemaattribute": "myschema"
+        }
+      }
+    }
+  }
+}`, 200, "*")
+
+	code, body := xGET(t, "/model")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"schemaattribute": "myschema"`),
+		"Missing schemaattribute:\n%s", body)
+}
+
+func TestDocSchemaValidate(t *testing.T) {
+	reg := NewRegistry("TestDocSchemaValidate")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "schemagroups": {
+      "singular": "schemagroup",
+      "resources": {
+        "schemas": { "singular": "schema" }
+      }
+    },
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": {
+            "myschema": { "type": "xid", "target": "/schemagroups/schemas[/versions]" }
+          },
+          "schemaattribute": "myschema"
+        }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/schemagroups/sg1/schemas/s1/versions/v1", `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "name": { "type": "string" },
+    "age": { "type": "integer", "minimum": 0 },
+    "tags": { "type": "array", "items": { "type": "string" } }
+  },
+  "required": [ "name" ]
+}`, 201, "*")
+
+	xHTTP(t, reg, "PUT", "/schemagroups/sg1/schemas/s1/versions/v2", `{
+  "type": "object",
+  "required": [ "name", "email" ]
+}`, 201, "*")
+
+	xHTTP(t, reg, "PUT", "/schemagroups/sg1/schemas/bad/versions/v1",
+		`{ "type": 5 }`, 201, "*")
+	xHTTP(t, reg, "PUT", "/schemagroups/sg1/schemas/empty$details",
+		`{}`, 201, "*")
+
+	// No schema, no validation
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f0", `not json`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "valid doc",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{"xRegistry-myschema: /schemagroups/sg1/schemas/s1/versions/v1"},
+		ReqBody:    `{"name":"john","age":5}`,
+		Code:       201,
+		ResHeaders: []string{"*"},
+		ResBody:    `{"name":"john","age":5}`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "invalid doc",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{"xRegistry-myschema: /schemagroups/sg1/schemas/s1/versions/v1"},
+		ReqBody:    `{"age":-1,"tags":["a",2]}`,
+		Code:       400,
+		ResBody: `Document of "/dirs/d1/files/f1/versions/1" doesn't validate against schema "/schemagroups/sg1/schemas/s1/versions/v1":
+  "": missing property 'name'
+  "/age": minimum: got -1, want 0
+  "/tags/1": got number, want string
+`,
+	})
+
+	// Old doc is still there
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", ``, 200,
+		`{"name":"john","age":5}`)
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "not json",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{"xRegistry-myschema: /schemagroups/sg1/schemas/s1/versions/v1"},
+		ReqBody:    `hello`,
+		Code:       400,
+		ResBody:    `Document of "/dirs/d1/files/f1/versions/1" isn't valid JSON: invalid character 'h' looking for beginning of value` + "\n",
+	})
+
+	// Resource XID means use its default Version (v2)
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:    "resource xid - metadata",
+		URL:     "/dirs/d1/files/f1$details",
+		Method:  "PATCH",
+		ReqBody: `{"myschema": "/schemagroups/sg1/schemas/s1"}`,
+		Code:    400,
+		ResBody: `Document of "/dirs/d1/files/f1/versions/1" doesn't validate against schema "/schemagroups/sg1/schemas/s1":
+  "": missing property 'email'
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:   "resource xid - inline doc",
+		URL:    "/dirs/d1/files/f1$details",
+		Method: "PUT",
+		ReqBody: `{"myschema": "/schemagroups/sg1/schemas/s1",
+          "file": {"name":"john","email":"j@example.com"}}`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody:    "*",
+	})
+
+	// Changing just the metadata re-checks the existing doc
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "metadata only",
+		URL:        "/dirs/d1/files/f1$details",
+		Method:     "PATCH",
+		ReqBody:    `{"myschema": "/schemagroups/sg1/schemas/s1/versions/v1"}`,
+		Code:       200,
+		ResBody:    "*",
+		ResHeaders: []string{"*"},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:    "new version",
+		URL:     "/dirs/d1/files/f1/versions",
+		Method:  "POST",
+		ReqBody: `{"v9":{"myschema":"/schemagroups/sg1/schemas/s1/versions/v1","file":{"name":5}}}`,
+		Code:    400,
+		ResBody: `Document of "/dirs/d1/files/f1/versions/v9" doesn't validate against schema "/schemagroups/sg1/schemas/s1/versions/v1":
+  "/name": got number, want string
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "missing schema",
+		URL:        "/dirs/d1/files/f2",
+		Method:     "PUT",
+		ReqHeaders: []string{"xRegistry-myschema: /schemagroups/sg1/schemas/s9"},
+		ReqBody:    `{}`,
+		Code:       400,
+		ResBody:    `Attribute "myschema": Schema "/schemagroups/sg1/schemas/s9" can't be found` + "\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "schema w/o doc",
+		URL:        "/dirs/d1/files/f2",
+		Method:     "PUT",
+		ReqHeaders: []string{"xRegistry-myschema: /schemagroups/sg1/schemas/empty"},
+		ReqBody:    `{}`,
+		Code:       400,
+		ResBody:    `Attribute "myschema": Schema "/schemagroups/sg1/schemas/empty" has no document` + "\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "invalid schema",
+		URL:        "/dirs/d1/files/f2",
+		Method:     "PUT",
+		ReqHeaders: []string{"xRegistry-myschema: /schemagroups/sg1/schemas/bad"},
+		ReqBody:    `{}`,
+		Code:       400,
+		ResBody: `Attribute "myschema": Schema "/schemagroups/sg1/schemas/bad" isn't a valid JSON Schema:
+  "/type": got number, want array
+  "/type": value must be one of 'array', 'boolean', 'integer', 'null', 'number', 'object', 'string'
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "group xid",
+		URL:        "/dirs/d1/files/f2",
+		Method:     "PUT",
+		ReqHeaders: []string{"xRegistry-myschema: /schemagroups/sg1"},
+		ReqBody:    `{}`,
+		Code:       400,
+		ResBody:    `Attribute "myschema" must match "/schemagroups/schemas[/versions]" target, missing "schemas"` + "\n",
+	})
+}
@@ -0,0 +1,250 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xregistry/server/registry"
+)
+
+// Read 'count' events from the "/events" stream, as "TYPE SUBJECT EPOCH"
+func readEvents(t *testing.T, url string, count int) []string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		"http://localhost:8181/"+strings.TrimLeft(url, "/"), nil)
+	xNoErr(t, err)
+
+	res, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	defer res.Body.Close()
+
+	xCheckEqual(t, "", res.Header.Get("Content-Type"), "text/event-stream")
+
+	results := []string{}
+	scanner := bufio.NewScanner(res.Body)
+	for len(results) < count && scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		ce := struct {
+			ID      string
+			Type    string
+			Subject string
+			Data    struct{ Epoch *int }
+		}{}
+		xNoErr(t, json.Unmarshal([]byte(line[6:]), &ce))
+
+		str := ce.Type
+		if ce.Subject != "" {
+			str += " " + ce.Subject
+		}
+		if ce.Data.Epoch != nil {
+			str += fmt.Sprintf(" %d", *ce.Data.Epoch)
+		}
+		results = append(results, str)
+	}
+	return results
+}
+
+func TestEventsSSE(t *testing.T) {
+	reg := NewRegistry("TestEventsSSE")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	since := registry.Events.LastSeq()
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", "{}", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1", "hello", 201, "*")
+	xHTTP(t, reg, "PATCH", "/dirs/d1", `{"labels":{"a":"b"}}`, 200, "*")
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f1", "", 204, "")
+
+	xCheckEqual(t, "", strings.Join(readEvents(t,
+		fmt.Sprintf("/events?since=%d", since), 10), "\n"),
+		`io.xregistry.group.created /dirs/d1 1
+io.xregistry.registry.updated / 2
+io.xregistry.resource.created /dirs/d1/files/f1
+io.xregistry.version.created /dirs/d1/files/f1/versions/1 1
+io.xregistry.meta.created /dirs/d1/files/f1/meta 1
+io.xregistry.group.updated /dirs/d1 2
+io.xregistry.group.updated /dirs/d1 3
+io.xregistry.meta.deleted /dirs/d1/files/f1/meta 1
+io.xregistry.group.updated /dirs/d1 4
+io.xregistry.resource.deleted /dirs/d1/files/f1`)
+
+	// Resume part way through via Last-Event-ID
+	xCheckEqual(t, "", strings.Join(readEventsWithID(t, since+7, 3), "\n"),
+		"io.xregistry.meta.deleted\n"+
+			"io.xregistry.group.updated\n"+
+			"io.xregistry.resource.deleted")
+
+	// Events from the future mean we missed some (e.g. server restarted)
+	xCheckEqual(t, "", readEvents(t,
+		fmt.Sprintf("/events?since=%d", since+1000), 1)[0],
+		registry.EVENT_RESET)
+
+	// Events for other registries aren't included
+	reg2, err := registry.NewRegistry(nil, "TestEventsSSE2")
+	defer PassDeleteReg(t, reg2)
+	xNoErr(t, err)
+	xNoErr(t, reg2.SaveAllAndCommit())
+
+	xHTTP(t, reg, "PUT", "/dirs/d2", "{}", 201, "*")
+
+	xCheckEqual(t, "", strings.Join(readEvents(t,
+		fmt.Sprintf("/reg-TestEventsSSE2/events?since=%d", since), 1), "\n"),
+		"io.xregistry.registry.created / 1")
+
+	xHTTP(t, reg, "PUT", "/events", "{}", 405,
+		"PUT is not allowed on \"/events\"\n")
+	xHTTP(t, reg, "DELETE", "/events", "", 405,
+		"DELETE is not allowed on \"/events\"\n")
+	xHTTP(t, reg, "GET", "/events?since=abc", "", 400,
+		"Invalid event sequence number \"abc\"\n")
+}
+
+func readEventsWithID(t *testing.T, lastID int64, count int) []string {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET",
+		"http://localhost:8181/events", nil)
+	xNoErr(t, err)
+	req.Header.Set("Last-Event-ID", fmt.Sprintf("%d", lastID))
+
+	res, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	defer res.Body.Close()
+
+	results := []string{}
+	scanner := bufio.NewScanner(res.Body)
+	for len(results) < count && scanner.Scan() {
+		if event, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			results = append(results, event)
+		}
+	}
+	return results
+}
+
+func TestEventsTx(t *testing.T) {
+	reg := NewRegistry("TestEventsTx")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	since := registry.Events.LastSeq()
+
+	// Nothing is published until the Tx is committed, and changes to the
+	// same entity are merged
+	d1, err := reg.AddGroup("dirs", "d1")
+	xNoErr(t, err)
+	xNoErr(t, d1.SetSave("description", "hi"))
+	d2, err := reg.AddGroup("dirs", "d2")
+	xNoErr(t, err)
+	xNoErr(t, d2.Delete())
+
+	events, _, _ := registry.Events.Since(since)
+	xCheckEqual(t, "", len(events), 0)
+
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	events, lost, _ := registry.Events.Since(since)
+	xCheck(t, !lost, "shouldn't be lost")
+	res := []string{}
+	for _, ev := range events {
+		if ev.Registry == reg.UID {
+			res = append(res, ev.Type()+" "+ev.XID)
+		}
+	}
+	xCheckEqual(t, "", strings.Join(res, "\n"),
+		"io.xregistry.group.created /dirs/d1")
+
+	// Rolled back changes never show up
+	since = registry.Events.LastSeq()
+	_, err = reg.AddGroup("dirs", "d3")
+	xNoErr(t, err)
+	xNoErr(t, reg.Rollback())
+
+	events, _, _ = registry.Events.Since(since)
+	xCheckEqual(t, "", len(events), 0)
+}
+
+func TestEventsWebhook(t *testing.T) {
+	reg := NewRegistry("TestEventsWebhook")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	mutex := sync.Mutex{}
+	received := []string{}
+	tries := 0
+
+	sink := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			// Fail the first attempt to make sure we retry
+			tries++
+			if tries == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			body, _ := io.ReadAll(r.Body)
+			ce := registry.CloudEvent{}
+			json.Unmarshal(body, &ce)
+			if ce.Source == "/reg-"+reg.UID {
+				received = append(received, r.Header.Get("Content-Type")+
+					" "+ce.Type+" "+ce.Subject)
+			}
+		}))
+	defer sink.Close()
+
+	wh := registry.NewWebhook(sink.URL)
+	wh.Backoff = 10 * time.Millisecond
+	wh.Start()
+	defer wh.Stop()
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", "{}", 201, "*")
+
+	for i := 0; i < 100; i++ {
+		mutex.Lock()
+		count := len(received)
+		mutex.Unlock()
+		if count >= 2 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	xCheckEqual(t, "", strings.Join(received, "\n"),
+		"application/cloudevents+json io.xregistry.group.created /dirs/d1\n"+
+			"application/cloudevents+json io.xregistry.registry.updated /")
+	xCheckEqual(t, "", tries, 3)
+}
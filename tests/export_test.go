@@ -50,6 +50,7 @@ func TestExportBasic(t *testing.T) {
       "epoch",
       "filter",
       "inline",
+      "migrate",
       "nodefaultversionid",
       "nodefaultversionsticky",
       "noepoch",
@@ -65,7 +66,7 @@ func TestExportBasic(t *testing.T) {
       "entities",
       "model"
     ],
-    "pagination": false,
+    "pagination": true,
     "schemas": [
       "xregistry-json/`+SPECVERSION+`"
     ],
@@ -97,6 +98,12 @@ func TestExportBasic(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -219,6 +226,12 @@ func TestExportBasic(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -321,6 +334,12 @@ func TestExportBasic(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -414,6 +433,12 @@ func TestExportBasic(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -479,6 +504,12 @@ func TestExportBasic(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -810,6 +841,7 @@ func TestExportBasic(t *testing.T) {
       "epoch",
       "filter",
       "inline",
+      "migrate",
       "nodefaultversionid",
       "nodefaultversionsticky",
       "noepoch",
@@ -825,7 +857,7 @@ func TestExportBasic(t *testing.T) {
       "entities",
       "model"
     ],
-    "pagination": false,
+    "pagination": true,
     "schemas": [
       "xregistry-json/`+SPECVERSION+`"
     ],
@@ -879,6 +911,12 @@ func TestExportBasic(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -1001,6 +1039,12 @@ func TestExportBasic(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -1103,6 +1147,12 @@ func TestExportBasic(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -1196,6 +1246,12 @@ func TestExportBasic(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -1261,6 +1317,12 @@ func TestExportBasic(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
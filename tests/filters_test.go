@@ -812,6 +812,146 @@ func TestFiltersOps(t *testing.T) {
 	}
 }
 
+func TestFiltersRelational(t *testing.T) {
+	reg := NewRegistry("TestFiltersRelational")
+	defer PassDeleteReg(t, reg)
+
+	gm, err := reg.Model.AddGroupModel("dirs", "dir")
+	xNoErr(t, err)
+	rm, err := gm.AddResourceModel("files", "file", 0, true, true, false)
+	xNoErr(t, err)
+	_, err = rm.AddAttr("myint", INTEGER)
+	xNoErr(t, err)
+	_, err = rm.AddAttr("mytime", TIMESTAMP)
+	xNoErr(t, err)
+	_, err = rm.AddAttr("mybool", BOOLEAN)
+	xNoErr(t, err)
+	xNoErr(t, reg.SaveModel())
+
+	d, _ := reg.AddGroup("dirs", "d1")
+	f, _ := d.AddResource("files", "f1", "v1")
+	f.SetSaveDefault("name", "bob")
+	f.SetSaveDefault("myint", 1)
+	f.SetSaveDefault("mytime", "2024-01-01T12:00:00Z")
+
+	f, _ = d.AddResource("files", "f2", "v1")
+	f.SetSaveDefault("name", "carl")
+	f.SetSaveDefault("myint", 5)
+	f.SetSaveDefault("mytime", "2024-06-01T12:00:00Z")
+
+	f, _ = d.AddResource("files", "f3", "v1")
+	f.SetSaveDefault("name", "alice")
+	f.SetSaveDefault("myint", 10)
+	f.SetSaveDefault("mytime", "2024-12-31T23:00:00Z")
+
+	d.AddResource("files", "f4", "v1") // no props
+
+	PRE := "/dirs/d1/files?oneline&filter="
+	tests := []struct {
+		Name string
+		URL  string
+		Exp  string
+	}{
+		{
+			Name: "myint<5",
+			URL:  PRE + "myint<5",
+			Exp:  `{"f1":{}}`,
+		},
+		{
+			Name: "myint<=5",
+			URL:  PRE + "myint<=5",
+			Exp:  `{"f1":{},"f2":{}}`,
+		},
+		{
+			Name: "myint>5",
+			URL:  PRE + "myint>5",
+			Exp:  `{"f3":{}}`,
+		},
+		{
+			Name: "myint>=5",
+			URL:  PRE + "myint>=5",
+			Exp:  `{"f2":{},"f3":{}}`,
+		},
+		{
+			Name: "myint<>5",
+			URL:  PRE + "myint<>5",
+			Exp:  `{"f1":{},"f3":{}}`,
+		},
+		{
+			Name: "myint!=5",
+			URL:  PRE + "myint!=5",
+			Exp:  `{"f1":{},"f3":{},"f4":{}}`,
+		},
+		{
+			Name: "myint>1 && myint<10",
+			URL:  PRE + "myint>1,myint<10",
+			Exp:  `{"f2":{}}`,
+		},
+		{
+			Name: "myint>9.5",
+			URL:  PRE + "myint>9.5",
+			Exp:  `{"f3":{}}`,
+		},
+		{
+			Name: "myint<2 || myint>9",
+			URL:  PRE + "myint<2&filter=myint>9",
+			Exp:  `{"f1":{},"f3":{}}`,
+		},
+		{
+			Name: "name>bob",
+			URL:  PRE + "name>bob",
+			Exp:  `{"f2":{}}`,
+		},
+		{
+			Name: "name<=bob",
+			URL:  PRE + "name<=bob",
+			Exp:  `{"f1":{},"f3":{}}`,
+		},
+		{
+			Name: "mytime>2024-06-01T00:00:00Z",
+			URL:  PRE + "mytime>2024-06-01T00:00:00Z",
+			Exp:  `{"f2":{},"f3":{}}`,
+		},
+		{
+			Name: "mytime<2025-01-01T00:00:00Z",
+			URL:  PRE + "mytime<2025-01-01T00:00:00Z",
+			Exp:  `{"f1":{},"f2":{},"f3":{}}`,
+		},
+		{
+			Name: "mytime<2024-06-01T13:00:00+02:00",
+			URL:  PRE + "mytime<2024-06-01T13:00:00%2B02:00",
+			Exp:  `{"f1":{}}`,
+		},
+		{
+			Name: "mytime>=2024-06-01T14:00:00+02:00",
+			URL:  PRE + "mytime>=2024-06-01T14:00:00%2B02:00",
+			Exp:  `{"f2":{},"f3":{}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Logf("Test name: %s", test.Name)
+		xCheckGet(t, reg, test.URL, test.Exp)
+	}
+
+	xHTTP(t, reg, "GET", PRE+"myint>abc", ``, 400,
+		"Value (abc) for filter \"myint\" must be a number\n")
+	xHTTP(t, reg, "GET", PRE+"mytime<abc", ``, 400,
+		"Value (abc) for filter \"mytime\" must be a timestamp\n")
+	xHTTP(t, reg, "GET", PRE+"name>b*", ``, 400,
+		"Wildcards aren't allowed in filter \"name\" when using a "+
+			"relational operator\n")
+	xHTTP(t, reg, "GET", PRE+"myint<", ``, 400,
+		"A value must be specified for filter \"myint\" when using a "+
+			"relational operator\n")
+	xHTTP(t, reg, "GET", PRE+"myint>=null", ``, 400,
+		"A value must be specified for filter \"myint\" when using a "+
+			"relational operator\n")
+	xHTTP(t, reg, "GET", PRE+"mybool>true", ``, 400,
+		"Relational operators aren't allowed on \"mybool\" since it is "+
+			"of type \"boolean\"\n")
+}
+
 func TestFiltersObjs(t *testing.T) {
 	reg := NewRegistry("TestFiltersObjs")
 	defer PassDeleteReg(t, reg)
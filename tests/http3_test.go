@@ -243,6 +243,12 @@ func TestHTTPModelSource(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -365,6 +371,12 @@ func TestHTTPModelSource(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -467,6 +479,12 @@ func TestHTTPModelSource(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -560,6 +578,12 @@ func TestHTTPModelSource(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -625,6 +649,12 @@ func TestHTTPModelSource(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -785,6 +815,12 @@ func TestHTTPModelSource(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -918,6 +954,12 @@ func TestHTTPModelSource(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -2486,3 +2528,518 @@ func TestHTTPModelEnum(t *testing.T) {
 `)
 
 }
+
+func TestHTTPPagination(t *testing.T) {
+	reg := NewRegistry("TestHTTPPagination")
+	defer PassDeleteReg(t, reg)
+
+	_, err := reg.Model.AddGroupModel("dirs", "dir")
+	xNoErr(t, err)
+
+	xHTTP(t, reg, "PUT", "/", `{
+  "dirs": {
+    "d1": { "name": "one" },
+    "d2": { "name": "two" },
+    "d3": { "name": "three" },
+    "d4": { "name": "four" },
+    "d5": { "name": "five" }
+  }
+}`, 200, `*`)
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "first page",
+		URL:        "/dirs?limit=2",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{`Link:<http://localhost:8181/dirs?limit=2&pagetoken=bzoy>; rel="next"; count=5`},
+		ResBody: `{
+  "d1": {
+    "dirid": "d1",
+    "self": "http://localhost:8181/dirs/d1",
+    "xid": "/dirs/d1",
+    "epoch": 1,
+    "name": "one",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  },
+  "d2": {
+    "dirid": "d2",
+    "self": "http://localhost:8181/dirs/d2",
+    "xid": "/dirs/d2",
+    "epoch": 1,
+    "name": "two",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  }
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "second page",
+		URL:        "/dirs?limit=2&pagetoken=bzoy",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{`Link:<http://localhost:8181/dirs?limit=2&pagetoken=bzo0>; rel="next"; count=5`},
+		ResBody: `{
+  "d3": {
+    "dirid": "d3",
+    "self": "http://localhost:8181/dirs/d3",
+    "xid": "/dirs/d3",
+    "epoch": 1,
+    "name": "three",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  },
+  "d4": {
+    "dirid": "d4",
+    "self": "http://localhost:8181/dirs/d4",
+    "xid": "/dirs/d4",
+    "epoch": 1,
+    "name": "four",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  }
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "last page",
+		URL:        "/dirs?limit=2&pagetoken=bzo0",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"-Link:"},
+		ResBody: `{
+  "d5": {
+    "dirid": "d5",
+    "self": "http://localhost:8181/dirs/d5",
+    "xid": "/dirs/d5",
+    "epoch": 1,
+    "name": "five",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  }
+}
+`,
+	})
+
+	// ?sort and ?filter are applied before the page is extracted, and
+	// carried over into the "next" link
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "sorted page",
+		URL:        "/dirs?limit=1&sort=name=desc",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{`Link:<http://localhost:8181/dirs?limit=1&pagetoken=bzox&sort=name%3Ddesc>; rel="next"; count=5`},
+		ResBody: `{
+  "d2": {
+    "dirid": "d2",
+    "self": "http://localhost:8181/dirs/d2",
+    "xid": "/dirs/d2",
+    "epoch": 1,
+    "name": "two",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  }
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "filtered page",
+		URL:        "/dirs?limit=1&filter=name=t*",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{`Link:<http://localhost:8181/dirs?filter=name%3Dt%2A&limit=1&pagetoken=bzox>; rel="next"; count=2`},
+		ResBody: `{
+  "d2": {
+    "dirid": "d2",
+    "self": "http://localhost:8181/dirs/d2",
+    "xid": "/dirs/d2",
+    "epoch": 1,
+    "name": "two",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  }
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "filtered last page",
+		URL:        "/dirs?limit=1&filter=name=t*&pagetoken=bzox",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"-Link:"},
+		ResBody: `{
+  "d3": {
+    "dirid": "d3",
+    "self": "http://localhost:8181/dirs/d3",
+    "xid": "/dirs/d3",
+    "epoch": 1,
+    "name": "three",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z"
+  }
+}
+`,
+	})
+
+	// Inlined children come along with their parent
+	gm := reg.Model.FindGroupModel("dirs")
+	_, err = gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, err)
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f1", "one", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f2", "two", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d3/files/f3", "three", 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "inlined page",
+		URL:        "/dirs?limit=1&pagetoken=bzox&inline=files",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{`Link:<http://localhost:8181/dirs?inline=files&limit=1&pagetoken=bzoy>; rel="next"; count=5`},
+		ResBody: `{
+  "d2": {
+    "dirid": "d2",
+    "self": "http://localhost:8181/dirs/d2",
+    "xid": "/dirs/d2",
+    "epoch": 3,
+    "name": "two",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+
+    "filesurl": "http://localhost:8181/dirs/d2/files",
+    "files": {
+      "f1": {
+        "fileid": "f1",
+        "versionid": "1",
+        "self": "http://localhost:8181/dirs/d2/files/f1$details",
+        "xid": "/dirs/d2/files/f1",
+        "epoch": 1,
+        "isdefault": true,
+        "createdat": "YYYY-MM-DDTHH:MM:03Z",
+        "modifiedat": "YYYY-MM-DDTHH:MM:03Z",
+        "ancestor": "1",
+
+        "metaurl": "http://localhost:8181/dirs/d2/files/f1/meta",
+        "versionsurl": "http://localhost:8181/dirs/d2/files/f1/versions",
+        "versionscount": 1
+      },
+      "f2": {
+        "fileid": "f2",
+        "versionid": "1",
+        "self": "http://localhost:8181/dirs/d2/files/f2$details",
+        "xid": "/dirs/d2/files/f2",
+        "epoch": 1,
+        "isdefault": true,
+        "createdat": "YYYY-MM-DDTHH:MM:02Z",
+        "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+        "ancestor": "1",
+
+        "metaurl": "http://localhost:8181/dirs/d2/files/f2/meta",
+        "versionsurl": "http://localhost:8181/dirs/d2/files/f2/versions",
+        "versionscount": 1
+      }
+    },
+    "filescount": 2
+  }
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "nested collection",
+		URL:        "/dirs/d2/files?limit=1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{`Link:<http://localhost:8181/dirs/d2/files?limit=1&pagetoken=bzox>; rel="next"; count=2`},
+		ResBody: `{
+  "f1": {
+    "fileid": "f1",
+    "versionid": "1",
+    "self": "http://localhost:8181/dirs/d2/files/f1$details",
+    "xid": "/dirs/d2/files/f1",
+    "epoch": 1,
+    "isdefault": true,
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+    "ancestor": "1",
+
+    "metaurl": "http://localhost:8181/dirs/d2/files/f1/meta",
+    "versionsurl": "http://localhost:8181/dirs/d2/files/f1/versions",
+    "versionscount": 1
+  }
+}
+`,
+	})
+
+	// Errors
+	xHTTP(t, reg, "GET", "/dirs?limit=0", ``, 400,
+		"Invalid ?limit value \"0\", must be a positive integer\n")
+	xHTTP(t, reg, "GET", "/dirs?limit=abc", ``, 400,
+		"Invalid ?limit value \"abc\", must be a positive integer\n")
+	xHTTP(t, reg, "GET", "/dirs?pagetoken=bzoy", ``, 400,
+		"?pagetoken requires ?limit to be specified\n")
+	xHTTP(t, reg, "GET", "/dirs?limit=2&pagetoken=xxx", ``, 400,
+		"Invalid ?pagetoken value \"xxx\"\n")
+	xHTTP(t, reg, "GET", "/dirs/d1?limit=2", ``, 400,
+		"Pagination is only allowed on collections\n")
+
+	// Turning off pagination means the params are ignored
+	xHTTP(t, reg, "PATCH", "/capabilities", `{"pagination":false}`, 200, `*`)
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "pagination off",
+		URL:        "/dirs?limit=2",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"-Link:"},
+		ResBody:    `*`,
+	})
+}
+
+func TestHTTPETag(t *testing.T) {
+	reg := NewRegistry("TestHTTPETag")
+	defer PassDeleteReg(t, reg)
+
+	_, _, err := reg.Model.CreateModels("dirs", "dir", "files", "file")
+	xNoErr(t, err)
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "create group",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqBody:    `{}`,
+		Code:       201,
+		ResHeaders: []string{`ETag:"1"`},
+		ResBody:    `*`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group",
+		URL:        "/dirs/d1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{`ETag:"1"`},
+		ResBody:    `*`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group - If-None-Match match",
+		URL:        "/dirs/d1",
+		Method:     "GET",
+		ReqHeaders: []string{`If-None-Match:"1"`},
+		Code:       304,
+		ResHeaders: []string{`ETag:"1"`},
+		ResBody:    ``,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group - If-None-Match weak match",
+		URL:        "/dirs/d1",
+		Method:     "GET",
+		ReqHeaders: []string{`If-None-Match:"0", W/"1"`},
+		Code:       304,
+		ResHeaders: []string{`ETag:"1"`},
+		ResBody:    ``,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group - If-None-Match no match",
+		URL:        "/dirs/d1",
+		Method:     "GET",
+		ReqHeaders: []string{`If-None-Match:"0"`},
+		Code:       200,
+		ResHeaders: []string{`ETag:"1"`},
+		ResBody:    `*`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "update group - If-Match mismatch",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqHeaders: []string{`If-Match:"0"`},
+		ReqBody:    `{"name":"d1"}`,
+		Code:       412,
+		ResHeaders: []string{"-ETag:"},
+		ResBody:    "If-Match (\"0\") doesn't match the current ETag (\"1\")\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "update group - If-Match weak never matches",
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{`If-Match:W/"1"`},
+		ReqBody:    `{"name":"d1"}`,
+		Code:       412,
+		ResBody:    "If-Match (W/\"1\") doesn't match the current ETag (\"1\")\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "update group - If-Match match",
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{`If-Match:"1"`},
+		ReqBody:    `{"name":"d1"}`,
+		Code:       200,
+		ResHeaders: []string{`ETag:"2"`},
+		ResBody:    `*`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "delete group - If-Match mismatch",
+		URL:        "/dirs/d1",
+		Method:     "DELETE",
+		ReqHeaders: []string{`If-Match:"1"`},
+		Code:       412,
+		ResBody:    "If-Match (\"1\") doesn't match the current ETag (\"2\")\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "delete group - If-Match match",
+		URL:        "/dirs/d1",
+		Method:     "DELETE",
+		ReqHeaders: []string{`If-Match:"2"`},
+		Code:       204,
+		ResBody:    ``,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "If-Match on missing entity",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqHeaders: []string{`If-Match:*`},
+		ReqBody:    `{}`,
+		Code:       412,
+		ResBody:    "If-Match failed, \"/dirs/d1\" doesn't exist\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "If-None-Match * on missing entity",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqHeaders: []string{`If-None-Match:*`},
+		ReqBody:    `{}`,
+		Code:       201,
+		ResHeaders: []string{`ETag:"1"`},
+		ResBody:    `*`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "If-None-Match * on existing entity",
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqHeaders: []string{`If-None-Match:*`},
+		ReqBody:    `{}`,
+		Code:       412,
+		ResBody:    "If-None-Match (*) matches the current ETag (\"1\")\n",
+	})
+
+	// Resources include the epoch of "meta" and of the default version,
+	// and a hash of the document when it's returned
+	res := xDoHTTP(t, reg, "PUT", "/dirs/d1/files/f1", "hello")
+	xCheckEqual(t, "", res.StatusCode, 201)
+	xCheckEqual(t, "", res.Header.Get("ETag"), `"1.1-2cf24dba5fb0a30e"`)
+
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1/files/f1", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), `"1.1-2cf24dba5fb0a30e"`)
+
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1/files/f1$details", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), `"1.1"`)
+
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1/files/f1/meta", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), `"1"`)
+
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1/files/f1/versions/1", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), `"1-2cf24dba5fb0a30e"`)
+
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1/files/f1/versions/1$details", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), `"1"`)
+
+	// Collections don't have an ETag and the headers are ignored
+	res = xDoHTTP(t, reg, "GET", "/dirs", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), ``)
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "update doc - If-Match mismatch",
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PUT",
+		ReqHeaders: []string{`If-Match:"1.1"`},
+		ReqBody:    `world`,
+		Code:       412,
+		ResBody: "If-Match (\"1.1\") doesn't match the current ETag " +
+			"(\"1.1-2cf24dba5fb0a30e\")\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:        "update doc - If-Match match",
+		URL:         "/dirs/d1/files/f1",
+		Method:      "PUT",
+		ReqHeaders:  []string{`If-Match:"1.1-2cf24dba5fb0a30e"`},
+		ReqBody:     `world`,
+		Code:        200,
+		HeaderMasks: []string{},
+		ResHeaders:  []string{"*"},
+		ResBody:     `world`,
+	})
+
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1/files/f1", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), `"1.2-486ea46224d1bb4f"`)
+
+	// Each representation has its own ETag
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1", "")
+	etag := res.Header.Get("ETag")
+	xCheckEqual(t, "", etag, `"2"`)
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group - YAML",
+		URL:        "/dirs/d1",
+		Method:     "GET",
+		ReqHeaders: []string{`Accept:application/yaml`, `If-None-Match:` + etag},
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody:    `*`,
+	})
+
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1/files/f1?doc", "")
+	xCheckNotEqual(t, "", res.Header.Get("ETag"), `"1.2"`)
+	xCheckNotEqual(t, "", res.Header.Get("ETag"), ``)
+
+	// Inlined children can change without the parent's epoch changing so
+	// there's no ETag, or 304, for those
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group - inline",
+		URL:        "/dirs/d1?inline=files",
+		Method:     "GET",
+		ReqHeaders: []string{`If-None-Match:` + etag},
+		Code:       200,
+		ResHeaders: []string{"*", "-ETag"},
+		ResBody:    `*`,
+	})
+
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1$details",
+		`{"description":"changed"}`, 200, "*")
+	res = xDoHTTP(t, reg, "GET", "/dirs/d1", "")
+	xCheckEqual(t, "", res.Header.Get("ETag"), etag)
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group - inline after child changed",
+		URL:        "/dirs/d1?inline=files",
+		Method:     "GET",
+		ReqHeaders: []string{`If-None-Match:` + etag},
+		Code:       200,
+		ResHeaders: []string{"*", "-ETag"},
+		BodyMasks:  []string{`(?s)^.*("description": "changed").*$||$1`},
+		ResBody:    `"description": "changed"`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		Name:       "get group - filter",
+		URL:        "/dirs/d1?filter=files.description=changed",
+		Method:     "GET",
+		ReqHeaders: []string{`If-None-Match:` + etag},
+		Code:       200,
+		ResHeaders: []string{"*", "-ETag"},
+		ResBody:    `*`,
+	})
+}
@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntegrityModel(t *testing.T) {
+	reg := NewRegistry("TestIntegrityModel")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "attributes": { "ref": { "type": "string", "integrity": "restrict" } }
+}`, 400, `"model.ref" must not have an "integrity" value since "type" is not "xid"
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "attributes": { "ref": { "type": "xid", "integrity": "cascade" } }
+}`, 400, `"model.ref" has an invalid "integrity" value (cascade). Must be one of 'none', 'restrict', 'cascade-null'
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "attributes": {
+    "ref": { "type": "xid", "integrity": "cascade-null", "required": true }
+  }
+}`, 400, `"model.ref" can't have an "integrity" value of "cascade-null" since it's "required"
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "attributes": { "ref": { "type": "xid", "integrity": "restrict" } }
+}`, 200, "*")
+
+	code, body := xGET(t, "/model")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"integrity": "restrict"`),
+		"Missing integrity:\n%s", body)
+
+	// Existing dangling references block turning it on
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": { "ref": { "type": "xid", "target": "/dirs" } }
+    }
+  }
+}`, 200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"ref":"/dirs/d2"}`, 201, "*")
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "ref": { "type": "xid", "target": "/dirs", "integrity": "restrict" }
+      }
+    }
+  }
+}`, 400, `The model change would make the following entities invalid (?migrate can add defaults and remove old attributes):
+  /dirs/d1: Attribute "ref" of "/dirs/d1" references "/dirs/d2" which doesn't exist
+`)
+}
+
+func TestIntegrity(t *testing.T) {
+	reg := NewRegistry("TestIntegrity")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "xid", "target": "/dirs", "integrity": "restrict" },
+        "link": { "type": "xid", "target": "/dirs/files",
+                  "integrity": "cascade-null" },
+        "any": { "type": "xid" },
+        "obj": {
+          "type": "object",
+          "attributes": {
+            "ref": { "type": "xid", "integrity": "restrict" }
+          }
+        }
+      },
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": {
+            "uses": { "type": "xid", "target": "/dirs/files[/versions]",
+                      "integrity": "restrict" }
+          }
+        }
+      }
+    }
+  }
+}`, 200, "*")
+
+	// Write time
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"owner":"/dirs/d9"}`, 400,
+		`Attribute "owner" of "/dirs/d1" references "/dirs/d9" which `+
+			"doesn't exist\n")
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"obj":{"ref":"/dirs/d9/files/f1"}}`,
+		400, `Attribute "obj.ref" of "/dirs/d1" references `+
+			`"/dirs/d9/files/f1" which doesn't exist`+"\n")
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"any":"/dirs/d9"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"owner":"/dirs/d1"}`, 200, "*")
+
+	// Both ends in the same request
+	xHTTP(t, reg, "POST", "/dirs", `{
+  "d3": {
+    "obj": { "ref": "/dirs/d3" },
+    "files": {
+      "f1": { "uses": "/dirs/d2/files/f2/versions/v1" }
+    }
+  },
+  "d2": {
+    "owner": "/dirs/d3",
+    "link": "/dirs/d3/files/f1",
+    "files": { "f2": { "versions": { "v1": {}, "v2": {} } } }
+  }
+}`, 200, "*")
+
+	// Restrict
+	xHTTP(t, reg, "DELETE", "/dirs/d3", "", 409,
+		`Can't delete "/dirs/d3" since it's referenced by: /dirs/d2 (owner)`+
+			"\n")
+	xHTTP(t, reg, "DELETE", "/dirs/d2/files/f2/versions/v1", "", 409,
+		`Can't delete "/dirs/d2/files/f2/versions/v1" since it's `+
+			`referenced by: /dirs/d3/files/f1/versions/1 (uses)`+"\n")
+	xHTTP(t, reg, "DELETE", "/dirs/d2", "", 409,
+		`Can't delete "/dirs/d2" since it's referenced by: `+
+			`/dirs/d3/files/f1/versions/1 (uses)`+"\n")
+	xHTTP(t, reg, "DELETE", "/dirs/d2/files/f2/versions/v2", "", 204, "")
+
+	// Nothing changed
+	xHTTP(t, reg, "GET", "/dirs/d2/files/f2/versions/v1$details", "", 200,
+		"*")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/dirs/d2",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("link": "[^"]*").*$||$1`},
+		ResBody:   `"link": "/dirs/d3/files/f1"`,
+	})
+
+	// Cascade-null
+	xHTTP(t, reg, "DELETE", "/dirs/d3/files/f1", "", 204, "")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d2",
+		Method: "GET",
+		Code:   200,
+		ResBody: `{
+  "dirid": "d2",
+  "self": "http://localhost:8181/dirs/d2",
+  "xid": "/dirs/d2",
+  "epoch": 2,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+  "owner": "/dirs/d3",
+
+  "filesurl": "http://localhost:8181/dirs/d2/files",
+  "filescount": 1
+}
+`,
+	})
+
+	// Deleting both ends at once is ok, and self references don't count
+	xHTTP(t, reg, "DELETE", "/dirs", `{"d2":{},"d3":{}}`, 204, "")
+	xHTTP(t, reg, "DELETE", "/dirs/d1", "", 204, "")
+}
+
+// Imported Resources, and xids inside of maps and arrays
+func TestIntegrityNested(t *testing.T) {
+	reg := NewRegistry("TestIntegrityNested")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "refs": {
+          "type": "map",
+          "item": {
+            "type": "object",
+            "attributes": {
+              "to": { "type": "xid", "integrity": "restrict" }
+            }
+          }
+        },
+        "links": {
+          "type": "array",
+          "item": {
+            "type": "object",
+            "attributes": {
+              "to": { "type": "xid", "integrity": "cascade-null" }
+            }
+          }
+        }
+      },
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": {
+            "uses": { "type": "xid", "integrity": "restrict" }
+          }
+        }
+      }
+    },
+    "others": {
+      "singular": "other",
+      "ximportresources": [ "/dirs/files" ]
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d3", `{
+  "refs": { "a": { "to": "/dirs/d1" } },
+  "links": [ { "to": "/dirs/d2" } ]
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/others/o1/files/f1$details", `{"uses":"/dirs/d2"}`,
+		201, "*")
+
+	xHTTP(t, reg, "DELETE", "/dirs/d1", "", 409,
+		`Can't delete "/dirs/d1" since it's referenced by: /dirs/d3 (refs.a.to)`+
+			"\n")
+	xHTTP(t, reg, "DELETE", "/dirs/d2", "", 409,
+		`Can't delete "/dirs/d2" since it's referenced by: `+
+			`/others/o1/files/f1/versions/1 (uses)`+"\n")
+
+	xHTTP(t, reg, "DELETE", "/others/o1/files/f1", "", 204, "")
+	xHTTP(t, reg, "DELETE", "/dirs/d2", "", 204, "")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/dirs/d3",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("links": [^\]]*\]).*$||$1`},
+		ResBody: `"links": [
+    {}
+  ]`,
+	})
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/xregistry/server/registry"
+)
+
+func TestHealthAndMetrics(t *testing.T) {
+	reg := NewRegistry("TestHealthAndMetrics")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("mdirs", "mdir")
+	gm.AddResourceModel("mfiles", "mfile", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	xHTTP(t, reg, "GET", "/healthz", ``, 200, "ok\n")
+	xHTTP(t, reg, "GET", "/readyz", ``, 200, "ok\n")
+
+	xHTTP(t, reg, "PUT", "/mdirs/d1/mfiles/f1$details", `{}`, 201, "*")
+	xHTTP(t, reg, "GET", "/mdirs/d1/mfiles/f1$details", ``, 200, "*")
+	xHTTP(t, reg, "GET", "/mdirs/d2/mfiles/f2", ``, 404, "*")
+	xHTTP(t, reg, "GET", "/foo", ``, 404, "*")
+
+	code, body := xGET(t, "metrics")
+	xCheckEqual(t, "", code, 200)
+
+	for _, line := range []string{
+		"# TYPE xrserver_http_requests_total counter",
+		`xrserver_http_requests_total{method="PUT",path="/mdirs/{id}/mfiles/{id}$details",code="201"} 1`,
+		`xrserver_http_requests_total{method="GET",path="/mdirs/{id}/mfiles/{id}",code="404"} 1`,
+		"# TYPE xrserver_http_request_duration_seconds histogram",
+		`xrserver_http_request_duration_seconds_bucket{method="PUT",path="/mdirs/{id}/mfiles/{id}$details",le="+Inf"} 1`,
+		`xrserver_http_request_duration_seconds_count{method="PUT",path="/mdirs/{id}/mfiles/{id}$details"} 1`,
+		"# TYPE xrserver_db_open_transactions gauge",
+	} {
+		xCheck(t, strings.Contains(body, line+"\n"),
+			"Missing %q in:\n%s", line, body)
+	}
+
+	// Bad paths are lumped together
+	xCheck(t, strings.Contains(body,
+		`xrserver_http_requests_total{method="GET",path="unknown",code="404"}`),
+		"Missing unknown path:\n%s", body)
+
+	// The infrastructure endpoints aren't counted
+	xCheck(t, !strings.Contains(body, `path="/healthz"`), "Has healthz")
+	xCheck(t, !strings.Contains(body, `path="/metrics"`), "Has metrics")
+
+	xHTTP(t, reg, "PUT", "/healthz", `{}`, 404, "*")
+}
+
+func TestMetricsAuth(t *testing.T) {
+	reg := NewRegistry("TestMetricsAuth")
+	defer PassDeleteReg(t, reg)
+
+	setupAuth(t, &registry.AuthConfig{
+		Tokens: map[string]string{"alltoken": "prom", "regtoken": "bob"},
+		Roles: map[string][]string{
+			"prom": {"reader"},
+			"bob":  {"admin:TestMetricsAuth"},
+		},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/metrics",
+		Method:     "GET",
+		Code:       401,
+		ResHeaders: []string{"*"},
+		ResBody:    "Authentication required\n",
+	})
+
+	// Even an admin of one Registry can't see the metrics of all of them
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/metrics",
+		Method:     "GET",
+		ReqHeaders: []string{"Authorization: Bearer regtoken"},
+		Code:       403,
+		ResHeaders: []string{"*"},
+		ResBody:    "User \"bob\" is not allowed to GET \"/metrics\"\n",
+	})
+
+	req, err := http.NewRequest("GET", "http://localhost:8181/metrics", nil)
+	xNoErr(t, err)
+	req.Header.Set("Authorization", "Bearer alltoken")
+	httpRes, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	body, _ := io.ReadAll(httpRes.Body)
+	httpRes.Body.Close()
+	xCheckEqual(t, "", httpRes.StatusCode, 200)
+	xCheck(t, strings.Contains(string(body),
+		"# TYPE xrserver_http_requests_total counter\n"),
+		"Missing metrics:\n%s", body)
+
+	// The probes still don't need auth
+	xHTTP(t, reg, "GET", "/healthz", ``, 200, "ok\n")
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrateModel(t *testing.T) {
+	reg := NewRegistry("TestMigrateModel")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "string" },
+        "size": { "type": "integer" },
+        "info": {
+          "type": "object",
+          "attributes": {
+            "a": { "type": "string" },
+            "b": { "type": "string" }
+          }
+        }
+      },
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": { "format": { "type": "string" } }
+        }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"owner":"john","size":5,
+      "info":{"a":"x","b":"y"}}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$details", `{"format":"json"}`,
+		201, "*")
+
+	newModel := `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "string", "required": true, "default": "nobody" },
+        "info": {
+          "type": "object",
+          "attributes": {
+            "a": { "type": "string" }
+          }
+        }
+      },
+      "resources": {
+        "files": { "singular": "file" }
+      }
+    }
+  }
+}`
+
+	// Without ?migrate every broken entity is listed and nothing changes
+	xHTTP(t, reg, "PUT", "/modelsource", newModel, 400,
+		`The model change would make the following entities invalid (?migrate can add defaults and remove old attributes):
+  /dirs/d1: Invalid extension(s) in "info": b
+  /dirs/d1/files/f1/versions/1: Invalid extension(s): format
+`)
+
+	code, body := xGET(t, "/modelsource")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"size"`), "Model changed:\n%s", body)
+
+	// Same thing via PUT /
+	xHTTP(t, reg, "PUT", "/", `{"modelsource":`+newModel+`}`, 400,
+		`The model change would make the following entities invalid (?migrate can add defaults and remove old attributes):
+  /dirs/d1: Invalid extension(s) in "info": b
+  /dirs/d1/files/f1/versions/1: Invalid extension(s): format
+`)
+
+	// Fixing the data in the same request is ok
+	xHTTP(t, reg, "PUT", "/", `{"modelsource":`+newModel+`,
+      "dirs": {
+        "d1": { "owner": "john", "info": {"a":"x"},
+                "files": { "f1": { "versions": { "1": {} } } } }
+      }
+    }`, 200, "*")
+
+	// Entities that weren't broken aren't touched
+	xHTTP(t, reg, "GET", "/dirs/d2", ``, 200, `{
+  "dirid": "d2",
+  "self": "http://localhost:8181/dirs/d2",
+  "xid": "/dirs/d2",
+  "epoch": 1,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+
+  "filesurl": "http://localhost:8181/dirs/d2/files",
+  "filescount": 0
+}
+`)
+}
+
+func TestMigrateModelFlag(t *testing.T) {
+	reg := NewRegistry("TestMigrateModelFlag")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "string" },
+        "size": { "type": "integer" },
+        "info": {
+          "type": "object",
+          "attributes": {
+            "a": { "type": "string" },
+            "b": { "type": "string" }
+          }
+        }
+      },
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": { "format": { "type": "string" } }
+        }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"owner":"john","size":5,
+      "info":{"a":"x","b":"y"}}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$details", `{"format":"json"}`,
+		201, "*")
+
+	// ?migrate can't make up a value for a required attribute
+	xHTTP(t, reg, "PUT", "/modelsource?migrate", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "string", "required": true }
+      },
+      "resources": {
+        "files": { "singular": "file" }
+      }
+    }
+  }
+}`, 400, `The model change would make the following entities invalid:
+  /dirs/d2: Required property "owner" is missing
+`)
+
+	// But it can add defaults and remove old attributes
+	xHTTP(t, reg, "PUT", "/modelsource?migrate", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "string", "required": true, "default": "nobody" },
+        "info": {
+          "type": "object",
+          "attributes": {
+            "a": { "type": "string" }
+          }
+        }
+      },
+      "resources": {
+        "files": { "singular": "file" }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "GET", "/dirs/d1", ``, 200, `{
+  "dirid": "d1",
+  "self": "http://localhost:8181/dirs/d1",
+  "xid": "/dirs/d1",
+  "epoch": 3,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+  "info": {
+    "a": "x"
+  },
+  "owner": "john",
+
+  "filesurl": "http://localhost:8181/dirs/d1/files",
+  "filescount": 1
+}
+`)
+
+	xHTTP(t, reg, "GET", "/dirs/d2", ``, 200, `{
+  "dirid": "d2",
+  "self": "http://localhost:8181/dirs/d2",
+  "xid": "/dirs/d2",
+  "epoch": 2,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+  "owner": "nobody",
+
+  "filesurl": "http://localhost:8181/dirs/d2/files",
+  "filescount": 0
+}
+`)
+
+	code, body := xGET(t, "/dirs/d1/files/f1/versions/1$details")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, !strings.Contains(body, `"format"`),
+		"format should be gone:\n%s", body)
+}
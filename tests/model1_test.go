@@ -33,6 +33,12 @@ func TestNoModel(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -145,6 +151,12 @@ func TestNoModel(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -259,6 +271,12 @@ func TestGroupModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -381,6 +399,12 @@ func TestGroupModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -455,6 +479,12 @@ func TestGroupModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -577,6 +607,12 @@ func TestGroupModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -651,6 +687,12 @@ func TestGroupModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -773,6 +815,12 @@ func TestGroupModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -904,6 +952,12 @@ func TestResourceModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -1052,6 +1106,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -1154,6 +1214,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1247,6 +1313,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1312,6 +1384,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1435,6 +1513,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -1537,6 +1621,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1630,6 +1720,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1695,6 +1791,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1828,6 +1930,12 @@ func TestResourceModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -1976,6 +2084,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -2078,6 +2192,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -2171,6 +2291,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -2236,6 +2362,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -2359,6 +2491,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -2433,6 +2571,12 @@ func TestResourceModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -2581,6 +2725,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -2683,6 +2833,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -2776,6 +2932,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -2841,6 +3003,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -2964,6 +3132,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -3039,6 +3213,12 @@ func TestResourceModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -3161,6 +3341,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -3263,6 +3449,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3356,6 +3548,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3421,6 +3619,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3553,6 +3757,12 @@ func TestResourceModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -3675,6 +3885,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -3777,6 +3993,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3870,6 +4092,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3935,6 +4163,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4094,6 +4328,12 @@ func TestResourceModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -4220,6 +4460,12 @@ func TestResourceModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -4322,6 +4568,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4399,6 +4651,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4464,6 +4722,12 @@ func TestResourceModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4626,6 +4890,12 @@ func TestResourceModelCreate(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -4752,6 +5022,12 @@ func TestResourceModelCreate(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -4854,6 +5130,12 @@ func TestResourceModelCreate(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -4931,6 +5213,12 @@ func TestResourceModelCreate(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -4996,6 +5284,12 @@ func TestResourceModelCreate(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -5192,6 +5486,12 @@ func TestResourceModelCreate(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -5314,6 +5614,12 @@ func TestResourceModelCreate(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -5416,6 +5722,12 @@ func TestResourceModelCreate(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -5493,6 +5805,12 @@ func TestResourceModelCreate(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -5558,6 +5876,12 @@ func TestResourceModelCreate(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -5726,6 +6050,12 @@ func TestResourceModelCreate(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -5848,6 +6178,12 @@ func TestResourceModelCreate(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -5954,6 +6290,12 @@ func TestResourceModelCreate(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -6076,6 +6418,12 @@ func TestResourceModelCreate(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -6178,6 +6526,12 @@ func TestMultModelCreate(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -6326,6 +6680,12 @@ func TestMultModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -6454,6 +6814,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6547,6 +6913,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6612,6 +6984,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6744,6 +7122,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6837,6 +7221,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6902,6 +7292,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7025,6 +7421,12 @@ func TestMultModelCreate(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -7153,6 +7555,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7246,6 +7654,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7311,6 +7725,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7443,6 +7863,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7536,6 +7962,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7601,6 +8033,12 @@ func TestMultModelCreate(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7785,6 +8223,12 @@ func TestMultModel2Create(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -7933,6 +8377,12 @@ func TestMultModel2Create(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -8035,6 +8485,12 @@ func TestMultModel2Create(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -8128,6 +8584,12 @@ func TestMultModel2Create(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -8193,6 +8655,12 @@ func TestMultModel2Create(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -8316,6 +8784,12 @@ func TestMultModel2Create(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -8418,6 +8892,12 @@ func TestMultModel2Create(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -8511,6 +8991,12 @@ func TestMultModel2Create(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -8576,6 +9062,12 @@ func TestMultModel2Create(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
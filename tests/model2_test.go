@@ -51,6 +51,12 @@ func TestModelLabels(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -176,6 +182,12 @@ func TestModelLabels(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -281,6 +293,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -374,6 +392,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -439,6 +463,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -581,6 +611,12 @@ func TestModelLabels(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -703,6 +739,12 @@ func TestModelLabels(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -805,6 +847,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -898,6 +946,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -963,6 +1017,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1141,6 +1201,12 @@ func TestModelLabels(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -1266,6 +1332,12 @@ func TestModelLabels(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -1371,6 +1443,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1464,6 +1542,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1529,6 +1613,12 @@ func TestModelLabels(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1842,6 +1932,7 @@ func TestUseSpecAttrs(t *testing.T) {
     "readonly": 8,
     "registryid": 10,
     "self": 4,
+    "shortself": 9,
     "specversion": 11,
     "versionid": 9,
     "xid": 3,
@@ -1870,6 +1961,12 @@ func TestUseSpecAttrs(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -2024,6 +2121,10 @@ func TestUseSpecAttrs(t *testing.T) {
             "name": "self",
             "type": "integer"
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "integer"
+          },
           "specversion": {
             "name": "specversion",
             "type": "integer"
@@ -2118,6 +2219,12 @@ func TestUseSpecAttrs(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -2280,6 +2387,10 @@ func TestUseSpecAttrs(t *testing.T) {
                 "name": "self",
                 "type": "integer"
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "integer"
+              },
               "specversion": {
                 "name": "specversion",
                 "type": "integer"
@@ -2354,6 +2465,12 @@ func TestUseSpecAttrs(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -2544,6 +2661,10 @@ func TestUseSpecAttrs(t *testing.T) {
                     "name": "self",
                     "type": "integer"
                   },
+                  "shortself": {
+                    "name": "shortself",
+                    "type": "integer"
+                  },
                   "specversion": {
                     "name": "specversion",
                     "type": "integer"
@@ -2577,6 +2698,12 @@ func TestUseSpecAttrs(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -2642,6 +2769,12 @@ func TestUseSpecAttrs(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -2855,6 +2988,10 @@ func TestUseSpecAttrs(t *testing.T) {
                     "name": "self",
                     "type": "integer"
                   },
+                  "shortself": {
+                    "name": "shortself",
+                    "type": "integer"
+                  },
                   "specversion": {
                     "name": "specversion",
                     "type": "integer"
@@ -2935,6 +3072,7 @@ func TestUseSpecAttrs(t *testing.T) {
         "readonly": 8,
         "registryid": 10,
         "self": 4,
+        "shortself": 9,
         "specversion": 11,
         "versionid": 9,
         "xid": 3,
@@ -2985,6 +3123,7 @@ func TestUseSpecAttrs(t *testing.T) {
             "readonly": 8,
             "registryid": 10,
             "self": 4,
+            "shortself": 9,
             "specversion": 11,
             "versionid": 9,
             "xid": 3,
@@ -3033,6 +3172,7 @@ func TestUseSpecAttrs(t *testing.T) {
               "readonly": 8,
               "registryid": 10,
               "self": 4,
+              "shortself": 9,
               "specversion": 11,
               "versionid": 9,
               "xid": 3,
@@ -3087,6 +3227,7 @@ func TestUseSpecAttrs(t *testing.T) {
                 "readonly": 8,
                 "registryid": 10,
                 "self": 4,
+                "shortself": 9,
                 "specversion": 11,
                 "versionid": 9,
                 "xid": 3,
@@ -3186,6 +3327,12 @@ func TestModelCompatibleWith(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -3310,6 +3457,12 @@ func TestModelCompatibleWith(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -3465,6 +3618,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3558,6 +3717,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3623,6 +3788,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3757,6 +3928,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3850,6 +4027,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -3915,6 +4098,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4048,6 +4237,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4141,6 +4336,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4206,6 +4407,12 @@ func TestModelCompatibleWith(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -4703,6 +4910,12 @@ func TestModelIncludes(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -4825,6 +5038,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -4927,6 +5146,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -5020,6 +5245,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -5085,6 +5316,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -5234,6 +5471,12 @@ func TestModelIncludes(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -5382,6 +5625,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -5484,6 +5733,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -5577,6 +5832,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -5642,6 +5903,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -5765,6 +6032,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -5867,6 +6140,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -5960,6 +6239,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6025,6 +6310,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6232,6 +6523,12 @@ func TestModelIncludes(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -6380,6 +6677,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -6482,6 +6785,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6575,6 +6884,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6640,6 +6955,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6763,6 +7084,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -6865,6 +7192,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -6958,6 +7291,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7023,6 +7362,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7167,6 +7512,12 @@ func TestModelIncludes(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -7315,6 +7666,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -7417,6 +7774,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7510,6 +7873,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7575,6 +7944,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7698,6 +8073,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -7800,6 +8181,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7893,6 +8280,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -7958,6 +8351,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -8102,6 +8501,12 @@ func TestModelIncludes(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -8250,6 +8655,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -8352,6 +8763,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -8445,6 +8862,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -8510,6 +8933,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -8633,6 +9062,12 @@ func TestModelIncludes(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -8735,6 +9170,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -8828,6 +9269,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -8893,6 +9340,12 @@ func TestModelIncludes(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -9137,6 +9590,12 @@ func TestModelMissingFields(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -9263,6 +9722,12 @@ func TestModelMissingFields(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -9369,6 +9834,12 @@ func TestModelMissingFields(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -9466,6 +9937,12 @@ func TestModelMissingFields(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -9531,6 +10008,12 @@ func TestModelMissingFields(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"strings"
 	"testing"
 
 	. "github.com/xregistry/server/common"
@@ -126,10 +127,10 @@ func TestModelXImportErrors(t *testing.T) {
         },
         "g3p": {
           "singular": "g3s",
-          "ximportresources": [ "/g2p/r1p" ]
+          "ximportresources": [ "/g2p/r2p" ]
         }
       }
-    }`, 400, `Group "g3p" references an imported Resource "/g2p/r1p", try using "/g1p/r1p" instead
+    }`, 400, `Group "g3p" references a non-existing Resource "/g2p/r2p"
 `)
 
 	xHTTP(t, reg, "PUT", "/modelsource", `{
@@ -207,6 +208,196 @@ func TestModelXImport(t *testing.T) {
 `)
 }
 
+func TestModelXImportTransitive(t *testing.T) {
+	reg := NewRegistry("TestModelXImportTransitive")
+	defer PassDeleteReg(t, reg)
+
+	// g3p imports r1p thru g2p, which imported it from g1p
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "g1p": {
+      "singular": "g1s",
+      "resources": { "r1p": { "singular": "r1s" } }
+    },
+    "g2p": {
+      "singular": "g2s",
+      "ximportresources": [ "/g1p/r1p" ]
+    },
+    "g3p": {
+      "singular": "g3s",
+      "ximportresources": [ "/g2p/r1p" ]
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "GET", "/modelsource", "", 200, `{
+  "groups": {
+    "g1p": {
+      "singular": "g1s",
+      "resources": {
+        "r1p": {
+          "singular": "r1s"
+        }
+      }
+    },
+    "g2p": {
+      "singular": "g2s",
+      "ximportresources": [
+        "/g1p/r1p"
+      ]
+    },
+    "g3p": {
+      "singular": "g3s",
+      "ximportresources": [
+        "/g2p/r1p"
+      ]
+    }
+  }
+}
+`)
+
+	// The model shows where it's really defined
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/model",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("g3p": {).*?("ximportresources": \[.*?\]).*$||$1 $2`},
+		ResBody: `"g3p": { "ximportresources": [
+        "/g1p/r1p"
+      ]`,
+	})
+
+	xHTTP(t, reg, "PUT", "/g3p/g1/r1p/r1$details", "{}", 201, `{
+  "r1sid": "r1",
+  "versionid": "1",
+  "self": "http://localhost:8181/g3p/g1/r1p/r1$details",
+  "xid": "/g3p/g1/r1p/r1",
+  "epoch": 1,
+  "isdefault": true,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+  "ancestor": "1",
+
+  "metaurl": "http://localhost:8181/g3p/g1/r1p/r1/meta",
+  "versionsurl": "http://localhost:8181/g3p/g1/r1p/r1/versions",
+  "versionscount": 1
+}
+`)
+	xHTTP(t, reg, "PUT", "/g1p/g1/r1p/r1", "{}", 201, "*")
+	xHTTP(t, reg, "PUT", "/g3p/g1/r1p/r2/meta", `{"xref":"/g1p/g1/r1p/r1"}`,
+		201, "*")
+	xHTTP(t, reg, "PUT", "/g3p/g1/r1p/r3/meta", `{"xref":"/g2p/g1/r1p/r1"}`,
+		400, `'xref' "/g2p/g1/r1p/r1" must point to a Resource of type `+
+			`"/g1p/r1p" not "/g2p/r1p"`+"\n")
+	xHTTP(t, reg, "GET", "/g3p/g1/r1p/r1/versions/1", "", 200, "*")
+	xHTTP(t, reg, "GET", "/g3p?inline=*&filter=r1p.r1sid=r1", "", 200, "*")
+	xHTTP(t, reg, "GET", "/?inline=*", "", 200, "*")
+	xHTTP(t, reg, "GET", "/?inline=g3p.r1p.meta", "", 200, "*")
+	xHTTP(t, reg, "GET", "/?inline=g3p.r2p", "", 400,
+		`Invalid 'inline' value: g3p.r2p`+"\n")
+
+	code, body := xGET(t, "/g3p/g1?inline=r1p.versions")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"xid": "/g3p/g1/r1p/r1/versions/1"`),
+		"Missing inlined version:\n%s", body)
+
+	// Cycles
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "g1p": {
+      "singular": "g1s",
+      "ximportresources": [ "/g2p/r1p" ]
+    },
+    "g2p": {
+      "singular": "g2s",
+      "ximportresources": [ "/g1p/r1p" ]
+    }
+  }
+}`, 400, `Group "g1p" has an "ximportresources" cycle: /g2p/r1p -> /g1p/r1p -> /g2p/r1p
+`)
+
+	// Back to itself
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "g1p": {
+      "singular": "g1s",
+      "ximportresources": [ "/g2p/r1p" ],
+      "resources": { "r2p": { "singular": "r2s" } }
+    },
+    "g2p": {
+      "singular": "g2s",
+      "ximportresources": [ "/g3p/r1p" ]
+    },
+    "g3p": {
+      "singular": "g3s",
+      "ximportresources": [ "/g1p/r1p" ]
+    }
+  }
+}`, 400, `Group "g1p" has an "ximportresources" cycle: /g2p/r1p -> /g3p/r1p -> /g1p/r1p -> /g2p/r1p
+`)
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "g1p": {
+      "singular": "g1s",
+      "resources": { "r1p": { "singular": "r1s" } }
+    },
+    "g2p": {
+      "singular": "g2s",
+      "ximportresources": [ "/g1p/r1p" ],
+      "resources": { "r2p": { "singular": "r2s" } }
+    },
+    "g3p": {
+      "singular": "g3s",
+      "ximportresources": [ "/g2p/r2p" ]
+    },
+    "g4p": {
+      "singular": "g4s",
+      "resources": { "r1p": { "singular": "r1s" } },
+      "ximportresources": [ "/g2p/r1p" ]
+    }
+  }
+}`, 400, `Group "g4p" has a Resource "r1p" that has a duplicate "plural" name
+`)
+
+	// Own Group, via another one
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "g1p": {
+      "singular": "g1s",
+      "ximportresources": [ "/g2p/r1p" ],
+      "resources": { "r1p": { "singular": "r1s" } }
+    },
+    "g2p": {
+      "singular": "g2s",
+      "ximportresources": [ "/g1p/r1p" ]
+    }
+  }
+}`, 400, `Group "g1p" has a bad "ximportresources" value (/g2p/r1p), it can't reference its own Group (/g1p/r1p)
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "g1p": {
+      "singular": "g1s",
+      "resources": { "r1p": { "singular": "r1s" } }
+    },
+    "g2p": {
+      "singular": "g2s",
+      "ximportresources": [ "/g1p/r1p" ]
+    },
+    "g3p": {
+      "singular": "g3s",
+      "ximportresources": [ "/g2p/r1p" ]
+    },
+    "g4p": {
+      "singular": "g4s",
+      "ximportresources": [ "/g1p/r1p", "/g3p/r1p" ]
+    }
+  }
+}`, 400, `Group "g4p" has a duplicate Resource "plural" name "r1p"
+`)
+}
+
 /* not allowed any more
 func TestModelResourceAttrs(t *testing.T) {
 	reg := NewRegistry("TestModelResourceAttrs")
@@ -251,6 +442,12 @@ func TestModelResourceAttrs(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -373,6 +570,12 @@ func TestModelResourceAttrs(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -475,6 +678,12 @@ func TestModelResourceAttrs(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -552,6 +761,12 @@ func TestModelResourceAttrs(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -639,6 +854,12 @@ func TestModelResourceAttrs(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -806,6 +1027,12 @@ func TestModelResourceAttrs(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -928,6 +1155,12 @@ func TestModelResourceAttrs(t *testing.T) {
           "immutable": true,
           "required": true
         },
+        "shortself": {
+          "name": "shortself",
+          "type": "url",
+          "readonly": true,
+          "immutable": true
+        },
         "xid": {
           "name": "xid",
           "type": "xid",
@@ -1030,6 +1263,12 @@ func TestModelResourceAttrs(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1123,6 +1362,12 @@ func TestModelResourceAttrs(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1192,6 +1437,12 @@ func TestModelResourceAttrs(t *testing.T) {
               "immutable": true,
               "required": true
             },
+            "shortself": {
+              "name": "shortself",
+              "type": "url",
+              "readonly": true,
+              "immutable": true
+            },
             "xid": {
               "name": "xid",
               "type": "xid",
@@ -1472,6 +1723,12 @@ func TestModelFullModel(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -1606,6 +1863,12 @@ func TestModelFullModel(t *testing.T) {
             "immutable": true,
             "required": true
           },
+          "shortself": {
+            "name": "shortself",
+            "type": "url",
+            "readonly": true,
+            "immutable": true
+          },
           "xid": {
             "name": "xid",
             "type": "xid",
@@ -1723,6 +1986,12 @@ func TestModelFullModel(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -1804,6 +2073,12 @@ func TestModelFullModel(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
@@ -1869,6 +2144,12 @@ func TestModelFullModel(t *testing.T) {
                 "immutable": true,
                 "required": true
               },
+              "shortself": {
+                "name": "shortself",
+                "type": "url",
+                "readonly": true,
+                "immutable": true
+              },
               "xid": {
                 "name": "xid",
                 "type": "xid",
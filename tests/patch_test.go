@@ -0,0 +1,390 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/xregistry/server/registry"
+)
+
+func TestPatchEntities(t *testing.T) {
+	reg := NewRegistry("TestPatchEntities")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	rm, _ := gm.AddResourceModel("files", "file", 0, true, true, true)
+	gm.AddAttrArray("tags", registry.NewItemType("string"))
+	rm.AddAttrArray("tags", registry.NewItemType("string"))
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{
+  "name": "dir1",
+  "labels": { "a": "1", "b": "2", "c": "3" },
+  "tags": [ "x", "y", "z" ]
+}`, 201, "*")
+
+	// Merge patch - remove one label, change another, leave the rest alone
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"labels":{"a":null,"c":"33"},"description":"my dir"}`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody: `{
+  "dirid": "d1",
+  "self": "http://localhost:8181/dirs/d1",
+  "xid": "/dirs/d1",
+  "epoch": 2,
+  "name": "dir1",
+  "description": "my dir",
+  "labels": {
+    "b": "2",
+    "c": "33"
+  },
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+  "tags": [
+    "x",
+    "y",
+    "z"
+  ],
+
+  "filesurl": "http://localhost:8181/dirs/d1/files",
+  "filescount": 0
+}
+`,
+	})
+
+	// JSON patch - array and map edits in one atomic request
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody: `[
+  {"op":"test", "path":"/epoch", "value":2},
+  {"op":"remove", "path":"/tags/1"},
+  {"op":"add", "path":"/tags/-", "value":"new"},
+  {"op":"move", "from":"/labels/b", "path":"/labels/bb"},
+  {"op":"replace", "path":"/name", "value":"dir one"},
+  {"op":"remove", "path":"/description"}
+]`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody: `{
+  "dirid": "d1",
+  "self": "http://localhost:8181/dirs/d1",
+  "xid": "/dirs/d1",
+  "epoch": 3,
+  "name": "dir one",
+  "labels": {
+    "bb": "2",
+    "c": "33"
+  },
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+  "tags": [
+    "x",
+    "z",
+    "new"
+  ],
+
+  "filesurl": "http://localhost:8181/dirs/d1/files",
+  "filescount": 0
+}
+`,
+	})
+
+	// Failed test op means nothing changes
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody: `[
+  {"op":"remove", "path":"/labels"},
+  {"op":"test", "path":"/epoch", "value":2}
+]`,
+		Code:       422,
+		ResHeaders: []string{"*"},
+		ResBody: `JSON patch operation #1: test failed, value at "/epoch" ` +
+			`doesn't match
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody:    `[{"op":"remove", "path":"/foo"}]`,
+		Code:       422,
+		ResHeaders: []string{"*"},
+		ResBody:    "JSON patch operation #0: \"/foo\" doesn't exist\n",
+	})
+
+	// Existing validation and epoch checks still apply
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"epoch":1}`,
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody:    `[{"op":"add", "path":"/tags/-", "value":5}]`,
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "*",
+	})
+
+	xHTTP(t, reg, "GET", "/dirs/d1", ``, 200, `{
+  "dirid": "d1",
+  "self": "http://localhost:8181/dirs/d1",
+  "xid": "/dirs/d1",
+  "epoch": 3,
+  "name": "dir one",
+  "labels": {
+    "bb": "2",
+    "c": "33"
+  },
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+  "tags": [
+    "x",
+    "z",
+    "new"
+  ],
+
+  "filesurl": "http://localhost:8181/dirs/d1/files",
+  "filescount": 0
+}
+`)
+
+	// Only PATCH, and only on single entities
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PUT",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{}`,
+		Code:       415,
+		ResHeaders: []string{"*"},
+		ResBody: "Content-Type \"application/merge-patch+json\" is only " +
+			"allowed on PATCH\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{}`,
+		Code:       415,
+		ResHeaders: []string{"*"},
+		ResBody: "Content-Type \"application/merge-patch+json\" is only " +
+			"allowed on a single entity\n",
+	})
+
+	// Resources, Versions and meta
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$details",
+		`{"labels":{"x":"1","y":"2"},"tags":["a"]}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1$details",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody: `[{"op":"remove","path":"/labels/x"},
+                   {"op":"add","path":"/tags/0","value":"first"}]`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)^.*("labels".*?}).*("tags".*?]).*$||$1 $2`},
+		ResBody: `"labels": {
+    "y": "2"
+  } "tags": [
+    "first",
+    "a"
+  ]`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/versions/1$details",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"labels":{"z":"3"}}`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)^.*("labels".*?}).*$||$1`},
+		ResBody: `"labels": {
+    "y": "2",
+    "z": "3"
+  }`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1/meta",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"defaultversionsticky":true}`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)^.*("defaultversionsticky": [a-z]*).*$||$1`},
+		ResBody:    `"defaultversionsticky": true`,
+	})
+
+	// Not on Resource documents
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{}`,
+		Code:       405,
+		ResHeaders: []string{"*"},
+		ResBody:    "PATCH is not allowed on Resource documents\n",
+	})
+
+	// Creating a new entity via a patch
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d2",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody:    `[{"op":"add","path":"/name","value":"dir2"}]`,
+		Code:       201,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)^.*("name".*?,).*$||$1`},
+		ResBody:    `"name": "dir2",`,
+	})
+
+	// The Registry itself
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"labels":{"r":"1"}}`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)^.*("labels".*?}).*$||$1`},
+		ResBody: `"labels": {
+    "r": "1"
+  }`,
+	})
+}
+
+func TestPatchModelAndCapabilities(t *testing.T) {
+	reg := NewRegistry("TestPatchModelAndCapabilities")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": { "singular": "file" }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/modelsource",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody: `[{"op":"add","path":"/groups/dirs/resources/files/maxversions",
+  "value":1},
+  {"op":"add","path":"/groups/tags","value":{"singular":"tag"}}]`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody: `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "maxversions": 1
+        }
+      }
+    },
+    "tags": {
+      "singular": "tag"
+    }
+  }
+}
+`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/modelsource",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"groups":{"tags":null}}`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody: `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": {
+          "singular": "file",
+          "maxversions": 1
+        }
+      }
+    }
+  }
+}
+`,
+	})
+
+	// Model validation still happens
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/modelsource",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"groups":{"dirs":{"singular":null}}}`,
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/modelsource",
+		Method:     "PATCH",
+		ReqBody:    `{}`,
+		Code:       405,
+		ResHeaders: []string{"*"},
+		ResBody:    "PATCH not allowed on '/modelsource'\n",
+	})
+
+	// Capabilities
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/capabilities",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/json-patch+json"},
+		ReqBody:    `[{"op":"replace","path":"/shortself","value":true}]`,
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)^.*("shortself": [a-z]*).*$||$1`},
+		ResBody:    `"shortself": true`,
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/capabilities",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/merge-patch+json"},
+		ReqBody:    `{"shortself":false,"foo":1}`,
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/capabilities",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)^.*("shortself": [a-z]*).*$||$1`},
+		ResBody:    `"shortself": true`,
+	})
+}
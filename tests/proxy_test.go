@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/xregistry/server/registry"
+)
+
+func TestProxyURL(t *testing.T) {
+	reg := NewRegistry("TestProxyURL")
+	defer PassDeleteReg(t, reg)
+
+	oldProxy := registry.Proxy
+	registry.Proxy = registry.NewProxyFetcher()
+	registry.Proxy.Allow = []string{"private"} // upstream is on loopback
+	defer func() { registry.Proxy = oldProxy }()
+
+	count := int32(0)
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Keep-Alive", "timeout=5")
+			w.Header().Set("X-Upstream", "yes")
+			if r.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			w.Write([]byte("hello from upstream"))
+		}))
+	defer upstream.Close()
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$details",
+		`{"contenttype":"text/plain",`+
+			`"fileproxyurl":"`+upstream.URL+`/doc"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2$details",
+		`{"contenttype":"text/plain",`+
+			`"fileproxyurl":"`+upstream.URL+`/missing"}`, 201, "*")
+
+	res, err := http.Get("http://localhost:8181/dirs/d1/files/f1")
+	xNoErr(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	xCheckEqual(t, "", string(body), "hello from upstream")
+	xCheckEqual(t, "", res.Header.Get("Content-Type"), "text/plain")
+	xCheckEqual(t, "", res.Header.Get("X-Upstream"), "yes")
+	xCheckEqual(t, "", res.Header.Get("Keep-Alive"), "")
+
+	// From the cache this time
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", "", 200, "hello from upstream")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/dirs/d1/files/f1$details?inline=file",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("file": "[^"]*").*$||$1`},
+		ResBody:   `"file": "hello from upstream"`,
+	})
+	xCheckEqual(t, "", atomic.LoadInt32(&count), int32(1))
+
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f2", "", 404, "Remote error\n")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/dirs/d1/files/f2$details?inline=file",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("file": "[^"]*").*$||$1`},
+		ResBody:   `"file": "GET error:404 Not Found"`,
+	})
+
+	// Upstream is on a loopback address, which is denied by default
+	registry.Proxy.Allow = nil
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", "", 502,
+		"Host \"127.0.0.1\" is not allowed\n")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:       "/dirs/d1/files/f1$details?inline=file",
+		Method:    "GET",
+		Code:      200,
+		BodyMasks: []string{`(?s)^.*("file": "[^"]*").*$||$1`},
+		ResBody:   `"file": "GET error:Host \"127.0.0.1\" is not allowed"`,
+	})
+
+	registry.Proxy.Deny = nil
+	registry.Proxy.Allow = []string{"*.example.com"}
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", "", 502,
+		"Host \"127.0.0.1\" is not allowed\n")
+
+	registry.Proxy.Allow = []string{"127.0.0.0/8"}
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1", "", 200, "hello from upstream")
+}
@@ -0,0 +1,378 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/xregistry/server/registry"
+)
+
+func TestReferencedBy(t *testing.T) {
+	reg := NewRegistry("TestReferencedBy")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "attributes": { "main": { "type": "xid" } },
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "xid" },
+        "obj": {
+          "type": "object",
+          "attributes": { "ref": { "type": "xid" } }
+        },
+        "list": { "type": "array", "item": { "type": "xid" } },
+        "name2": { "type": "string" }
+      },
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": { "uses": { "type": "xid" } }
+        }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/", `{"main":"/dirs/d1"}`, 200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{
+  "owner": "/dirs/d1",
+  "obj": { "ref": "/dirs/d1" },
+  "list": [ "/dirs/d9", "/dirs/d1/files/f1" ],
+  "name2": "/dirs/d1"
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d3/files/f3$details", `{"uses":"/dirs/d1"}`,
+		201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d3/files/f4/meta",
+		`{"xref":"/dirs/d1/files/f1"}`, 201, "*")
+
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby", "", 200, `{
+  "/": {
+    "xid": "/",
+    "attributes": [
+      "main"
+    ]
+  },
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "obj.ref",
+      "owner"
+    ]
+  },
+  "/dirs/d3/files/f3/versions/1": {
+    "xid": "/dirs/d3/files/f3/versions/1",
+    "attributes": [
+      "uses"
+    ]
+  }
+}
+`)
+
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1$details?referencedby", "", 200, `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "list[1]"
+    ]
+  },
+  "/dirs/d3/files/f4/meta": {
+    "xid": "/dirs/d3/files/f4/meta",
+    "attributes": [
+      "xref"
+    ]
+  }
+}
+`)
+
+	// Filter by type of the referencing entity
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/dirs", "", 200, `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "obj.ref",
+      "owner"
+    ]
+  }
+}
+`)
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/", "", 200, `{
+  "/": {
+    "xid": "/",
+    "attributes": [
+      "main"
+    ]
+  }
+}
+`)
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/dirs/files/versions", "",
+		200, `{
+  "/dirs/d3/files/f3/versions/1": {
+    "xid": "/dirs/d3/files/f3/versions/1",
+    "attributes": [
+      "uses"
+    ]
+  }
+}
+`)
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1?referencedby=/dirs/files/meta",
+		"", 200, `{
+  "/dirs/d3/files/f4/meta": {
+    "xid": "/dirs/d3/files/f4/meta",
+    "attributes": [
+      "xref"
+    ]
+  }
+}
+`)
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/dirs/files", "", 200, "{}\n")
+	xHTTP(t, reg, "GET", "/?referencedby", "", 200, "{}\n")
+
+	// Pagination
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1?referencedby&limit=2",
+		Method: "GET",
+		Code:   200,
+		ResHeaders: []string{
+			"Link: <http://localhost:8181/dirs/d1?limit=2&pagetoken=bzoy" +
+				"&referencedby=>; rel=\"next\"; count=3",
+		},
+		ResBody: `{
+  "/": {
+    "xid": "/",
+    "attributes": [
+      "main"
+    ]
+  },
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "obj.ref",
+      "owner"
+    ]
+  }
+}
+`,
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1?referencedby&limit=2&pagetoken=bzoy",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"-Link"},
+		ResBody: `{
+  "/dirs/d3/files/f3/versions/1": {
+    "xid": "/dirs/d3/files/f3/versions/1",
+    "attributes": [
+      "uses"
+    ]
+  }
+}
+`,
+	})
+
+	// Updates and deletes keep it in sync
+	xHTTP(t, reg, "PATCH", "/dirs/d2", `{"owner":null}`, 200, "*")
+	xHTTP(t, reg, "DELETE", "/dirs/d3/files/f3", "", 204, "")
+	xHTTP(t, reg, "PATCH", "/dirs/d3/files/f4/meta", `{"xref":null}`, 200,
+		"*")
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/dirs", "", 200, `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "obj.ref"
+    ]
+  }
+}
+`)
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1?referencedby", "", 200, `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "list[1]"
+    ]
+  }
+}
+`)
+
+	// Model changes too
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "attributes": { "main": { "type": "xid" } },
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": {
+        "owner": { "type": "xid" },
+        "obj": {
+          "type": "object",
+          "attributes": { "ref": { "type": "string" } }
+        },
+        "list": { "type": "array", "item": { "type": "xid" } },
+        "name2": { "type": "xid" }
+      },
+      "resources": {
+        "files": { "singular": "file" }
+      }
+    }
+  }
+}`, 200, "*")
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/dirs", "", 200, `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "name2"
+    ]
+  }
+}
+`)
+
+	// Errors
+	xHTTP(t, reg, "GET", "/dirs/d9?referencedby", "", 404, "Not found\n")
+	xHTTP(t, reg, "GET", "/dirs?referencedby", "", 400,
+		"?referencedby is only allowed on a GET of an entity\n")
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=dirs", "", 400,
+		`Invalid ?referencedby value "dirs": "dirs" must start with /`+"\n")
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/foos", "", 400,
+		`Invalid ?referencedby value "/foos": unknown Group type "foos"`+
+			"\n")
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby=/dirs/foos", "", 400,
+		`Invalid ?referencedby value "/dirs/foos": unknown Resource `+
+			`type "foos"`+"\n")
+}
+
+func TestReferencedByAuth(t *testing.T) {
+	reg := NewRegistry("TestReferencedByAuth")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "attributes": { "main": { "type": "xid" } },
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": { "owner": { "type": "xid" } }
+    },
+    "secrets": {
+      "singular": "secret",
+      "attributes": { "owner": { "type": "xid" } }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{"owner":"/dirs/d1"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/secrets/s1", `{"owner":"/dirs/d1"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/", `{"main":"/dirs/d1"}`, 200, "*")
+
+	setupAuth(t, &registry.AuthConfig{
+		Tokens: map[string]string{"dirstoken": "bob", "alltoken": "carol"},
+		Roles: map[string][]string{
+			"bob":   {"reader:TestReferencedByAuth/dirs"},
+			"carol": {"reader:TestReferencedByAuth"},
+		},
+	})
+
+	// Referrers in Group types bob can't read, or the Registry, are skipped
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1?referencedby",
+		Method:     "GET",
+		ReqHeaders: []string{"Authorization: Bearer dirstoken"},
+		Code:       200,
+		ResBody: `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "owner"
+    ]
+  }
+}
+`,
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1?referencedby=/secrets",
+		Method:     "GET",
+		ReqHeaders: []string{"Authorization: Bearer dirstoken"},
+		Code:       200,
+		ResBody:    "{}\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1?referencedby",
+		Method:     "GET",
+		ReqHeaders: []string{"Authorization: Bearer alltoken"},
+		Code:       200,
+		ResBody: `{
+  "/": {
+    "xid": "/",
+    "attributes": [
+      "main"
+    ]
+  },
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "owner"
+    ]
+  },
+  "/secrets/s1": {
+    "xid": "/secrets/s1",
+    "attributes": [
+      "owner"
+    ]
+  }
+}
+`,
+	})
+}
+
+func TestReferencedByUpgrade(t *testing.T) {
+	reg := NewRegistry("TestReferencedByUpgrade")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": { "owner": { "type": "xid" } },
+      "resources": {
+        "files": {
+          "singular": "file",
+          "attributes": { "uses": { "type": "xid" } }
+        }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{"owner":"/dirs/d1"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f2$details", `{"uses":"/dirs/d1"}`,
+		201, "*")
+
+	// DBs from before XIDRefs existed get it filled in at startup
+	tx, err := registry.NewTx()
+	xNoErr(t, err)
+	xNoErr(t, registry.Do(tx, `DELETE FROM XIDRefs WHERE RegistrySID=?`,
+		reg.DbSID))
+	xNoErr(t, registry.Do(tx, `DELETE FROM DBUpgrades WHERE Name=?`,
+		"xidrefs"))
+	xNoErr(t, tx.Commit())
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby", "", 200, "{}\n")
+
+	xNoErr(t, registry.UpgradeDB())
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby", "", 200, `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "owner"
+    ]
+  },
+  "/dirs/d2/files/f2/versions/1": {
+    "xid": "/dirs/d2/files/f2/versions/1",
+    "attributes": [
+      "uses"
+    ]
+  }
+}
+`)
+}
@@ -0,0 +1,340 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/xregistry/server/registry"
+)
+
+func TestSearchBasic(t *testing.T) {
+	if registry.DBDRIVER == "mysql" {
+		t.Skip("The searchscores are SQLite's word counts, see " +
+			"TestSearchMySQL")
+	}
+	reg := NewRegistry("TestSearchBasic")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"labels": {"team": "payments"}}`,
+		201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{"description": "Orders"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d3", `{
+  "name": "Payments API",
+  "description": "Everything about payments and refunds"
+}`, 201, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f1$details", `{
+  "description": "Billing service",
+  "contenttype": "text/plain",
+  "file": "The invoice schema"
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f2$details", `{
+  "contenttype": "application/json",
+  "file": { "invoice": "invoice" }
+}`, 201, "*")
+
+	// Ranked by score, not by ID
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search=payments",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody: `{
+  "d3": {
+    "dirid": "d3",
+    "self": "http://localhost:8181/dirs/d3",
+    "xid": "/dirs/d3",
+    "epoch": 1,
+    "name": "Payments API",
+    "description": "Everything about payments and refunds",
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+    "searchscore": 2,
+
+    "filesurl": "http://localhost:8181/dirs/d3/files",
+    "filescount": 0
+  },
+  "d1": {
+    "dirid": "d1",
+    "self": "http://localhost:8181/dirs/d1",
+    "xid": "/dirs/d1",
+    "epoch": 1,
+    "labels": {
+      "team": "payments"
+    },
+    "createdat": "YYYY-MM-DDTHH:MM:02Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+    "searchscore": 1,
+
+    "filesurl": "http://localhost:8181/dirs/d1/files",
+    "filescount": 0
+  }
+}
+`,
+	})
+
+	// Pages are ranked too
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search=payments&limit=1&pagetoken=bzox",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"-Link:"},
+		BodyMasks:  []string{`(?s)("dirid": "[^"]*").*$||$1`},
+		ResBody: `{
+  "d1": {
+    "dirid": "d1"`,
+	})
+	res := xDoHTTP(t, reg, "GET", "/dirs?search=payments&limit=1", "")
+	xCheckEqual(t, "", res.Header.Get("Link"), `<http://localhost:8181/`+
+		`dirs?limit=1&pagetoken=bzox&search=payments>; rel="next"; count=2`)
+
+	// Combined with ?filter
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search=payments&filter=dirid=d1",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)("d1": \{).*?("searchscore")||$1$2`},
+		ResBody: `{
+  "d1": {"searchscore": 1,
+
+    "filesurl": "http://localhost:8181/dirs/d1/files",
+    "filescount": 0
+  }
+}
+`,
+	})
+
+	// Groups match via their Resources' Versions
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search=billing&inline=files",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks: []string{
+			`(?s)"(createdat|modifiedat|self|xid|epoch|isdefault|` +
+				`description|versionsurl|metaurl|filesurl|shortself)": ` +
+				`[^,\n]*,?\n\s*||`,
+		},
+		ResBody: `{
+  "d2": {
+    "dirid": "d2",
+    "searchscore": 1,
+
+    "files": {
+      "f1": {
+        "fileid": "f1",
+        "versionid": "1",
+        "ancestor": "1",
+        "contenttype": "text/plain",
+
+        "versionscount": 1
+      }
+    },
+    "filescount": 1
+  }
+}
+`,
+	})
+
+	// Document contents are only searched when asked to
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d2/files?search=invoice",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody:    "{}\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d2/files?search=invoice&searchdocs",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody: `{
+  "f2": {
+    "fileid": "f2",
+    "versionid": "1",
+    "self": "http://localhost:8181/dirs/d2/files/f2$details",
+    "xid": "/dirs/d2/files/f2",
+    "epoch": 1,
+    "isdefault": true,
+    "createdat": "YYYY-MM-DDTHH:MM:01Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+    "ancestor": "1",
+    "contenttype": "application/json",
+    "searchscore": 2,
+
+    "metaurl": "http://localhost:8181/dirs/d2/files/f2/meta",
+    "versionsurl": "http://localhost:8181/dirs/d2/files/f2/versions",
+    "versionscount": 1
+  },
+  "f1": {
+    "fileid": "f1",
+    "versionid": "1",
+    "self": "http://localhost:8181/dirs/d2/files/f1$details",
+    "xid": "/dirs/d2/files/f1",
+    "epoch": 1,
+    "isdefault": true,
+    "description": "Billing service",
+    "createdat": "YYYY-MM-DDTHH:MM:02Z",
+    "modifiedat": "YYYY-MM-DDTHH:MM:02Z",
+    "ancestor": "1",
+    "contenttype": "text/plain",
+    "searchscore": 1,
+
+    "metaurl": "http://localhost:8181/dirs/d2/files/f1/meta",
+    "versionsurl": "http://localhost:8181/dirs/d2/files/f1/versions",
+    "versionscount": 1
+  }
+}
+`,
+	})
+
+	// Versions
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f1/versions/2$details",
+		`{"description": "Other"}`, 201, "*")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d2/files/f1/versions?search=billing",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)("1": \{).*?("searchscore")||$1$2`},
+		ResBody: `{
+  "1": {"searchscore": 1
+  }
+}
+`,
+	})
+
+	// Errors
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1?search=payments",
+		Method:     "GET",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "?search is only allowed on a GET of a collection\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search=payments",
+		Method:     "POST",
+		ReqBody:    "{}",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "?search is only allowed on a GET of a collection\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search",
+		Method:     "GET",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "Missing ?search value\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?searchdocs",
+		Method:     "GET",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "?searchdocs requires ?search to be specified\n",
+	})
+}
+
+// MySQL's FULLTEXT relevance scores depend on the rest of the DB, so only
+// the matches and their order are checked
+func TestSearchMySQL(t *testing.T) {
+	if registry.DBDRIVER != "mysql" {
+		t.Skip("Only for MySQL's FULLTEXT indexes")
+	}
+
+	reg := NewRegistry("TestSearchMySQL")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{"labels": {"team": "payments"}}`,
+		201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{"description": "Orders"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d3", `{
+  "name": "Payments API",
+  "description": "Everything about payments and refunds"
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f1$details", `{
+  "contenttype": "text/plain",
+  "file": "The invoice schema"
+}`, 201, "*")
+
+	entitiesOnly := `(?s)(\n  "[a-z0-9]+": \{).*?\n  \}||$1}`
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search=payments",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{entitiesOnly},
+		ResBody: `{
+  "d3": {},
+  "d1": {}
+}
+`,
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d2/files?search=invoice",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody:    "{}\n",
+	})
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs?search=invoice&searchdocs",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{entitiesOnly},
+		ResBody: `{
+  "d2": {}
+}
+`,
+	})
+}
+
+func TestSearchUpgrade(t *testing.T) {
+	reg := NewRegistry("TestSearchUpgrade")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1$details", `{
+  "contenttype": "text/plain",
+  "file": "The invoice schema"
+}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2", "\xff invoice", 201, "*")
+
+	// DBs from before ?search existed get the column added, and filled in,
+	// at startup
+	tx, err := registry.NewTx()
+	xNoErr(t, err)
+	xNoErr(t, registry.Do(tx,
+		`ALTER TABLE ResourceContents DROP COLUMN SearchText`))
+	xNoErr(t, registry.Do(tx, `DELETE FROM DBUpgrades WHERE Name=?`,
+		"search"))
+	xNoErr(t, tx.Commit())
+
+	xNoErr(t, registry.UpgradeDB())
+
+	// f2 isn't valid UTF-8 so it's not searchable
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files?search=invoice&searchdocs",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)(\n  "[a-z0-9]+": \{).*?\n  \}||$1}`},
+		ResBody: `{
+  "f1": {}
+}
+`,
+	})
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"bufio"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/xregistry/server/registry"
+)
+
+func TestServerShutdown(t *testing.T) {
+	reg := NewRegistry("TestServerShutdown")
+	defer PassDeleteReg(t, reg)
+
+	server := registry.NewServer(8183).Start()
+	defer server.Close()
+
+	res, err := http.Get("http://localhost:8183/healthz")
+	xNoErr(t, err)
+	xCheckEqual(t, "", res.StatusCode, 200)
+	res.Body.Close()
+
+	// An open event stream shouldn't hold up the shutdown
+	res, err = http.Get("http://localhost:8183/events")
+	xNoErr(t, err)
+	xCheckEqual(t, "", res.StatusCode, 200)
+	streamDone := make(chan struct{})
+	go func() {
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+		}
+		close(streamDone)
+	}()
+
+	start := time.Now()
+	xNoErr(t, server.Shutdown(5*time.Second))
+	xCheck(t, time.Since(start) < 2*time.Second, "Shutdown took: %s",
+		time.Since(start))
+
+	select {
+	case <-streamDone:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Event stream wasn't closed")
+	}
+
+	_, err = http.Get("http://localhost:8183/healthz")
+	xCheck(t, err != nil, "Server should be down")
+}
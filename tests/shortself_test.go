@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"testing"
+
+	. "github.com/xregistry/server/common"
+	"github.com/xregistry/server/registry"
+)
+
+func TestShortSelf(t *testing.T) {
+	reg := NewRegistry("TestShortSelf")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1", "hello", 201, "*")
+
+	// Not enabled by default
+	xHTTP(t, reg, "GET", "/dirs/d1", ``, 200, `{
+  "dirid": "d1",
+  "self": "http://localhost:8181/dirs/d1",
+  "xid": "/dirs/d1",
+  "epoch": 1,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+
+  "filesurl": "http://localhost:8181/dirs/d1/files",
+  "filescount": 1
+}
+`)
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d1"), ``, 404, "Not found\n")
+
+	xHTTP(t, reg, "PATCH", "/capabilities", `{"shortself":true}`, 200, "*")
+
+	xHTTP(t, reg, "GET", "/dirs/d1", ``, 200, `{
+  "dirid": "d1",
+  "self": "http://localhost:8181/dirs/d1",
+  "shortself": "http://localhost:8181/r?u=`+MD5("dirs/d1")+`",
+  "xid": "/dirs/d1",
+  "epoch": 1,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+
+  "filesurl": "http://localhost:8181/dirs/d1/files",
+  "filescount": 1
+}
+`)
+
+	// Same rules as "self" for when "$details" is used
+	xHTTP(t, reg, "GET", "/dirs/d1/files/f1/versions/v1$details", ``, 200,
+		`{
+  "fileid": "f1",
+  "versionid": "v1",
+  "self": "http://localhost:8181/dirs/d1/files/f1/versions/v1$details",
+  "shortself": "http://localhost:8181/r?u=`+
+			MD5("dirs/d1/files/f1/versions/v1$details")+`",
+  "xid": "/dirs/d1/files/f1/versions/v1",
+  "epoch": 1,
+  "isdefault": true,
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+  "ancestor": "v1"
+}
+`)
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/r?u=" + MD5(""),
+		Method:     "GET",
+		Code:       301,
+		ResHeaders: []string{"Location: http://localhost:8181/"},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/r?u=" + MD5("dirs/d1/files/f1/meta"),
+		Method:     "GET",
+		Code:       301,
+		ResHeaders: []string{"Location: http://localhost:8181/dirs/d1/files/f1/meta"},
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/r?u=" + MD5("dirs/d1/files/f1/versions/v1$details"),
+		Method: "GET",
+		Code:   301,
+		ResHeaders: []string{"Location: " +
+			"http://localhost:8181/dirs/d1/files/f1/versions/v1$details"},
+	})
+
+	// Other query parameters are passed along
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/r?u=" + MD5("dirs/d1") + "&inline=files&doc",
+		Method: "GET",
+		Code:   301,
+		ResHeaders: []string{"Location: " +
+			"http://localhost:8181/dirs/d1?doc&inline=files"},
+	})
+
+	// Versions of an xref'd Resource, including ones added to the target
+	// after the xref was set
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/fx/meta",
+		`{"xref":"/dirs/d1/files/f1"}`, 201, "*")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/r?u=" + MD5("dirs/d1/files/fx/versions/v1"),
+		Method: "GET",
+		Code:   301,
+		ResHeaders: []string{"Location: " +
+			"http://localhost:8181/dirs/d1/files/fx/versions/v1"},
+	})
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", "bye", 201, "*")
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/r?u=" + MD5("dirs/d1/files/fx/versions/v2$details"),
+		Method: "GET",
+		Code:   301,
+		ResHeaders: []string{"Location: " +
+			"http://localhost:8181/dirs/d1/files/fx/versions/v2$details"},
+	})
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f1/versions/v2", ``, 204, ``)
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d1/files/fx/versions/v2"), ``,
+		404, "Not found\n")
+
+	// Removing the xref removes them
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/fx/meta", `{"xref":null}`, 200,
+		"*")
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d1/files/fx/versions/v1$details"),
+		``, 404, "Not found\n")
+
+	// DBs from before ShortSelfs existed get them added at startup
+	tx, err := registry.NewTx()
+	xNoErr(t, err)
+	xNoErr(t, registry.Do(tx, `DELETE FROM ShortSelfs WHERE RegistrySID=?`,
+		reg.DbSID))
+	xNoErr(t, registry.Do(tx, `DELETE FROM DBUpgrades WHERE Name=?`,
+		"shortselfs"))
+	xNoErr(t, tx.Commit())
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d1"), ``, 404, "Not found\n")
+	xNoErr(t, registry.UpgradeDB())
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/r?u=" + MD5("dirs/d1/files/f1/versions/v1$details"),
+		Method: "GET",
+		Code:   301,
+		ResHeaders: []string{"Location: " +
+			"http://localhost:8181/dirs/d1/files/f1/versions/v1$details"},
+	})
+
+	xHTTP(t, reg, "GET", "/r", ``, 400,
+		"Missing the \"u\" query parameter\n")
+	xHTTP(t, reg, "GET", "/r?u=xxx", ``, 404, "Not found\n")
+	xHTTP(t, reg, "PUT", "/r?u="+MD5("dirs/d1"), `{}`, 405,
+		"PUT is not allowed on \"/r\"\n")
+
+	// Deleting an entity removes its short links, and its children's
+	xHTTP(t, reg, "DELETE", "/dirs/d1", ``, 204, ``)
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d1"), ``, 404, "Not found\n")
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d1/files/f1/meta"), ``, 404,
+		"Not found\n")
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d1/files/f1/versions/v1"), ``,
+		404, "Not found\n")
+}
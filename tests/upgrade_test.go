@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/xregistry/server/common"
+	"github.com/xregistry/server/registry"
+)
+
+// Start from a DB created with the schema from before ShortSelfs, XIDRefs,
+// ?search and BlobStores (files/upgrade) and make sure UpgradeDB brings it
+// up to date, including the triggers that clean up the new tables
+func TestUpgradeOldSchema(t *testing.T) {
+	file := "files/upgrade/init.sql"
+	if registry.DBDRIVER == "sqlite" {
+		file = "files/upgrade/init-sqlite.sql"
+	}
+	schema, err := os.ReadFile(file)
+	xNoErr(t, err)
+
+	registry.DeleteDB("upgradetest")
+	xNoErr(t, registry.CreateDBWithSchema("upgradetest", string(schema)))
+	xNoErr(t, registry.CloseDB())
+	xNoErr(t, registry.OpenDB("upgradetest"))
+	defer func() {
+		registry.CloseDB()
+		registry.DeleteDB("upgradetest")
+		registry.OpenDB("testreg")
+	}()
+
+	xNoErr(t, registry.UpgradeDB())
+	xNoErr(t, registry.UpgradeDB()) // Nothing left to do
+
+	reg := NewRegistry("TestUpgradeOldSchema")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "attributes": { "owner": { "type": "xid" } },
+      "resources": { "files": { "singular": "file" } }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PATCH", "/capabilities", `{"shortself":true}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1", `{}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2", `{"owner":"/dirs/d1"}`, 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d2/files/f1$details", `{
+  "contenttype": "text/plain",
+  "file": "The invoice schema"
+}`, 201, "*")
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/r?u=" + MD5("dirs/d2"),
+		Method:     "GET",
+		Code:       301,
+		ResHeaders: []string{"Location: http://localhost:8181/dirs/d2"},
+		ResBody:    "*",
+	})
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby", "", 200, `{
+  "/dirs/d2": {
+    "xid": "/dirs/d2",
+    "attributes": [
+      "owner"
+    ]
+  }
+}
+`)
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d2/files?search=invoice&searchdocs",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*"},
+		BodyMasks:  []string{`(?s)(\n  "[a-z0-9]+": \{).*?\n  \}||$1}`},
+		ResBody: `{
+  "f1": {}
+}
+`,
+	})
+
+	// The re-created triggers clean up the new tables
+	xHTTP(t, reg, "DELETE", "/dirs/d2", "", 204, "")
+	xHTTP(t, reg, "GET", "/r?u="+MD5("dirs/d2"), ``, 404, "Not found\n")
+	xHTTP(t, reg, "GET", "/dirs/d1?referencedby", "", 200, "{}\n")
+
+	tx, err := registry.NewTx()
+	xNoErr(t, err)
+	defer tx.Rollback()
+	for _, table := range []string{"ShortSelfs", "XIDRefs"} {
+		results, err := registry.Query(tx, `SELECT COUNT(*) FROM `+table+
+			` WHERE EntitySID NOT IN (SELECT eSID FROM Entities)`)
+		xNoErr(t, err)
+		row := results.NextRow()
+		results.Close()
+		xCheckEqual(t, table, NotNilInt(row[0]), 0)
+	}
+}
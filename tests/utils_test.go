@@ -19,6 +19,9 @@ import (
 	"github.com/xregistry/server/registry"
 )
 
+// The xRegistry server all tests talk to (port 8181)
+var TestServer *registry.Server
+
 func TestMain(m *testing.M) {
 	if tmp := os.Getenv("RX_VERBOSE"); tmp != "" {
 		if tmpInt, err := strconv.Atoi(tmp); err == nil {
@@ -37,8 +40,11 @@ func TestMain(m *testing.M) {
 	// }
 	// registry.OpenDB(DBName)
 
+	// The proxyurl tests use the fileserver below, on localhost
+	registry.Proxy.Allow = []string{"private"}
+
 	// Start xRegistry HTTP server
-	server := registry.NewServer(8181).Start()
+	TestServer = registry.NewServer(8181).Start()
 
 	// Start testing fileserver
 	fsServer := &http.Server{
@@ -51,7 +57,7 @@ func TestMain(m *testing.M) {
 	rc := m.Run()
 
 	// Shutdown HTTP servers
-	server.Close()
+	TestServer.Close()
 	fsServer.Close()
 
 	if rc == 0 {
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/xregistry/server/common"
+	"github.com/xregistry/server/registry"
+)
+
+// Returns "DEFAULTVERSIONID: VID1,VID2,..." for the Resource at 'path'
+func versionSummary(t *testing.T, path string) string {
+	t.Helper()
+
+	code, body := xGET(t, path+"/meta")
+	xCheckEqual(t, "", code, 200)
+	meta := map[string]any{}
+	xNoErr(t, json.Unmarshal([]byte(body), &meta))
+
+	code, body = xGET(t, path+"/versions")
+	xCheckEqual(t, "", code, 200)
+	vers := map[string]any{}
+	xNoErr(t, json.Unmarshal([]byte(body), &vers))
+
+	return meta["defaultversionid"].(string) + ": " +
+		strings.Join(SortedKeys(vers), ",")
+}
+
+func TestVersionModeModel(t *testing.T) {
+	reg := NewRegistry("TestVersionModeModel")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": { "singular": "file", "versionmode": "bogus" }
+      }
+    }
+  }
+}`, 400, `Resource "files" has an invalid 'versionmode' value (bogus). Must be one of 'manual', 'createdat', 'semver'
+`)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": { "singular": "file", "versionmode": "semver" }
+      }
+    }
+  }
+}`, 200, "*")
+
+	code, body := xGET(t, "/model")
+	xCheckEqual(t, "", code, 200)
+	xCheck(t, strings.Contains(body, `"versionmode": "semver"`),
+		"Missing versionmode:\n%s", body)
+
+	rm := &registry.ResourceModel{}
+	xCheckEqual(t, "", rm.GetVersionMode(), VERSIONMODE_MANUAL)
+}
+
+func TestVersionModeSemver(t *testing.T) {
+	reg := NewRegistry("TestVersionModeSemver")
+	defer PassDeleteReg(t, reg)
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": { "singular": "file", "versionmode": "semver",
+                   "maxversions": 3 }
+      }
+    }
+  }
+}`, 200, "*")
+
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/1.10.0", "{}", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/1.2.0", "{}", 201, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"1.10.0: 1.10.0,1.2.0")
+
+	// Pre-releases are older than the release
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/2.0.0-rc.1", "{}",
+		201, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"2.0.0-rc.1: 1.10.0,1.2.0,2.0.0-rc.1")
+
+	// maxversions prunes the lowest semver, not the oldest one
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/2.0.0", "{}", 201, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"2.0.0: 1.10.0,2.0.0,2.0.0-rc.1")
+
+	// Deleting the default picks the next highest semver
+	xHTTP(t, reg, "DELETE", "/dirs/d1/files/f1/versions/2.0.0", "", 204, "")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"2.0.0-rc.1: 1.10.0,2.0.0-rc.1")
+
+	// Sticky defaults are left alone
+	xHTTP(t, reg, "PATCH", "/dirs/d1/files/f1/meta",
+		`{"defaultversionid":"1.10.0","defaultversionsticky":true}`, 200, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/3.0.0", "{}", 201, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"1.10.0: 1.10.0,2.0.0-rc.1,3.0.0")
+
+	// Non-semver IDs are older than all semver ones
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/0.0.1", "{}", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f2/versions/latest", "{}", 201, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f2"),
+		"0.0.1: 0.0.1,latest")
+}
+
+func TestVersionModeCreatedAt(t *testing.T) {
+	reg := NewRegistry("TestVersionModeCreatedAt")
+	defer PassDeleteReg(t, reg)
+
+	gm, _ := reg.Model.AddGroupModel("dirs", "dir")
+	gm.AddResourceModel("files", "file", 0, true, true, true)
+	xNoErr(t, reg.SaveAllAndCommit())
+
+	// v0 is a new root so with "manual" the leaf (v2) is still the newest
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v1", "{}", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v2", "{}", 201, "*")
+	xHTTP(t, reg, "PUT", "/dirs/d1/files/f1/versions/v0$details",
+		`{"ancestor":"v0"}`, 201, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"v2: v0,v1,v2")
+
+	// Changing the versionmode re-evaluates the default Version
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": { "singular": "file", "versionmode": "createdat" }
+      }
+    }
+  }
+}`, 200, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"v0: v0,v1,v2")
+
+	xHTTP(t, reg, "PUT", "/modelsource", `{
+  "groups": {
+    "dirs": {
+      "singular": "dir",
+      "resources": {
+        "files": { "singular": "file", "versionmode": "semver" }
+      }
+    }
+  }
+}`, 200, "*")
+	xCheckEqual(t, "", versionSummary(t, "/dirs/d1/files/f1"),
+		"v2: v0,v1,v2")
+}
@@ -114,6 +114,7 @@ modifiedat      timestamp     y     -    y
 name            string        -     -    y     
 registryid      string        y     y    -     
 self            url           y     y    -     
+shortself       url           -     y    -     
 specversion     string        y     y    y     
 xid             xid           y     y    -     
 
@@ -133,6 +134,7 @@ GROUP: dirs / dir
   modifiedat      timestamp     y     -    y     
   name            string        -     -    y     
   self            url           y     y    -     
+  shortself       url           -     y    -     
   xid             xid           y     y    -     
 
   RESOURCE: files/ file
@@ -159,6 +161,7 @@ GROUP: dirs / dir
     modifiedat      timestamp     y     -    y     
     name            string        -     -    y     
     self            url           y     y    -     
+    shortself       url           -     y    -     
     versionid       string        y     -    -     
     xid             xid           y     y    -     
 
@@ -167,6 +170,7 @@ GROUP: dirs / dir
     meta                   object        -     -    y     
     metaurl                url           y     y    -     
     self                   url           y     y    -     
+    shortself              url           -     y    -     
     versions               map(object)   -     -    y     
     versionscount          uinteger      y     y    y     
     versionsurl            url           y     y    -     
@@ -185,6 +189,7 @@ GROUP: dirs / dir
     modifiedat               timestamp   y     -    y     
     readonly                 boolean     y     y    y     false
     self                     url         y     y    -     
+    shortself                url         -     y    -     
     xid                      xid         y     y    -     
     xref                     url         -     -    y     
 `, "", true)
@@ -514,6 +519,12 @@ func TestXRGroupType(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -539,6 +550,7 @@ func TestXRGroupType(t *testing.T) {
   modifiedat      timestamp     y     -    y     
   name            string        -     -    y     
   self            url           y     y    -     
+  shortself       url           -     y    -     
   xid             xid           y     y    -     
 `, "Created Group type: dirs4:dir4\n", true)
 
@@ -556,6 +568,7 @@ func TestXRGroupType(t *testing.T) {
   modifiedat      timestamp     y     -    y     
   name            string        -     -    y     
   self            url           y     y    -     
+  shortself       url           -     y    -     
   xid             xid           y     y    -     
 `, "", true)
 
@@ -621,6 +634,12 @@ func TestXRGroupType(t *testing.T) {
       "immutable": true,
       "required": true
     },
+    "shortself": {
+      "name": "shortself",
+      "type": "url",
+      "readonly": true,
+      "immutable": true
+    },
     "xid": {
       "name": "xid",
       "type": "xid",
@@ -690,6 +709,12 @@ func TestXRGroupType(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -755,6 +780,12 @@ func TestXRGroupType(t *testing.T) {
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
@@ -913,6 +944,12 @@ files2 / file2   true      0
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "versionid": {
         "name": "versionid",
         "type": "string",
@@ -958,6 +995,12 @@ files2 / file2   true      0
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "versions": {
         "name": "versions",
         "type": "map",
@@ -1097,6 +1140,12 @@ files2 / file2   true      0
         "immutable": true,
         "required": true
       },
+      "shortself": {
+        "name": "shortself",
+        "type": "url",
+        "readonly": true,
+        "immutable": true
+      },
       "xid": {
         "name": "xid",
         "type": "xid",
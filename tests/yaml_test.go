@@ -0,0 +1,286 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestYAMLBasic(t *testing.T) {
+	reg := NewRegistry("TestYAMLBasic")
+	defer PassDeleteReg(t, reg)
+
+	// Set the model via YAML, order of the attributes must be kept
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/modelsource",
+		Method:     "PUT",
+		ReqHeaders: []string{"Content-Type: application/yaml"},
+		ReqBody: `groups:
+  dirs:
+    singular: dir
+    resources:
+      files:
+        singular: file
+        hasdocument: true
+`,
+		Code:       200,
+		ResHeaders: []string{"Content-Type: application/json"},
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/modelsource",
+		Method:     "GET",
+		ReqHeaders: []string{"Accept: application/yaml"},
+		Code:       200,
+		ResHeaders: []string{"Content-Type: application/yaml"},
+		ResBody: `groups:
+  dirs:
+    singular: dir
+    resources:
+      files:
+        singular: file
+        hasdocument: true
+`,
+	})
+
+	// Entity metadata in and out as YAML
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1",
+		Method: "PUT",
+		ReqHeaders: []string{
+			"Content-Type: application/yaml",
+			"Accept: application/yaml",
+		},
+		ReqBody: `name: "5"
+labels:
+  zzz: last
+  aaa: first
+`,
+		Code: 201,
+		ResHeaders: []string{
+			"Content-Type: application/yaml",
+			"Location: http://localhost:8181/dirs/d1",
+		},
+		ResBody: `dirid: d1
+self: http://localhost:8181/dirs/d1
+xid: /dirs/d1
+epoch: 1
+name: "5"
+labels:
+  aaa: first
+  zzz: last
+createdat: "YYYY-MM-DDTHH:MM:01Z"
+modifiedat: "YYYY-MM-DDTHH:MM:01Z"
+filesurl: http://localhost:8181/dirs/d1/files
+filescount: 0
+`,
+	})
+
+	// JSON is still the default
+	xHTTP(t, reg, "GET", "/dirs/d1", ``, 200, `{
+  "dirid": "d1",
+  "self": "http://localhost:8181/dirs/d1",
+  "xid": "/dirs/d1",
+  "epoch": 1,
+  "name": "5",
+  "labels": {
+    "aaa": "first",
+    "zzz": "last"
+  },
+  "createdat": "YYYY-MM-DDTHH:MM:01Z",
+  "modifiedat": "YYYY-MM-DDTHH:MM:01Z",
+
+  "filesurl": "http://localhost:8181/dirs/d1/files",
+  "filescount": 0
+}
+`)
+
+	// Resource documents are never converted
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1/files/f1",
+		Method: "PUT",
+		ReqHeaders: []string{
+			"Content-Type: application/yaml",
+			"Accept: application/yaml",
+		},
+		ReqBody:    "foo: bar\n",
+		Code:       201,
+		ResHeaders: []string{"*"},
+		ResBody:    "foo: bar\n",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f1",
+		Method:     "GET",
+		ReqHeaders: []string{"Accept: application/yaml"},
+		Code:       200,
+		ResHeaders: []string{"*"},
+		ResBody:    "foo: bar\n",
+	})
+
+	// Not even JSON ones, so the ETag still matches the body
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f2",
+		Method:     "PUT",
+		ReqHeaders: []string{"Content-Type: application/json"},
+		ReqBody:    `{"foo":"bar"}`,
+		Code:       201,
+		ResHeaders: []string{"*"},
+		ResBody:    `{"foo":"bar"}`,
+	})
+	res := xDoHTTP(t, reg, "GET", "/dirs/d1/files/f2", "")
+	xCheckEqual(t, "", res.StatusCode, 200)
+
+	req, err := http.NewRequest("GET", "http://localhost:8181/dirs/d1/files/f2",
+		nil)
+	xNoErr(t, err)
+	req.Header.Add("Accept", "application/yaml")
+	yamlRes, err := http.DefaultClient.Do(req)
+	xNoErr(t, err)
+	yamlBody, _ := io.ReadAll(yamlRes.Body)
+	yamlRes.Body.Close()
+	xCheckEqual(t, "", string(yamlBody), res.body)
+	xCheckEqual(t, "", yamlRes.Header.Get("Content-Type"), "application/json")
+	xCheckEqual(t, "", yamlRes.Header.Get("ETag"), res.Header.Get("ETag"))
+	xCheckEqual(t, "", yamlRes.Header.Get("Vary"), "")
+
+	// Metadata depends on Accept
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1/files/f2$details",
+		Method:     "GET",
+		Code:       200,
+		ResHeaders: []string{"*", "Content-Type: application/json", "Vary: Accept"},
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/dirs/d1/files/f1$details",
+		Method: "PATCH",
+		ReqHeaders: []string{
+			"Content-Type: application/yaml",
+			"Accept: application/yaml",
+		},
+		ReqBody:    "description: my file\n",
+		Code:       200,
+		ResHeaders: []string{"Content-Type: application/yaml"},
+		BodyMasks:  []string{`(?s)^.*(description: my file).*$||$1`},
+		ResBody:    "description: my file",
+	})
+
+	// Errors are just text
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1",
+		Method:     "PATCH",
+		ReqHeaders: []string{"Content-Type: application/yaml"},
+		ReqBody:    "labels: [\n",
+		Code:       400,
+		ResHeaders: []string{"*"},
+		ResBody:    "*",
+	})
+
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d2",
+		Method:     "GET",
+		ReqHeaders: []string{"Accept: application/yaml"},
+		Code:       404,
+		ResHeaders: []string{"*"},
+		ResBody:    "Not found\n",
+	})
+
+	// Capabilities
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:    "/capabilities",
+		Method: "PATCH",
+		ReqHeaders: []string{
+			"Content-Type: application/yaml",
+			"Accept: application/yaml",
+		},
+		ReqBody:    "shortself: true\n",
+		Code:       200,
+		ResHeaders: []string{"Content-Type: application/yaml"},
+		BodyMasks:  []string{`(?s)^.*(\nshortself: true\n).*$||$1`},
+		ResBody:    "\nshortself: true\n",
+	})
+
+	// Model and export
+	for _, url := range []string{"/model", "/export", "/capabilities"} {
+		xCheckHTTP(t, reg, &HTTPTest{
+			URL:        url,
+			Method:     "GET",
+			ReqHeaders: []string{"Accept: application/yaml"},
+			Code:       200,
+			ResHeaders: []string{"*", "Content-Type: application/yaml"},
+			ResBody:    "*",
+		})
+	}
+
+	// An HTML view is never YAML
+	xCheckHTTP(t, reg, &HTTPTest{
+		URL:        "/dirs/d1?html",
+		Method:     "GET",
+		ReqHeaders: []string{"Accept: application/yaml"},
+		Code:       200,
+		ResHeaders: []string{"*", "Content-Type: text/html"},
+		ResBody:    "*",
+	})
+}
+
+func TestYAMLXR(t *testing.T) {
+	reg := NewRegistry("TestYAMLXR")
+	defer PassDeleteReg(t, reg)
+
+	xCLIServer("localhost:8181")
+
+	xCLI(t, "model update -d @-", `groups:
+  dirs:
+    singular: dir
+    resources:
+      files:
+        singular: file
+`, "", "", true)
+
+	xCLI(t, "create /dirs/d1 -o yaml -d @-", `name: my dir
+labels:
+  b: "2"
+  a: "1"
+`, `dirid: d1
+self: http://localhost:8181/dirs/d1
+xid: /dirs/d1
+epoch: 1
+name: my dir
+labels:
+  a: "1"
+  b: "2"
+createdat: "YYYY-MM-DDTHH:MM:01Z"
+modifiedat: "YYYY-MM-DDTHH:MM:01Z"
+filesurl: http://localhost:8181/dirs/d1/files
+filescount: 0
+`, "", true)
+
+	xCLI(t, "get /dirs/d1 -o yaml", "", `dirid: d1
+self: http://localhost:8181/dirs/d1
+xid: /dirs/d1
+epoch: 1
+name: my dir
+labels:
+  a: "1"
+  b: "2"
+createdat: "YYYY-MM-DDTHH:MM:01Z"
+modifiedat: "YYYY-MM-DDTHH:MM:01Z"
+filesurl: http://localhost:8181/dirs/d1/files
+filescount: 0
+`, "", true)
+
+	xCLI(t, "get /dirs/d1 -o foo", "", "",
+		"--output must be one of: json, yaml, table\n", false)
+
+	xCLI(t, "create /dirs/d1 -f -d @-", "name: [\n", "", "*", false)
+
+	out, err := exec.Command("../xr", "model", "get", "-o", "yaml").Output()
+	xNoErr(t, err)
+	xCheck(t, strings.Contains(string(out), "\ngroups:\n  dirs:\n    plural: dirs\n"),
+		"Bad model:\n%s", string(out))
+}